@@ -21,4 +21,28 @@ env "gorm" {
       diff = "{{ sql . \"  \" }}"
     }
   }
-}
\ No newline at end of file
+}
+data "external_schema" "gorm_sqlite" {
+  program = [
+    "go",
+    "run",
+    "-mod=mod",
+    "ariga.io/atlas-provider-gorm",
+    "load",
+    "--path", "./models",
+    "--dialect", "sqlite",
+  ]
+}
+
+env "gorm_sqlite" {
+  src = data.external_schema.gorm_sqlite.url
+  dev = "sqlite://dev?mode=memory"
+  migration {
+    dir = "file://migrations_sqlite"
+  }
+  format {
+    migrate {
+      diff = "{{ sql . \"  \" }}"
+    }
+  }
+}
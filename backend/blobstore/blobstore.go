@@ -0,0 +1,54 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps blobs under slash separated keys
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns ErrNotFound when there is no blob for the key
+	Get(ctx context.Context, key string) ([]byte, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete succeeds when there is no blob for the key
+	Delete(ctx context.Context, key string) error
+}
+
+// NewStoreFromEnv selects the store with PLAN_STORAGE_BACKEND, local disk by default
+func NewStoreFromEnv() (Store, error) {
+	switch strings.ToLower(os.Getenv("PLAN_STORAGE_BACKEND")) {
+	case "", "local":
+		dir := os.Getenv("PLAN_STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "plans"
+		}
+		return &LocalStore{Dir: dir}, nil
+	case "s3":
+		bucket := os.Getenv("PLAN_STORAGE_S3_BUCKET")
+		if bucket == "" {
+			return nil, fmt.Errorf("missing environment variable: required PLAN_STORAGE_S3_BUCKET")
+		}
+		return NewS3Store(context.Background(), bucket, os.Getenv("PLAN_STORAGE_S3_PREFIX"))
+	default:
+		return nil, fmt.Errorf("unknown plan storage backend: %v", os.Getenv("PLAN_STORAGE_BACKEND"))
+	}
+}
+
+// ValidateKey rejects keys that could escape the directory or prefix of the store
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return fmt.Errorf("invalid key: %v", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid key: %v", key)
+		}
+	}
+	return nil
+}
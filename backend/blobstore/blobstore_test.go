@@ -0,0 +1,96 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeS3Client struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3Client) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.objects[*params.Bucket+"/"+*params.Key]; !ok {
+		return nil, &types.NotFound{}
+	}
+	return &s3.HeadObjectOutput{}, nil
+}
+
+func (f *fakeS3Client) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[*params.Bucket+"/"+*params.Key] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3Client) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[*params.Bucket+"/"+*params.Key]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (f *fakeS3Client) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.objects, *params.Bucket+"/"+*params.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	_, err := store.Get(ctx, "orgs/1/plan.tfplan")
+	assert.ErrorIs(t, err, ErrNotFound)
+	exists, err := store.Exists(ctx, "orgs/1/plan.tfplan")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	assert.NoError(t, store.Put(ctx, "orgs/1/plan.tfplan", []byte("first")))
+	assert.NoError(t, store.Put(ctx, "orgs/1/plan.tfplan", []byte("second")))
+	data, err := store.Get(ctx, "orgs/1/plan.tfplan")
+	assert.NoError(t, err)
+	assert.Equal(t, "second", string(data))
+	exists, err = store.Exists(ctx, "orgs/1/plan.tfplan")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	assert.NoError(t, store.Delete(ctx, "orgs/1/plan.tfplan"))
+	assert.NoError(t, store.Delete(ctx, "orgs/1/plan.tfplan"))
+	_, err = store.Get(ctx, "orgs/1/plan.tfplan")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	for _, key := range []string{"", "/etc/passwd", "orgs/1/../2/plan.tfplan", "orgs//plan.tfplan"} {
+		assert.Error(t, store.Put(ctx, key, []byte("x")), key)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	testStore(t, &LocalStore{Dir: t.TempDir()})
+}
+
+func TestS3Store(t *testing.T) {
+	client := &fakeS3Client{objects: map[string][]byte{}}
+	store := &S3Store{Client: client, Bucket: "plans", Prefix: "digger/"}
+	testStore(t, store)
+
+	assert.NoError(t, store.Put(context.Background(), "orgs/1/plan.tfplan", []byte("plan")))
+	assert.Contains(t, client.objects, "plans/digger/orgs/1/plan.tfplan")
+}
@@ -0,0 +1,127 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"io"
+	"os"
+	"strings"
+)
+
+type S3Client interface {
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// S3Store keeps blobs in a bucket of S3 or of a service compatible with it, below Prefix
+type S3Store struct {
+	Client S3Client
+	Bucket string
+	Prefix string
+}
+
+// NewS3Store uses the default aws credentials, AWS_S3_ENDPOINT points it at another S3 compatible service and
+// AWS_S3_FORCE_PATH_STYLE=true puts the bucket in the path, like the plan storage of the runners
+func NewS3Store(ctx context.Context, bucket string, prefix string) (*S3Store, error) {
+	sdkConfig, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not load aws config: %v", err)
+	}
+	client := s3.NewFromConfig(sdkConfig, func(o *s3.Options) {
+		if endpoint := os.Getenv("AWS_S3_ENDPOINT"); endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+		if os.Getenv("AWS_S3_FORCE_PATH_STYLE") == "true" {
+			o.UsePathStyle = true
+		}
+	})
+	return &S3Store{Client: client, Bucket: bucket, Prefix: prefix}, nil
+}
+
+func (s *S3Store) key(key string) (*string, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	if s.Prefix == "" {
+		return aws.String(key), nil
+	}
+	return aws.String(strings.TrimSuffix(s.Prefix, "/") + "/" + key), nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, data []byte) error {
+	objectKey, err := s.key(key)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    objectKey,
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return fmt.Errorf("could not write %v to bucket: %v", *objectKey, err)
+	}
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	objectKey, err := s.key(key)
+	if err != nil {
+		return nil, err
+	}
+	output, err := s.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    objectKey,
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("could not read %v from bucket: %v", *objectKey, err)
+	}
+	defer output.Body.Close()
+	return io.ReadAll(output.Body)
+}
+
+func (s *S3Store) Exists(ctx context.Context, key string) (bool, error) {
+	objectKey, err := s.key(key)
+	if err != nil {
+		return false, err
+	}
+	_, err = s.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    objectKey,
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("could not get attributes of %v: %v", *objectKey, err)
+	}
+	return true, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	objectKey, err := s.key(key)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    objectKey,
+	})
+	if err != nil {
+		return fmt.Errorf("could not delete %v from bucket: %v", *objectKey, err)
+	}
+	return nil
+}
@@ -12,8 +12,10 @@ import (
 	"time"
 
 	"github.com/diggerhq/digger/backend/controllers"
+	"github.com/diggerhq/digger/backend/metrics"
 	"github.com/diggerhq/digger/backend/middleware"
 	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/libs/tracing"
 	"github.com/getsentry/sentry-go"
 	sentrygin "github.com/getsentry/sentry-go/gin"
 	"github.com/gin-contrib/sessions"
@@ -42,6 +44,8 @@ func Bootstrap(templates embed.FS, githubController controllers.GithubController
 		log.Printf("Sentry initialization failed: %v\n", err)
 	}
 
+	tracing.Init("digger-backend")
+
 	//database migrations
 	models.ConnectDatabase()
 
@@ -79,8 +83,21 @@ func Bootstrap(templates embed.FS, githubController controllers.GithubController
 		r.LoadHTMLGlob("templates/*.tmpl")
 	}
 
-	r.POST("/github-app-webhook", githubController.GithubAppWebHook)
-	r.POST("/github-app-webhook/aam", controllers.GithubAppWebHookAfterMerge)
+	r.GET("/metrics", metrics.Handler())
+
+	githubWebhookMetrics := metrics.WebhookMiddleware("github", "X-GitHub-Event")
+	r.POST("/github-app-webhook", githubWebhookMetrics, githubController.GithubAppWebHook)
+	r.POST("/github-app-webhook/aam", githubWebhookMetrics, controllers.GithubAppWebHookAfterMerge)
+
+	gitlabController := controllers.GitlabController{CiBackendProvider: githubController.CiBackendProvider}
+	r.POST("/gitlab-webhook", metrics.WebhookMiddleware("gitlab", "X-Gitlab-Event"), gitlabController.GitlabWebhookHandler)
+
+	agentController := controllers.AgentController{GithubToken: controllers.GithubInstallationToken}
+	agentsGroup := r.Group("/agents")
+	agentsGroup.Use(middleware.AgentTokenAuth())
+	agentsGroup.POST("/jobs/claim", agentController.ClaimJob)
+	agentsGroup.POST("/jobs/:id/heartbeat", agentController.Heartbeat)
+	agentsGroup.POST("/jobs/:id/finish", agentController.FinishJob)
 
 	tenantActionsGroup := r.Group("/api/tenants")
 	tenantActionsGroup.Use(middleware.CORSMiddleware())
@@ -93,60 +110,111 @@ func Bootstrap(templates embed.FS, githubController controllers.GithubController
 	githubGroup.GET("/setup", controllers.GithubAppSetup)
 	githubGroup.GET("/exchange-code", controllers.GithubSetupExchangeCode)
 
+	fronteggWebhookProcessor := r.Group("/")
+	fronteggWebhookProcessor.Use(middleware.SecretCodeAuth())
+
+	registerApiRoutes(r, middleware.GetApiMiddleware(), controllers.JobStatusController{CiBackendProvider: githubController.CiBackendProvider})
+
+	fronteggWebhookProcessor.POST("/create-org-from-frontegg", controllers.CreateFronteggOrgFromWebhook)
+
+	return r
+}
+
+// registerApiRoutes sets up the token authenticated routes, each guarded by the permission it requires
+func registerApiRoutes(r *gin.Engine, apiMiddleware gin.HandlerFunc, jobStatusController controllers.JobStatusController) {
+	read := middleware.RequirePermission(models.PermissionRead)
+	operate := middleware.RequirePermission(models.PermissionOperate)
+	policyWrite := middleware.RequirePermission(models.PermissionPolicyWrite)
+	admin := middleware.RequirePermission(models.PermissionAdmin)
+	// org policies apply to every repo, so jobs with repo restricted tokens need to read them. Handlers of other org
+	// routes with it filter by the restricted repo themselves
+	shared := middleware.AllowRepoRestricted()
+
 	authorized := r.Group("/")
-	authorized.Use(middleware.GetApiMiddleware(), middleware.AccessLevel(models.CliJobAccessType, models.AccessPolicyType, models.AdminPolicyType))
+	authorized.Use(apiMiddleware)
 
-	admin := r.Group("/")
-	admin.Use(middleware.GetApiMiddleware(), middleware.AccessLevel(models.AdminPolicyType))
+	authorized.GET("/repos/:repo/projects/:projectName/access-policy", read, controllers.FindAccessPolicy)
+	authorized.GET("/orgs/:organisation/access-policy", shared, read, controllers.FindAccessPolicyForOrg)
 
-	fronteggWebhookProcessor := r.Group("/")
-	fronteggWebhookProcessor.Use(middleware.SecretCodeAuth())
+	authorized.GET("/repos/:repo/projects/:projectName/plan-policy", read, controllers.FindPlanPolicy)
+	authorized.GET("/orgs/:organisation/plan-policy", shared, read, controllers.FindPlanPolicyForOrg)
+
+	authorized.GET("/repos/:repo/projects/:projectName/drift-policy", read, controllers.FindDriftPolicy)
+	authorized.GET("/orgs/:organisation/drift-policy", shared, read, controllers.FindDriftPolicyForOrg)
+
+	authorized.GET("/repos/:repo/projects/:projectName/policies/:policyType/versions", read, controllers.ListPolicyVersions)
+	authorized.GET("/repos/:repo/projects/:projectName/policies/:policyType/versions/:version", read, controllers.GetPolicyVersion)
+	authorized.GET("/repos/:repo/projects/:projectName/policies/:policyType/diff", read, controllers.DiffPolicyVersions)
+	authorized.GET("/orgs/:organisation/policies/:policyType/versions", read, controllers.ListPolicyVersions)
+	authorized.GET("/orgs/:organisation/policies/:policyType/versions/:version", read, controllers.GetPolicyVersion)
+	authorized.GET("/orgs/:organisation/policies/:policyType/diff", read, controllers.DiffPolicyVersions)
 
-	authorized.GET("/repos/:repo/projects/:projectName/access-policy", controllers.FindAccessPolicy)
-	authorized.GET("/orgs/:organisation/access-policy", controllers.FindAccessPolicyForOrg)
+	authorized.GET("/policy-decisions", shared, read, controllers.GetPolicyDecisions)
 
-	authorized.GET("/repos/:repo/projects/:projectName/plan-policy", controllers.FindPlanPolicy)
-	authorized.GET("/orgs/:organisation/plan-policy", controllers.FindPlanPolicyForOrg)
+	authorized.GET("/repos/:repo/projects/:projectName/runs", read, controllers.RunHistoryForProject)
+	authorized.POST("/repos/:repo/projects/:projectName/runs", operate, controllers.CreateRunForProject)
 
-	authorized.GET("/repos/:repo/projects/:projectName/drift-policy", controllers.FindDriftPolicy)
-	authorized.GET("/orgs/:organisation/drift-policy", controllers.FindDriftPolicyForOrg)
+	authorized.POST("/repos/:repo/projects/:projectName/jobs/:jobId/set-status", operate, jobStatusController.SetJobStatusForProject)
 
-	authorized.GET("/repos/:repo/projects/:projectName/runs", controllers.RunHistoryForProject)
-	authorized.POST("/repos/:repo/projects/:projectName/runs", controllers.CreateRunForProject)
+	authorized.GET("/repos/:repo/projects", read, controllers.FindProjectsForRepo)
+	authorized.POST("/repos/:repo/report-projects", operate, controllers.ReportProjectsForRepo)
 
-	authorized.POST("/repos/:repo/projects/:projectName/jobs/:jobId/set-status", controllers.SetJobStatusForProject)
+	authorized.GET("/orgs/:organisation/projects", read, controllers.FindProjectsForOrg)
 
-	authorized.GET("/repos/:repo/projects", controllers.FindProjectsForRepo)
-	authorized.POST("/repos/:repo/report-projects", controllers.ReportProjectsForRepo)
+	authorized.PUT("/repos/:repo/projects/:projectName/access-policy", policyWrite, controllers.UpsertAccessPolicyForRepoAndProject)
+	authorized.PUT("/orgs/:organisation/access-policy", policyWrite, controllers.UpsertAccessPolicyForOrg)
 
-	authorized.GET("/orgs/:organisation/projects", controllers.FindProjectsForOrg)
+	authorized.PUT("/repos/:repo/projects/:projectName/plan-policy", policyWrite, controllers.UpsertPlanPolicyForRepoAndProject)
+	authorized.PUT("/orgs/:organisation/plan-policy", policyWrite, controllers.UpsertPlanPolicyForOrg)
 
-	admin.PUT("/repos/:repo/projects/:projectName/access-policy", controllers.UpsertAccessPolicyForRepoAndProject)
-	admin.PUT("/orgs/:organisation/access-policy", controllers.UpsertAccessPolicyForOrg)
+	authorized.PUT("/repos/:repo/projects/:projectName/drift-policy", policyWrite, controllers.UpsertDriftPolicyForRepoAndProject)
+	authorized.PUT("/orgs/:organisation/drift-policy", policyWrite, controllers.UpsertDriftPolicyForOrg)
 
-	admin.PUT("/repos/:repo/projects/:projectName/plan-policy", controllers.UpsertPlanPolicyForRepoAndProject)
-	admin.PUT("/orgs/:organisation/plan-policy", controllers.UpsertPlanPolicyForOrg)
+	authorized.POST("/repos/:repo/projects/:projectName/policies/:policyType/versions/:version/rollback", policyWrite, controllers.RollbackPolicy)
+	authorized.POST("/orgs/:organisation/policies/:policyType/versions/:version/rollback", policyWrite, controllers.RollbackPolicy)
 
-	admin.PUT("/repos/:repo/projects/:projectName/drift-policy", controllers.UpsertDriftPolicyForRepoAndProject)
-	admin.PUT("/orgs/:organisation/drift-policy", controllers.UpsertDriftPolicyForOrg)
+	authorized.POST("/tokens/issue-access-token", admin, controllers.IssueAccessTokenForOrg)
+	authorized.GET("/tokens", admin, controllers.ListTokens)
+	authorized.POST("/tokens", admin, controllers.CreateToken)
+	authorized.POST("/tokens/:tokenId/rotate", admin, controllers.RotateToken)
+	authorized.DELETE("/tokens/:tokenId", admin, controllers.RevokeToken)
 
-	admin.POST("/tokens/issue-access-token", controllers.IssueAccessTokenForOrg)
+	authorized.GET("/role-bindings", admin, controllers.ListRoleBindings)
+	authorized.POST("/role-bindings", admin, controllers.CreateRoleBinding)
+	authorized.DELETE("/role-bindings/:bindingId", admin, controllers.DeleteRoleBinding)
+
+	authorized.GET("/webhooks", admin, controllers.ListWebhooks)
+	authorized.POST("/webhooks", admin, controllers.CreateWebhook)
+	authorized.DELETE("/webhooks/:webhookId", admin, controllers.DeleteWebhook)
+	authorized.GET("/webhooks/:webhookId/deliveries", admin, controllers.ListWebhookDeliveries)
+	authorized.POST("/webhooks/:webhookId/deliveries/:deliveryId/redeliver", admin, controllers.RedeliverWebhookDelivery)
+
+	authorized.GET("/agent-tokens", admin, controllers.ListAgentTokens)
+	authorized.POST("/agent-tokens", admin, controllers.CreateAgentToken)
+	authorized.DELETE("/agent-tokens/:tokenId", admin, controllers.DeleteAgentToken)
+
+	authorized.PUT("/repos/:repo/plans/*planPath", operate, controllers.UploadPlan)
+	authorized.GET("/repos/:repo/plans/*planPath", read, controllers.DownloadPlan)
+	authorized.HEAD("/repos/:repo/plans/*planPath", read, controllers.PlanExists)
+	authorized.DELETE("/repos/:repo/plans/*planPath", operate, controllers.DeletePlan)
+	authorized.GET("/plan-storage/settings", read, controllers.GetPlanStorageSettings)
+	authorized.PUT("/plan-storage/settings", admin, controllers.UpdatePlanStorageSettings)
 
 	r.Use(middleware.CORSMiddleware())
 	projectsApiGroup := r.Group("/api/projects")
-	projectsApiGroup.Use(middleware.GetApiMiddleware())
-	projectsApiGroup.GET("/", controllers.FindProjectsForOrg)
-	projectsApiGroup.GET("/:project_id", controllers.ProjectDetails)
-	projectsApiGroup.GET("/:project_id/runs", controllers.RunsForProject)
+	projectsApiGroup.Use(apiMiddleware)
+	projectsApiGroup.GET("/", read, controllers.FindProjectsForOrg)
+	projectsApiGroup.GET("/:project_id", read, controllers.ProjectDetails)
+	projectsApiGroup.GET("/:project_id/runs", read, controllers.RunsForProject)
 
 	runsApiGroup := r.Group("/api/runs")
-	runsApiGroup.Use(middleware.CORSMiddleware(), middleware.GetApiMiddleware())
-	runsApiGroup.GET("/:run_id", controllers.RunDetails)
-	runsApiGroup.POST("/:run_id/approve", controllers.ApproveRun)
+	runsApiGroup.Use(middleware.CORSMiddleware(), apiMiddleware)
+	runsApiGroup.GET("/:run_id", read, controllers.RunDetails)
+	runsApiGroup.POST("/:run_id/approve", operate, controllers.ApproveRun)
 
-	fronteggWebhookProcessor.POST("/create-org-from-frontegg", controllers.CreateFronteggOrgFromWebhook)
-
-	return r
+	auditApiGroup := r.Group("/api/audit")
+	auditApiGroup.Use(middleware.CORSMiddleware(), apiMiddleware)
+	auditApiGroup.GET("/", admin, controllers.GetAuditLog)
 }
 
 func initLogging() {
@@ -0,0 +1,375 @@
+package bootstrap
+
+import (
+	"fmt"
+	"github.com/diggerhq/digger/backend/blobstore"
+	"github.com/diggerhq/digger/backend/controllers"
+	"github.com/diggerhq/digger/backend/middleware"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/backend/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+var routePermissions = []struct {
+	method     string
+	path       string
+	permission string
+}{
+	{"GET", "/repos/test-repo/projects/prod/access-policy", models.PermissionRead},
+	{"GET", "/orgs/testOrg/access-policy", models.PermissionRead},
+	{"GET", "/repos/test-repo/projects/prod/plan-policy", models.PermissionRead},
+	{"GET", "/orgs/testOrg/plan-policy", models.PermissionRead},
+	{"GET", "/repos/test-repo/projects/prod/drift-policy", models.PermissionRead},
+	{"GET", "/orgs/testOrg/drift-policy", models.PermissionRead},
+	{"GET", "/repos/test-repo/projects/prod/policies/access/versions", models.PermissionRead},
+	{"GET", "/repos/test-repo/projects/prod/policies/access/versions/1", models.PermissionRead},
+	{"GET", "/repos/test-repo/projects/prod/policies/access/diff", models.PermissionRead},
+	{"GET", "/orgs/testOrg/policies/plan/versions", models.PermissionRead},
+	{"GET", "/orgs/testOrg/policies/plan/versions/1", models.PermissionRead},
+	{"GET", "/orgs/testOrg/policies/plan/diff", models.PermissionRead},
+	{"GET", "/policy-decisions", models.PermissionRead},
+	{"GET", "/repos/test-repo/projects/prod/runs", models.PermissionRead},
+	{"POST", "/repos/test-repo/projects/prod/runs", models.PermissionOperate},
+	{"POST", "/repos/test-repo/projects/prod/jobs/1/set-status", models.PermissionOperate},
+	{"GET", "/repos/test-repo/projects", models.PermissionRead},
+	{"POST", "/repos/test-repo/report-projects", models.PermissionOperate},
+	{"GET", "/orgs/testOrg/projects", models.PermissionRead},
+	{"PUT", "/repos/test-repo/projects/prod/access-policy", models.PermissionPolicyWrite},
+	{"PUT", "/orgs/testOrg/access-policy", models.PermissionPolicyWrite},
+	{"PUT", "/repos/test-repo/projects/prod/plan-policy", models.PermissionPolicyWrite},
+	{"PUT", "/orgs/testOrg/plan-policy", models.PermissionPolicyWrite},
+	{"PUT", "/repos/test-repo/projects/prod/drift-policy", models.PermissionPolicyWrite},
+	{"PUT", "/orgs/testOrg/drift-policy", models.PermissionPolicyWrite},
+	{"POST", "/repos/test-repo/projects/prod/policies/access/versions/1/rollback", models.PermissionPolicyWrite},
+	{"POST", "/orgs/testOrg/policies/plan/versions/1/rollback", models.PermissionPolicyWrite},
+	{"POST", "/tokens/issue-access-token", models.PermissionAdmin},
+	{"GET", "/tokens", models.PermissionAdmin},
+	{"POST", "/tokens", models.PermissionAdmin},
+	{"POST", "/tokens/1000/rotate", models.PermissionAdmin},
+	{"DELETE", "/tokens/1000", models.PermissionAdmin},
+	{"GET", "/role-bindings", models.PermissionAdmin},
+	{"POST", "/role-bindings", models.PermissionAdmin},
+	{"DELETE", "/role-bindings/1000", models.PermissionAdmin},
+	{"GET", "/webhooks", models.PermissionAdmin},
+	{"POST", "/webhooks", models.PermissionAdmin},
+	{"DELETE", "/webhooks/1000", models.PermissionAdmin},
+	{"GET", "/webhooks/1000/deliveries", models.PermissionAdmin},
+	{"POST", "/webhooks/1000/deliveries/1/redeliver", models.PermissionAdmin},
+	{"GET", "/agent-tokens", models.PermissionAdmin},
+	{"POST", "/agent-tokens", models.PermissionAdmin},
+	{"DELETE", "/agent-tokens/1000", models.PermissionAdmin},
+	{"PUT", "/repos/test-repo/plans/digger-1-prod.tfplan", models.PermissionOperate},
+	{"GET", "/repos/test-repo/plans/digger-1-prod.tfplan", models.PermissionRead},
+	{"HEAD", "/repos/test-repo/plans/digger-1-prod.tfplan", models.PermissionRead},
+	{"DELETE", "/repos/test-repo/plans/digger-1-prod.tfplan", models.PermissionOperate},
+	{"GET", "/plan-storage/settings", models.PermissionRead},
+	{"PUT", "/plan-storage/settings", models.PermissionAdmin},
+	{"GET", "/api/projects/", models.PermissionRead},
+	{"GET", "/api/projects/1", models.PermissionRead},
+	{"GET", "/api/projects/1/runs", models.PermissionRead},
+	{"GET", "/api/runs/1000", models.PermissionRead},
+	{"POST", "/api/runs/1000/approve", models.PermissionOperate},
+	{"GET", "/api/audit/", models.PermissionAdmin},
+}
+
+func setupSuite(tb testing.TB) (func(tb testing.TB), *models.Organisation) {
+	dbName := "database_bootstrap_test.db"
+
+	e := os.Remove(dbName)
+	if e != nil {
+		if !strings.Contains(e.Error(), "no such file or directory") {
+			log.Fatal(e)
+		}
+	}
+
+	gdb, err := gorm.Open(sqlite.Open(dbName), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = gdb.AutoMigrate(&models.Policy{}, &models.Organisation{}, &models.Repo{}, &models.Project{}, &models.Token{},
+		&models.User{}, &models.ProjectRun{}, &models.DiggerRun{}, &models.DiggerRunStage{}, &models.DiggerBatch{},
+		&models.DiggerJob{}, &models.DiggerJobSummary{}, &models.JobToken{}, &models.RoleBinding{}, &models.AuditLogEntry{},
+		&models.OrgWebhook{}, &models.WebhookDelivery{}, &models.AgentToken{}, &models.AgentJob{}, &models.PlanArtifact{}, &models.PolicyVersion{}, &models.PolicyDecision{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	database := &models.Database{GormDB: gdb}
+	models.DB = database
+	services.PlanStore = &blobstore.LocalStore{Dir: tb.TempDir()}
+
+	org, err := database.CreateOrganisation("testOrg", "test", "11111111-1111-1111-1111-111111111111")
+	if err != nil {
+		log.Fatal(err)
+	}
+	repo, err := database.CreateRepo("test-repo", "", "", "", "", org, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = database.CreateProject("prod", org, repo)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = database.CreateProject("dev", org, repo)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return func(tb testing.TB) {
+		e := os.Remove(dbName)
+		if e != nil {
+			log.Fatal(e)
+		}
+	}, org
+}
+
+// testAuth takes the subject and access level from request headers
+func testAuth(orgId uint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(middleware.ORGANISATION_ID_KEY, orgId)
+		if subject := c.GetHeader("X-Subject"); subject != "" {
+			c.Set(middleware.SUBJECT_KEY, subject)
+		}
+		if scopes := c.GetHeader("X-Scopes"); scopes != "" {
+			c.Set(middleware.TOKEN_SCOPES_KEY, strings.Split(scopes, ","))
+		}
+		if repo := c.GetHeader("X-Repo-Restriction"); repo != "" {
+			c.Set(middleware.REPO_RESTRICTION_KEY, repo)
+		}
+		c.Set(middleware.ACCESS_LEVEL_KEY, c.GetHeader("X-Access-Level"))
+		c.Next()
+	}
+}
+
+func setupRouter(orgId uint) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.Recovery())
+	registerApiRoutes(r, testAuth(orgId), controllers.JobStatusController{})
+	return r
+}
+
+func isDenied(r *gin.Engine, method string, path string, subject string, accessLevel string) bool {
+	req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Subject", subject)
+	req.Header.Set("X-Access-Level", accessLevel)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code == http.StatusForbidden && strings.Contains(w.Body.String(), "is required to access this resource")
+}
+
+func TestEveryRouteRequiresItsPermission(t *testing.T) {
+	teardown, org := setupSuite(t)
+	defer teardown(t)
+	r := setupRouter(org.ID)
+
+	roles := []string{models.RoleViewer, models.RoleOperator, models.RolePolicyAdmin, models.RoleOrgAdmin}
+	for _, role := range roles {
+		_, err := models.DB.CreateRoleBinding(org.ID, "user:"+role, role, models.RoleScopeOrg, "", "")
+		assert.NoError(t, err)
+	}
+
+	for _, route := range routePermissions {
+		for _, role := range roles {
+			expectDenied := !models.RoleHasPermission(role, route.permission)
+			denied := isDenied(r, route.method, route.path, "user:"+role, "")
+			assert.Equal(t, expectDenied, denied, fmt.Sprintf("%v %v with role %v", route.method, route.path, role))
+		}
+	}
+}
+
+func TestAllApiRoutesAreCovered(t *testing.T) {
+	teardown, org := setupSuite(t)
+	defer teardown(t)
+	r := setupRouter(org.ID)
+	assert.Equal(t, len(routePermissions), len(r.Routes()))
+}
+
+func TestScopedBindings(t *testing.T) {
+	teardown, org := setupSuite(t)
+	defer teardown(t)
+	r := setupRouter(org.ID)
+
+	_, err := models.DB.CreateRoleBinding(org.ID, "token:1", models.RolePolicyAdmin, models.RoleScopeProject, "test-repo", "prod")
+	assert.NoError(t, err)
+	_, err = models.DB.CreateRoleBinding(org.ID, "token:2", models.RoleViewer, models.RoleScopeRepo, "test-repo", "")
+	assert.NoError(t, err)
+
+	// an admin access level does not widen explicit bindings
+	assert.False(t, isDenied(r, "PUT", "/repos/test-repo/projects/prod/access-policy", "token:1", models.AdminPolicyType))
+	assert.True(t, isDenied(r, "PUT", "/repos/test-repo/projects/dev/access-policy", "token:1", models.AdminPolicyType))
+	assert.True(t, isDenied(r, "PUT", "/orgs/testOrg/access-policy", "token:1", models.AdminPolicyType))
+	assert.True(t, isDenied(r, "POST", "/tokens/issue-access-token", "token:1", models.AdminPolicyType))
+
+	// project ids are resolved to their repo and project
+	assert.False(t, isDenied(r, "GET", "/api/projects/1", "token:1", ""))
+	assert.True(t, isDenied(r, "GET", "/api/projects/2", "token:1", ""))
+
+	assert.False(t, isDenied(r, "GET", "/repos/test-repo/projects", "token:2", ""))
+	assert.False(t, isDenied(r, "GET", "/api/projects/2", "token:2", ""))
+	assert.True(t, isDenied(r, "GET", "/orgs/testOrg/projects", "token:2", ""))
+	assert.True(t, isDenied(r, "POST", "/repos/test-repo/projects/prod/runs", "token:2", ""))
+}
+
+func TestAccessLevelFallback(t *testing.T) {
+	teardown, org := setupSuite(t)
+	defer teardown(t)
+	r := setupRouter(org.ID)
+
+	assert.False(t, isDenied(r, "POST", "/repos/test-repo/projects/prod/jobs/1/set-status", "", models.CliJobAccessType))
+	assert.True(t, isDenied(r, "PUT", "/orgs/testOrg/plan-policy", "", models.CliJobAccessType))
+	assert.False(t, isDenied(r, "POST", "/api/runs/1000/approve", "token:3", models.AccessPolicyType))
+	assert.True(t, isDenied(r, "POST", "/tokens/issue-access-token", "token:3", models.AccessPolicyType))
+	assert.False(t, isDenied(r, "POST", "/tokens/issue-access-token", "", models.AdminPolicyType))
+	assert.True(t, isDenied(r, "GET", "/orgs/testOrg/projects", "", ""))
+}
+
+func TestRepoRestrictedTokens(t *testing.T) {
+	teardown, org := setupSuite(t)
+	defer teardown(t)
+	r := setupRouter(org.ID)
+
+	request := func(method string, path string) bool {
+		req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Access-Level", models.CliJobAccessType)
+		req.Header.Set("X-Repo-Restriction", "test-repo")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code == http.StatusForbidden && strings.Contains(w.Body.String(), "is required to access this resource")
+	}
+
+	assert.False(t, request("GET", "/repos/test-repo/projects"))
+	assert.True(t, request("GET", "/repos/other-repo/projects"))
+	assert.False(t, request("GET", "/api/projects/1"))
+	// org routes are denied unless they are marked as safe for restricted tokens
+	assert.True(t, request("GET", "/orgs/testOrg/projects"))
+	assert.True(t, request("GET", "/api/projects/"))
+	assert.False(t, request("GET", "/orgs/testOrg/access-policy"))
+	assert.False(t, request("GET", "/orgs/testOrg/plan-policy"))
+	// the handler filters policy decisions by the restricted repo
+	assert.False(t, request("GET", "/policy-decisions"))
+}
+
+func TestRepoRestrictedTokensCannotAccessPlansOfOtherRepos(t *testing.T) {
+	teardown, org := setupSuite(t)
+	defer teardown(t)
+	r := setupRouter(org.ID)
+
+	request := func(method string, path string, repo string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader("plan"))
+		req.Header.Set("X-Access-Level", models.CliJobAccessType)
+		req.Header.Set("X-Repo-Restriction", repo)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, request("PUT", "/repos/test-repo/plans/digger-1-prod.tfplan", "test-repo").Code)
+
+	for _, method := range []string{"GET", "PUT", "DELETE"} {
+		w := request(method, "/repos/test-repo/plans/digger-1-prod.tfplan", "other-repo")
+		assert.Equal(t, http.StatusForbidden, w.Code, method)
+	}
+	// the plan of the other repo is untouched and plans of the same path are kept apart per repo
+	w := request("GET", "/repos/test-repo/plans/digger-1-prod.tfplan", "test-repo")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "plan", w.Body.String())
+	assert.Equal(t, http.StatusNotFound, request("GET", "/repos/other-repo/plans/digger-1-prod.tfplan", "other-repo").Code)
+}
+
+func TestTokenScopesRestrictPermissions(t *testing.T) {
+	teardown, org := setupSuite(t)
+	defer teardown(t)
+	r := setupRouter(org.ID)
+
+	request := func(method string, path string, scopes string) bool {
+		req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Access-Level", models.AdminPolicyType)
+		req.Header.Set("X-Scopes", scopes)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code == http.StatusForbidden && strings.Contains(w.Body.String(), "is required to access this resource")
+	}
+
+	assert.False(t, request("GET", "/orgs/testOrg/projects", models.PermissionRead))
+	assert.True(t, request("PUT", "/orgs/testOrg/plan-policy", models.PermissionRead))
+	assert.False(t, request("PUT", "/orgs/testOrg/plan-policy", models.PermissionRead+","+models.PermissionPolicyWrite))
+	assert.False(t, request("GET", "/tokens", ""))
+}
+
+func TestStateChangesAreAudited(t *testing.T) {
+	teardown, org := setupSuite(t)
+	defer teardown(t)
+	r := setupRouter(org.ID)
+
+	request := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Subject", "user:alice")
+		req.Header.Set("X-Access-Level", models.AdminPolicyType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, request("PUT", "/orgs/testOrg/plan-policy", "package digger\n").Code)
+	assert.Equal(t, http.StatusOK, request("PUT", "/orgs/testOrg/plan-policy", "package digger\ndeny[msg] { false }\n").Code)
+
+	entries, err := models.DB.GetAuditLogEntries(org.ID, models.AuditLogFilter{Action: models.AuditActionPolicyUpsert})
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(entries)) {
+		assert.Equal(t, "user:alice", entries[1].Actor)
+		assert.Equal(t, "orgs/testOrg/plan-policy", entries[1].Target)
+		assert.Contains(t, entries[1].Diff, "+deny[msg] { false }")
+	}
+
+	w := request("GET", "/api/audit/?format=jsonl&actor=user:alice", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, strings.Count(w.Body.String(), "\n"))
+	assert.Contains(t, w.Body.String(), `"action":"policy.upsert"`)
+}
+
+func TestWebhookSecretIsOnlyReturnedOnCreation(t *testing.T) {
+	teardown, org := setupSuite(t)
+	defer teardown(t)
+	r := setupRouter(org.ID)
+
+	request := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Access-Level", models.AdminPolicyType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, request("POST", "/webhooks", `{"url": "ftp://example.com"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, request("POST", "/webhooks", `{"url": "https://example.com", "events": ["job.deleted"]}`).Code)
+
+	w := request("POST", "/webhooks", `{"url": "https://example.com/digger", "events": ["job.status_changed"]}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"secret":"whsec_`)
+
+	w = request("GET", "/webhooks", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"events":["job.status_changed"]`)
+	assert.NotContains(t, w.Body.String(), "whsec_")
+
+	entries, err := models.DB.GetAuditLogEntries(org.ID, models.AuditLogFilter{Action: models.AuditActionWebhookCreate})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+}
@@ -0,0 +1,88 @@
+package ci_backends
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/libs/orchestrator"
+	"github.com/diggerhq/digger/libs/spec"
+	"log"
+	"strconv"
+)
+
+// AgentCi queues jobs for `digger agent` processes, the agents of the project's pool claim them from the backend so
+// they only need outbound connectivity
+type AgentCi struct{}
+
+// AgentCiBackend is selected with CI_BACKEND=agent
+func AgentCiBackend() (CiBackend, error) {
+	return InstrumentedCiBackend{Name: "agent", Backend: AgentCi{}}, nil
+}
+
+func (a AgentCi) TriggerWorkflow(repoOwner string, repoName string, job models.DiggerJob, jobString string, commentId int64) error {
+	log.Printf("Queue agent job: repoOwner: %v, repoName: %v, commentId: %v", repoOwner, repoName, commentId)
+	var jobSpec orchestrator.JobJson
+	err := json.Unmarshal([]byte(jobString), &jobSpec)
+	if err != nil {
+		log.Printf("could not unmarshal job string: %v", err)
+		return fmt.Errorf("could not marshal json string: %v", err)
+	}
+
+	jobToken, err := models.DB.GetJobToken(jobSpec.BackendJobToken)
+	if err != nil {
+		return fmt.Errorf("could not get job token: %v", err)
+	}
+	if jobToken == nil {
+		return fmt.Errorf("job token of job %v not found", job.DiggerJobID)
+	}
+
+	vcsType := job.Batch.VCS
+	if vcsType == "" {
+		vcsType = models.DiggerVCSGithub
+	}
+	batchIdShort := job.Batch.ID.String()[:8]
+	diggerCommand := fmt.Sprintf("digger %v", job.Batch.BatchType)
+	runName := fmt.Sprintf("[%v] %v %v By: %v PR: %v", batchIdShort, diggerCommand, jobSpec.ProjectName, jobSpec.RequestedBy, *jobSpec.PullRequestNumber)
+	runSpec := spec.Spec{
+		JobId:     job.DiggerJobID,
+		CommentId: strconv.FormatInt(commentId, 10),
+		RunName:   runName,
+		Job:       jobSpec,
+		Reporter: spec.ReporterSpec{
+			ReportingStrategy: "comments_per_run",
+			ReporterType:      "lazy",
+		},
+		Lock: spec.LockSpec{
+			LockType: "noop",
+		},
+		Backend: spec.BackendSpec{
+			BackendHostname:         jobSpec.BackendHostname,
+			BackendOrganisationName: jobSpec.BackendOrganisationName,
+			BackendJobToken:         jobSpec.BackendJobToken,
+			BackendType:             "backend",
+		},
+		VCS: spec.VcsSpec{
+			VcsType:   string(vcsType),
+			Actor:     jobSpec.RequestedBy,
+			RepoOwner: repoOwner,
+			RepoName:  repoName,
+		},
+		Policy: spec.PolicySpec{
+			PolicyType: "http",
+		},
+	}
+	specBytes, err := json.Marshal(runSpec)
+	if err != nil {
+		return fmt.Errorf("could not marshal spec: %v", err)
+	}
+
+	pool := jobSpec.AgentPool
+	if pool == "" {
+		pool = models.DefaultAgentPool
+	}
+	_, err = models.DB.CreateAgentJob(jobToken.OrganisationID, pool, job.DiggerJobID, string(specBytes))
+	if err != nil {
+		return fmt.Errorf("could not queue agent job: %v", err)
+	}
+	return nil
+}
@@ -15,4 +15,6 @@ type CiBackendOptions struct {
 	RepoFullName         string
 	RepoOwner            string
 	RepoName             string
+	// VCS defaults to github
+	VCS models.DiggerVCSType
 }
@@ -0,0 +1,108 @@
+package ci_backends
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/libs/orchestrator"
+	"github.com/diggerhq/digger/libs/spec"
+	"github.com/xanzy/go-gitlab"
+	"log"
+	"os"
+	"strconv"
+)
+
+// GitlabPipelineCi runs jobs in pipelines started through the pipeline trigger api, the run spec is passed in the
+// DIGGER_SPEC variable so that the pipeline only needs to call `digger run_spec`
+type GitlabPipelineCi struct {
+	Client       *gitlab.Client
+	TriggerToken string
+}
+
+// NewGitlabPipelineCi reads GITLAB_TOKEN, GITLAB_TRIGGER_TOKEN and the optional GITLAB_BASE_URL
+func NewGitlabPipelineCi() (*GitlabPipelineCi, error) {
+	token := os.Getenv("GITLAB_TOKEN")
+	triggerToken := os.Getenv("GITLAB_TRIGGER_TOKEN")
+	if token == "" || triggerToken == "" {
+		return nil, fmt.Errorf("missing environment variable: required GITLAB_TOKEN, GITLAB_TRIGGER_TOKEN")
+	}
+	options := make([]gitlab.ClientOptionFunc, 0)
+	if baseUrl := os.Getenv("GITLAB_BASE_URL"); baseUrl != "" {
+		options = append(options, gitlab.WithBaseURL(baseUrl))
+	}
+	client, err := gitlab.NewClient(token, options...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create gitlab client: %v", err)
+	}
+	return &GitlabPipelineCi{Client: client, TriggerToken: triggerToken}, nil
+}
+
+// GitlabCiBackend is the backend used for batches of gitlab merge requests
+func GitlabCiBackend() (CiBackend, error) {
+	backend, err := NewGitlabPipelineCi()
+	if err != nil {
+		log.Printf("GetCiBackend: could not create gitlab pipeline backend: %v", err)
+		return nil, err
+	}
+	return InstrumentedCiBackend{Name: "gitlab_pipelines", Backend: backend}, nil
+}
+
+func (g GitlabPipelineCi) TriggerWorkflow(repoOwner string, repoName string, job models.DiggerJob, jobString string, commentId int64) error {
+	log.Printf("Trigger GitLab pipeline: repoOwner: %v, repoName: %v, commentId: %v", repoOwner, repoName, commentId)
+	var jobSpec orchestrator.JobJson
+	err := json.Unmarshal([]byte(jobString), &jobSpec)
+	if err != nil {
+		log.Printf("could not unmarshal job string: %v", err)
+		return fmt.Errorf("could not marshal json string: %v", err)
+	}
+
+	batchIdShort := job.Batch.ID.String()[:8]
+	diggerCommand := fmt.Sprintf("digger %v", job.Batch.BatchType)
+	runName := fmt.Sprintf("[%v] %v %v By: %v MR: %v", batchIdShort, diggerCommand, jobSpec.ProjectName, jobSpec.RequestedBy, *jobSpec.PullRequestNumber)
+	runSpec := spec.Spec{
+		JobId:     job.DiggerJobID,
+		CommentId: strconv.FormatInt(commentId, 10),
+		RunName:   runName,
+		Job:       jobSpec,
+		Reporter: spec.ReporterSpec{
+			ReportingStrategy: "comments_per_run",
+			ReporterType:      "lazy",
+		},
+		Lock: spec.LockSpec{
+			LockType: "noop",
+		},
+		Backend: spec.BackendSpec{
+			BackendHostname:         jobSpec.BackendHostname,
+			BackendOrganisationName: jobSpec.BackendOrganisationName,
+			BackendJobToken:         jobSpec.BackendJobToken,
+			BackendType:             "backend",
+		},
+		VCS: spec.VcsSpec{
+			VcsType:   string(models.DiggerVCSGitlab),
+			Actor:     jobSpec.RequestedBy,
+			RepoOwner: repoOwner,
+			RepoName:  repoName,
+		},
+		Policy: spec.PolicySpec{
+			PolicyType: "http",
+		},
+	}
+	specBytes, err := json.Marshal(runSpec)
+	if err != nil {
+		return fmt.Errorf("could not marshal spec: %v", err)
+	}
+
+	branch := job.Batch.BranchName
+	_, _, err = g.Client.PipelineTriggers.RunPipelineTrigger(repoOwner+"/"+repoName, &gitlab.RunPipelineTriggerOptions{
+		Ref:   &branch,
+		Token: &g.TriggerToken,
+		Variables: map[string]string{
+			"DIGGER_SPEC":     string(specBytes),
+			"DIGGER_RUN_NAME": runName,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not trigger gitlab pipeline: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package ci_backends
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/libs/spec"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitlabPipelineCiTriggersPipelineWithSpec(t *testing.T) {
+	var path string
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": 1}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("GITLAB_TOKEN", "token")
+	t.Setenv("GITLAB_TRIGGER_TOKEN", "trigger")
+	t.Setenv("GITLAB_BASE_URL", server.URL)
+	backend, err := NewGitlabPipelineCi()
+	assert.NoError(t, err)
+
+	job := models.DiggerJob{
+		DiggerJobID: "job-1",
+		Batch:       &models.DiggerBatch{ID: uuid.New(), BranchName: "feature", BatchType: "plan"},
+	}
+	jobString := `{"projectName": "dev", "requestedBy": "alice", "pullRequestNumber": 3}`
+	assert.NoError(t, backend.TriggerWorkflow("group/sub", "demo", job, jobString, 42))
+
+	assert.Equal(t, "/api/v4/projects/group%2Fsub%2Fdemo/trigger/pipeline", path)
+	assert.Equal(t, "feature", body["ref"])
+	assert.Equal(t, "trigger", body["token"])
+	variables := body["variables"].(map[string]interface{})
+	var runSpec spec.Spec
+	assert.NoError(t, json.Unmarshal([]byte(variables["DIGGER_SPEC"].(string)), &runSpec))
+	assert.Equal(t, "job-1", runSpec.JobId)
+	assert.Equal(t, "42", runSpec.CommentId)
+	assert.Equal(t, "gitlab", runSpec.VCS.VcsType)
+	assert.Equal(t, "dev", runSpec.Job.ProjectName)
+}
+
+func TestNewGitlabPipelineCiRequiresTokens(t *testing.T) {
+	t.Setenv("GITLAB_TOKEN", "token")
+	t.Setenv("GITLAB_TRIGGER_TOKEN", "")
+	_, err := NewGitlabPipelineCi()
+	assert.Error(t, err)
+}
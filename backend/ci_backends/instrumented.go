@@ -0,0 +1,25 @@
+package ci_backends
+
+import (
+	"github.com/diggerhq/digger/backend/metrics"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/backend/utils"
+	"github.com/diggerhq/digger/libs/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"time"
+)
+
+// InstrumentedCiBackend records dispatch latency and failures of the wrapped backend and traces the dispatch
+type InstrumentedCiBackend struct {
+	Name    string
+	Backend CiBackend
+}
+
+func (i InstrumentedCiBackend) TriggerWorkflow(repoOwner string, repoName string, job models.DiggerJob, jobString string, commentId int64) error {
+	_, span, jobString := utils.StartJobSpan(jobString, "CiDispatch", attribute.String("ci.backend", i.Name))
+	startedAt := time.Now()
+	err := i.Backend.TriggerWorkflow(repoOwner, repoName, job, jobString, commentId)
+	metrics.ObserveDispatch(i.Name, startedAt, err)
+	tracing.EndSpan(span, err)
+	return err
+}
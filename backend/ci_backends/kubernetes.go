@@ -0,0 +1,340 @@
+package ci_backends
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/backend/utils"
+	"github.com/diggerhq/digger/libs/orchestrator"
+	dg_github "github.com/diggerhq/digger/libs/orchestrator/github"
+	"github.com/diggerhq/digger/libs/spec"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	kubernetesWorkspaceDir = "/workspace"
+	kubernetesJobLabel     = "digger.dev/job-id"
+)
+
+// KubernetesJobsCi runs every digger job as a kubernetes Job. The run spec and the credentials are stored in a Secret
+// owned by the Job, an init container clones the branch and the main container runs `digger run_spec` in the checkout.
+type KubernetesJobsCi struct {
+	Client kubernetes.Interface
+	Config KubernetesJobsConfig
+	// GetToken returns the token used to clone the repository and comment on the pull request
+	GetToken func(job models.DiggerJob) (string, error)
+}
+
+type KubernetesJobsConfig struct {
+	Namespace      string
+	Image          string
+	CloneImage     string
+	ServiceAccount string
+	CpuRequest     string
+	CpuLimit       string
+	MemoryRequest  string
+	MemoryLimit    string
+	// TTLSecondsAfterFinished is how long finished jobs are kept before kubernetes deletes them with their pods and secret
+	TTLSecondsAfterFinished int32
+	BackoffLimit            int32
+}
+
+// KubernetesJobsConfigFromEnv reads the DIGGER_K8S_* variables, DIGGER_K8S_IMAGE is required
+func KubernetesJobsConfigFromEnv() (KubernetesJobsConfig, error) {
+	config := KubernetesJobsConfig{
+		Namespace:               os.Getenv("DIGGER_K8S_NAMESPACE"),
+		Image:                   os.Getenv("DIGGER_K8S_IMAGE"),
+		CloneImage:              os.Getenv("DIGGER_K8S_CLONE_IMAGE"),
+		ServiceAccount:          os.Getenv("DIGGER_K8S_SERVICE_ACCOUNT"),
+		CpuRequest:              os.Getenv("DIGGER_K8S_CPU_REQUEST"),
+		CpuLimit:                os.Getenv("DIGGER_K8S_CPU_LIMIT"),
+		MemoryRequest:           os.Getenv("DIGGER_K8S_MEMORY_REQUEST"),
+		MemoryLimit:             os.Getenv("DIGGER_K8S_MEMORY_LIMIT"),
+		TTLSecondsAfterFinished: 3600,
+		BackoffLimit:            0,
+	}
+	if config.Image == "" {
+		return config, fmt.Errorf("missing environment variable: required DIGGER_K8S_IMAGE")
+	}
+	if config.Namespace == "" {
+		config.Namespace = "default"
+	}
+	if config.CloneImage == "" {
+		config.CloneImage = "alpine/git"
+	}
+	if ttl := os.Getenv("DIGGER_K8S_TTL_SECONDS_AFTER_FINISHED"); ttl != "" {
+		seconds, err := strconv.ParseInt(ttl, 10, 32)
+		if err != nil {
+			return config, fmt.Errorf("invalid DIGGER_K8S_TTL_SECONDS_AFTER_FINISHED: %v", err)
+		}
+		config.TTLSecondsAfterFinished = int32(seconds)
+	}
+	if limit := os.Getenv("DIGGER_K8S_BACKOFF_LIMIT"); limit != "" {
+		retries, err := strconv.ParseInt(limit, 10, 32)
+		if err != nil {
+			return config, fmt.Errorf("invalid DIGGER_K8S_BACKOFF_LIMIT: %v", err)
+		}
+		config.BackoffLimit = int32(retries)
+	}
+	return config, nil
+}
+
+// resources parses the configured requests and limits, empty values are left unset
+func (c KubernetesJobsConfig) resources() (corev1.ResourceRequirements, error) {
+	requirements := corev1.ResourceRequirements{Requests: corev1.ResourceList{}, Limits: corev1.ResourceList{}}
+	quantities := []struct {
+		list  corev1.ResourceList
+		name  corev1.ResourceName
+		value string
+	}{
+		{requirements.Requests, corev1.ResourceCPU, c.CpuRequest},
+		{requirements.Limits, corev1.ResourceCPU, c.CpuLimit},
+		{requirements.Requests, corev1.ResourceMemory, c.MemoryRequest},
+		{requirements.Limits, corev1.ResourceMemory, c.MemoryLimit},
+	}
+	for _, q := range quantities {
+		if q.value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(q.value)
+		if err != nil {
+			return requirements, fmt.Errorf("invalid %v quantity %v: %v", q.name, q.value, err)
+		}
+		q.list[q.name] = quantity
+	}
+	return requirements, nil
+}
+
+// NewKubernetesJobsCi uses the in-cluster service account, or KUBECONFIG when running outside of the cluster
+func NewKubernetesJobsCi() (*KubernetesJobsCi, error) {
+	config, err := KubernetesJobsConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		restConfig, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{}).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("could not load kubernetes config: %v", err)
+		}
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not create kubernetes client: %v", err)
+	}
+	return &KubernetesJobsCi{Client: client, Config: config, GetToken: tokenForJob}, nil
+}
+
+// KubernetesCiBackend is selected with CI_BACKEND=kubernetes
+func KubernetesCiBackend() (CiBackend, error) {
+	backend, err := NewKubernetesJobsCi()
+	if err != nil {
+		log.Printf("GetCiBackend: could not create kubernetes jobs backend: %v", err)
+		return nil, err
+	}
+	return InstrumentedCiBackend{Name: "kubernetes_jobs", Backend: backend}, nil
+}
+
+// tokenForJob returns GITLAB_TOKEN for jobs of gitlab projects and a token of the github installation otherwise
+func tokenForJob(job models.DiggerJob) (string, error) {
+	if job.Batch.VCS == models.DiggerVCSGitlab {
+		token := os.Getenv("GITLAB_TOKEN")
+		if token == "" {
+			return "", fmt.Errorf("GITLAB_TOKEN is not set")
+		}
+		return token, nil
+	}
+	return githubTokenForJob(job)
+}
+
+func githubTokenForJob(job models.DiggerJob) (string, error) {
+	_, token, err := utils.GetGithubService(
+		utils.DiggerGithubRealClientProvider{},
+		job.Batch.GithubInstallationId,
+		job.Batch.RepoFullName,
+		job.Batch.RepoOwner,
+		job.Batch.RepoName,
+	)
+	if err != nil {
+		return "", err
+	}
+	return *token, nil
+}
+
+// kubernetesName turns a digger job id into a valid object name
+func kubernetesName(jobId string) string {
+	name := "digger-" + strings.ToLower(jobId)
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+func (k KubernetesJobsCi) TriggerWorkflow(repoOwner string, repoName string, job models.DiggerJob, jobString string, commentId int64) error {
+	log.Printf("Trigger Kubernetes Job: repoOwner: %v, repoName: %v, commentId: %v", repoOwner, repoName, commentId)
+	var jobSpec orchestrator.JobJson
+	err := json.Unmarshal([]byte(jobString), &jobSpec)
+	if err != nil {
+		log.Printf("could not unmarshal job string: %v", err)
+		return fmt.Errorf("could not marshal json string: %v", err)
+	}
+
+	vcsType := job.Batch.VCS
+	if vcsType == "" {
+		vcsType = models.DiggerVCSGithub
+	}
+	batchIdShort := job.Batch.ID.String()[:8]
+	diggerCommand := fmt.Sprintf("digger %v", job.Batch.BatchType)
+	runName := fmt.Sprintf("[%v] %v %v By: %v PR: %v", batchIdShort, diggerCommand, jobSpec.ProjectName, jobSpec.RequestedBy, *jobSpec.PullRequestNumber)
+	runSpec := spec.Spec{
+		JobId:     job.DiggerJobID,
+		CommentId: strconv.FormatInt(commentId, 10),
+		RunName:   runName,
+		Job:       jobSpec,
+		Reporter: spec.ReporterSpec{
+			ReportingStrategy: "comments_per_run",
+			ReporterType:      "lazy",
+		},
+		Lock: spec.LockSpec{
+			LockType: "noop",
+		},
+		Backend: spec.BackendSpec{
+			BackendHostname:         jobSpec.BackendHostname,
+			BackendOrganisationName: jobSpec.BackendOrganisationName,
+			BackendJobToken:         jobSpec.BackendJobToken,
+			BackendType:             "backend",
+		},
+		VCS: spec.VcsSpec{
+			VcsType:   string(vcsType),
+			Actor:     jobSpec.RequestedBy,
+			RepoOwner: repoOwner,
+			RepoName:  repoName,
+		},
+		Policy: spec.PolicySpec{
+			PolicyType: "http",
+		},
+	}
+	specBytes, err := json.Marshal(runSpec)
+	if err != nil {
+		return fmt.Errorf("could not marshal spec: %v", err)
+	}
+
+	token, err := k.GetToken(job)
+	if err != nil {
+		return fmt.Errorf("TriggerWorkflow: could not retrieve token: %v", err)
+	}
+	repoUrl, tokenUser, tokenVariable := dg_github.GithubUrlsFromEnv().RepoUrl(repoOwner+"/"+repoName), "x-access-token", "GITHUB_TOKEN"
+	if vcsType == models.DiggerVCSGitlab {
+		repoUrl, tokenUser, tokenVariable = utils.GitlabRepoUrl(repoOwner+"/"+repoName), "oauth2", "GITLAB_TOKEN"
+	}
+	cloneUrl, err := url.Parse(repoUrl + ".git")
+	if err != nil {
+		return fmt.Errorf("invalid repository url: %v", err)
+	}
+	cloneUrl.User = url.UserPassword(tokenUser, token)
+
+	resources, err := k.Config.resources()
+	if err != nil {
+		return err
+	}
+
+	name := kubernetesName(job.DiggerJobID)
+	labels := map[string]string{
+		"app.kubernetes.io/managed-by": "digger",
+		kubernetesJobLabel:             job.DiggerJobID,
+	}
+	secretRef := corev1.EnvFromSource{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}}}
+	workspace := []corev1.VolumeMount{{Name: "workspace", MountPath: kubernetesWorkspaceDir}}
+	ttl := k.Config.TTLSecondsAfterFinished
+	backoffLimit := k.Config.BackoffLimit
+
+	kubernetesJob := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   k.Config.Namespace,
+			Labels:      labels,
+			Annotations: map[string]string{"digger.dev/run-name": runName},
+		},
+		Spec: batchv1.JobSpec{
+			TTLSecondsAfterFinished: &ttl,
+			BackoffLimit:            &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: k.Config.ServiceAccount,
+					InitContainers: []corev1.Container{{
+						Name:         "clone",
+						Image:        k.Config.CloneImage,
+						Command:      []string{"sh", "-c", `git clone --depth 1 --branch "$DIGGER_BRANCH" "$DIGGER_CLONE_URL" ` + kubernetesWorkspaceDir},
+						Env:          []corev1.EnvVar{{Name: "DIGGER_BRANCH", Value: job.Batch.BranchName}},
+						EnvFrom:      []corev1.EnvFromSource{secretRef},
+						VolumeMounts: workspace,
+					}},
+					Containers: []corev1.Container{{
+						Name:         "digger",
+						Image:        k.Config.Image,
+						Command:      []string{"digger", "run_spec"},
+						WorkingDir:   kubernetesWorkspaceDir,
+						Env:          []corev1.EnvVar{{Name: "DIGGER_RUN_NAME", Value: runName}},
+						EnvFrom:      []corev1.EnvFromSource{secretRef},
+						Resources:    resources,
+						VolumeMounts: workspace,
+					}},
+					Volumes: []corev1.Volume{{Name: "workspace", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}},
+				},
+			},
+		},
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: k.Config.Namespace,
+			Labels:    labels,
+		},
+		StringData: map[string]string{
+			"DIGGER_SPEC":      string(specBytes),
+			"DIGGER_CLONE_URL": cloneUrl.String(),
+			tokenVariable:      token,
+		},
+	}
+
+	ctx := context.Background()
+	secret, err = k.Client.CoreV1().Secrets(k.Config.Namespace).Create(ctx, secret, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("could not create secret for kubernetes job: %v", err)
+	}
+	created, err := k.Client.BatchV1().Jobs(k.Config.Namespace).Create(ctx, kubernetesJob, metav1.CreateOptions{})
+	if err != nil {
+		k.Client.CoreV1().Secrets(k.Config.Namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{})
+		return fmt.Errorf("could not create kubernetes job: %v", err)
+	}
+
+	// the secret is owned by the job so that it is garbage collected together with it once the ttl expires
+	controller := true
+	secret.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: "batch/v1",
+		Kind:       "Job",
+		Name:       created.Name,
+		UID:        created.UID,
+		Controller: &controller,
+	}}
+	_, err = k.Client.CoreV1().Secrets(k.Config.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
+	if err != nil {
+		log.Printf("could not set owner of secret %v, it will not be deleted with the job: %v", secret.Name, err)
+	}
+	return nil
+}
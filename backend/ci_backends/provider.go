@@ -2,8 +2,10 @@ package ci_backends
 
 import (
 	"fmt"
+	"github.com/diggerhq/digger/backend/models"
 	"github.com/diggerhq/digger/backend/utils"
 	"log"
+	"os"
 )
 
 type CiBackendProvider interface {
@@ -13,6 +15,15 @@ type CiBackendProvider interface {
 type DefaultBackendProvider struct{}
 
 func (d DefaultBackendProvider) GetCiBackend(options CiBackendOptions) (CiBackend, error) {
+	if os.Getenv("CI_BACKEND") == "agent" {
+		return AgentCiBackend()
+	}
+	if os.Getenv("CI_BACKEND") == "kubernetes" {
+		return KubernetesCiBackend()
+	}
+	if options.VCS == models.DiggerVCSGitlab {
+		return GitlabCiBackend()
+	}
 	client, _, err := utils.GetGithubClient(&utils.DiggerGithubRealClientProvider{}, options.GithubInstallationId, options.RepoFullName)
 	if err != nil {
 		log.Printf("GetCiBackend: could not get github client: %v", err)
@@ -21,5 +32,5 @@ func (d DefaultBackendProvider) GetCiBackend(options CiBackendOptions) (CiBacken
 	backend := &GithubActionCi{
 		Client: client,
 	}
-	return backend, nil
+	return InstrumentedCiBackend{Name: "github_actions", Backend: backend}, nil
 }
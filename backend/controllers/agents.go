@@ -0,0 +1,334 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/diggerhq/digger/backend/middleware"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/backend/services"
+	"github.com/diggerhq/digger/backend/utils"
+	dg_github "github.com/diggerhq/digger/libs/orchestrator/github"
+	"github.com/diggerhq/digger/libs/spec"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxAgentClaimWait = 30 * time.Second
+
+type CreateAgentTokenRequest struct {
+	Name string `json:"name"`
+	// Pool defaults to the default pool
+	Pool string `json:"pool"`
+}
+
+func ListAgentTokens(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+
+	tokens, err := models.DB.GetAgentTokens(orgId)
+	if err != nil {
+		log.Printf("Error fetching agent tokens: %v", err)
+		c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+		return
+	}
+
+	response := make([]interface{}, 0)
+	for _, t := range tokens {
+		response = append(response, t.MapToJsonStruct())
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateAgentToken registers a token for the agents of a pool, its value is only returned in this response
+func CreateAgentToken(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+
+	var request CreateAgentTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Pool == "" {
+		request.Pool = models.DefaultAgentPool
+	}
+
+	token, value, err := models.DB.CreateAgentToken(orgId.(uint), request.Name, request.Pool)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error creating agent token")
+		return
+	}
+	recordAuditEvent(c, token.OrganisationID, models.AuditActionAgentTokenCreate, fmt.Sprintf("agent-tokens/%v", token.ID), token.Pool)
+	c.JSON(http.StatusCreated, gin.H{
+		"agent_token": token.MapToJsonStruct(),
+		"token":       value,
+	})
+}
+
+func DeleteAgentToken(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+
+	tokenId, err := strconv.Atoi(c.Param("tokenId"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid token id")
+		return
+	}
+
+	err = models.DB.DeleteAgentToken(orgId, uint(tokenId))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.String(http.StatusNotFound, "Could not find agent token")
+		} else {
+			log.Printf("Error deleting agent token: %v", err)
+			c.String(http.StatusInternalServerError, "Error deleting agent token")
+		}
+		return
+	}
+	recordAuditEvent(c, orgId.(uint), models.AuditActionAgentTokenDelete, fmt.Sprintf("agent-tokens/%v", tokenId), "")
+	c.Status(http.StatusNoContent)
+}
+
+// AgentController serves the routes `digger agent` polls, they are authenticated with agent tokens
+type AgentController struct {
+	// GithubToken returns the token the agent clones and comments with, it is created when the job is claimed
+	// since installation tokens expire after an hour
+	GithubToken func(batch *models.DiggerBatch) (string, error)
+}
+
+type ClaimAgentJobRequest struct {
+	AgentName string `json:"agent_name"`
+	// WaitSeconds is how long the request is held when there is no job in the queue
+	WaitSeconds int `json:"wait_seconds"`
+}
+
+type ClaimedAgentJob struct {
+	Id       uint   `json:"id"`
+	JobId    string `json:"job_id"`
+	Spec     string `json:"spec"`
+	VcsType  string `json:"vcs_type"`
+	CloneUrl string `json:"clone_url"`
+	Branch   string `json:"branch"`
+	// Token is empty for gitlab, agents use their own GITLAB_TOKEN
+	Token string `json:"token"`
+}
+
+type FinishAgentJobRequest struct {
+	ExitCode int `json:"exit_code"`
+}
+
+// GithubInstallationToken creates a token of the installation of the batch
+func GithubInstallationToken(batch *models.DiggerBatch) (string, error) {
+	_, token, err := utils.GetGithubService(
+		utils.DiggerGithubRealClientProvider{},
+		batch.GithubInstallationId,
+		batch.RepoFullName,
+		batch.RepoOwner,
+		batch.RepoName,
+	)
+	if err != nil {
+		return "", err
+	}
+	return *token, nil
+}
+
+func agentFromContext(c *gin.Context) (uint, string, bool) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return 0, "", false
+	}
+	return orgId.(uint), c.GetString(middleware.AGENT_POOL_KEY), true
+}
+
+// ClaimJob hands the oldest queued job of the pool to the agent, the request is held until a job is queued or the
+// wait expires, in which case it returns 204
+func (a AgentController) ClaimJob(c *gin.Context) {
+	orgId, pool, ok := agentFromContext(c)
+	if !ok {
+		return
+	}
+
+	var request ClaimAgentJobRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	wait := time.Duration(request.WaitSeconds) * time.Second
+	if wait > maxAgentClaimWait {
+		wait = maxAgentClaimWait
+	}
+	deadline := time.Now().Add(wait)
+
+	for {
+		agentJob, err := models.DB.ClaimAgentJob(orgId, pool, request.AgentName)
+		if err != nil {
+			log.Printf("Error claiming agent job: %v", err)
+			c.String(http.StatusInternalServerError, "Error claiming job")
+			return
+		}
+		if agentJob != nil {
+			claimed, err := a.prepareClaimedJob(orgId, agentJob)
+			if err != nil {
+				log.Printf("Error preparing agent job %v, returning it to the queue: %v", agentJob.ID, err)
+				agentJob.Status = models.AgentJobQueued
+				agentJob.AgentName = ""
+				if err := models.DB.UpdateAgentJob(agentJob); err != nil {
+					log.Printf("Error returning agent job %v to the queue: %v", agentJob.ID, err)
+				}
+				c.String(http.StatusInternalServerError, "Error preparing job")
+				return
+			}
+			log.Printf("Agent %v of pool %v claimed job %v", request.AgentName, pool, agentJob.DiggerJobID)
+			c.JSON(http.StatusOK, claimed)
+			return
+		}
+		if !time.Now().Before(deadline) {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// prepareClaimedJob creates the credentials of the job, the job token is replaced since the job may have waited in
+// the queue for longer than the token lives
+func (a AgentController) prepareClaimedJob(orgId uint, agentJob *models.AgentJob) (*ClaimedAgentJob, error) {
+	job, err := models.DB.GetDiggerJob(agentJob.DiggerJobID)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch job: %v", err)
+	}
+	if job.ID == 0 || job.Batch == nil {
+		return nil, fmt.Errorf("job %v not found", agentJob.DiggerJobID)
+	}
+
+	var runSpec spec.Spec
+	err = json.Unmarshal([]byte(agentJob.Spec), &runSpec)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal spec: %v", err)
+	}
+	jobToken, err := models.DB.CreateDiggerJobToken(orgId, job.Batch.RepoFullName)
+	if err != nil {
+		return nil, fmt.Errorf("could not create job token: %v", err)
+	}
+	runSpec.Job.BackendJobToken = jobToken.Value
+	runSpec.Backend.BackendJobToken = jobToken.Value
+	specBytes, err := json.Marshal(runSpec)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal spec: %v", err)
+	}
+
+	claimed := &ClaimedAgentJob{
+		Id:      agentJob.ID,
+		JobId:   agentJob.DiggerJobID,
+		Spec:    string(specBytes),
+		VcsType: string(job.Batch.VCS),
+		Branch:  job.Batch.BranchName,
+	}
+	if job.Batch.VCS == models.DiggerVCSGitlab {
+		claimed.CloneUrl = utils.GitlabRepoUrl(job.Batch.RepoFullName) + ".git"
+	} else {
+		claimed.VcsType = string(models.DiggerVCSGithub)
+		claimed.CloneUrl = dg_github.GithubUrlsFromEnv().RepoUrl(job.Batch.RepoFullName) + ".git"
+		claimed.Token, err = a.GithubToken(job.Batch)
+		if err != nil {
+			return nil, fmt.Errorf("could not get github token: %v", err)
+		}
+	}
+	return claimed, nil
+}
+
+// claimedJobFromParam returns the job if it is still claimed by an agent of the pool, it responds 404 otherwise so
+// that agents stop running jobs that were declared lost
+func claimedJobFromParam(c *gin.Context) (*models.AgentJob, bool) {
+	orgId, pool, ok := agentFromContext(c)
+	if !ok {
+		return nil, false
+	}
+
+	agentJobId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid job id")
+		return nil, false
+	}
+
+	agentJob, err := models.DB.GetClaimedAgentJob(orgId, pool, uint(agentJobId))
+	if err != nil {
+		log.Printf("Error fetching agent job: %v", err)
+		c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+		return nil, false
+	}
+	if agentJob == nil {
+		c.String(http.StatusNotFound, "Could not find claimed job")
+		return nil, false
+	}
+	return agentJob, true
+}
+
+func (a AgentController) Heartbeat(c *gin.Context) {
+	agentJob, ok := claimedJobFromParam(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	agentJob.HeartbeatAt = &now
+	err := models.DB.UpdateAgentJob(agentJob)
+	if err != nil {
+		log.Printf("Error updating agent job heartbeat: %v", err)
+		c.String(http.StatusInternalServerError, "Error updating job")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// FinishJob records the exit code of `digger run_spec`, the job is failed if it exited before reporting its result
+func (a AgentController) FinishJob(c *gin.Context) {
+	agentJob, ok := claimedJobFromParam(c)
+	if !ok {
+		return
+	}
+
+	var request FinishAgentJobRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	agentJob.Status = models.AgentJobFinished
+	agentJob.FinishedAt = &now
+	agentJob.ExitCode = &request.ExitCode
+	err := models.DB.UpdateAgentJob(agentJob)
+	if err != nil {
+		log.Printf("Error finishing agent job: %v", err)
+		c.String(http.StatusInternalServerError, "Error updating job")
+		return
+	}
+
+	if request.ExitCode != 0 {
+		err = services.FailUnfinishedDiggerJob(agentJob.DiggerJobID)
+		if err != nil {
+			log.Printf("Error failing job of agent job %v: %v", agentJob.ID, err)
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/diggerhq/digger/backend/ci_backends"
+	"github.com/diggerhq/digger/backend/middleware"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/backend/services"
+	orchestrator_scheduler "github.com/diggerhq/digger/libs/orchestrator/scheduler"
+	"github.com/diggerhq/digger/libs/spec"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupAgentTest(t *testing.T) (func(tb testing.TB), *models.Organisation, *models.DiggerJob) {
+	teardownSuite, database := setupSuite(t)
+	assert.NoError(t, database.GormDB.AutoMigrate(&models.DiggerBatch{}, &models.DiggerJobSummary{}, &models.AgentToken{},
+		&models.AgentJob{}, &models.OrgWebhook{}, &models.WebhookDelivery{}))
+
+	org, err := database.GetOrganisation("11111111-1111-1111-1111-111111111111")
+	assert.NoError(t, err)
+	batch, err := database.CreateDiggerBatch(1, "diggerhq", "demo", "diggerhq/demo", 3, "", "feature", "plan", nil)
+	assert.NoError(t, err)
+	job, err := database.CreateDiggerJob(batch.ID, []byte("{}"), "digger_workflow.yml")
+	assert.NoError(t, err)
+	job.Batch = batch
+	jobToken, err := database.CreateDiggerJobToken(org.ID, "diggerhq/demo")
+	assert.NoError(t, err)
+
+	jobString, err := json.Marshal(map[string]interface{}{
+		"projectName":       "dev",
+		"requestedBy":       "alice",
+		"pullRequestNumber": 3,
+		"backend_job_token": jobToken.Value,
+		"agent_pool":        "private",
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, ci_backends.AgentCi{}.TriggerWorkflow("diggerhq", "demo", *job, string(jobString), 42))
+	return teardownSuite, org, job
+}
+
+func agentRequest(t *testing.T, token string, method string, path string, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	controller := AgentController{GithubToken: func(batch *models.DiggerBatch) (string, error) { return "ghs_token", nil }}
+	agents := r.Group("/agents")
+	agents.Use(middleware.AgentTokenAuth())
+	agents.POST("/jobs/claim", controller.ClaimJob)
+	agents.POST("/jobs/:id/heartbeat", controller.Heartbeat)
+	agents.POST("/jobs/:id/finish", controller.FinishJob)
+
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAgentClaimsJobOfItsPool(t *testing.T) {
+	teardownSuite, org, job := setupAgentTest(t)
+	defer teardownSuite(t)
+
+	_, defaultToken, err := models.DB.CreateAgentToken(org.ID, "default agents", models.DefaultAgentPool)
+	assert.NoError(t, err)
+	_, privateToken, err := models.DB.CreateAgentToken(org.ID, "private agents", "private")
+	assert.NoError(t, err)
+
+	claim := `{"agent_name": "agent-1", "wait_seconds": 0}`
+	assert.Equal(t, http.StatusForbidden, agentRequest(t, "", "POST", "/agents/jobs/claim", claim).Code)
+	assert.Equal(t, http.StatusForbidden, agentRequest(t, "t:not-an-agent-token", "POST", "/agents/jobs/claim", claim).Code)
+	assert.Equal(t, http.StatusForbidden, agentRequest(t, "a:unknown", "POST", "/agents/jobs/claim", claim).Code)
+	assert.Equal(t, http.StatusNoContent, agentRequest(t, defaultToken, "POST", "/agents/jobs/claim", claim).Code)
+
+	w := agentRequest(t, privateToken, "POST", "/agents/jobs/claim", claim)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var claimed ClaimedAgentJob
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &claimed))
+	assert.Equal(t, job.DiggerJobID, claimed.JobId)
+	assert.Equal(t, "github", claimed.VcsType)
+	assert.Equal(t, "https://github.com/diggerhq/demo.git", claimed.CloneUrl)
+	assert.Equal(t, "feature", claimed.Branch)
+	assert.Equal(t, "ghs_token", claimed.Token)
+	var runSpec spec.Spec
+	assert.NoError(t, json.Unmarshal([]byte(claimed.Spec), &runSpec))
+	assert.Equal(t, "42", runSpec.CommentId)
+	assert.Equal(t, runSpec.Job.BackendJobToken, runSpec.Backend.BackendJobToken)
+	jobToken, err := models.DB.GetJobToken(runSpec.Backend.BackendJobToken)
+	assert.NoError(t, err)
+	assert.NotNil(t, jobToken)
+
+	// the job can only be claimed once
+	assert.Equal(t, http.StatusNoContent, agentRequest(t, privateToken, "POST", "/agents/jobs/claim", claim).Code)
+
+	heartbeat := fmt.Sprintf("/agents/jobs/%v/heartbeat", claimed.Id)
+	finish := fmt.Sprintf("/agents/jobs/%v/finish", claimed.Id)
+	assert.Equal(t, http.StatusNotFound, agentRequest(t, defaultToken, "POST", heartbeat, "").Code)
+	assert.Equal(t, http.StatusNoContent, agentRequest(t, privateToken, "POST", heartbeat, "").Code)
+
+	assert.Equal(t, http.StatusNoContent, agentRequest(t, privateToken, "POST", finish, `{"exit_code": 1}`).Code)
+	assert.Equal(t, http.StatusNotFound, agentRequest(t, privateToken, "POST", heartbeat, "").Code)
+
+	// the job never reported a result so it is failed
+	refreshed, err := models.DB.GetDiggerJob(job.DiggerJobID)
+	assert.NoError(t, err)
+	assert.Equal(t, orchestrator_scheduler.DiggerJobFailed, refreshed.Status)
+}
+
+func TestStaleAgentJobsAreLost(t *testing.T) {
+	teardownSuite, org, job := setupAgentTest(t)
+	defer teardownSuite(t)
+
+	agentJob, err := models.DB.ClaimAgentJob(org.ID, "private", "agent-1")
+	assert.NoError(t, err)
+	assert.NotNil(t, agentJob)
+
+	services.ExpireStaleAgentJobs(time.Now())
+	agentJob, err = models.DB.GetClaimedAgentJob(org.ID, "private", agentJob.ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, agentJob)
+
+	services.ExpireStaleAgentJobs(time.Now().Add(services.AgentJobHeartbeatTimeout + time.Minute))
+	lost, err := models.DB.GetClaimedAgentJob(org.ID, "private", agentJob.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, lost)
+
+	refreshed, err := models.DB.GetDiggerJob(job.DiggerJobID)
+	assert.NoError(t, err)
+	assert.Equal(t, orchestrator_scheduler.DiggerJobFailed, refreshed.Status)
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/diggerhq/digger/backend/middleware"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// auditActor identifies who performed a request, falling back to the token type when there is no subject
+func auditActor(c *gin.Context) string {
+	if subject := c.GetString(middleware.SUBJECT_KEY); subject != "" {
+		return subject
+	}
+	return fmt.Sprintf("%v-token", c.GetString(middleware.ACCESS_LEVEL_KEY))
+}
+
+// recordAuditEvent stores an audit log entry for the current request, failures are logged and don't fail the request
+func recordAuditEvent(c *gin.Context, orgId uint, action string, target string, diff string) {
+	_, err := models.DB.CreateAuditLogEntry(orgId, auditActor(c), action, target, diff, c.ClientIP())
+	if err != nil {
+		log.Printf("Failed to record audit event %v for %v: %v", action, target, err)
+	}
+}
+
+// recordWebhookAuditEvent stores an audit log entry for actions triggered by vcs webhooks, which have no client ip
+func recordWebhookAuditEvent(orgId uint, actor string, action string, target string) {
+	_, err := models.DB.CreateAuditLogEntry(orgId, actor, action, target, "", "")
+	if err != nil {
+		log.Printf("Failed to record audit event %v for %v: %v", action, target, err)
+	}
+}
+
+// GetAuditLog lists audit log entries of the org, with format=jsonl entries are exported as JSON lines
+func GetAuditLog(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+
+	filter := models.AuditLogFilter{
+		Action: c.Query("action"),
+		Actor:  c.Query("actor"),
+		Target: c.Query("target"),
+	}
+	for param, dest := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.String(http.StatusBadRequest, fmt.Sprintf("Invalid %v, expected RFC3339 timestamp", param))
+			return
+		}
+		*dest = &t
+	}
+	if limit := c.Query("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l < 0 {
+			c.String(http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = l
+	}
+
+	entries, err := models.DB.GetAuditLogEntries(orgId, filter)
+	if err != nil {
+		log.Printf("Error fetching audit log: %v", err)
+		c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+		return
+	}
+
+	if c.Query("format") == "jsonl" {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", "attachment; filename=audit.jsonl")
+		c.Status(http.StatusOK)
+		encoder := json.NewEncoder(c.Writer)
+		for _, entry := range entries {
+			if err := encoder.Encode(entry.MapToJsonStruct()); err != nil {
+				log.Printf("Error writing audit log export: %v", err)
+				return
+			}
+		}
+		return
+	}
+
+	response := make([]interface{}, 0)
+	for _, entry := range entries {
+		response = append(response, entry.MapToJsonStruct())
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -23,16 +23,20 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/diggerhq/digger/backend/metrics"
 	"github.com/diggerhq/digger/backend/middleware"
 	"github.com/diggerhq/digger/backend/models"
 	"github.com/diggerhq/digger/backend/utils"
 	dg_configuration "github.com/diggerhq/digger/libs/digger_config"
 	"github.com/diggerhq/digger/libs/orchestrator"
 	dg_github "github.com/diggerhq/digger/libs/orchestrator/github"
+	"github.com/diggerhq/digger/libs/tracing"
 	"github.com/dominikbraun/graph"
 	"github.com/gin-gonic/gin"
 	"github.com/google/go-github/v61/github"
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/oauth2"
 )
 
@@ -53,6 +57,8 @@ func (g GithubController) GithubAppWebHook(c *gin.Context) {
 	}
 
 	webhookType := github.WebHookType(c.Request)
+	ctx, span := tracing.Tracer().Start(c.Request.Context(), "GithubAppWebHook", trace.WithAttributes(attribute.String("github.event", webhookType)))
+	defer span.End()
 	event, err := github.ParseWebHook(webhookType, payload)
 	if err != nil {
 		log.Printf("Failed to parse Github Event. :%v\n", err)
@@ -100,7 +106,7 @@ func (g GithubController) GithubAppWebHook(c *gin.Context) {
 			c.String(http.StatusOK, "OK")
 			return
 		}
-		err := handleIssueCommentEvent(gh, event, g.CiBackendProvider)
+		err := handleIssueCommentEvent(ctx, gh, event, g.CiBackendProvider)
 		if err != nil {
 			log.Printf("handleIssueCommentEvent error: %v", err)
 			c.String(http.StatusInternalServerError, err.Error())
@@ -108,7 +114,7 @@ func (g GithubController) GithubAppWebHook(c *gin.Context) {
 		}
 	case *github.PullRequestEvent:
 		log.Printf("Got pull request event for %d", *event.PullRequest.ID)
-		err := handlePullRequestEvent(gh, event, g.CiBackendProvider)
+		err := handlePullRequestEvent(ctx, gh, event, g.CiBackendProvider)
 		if err != nil {
 			log.Printf("handlePullRequestEvent error: %v", err)
 			c.String(http.StatusInternalServerError, err.Error())
@@ -191,16 +197,10 @@ func GithubAppSetup(c *gin.Context) {
 		},
 	}
 
-	url := &url.URL{
-		Scheme: "https",
-		Host:   "github.com",
-		Path:   "/settings/apps/new",
-	}
-
-	// https://developer.github.com/apps/building-github-apps/creating-github-apps-using-url-parameters/#about-github-app-url-parameters
-	githubOrg := os.Getenv("GITHUB_ORG")
-	if githubOrg != "" {
-		url.Path = fmt.Sprintf("organizations/%s%s", githubOrg, url.Path)
+	url, err := githubAppManifestTarget(dg_github.GithubUrlsFromEnv(), os.Getenv("GITHUB_ORG"))
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
 	jsonManifest, err := json.MarshalIndent(manifest, "", " ")
@@ -212,6 +212,21 @@ func GithubAppSetup(c *gin.Context) {
 	c.HTML(http.StatusOK, "github_setup.tmpl", gin.H{"Target": url.String(), "Manifest": string(jsonManifest)})
 }
 
+// githubAppManifestTarget returns the page of the github instance the app manifest is posted to
+func githubAppManifestTarget(urls dg_github.GithubUrls, githubOrg string) (*url.URL, error) {
+	target, err := url.Parse(urls.Web())
+	if err != nil {
+		return nil, fmt.Errorf("invalid github server url %v: %v", urls.Web(), err)
+	}
+	appsPath := "/settings/apps/new"
+	// https://developer.github.com/apps/building-github-apps/creating-github-apps-using-url-parameters/#about-github-app-url-parameters
+	if githubOrg != "" {
+		appsPath = fmt.Sprintf("/organizations/%s%s", githubOrg, appsPath)
+	}
+	target.Path = strings.TrimSuffix(target.Path, "/") + appsPath
+	return target, nil
+}
+
 // GithubSetupExchangeCode handles the user coming back from creating their app
 // A code query parameter is exchanged for this app's ID, key, and webhook_secret
 // Implements https://developer.github.com/apps/building-github-apps/creating-github-apps-from-a-manifest/#implementing-the-github-app-manifest-flow
@@ -221,7 +236,11 @@ func GithubSetupExchangeCode(c *gin.Context) {
 		c.Error(fmt.Errorf("Ignoring callback, missing code query parameter"))
 	}
 
-	client := github.NewClient(nil)
+	client, err := dg_github.GithubUrlsFromEnv().NewClient(nil)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	cfg, _, err := client.Apps.CompleteAppManifest(context.Background(), code)
 	if err != nil {
 		c.Error(fmt.Errorf("Failed to exchange code for github app: %s", err))
@@ -299,7 +318,7 @@ func handleInstallationRepositoriesAddedEvent(ghClientProvider utils.GithubClien
 		repoFullName := *repo.FullName
 		repoOwner := strings.Split(*repo.FullName, "/")[0]
 		repoName := *repo.Name
-		repoUrl := fmt.Sprintf("https://github.com/%v", repoFullName)
+		repoUrl := dg_github.GithubUrlsFromEnv().RepoUrl(repoFullName)
 		_, err := models.DB.GithubRepoAdded(installationId, appId, login, accountId, repoFullName)
 		if err != nil {
 			log.Printf("GithubRepoAdded failed, error: %v\n", err)
@@ -340,7 +359,7 @@ func handleInstallationCreatedEvent(installation *github.InstallationEvent) erro
 		repoFullName := *repo.FullName
 		repoOwner := strings.Split(*repo.FullName, "/")[0]
 		repoName := *repo.Name
-		repoUrl := fmt.Sprintf("https://github.com/%v", repoFullName)
+		repoUrl := dg_github.GithubUrlsFromEnv().RepoUrl(repoFullName)
 
 		log.Printf("Adding a new installation %d for repo: %s", installationId, repoFullName)
 		_, err := models.DB.GithubRepoAdded(installationId, appId, login, accountId, repoFullName)
@@ -426,7 +445,7 @@ func handlePushEvent(gh utils.GithubClientProvider, payload *github.PushEvent) e
 	return nil
 }
 
-func handlePullRequestEvent(gh utils.GithubClientProvider, payload *github.PullRequestEvent, ciBackendProvider ci_backends.CiBackendProvider) error {
+func handlePullRequestEvent(ctx context.Context, gh utils.GithubClientProvider, payload *github.PullRequestEvent, ciBackendProvider ci_backends.CiBackendProvider) error {
 	installationId := *payload.Installation.ID
 	repoName := *payload.Repo.Name
 	repoOwner := *payload.Repo.Owner.Login
@@ -502,6 +521,9 @@ func handlePullRequestEvent(gh utils.GithubClientProvider, payload *github.PullR
 			utils.InitCommentReporter(ghService, prNumber, fmt.Sprintf(":x: Failed perform lock action on project: %v %v", project.Name, err))
 			return fmt.Errorf("failed to perform lock action on project: %v, %v", project.Name, err)
 		}
+		if *diggerCommand == orchestrator.DiggerCommandUnlock {
+			recordWebhookAuditEvent(organisationId, "github:"+payload.Sender.GetLogin(), models.AuditActionProjectUnlock, fmt.Sprintf("repos/%v/projects/%v", repoFullName, project.Name))
+		}
 	}
 
 	// if commands are locking or unlocking we don't need to trigger any jobs
@@ -546,7 +568,7 @@ func handlePullRequestEvent(gh utils.GithubClientProvider, payload *github.PullR
 		impactedJobsMap[j.ProjectName] = j
 	}
 
-	batchId, _, err := utils.ConvertJobsToDiggerJobs(*diggerCommand, organisationId, impactedJobsMap, impactedProjectsMap, projectsGraph, installationId, branch, prNumber, repoOwner, repoName, repoFullName, commitSha, commentReporter.CommentId, diggerYmlStr)
+	batchId, _, err := utils.ConvertJobsToDiggerJobs(ctx, *diggerCommand, organisationId, impactedJobsMap, impactedProjectsMap, projectsGraph, installationId, branch, prNumber, repoOwner, repoName, repoFullName, commitSha, commentReporter.CommentId, diggerYmlStr)
 	if err != nil {
 		log.Printf("ConvertJobsToDiggerJobs error: %v", err)
 		utils.InitCommentReporter(ghService, prNumber, fmt.Sprintf(":x: ConvertJobsToDiggerJobs error: %v", err))
@@ -687,7 +709,7 @@ func getBatchType(jobs []orchestrator.Job) orchestrator_scheduler.DiggerBatchTyp
 	}
 }
 
-func handleIssueCommentEvent(gh utils.GithubClientProvider, payload *github.IssueCommentEvent, ciBackendProvider ci_backends.CiBackendProvider) error {
+func handleIssueCommentEvent(ctx context.Context, gh utils.GithubClientProvider, payload *github.IssueCommentEvent, ciBackendProvider ci_backends.CiBackendProvider) error {
 	installationId := *payload.Installation.ID
 	repoName := *payload.Repo.Name
 	repoOwner := *payload.Repo.Owner.Login
@@ -775,6 +797,9 @@ func handleIssueCommentEvent(gh utils.GithubClientProvider, payload *github.Issu
 			utils.InitCommentReporter(ghService, issueNumber, fmt.Sprintf(":x: Failed perform lock action on project: %v %v", project.Name, err))
 			return fmt.Errorf("failed perform lock action on project: %v %v", project.Name, err)
 		}
+		if *diggerCommand == orchestrator.DiggerCommandUnlock {
+			recordWebhookAuditEvent(orgId, "github:"+payload.Comment.GetUser().GetLogin(), models.AuditActionProjectUnlock, fmt.Sprintf("repos/%v/projects/%v", repoFullName, project.Name))
+		}
 	}
 
 	// if commands are locking or unlocking we don't need to trigger any jobs
@@ -823,7 +848,7 @@ func handleIssueCommentEvent(gh utils.GithubClientProvider, payload *github.Issu
 		impactedProjectsJobMap[j.ProjectName] = j
 	}
 
-	batchId, _, err := utils.ConvertJobsToDiggerJobs(*diggerCommand, orgId, impactedProjectsJobMap, impactedProjectsMap, projectsGraph, installationId, *branch, issueNumber, repoOwner, repoName, repoFullName, *commitSha, commentReporter.CommentId, diggerYmlStr)
+	batchId, _, err := utils.ConvertJobsToDiggerJobs(ctx, *diggerCommand, orgId, impactedProjectsJobMap, impactedProjectsMap, projectsGraph, installationId, *branch, issueNumber, repoOwner, repoName, repoFullName, *commitSha, commentReporter.CommentId, diggerYmlStr)
 	if err != nil {
 		log.Printf("ConvertJobsToDiggerJobs error: %v", err)
 		utils.InitCommentReporter(ghService, issueNumber, fmt.Sprintf(":x: ConvertJobsToDiggerJobs error: %v", err))
@@ -908,10 +933,17 @@ func PerformLockingActionFromCommand(prLock dg_locking.PullRequestLock, command
 			err = fmt.Errorf("failed to lock project: %v", err)
 		}
 	}
+	if err != nil && command != orchestrator.DiggerCommandUnlock {
+		// only look up the holder when locking failed so that the happy path doesn't pay for it
+		lockedBy, lockErr := prLock.InternalLock.GetLock(prLock.LockId())
+		if lockErr == nil && lockedBy != nil && *lockedBy != prLock.PrNumber {
+			metrics.LockContention.Inc()
+		}
+	}
 	return err
 }
 
-func TriggerDiggerJobs(ciBackend ci_backends.CiBackend, repoOwner string, repoName string, batchId *uuid.UUID, prNumber int, prService *dg_github.GithubService) error {
+func TriggerDiggerJobs(ciBackend ci_backends.CiBackend, repoOwner string, repoName string, batchId *uuid.UUID, prNumber int, prService orchestrator.PullRequestService) error {
 	_, err := models.DB.GetDiggerBatch(batchId)
 	if err != nil {
 		log.Printf("failed to get digger batch, %v\n", err)
@@ -1143,12 +1175,13 @@ func GithubReposPage(c *gin.Context) {
 // validation based on https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/generating-a-user-access-token-for-a-github-app , step 3
 func validateGithubCallback(clientId string, clientSecret string, code string, installationId int64) (bool, error) {
 	ctx := context.Background()
+	urls := dg_github.GithubUrlsFromEnv()
 	type OAuthAccessResponse struct {
 		AccessToken string `json:"access_token"`
 	}
 	httpClient := http.Client{}
 
-	reqURL := fmt.Sprintf("https://github.com/login/oauth/access_token?client_id=%s&client_secret=%s&code=%s", clientId, clientSecret, code)
+	reqURL := fmt.Sprintf("%s/login/oauth/access_token?client_id=%s&client_secret=%s&code=%s", urls.Web(), clientId, clientSecret, code)
 	req, err := http.NewRequest(http.MethodPost, reqURL, nil)
 	if err != nil {
 		return false, fmt.Errorf("could not create HTTP request: %v\n", err)
@@ -1173,7 +1206,10 @@ func validateGithubCallback(clientId string, clientSecret string, code string, i
 		&oauth2.Token{AccessToken: t.AccessToken},
 	)
 	tc := oauth2.NewClient(ctx, ts)
-	client := github.NewClient(tc)
+	client, err := urls.NewClient(tc)
+	if err != nil {
+		return false, err
+	}
 
 	installationIdMatch := false
 	// list all installations for the user
@@ -87,7 +87,7 @@ func GithubAppWebHookAfterMerge(c *gin.Context) {
 	//	}
 	case *github.PullRequestEvent:
 		log.Printf("Got pull request event for %d  IN APPLY AFTER MERGE", *event.PullRequest.ID)
-		err := handlePullRequestEvent(gh, event, nil)
+		err := handlePullRequestEvent(c.Request.Context(), gh, event, nil)
 		if err != nil {
 			log.Printf("handlePullRequestEvent error: %v", err)
 			c.String(http.StatusInternalServerError, err.Error())
@@ -210,7 +210,7 @@ func handlePushEventApplyAfterMerge(gh utils.GithubClientProvider, payload *gith
 			applyJob := applyJobs[i]
 			projectName := planJob.ProjectName
 
-			planJobToken, err := models.DB.CreateDiggerJobToken(orgId)
+			planJobToken, err := models.DB.CreateDiggerJobToken(orgId, repoFullName)
 			if err != nil {
 				log.Printf("Error creating job token: %v %v", projectName, err)
 				return fmt.Errorf("error creating job token")
@@ -223,7 +223,7 @@ func handlePushEventApplyAfterMerge(gh utils.GithubClientProvider, payload *gith
 
 			}
 
-			applyJobToken, err := models.DB.CreateDiggerJobToken(orgId)
+			applyJobToken, err := models.DB.CreateDiggerJobToken(orgId, repoFullName)
 			if err != nil {
 				log.Printf("Error creating job token: %v %v", projectName, err)
 				return fmt.Errorf("error creating job token")
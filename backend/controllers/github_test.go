@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
+	"html/template"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
@@ -11,6 +15,8 @@ import (
 	"github.com/diggerhq/digger/backend/utils"
 	configuration "github.com/diggerhq/digger/libs/digger_config"
 	orchestrator "github.com/diggerhq/digger/libs/orchestrator"
+	dg_github "github.com/diggerhq/digger/libs/orchestrator/github"
+	"github.com/gin-gonic/gin"
 	"github.com/google/go-github/v61/github"
 	"github.com/migueleliasweb/go-github-mock/src/mock"
 	"github.com/stretchr/testify/assert"
@@ -590,7 +596,7 @@ func setupSuite(tb testing.TB) (func(tb testing.TB), *models.Database) {
 	// migrate tables
 	err = gdb.AutoMigrate(&models.Policy{}, &models.Organisation{}, &models.Repo{}, &models.Project{}, &models.Token{},
 		&models.User{}, &models.ProjectRun{}, &models.GithubAppInstallation{}, &models.GithubApp{}, &models.GithubAppInstallationLink{},
-		&models.GithubDiggerJobLink{}, &models.DiggerJob{}, &models.DiggerJobParentLink{}, &models.JobToken{})
+		&models.GithubDiggerJobLink{}, &models.DiggerJob{}, &models.DiggerJobParentLink{}, &models.JobToken{}, &models.RoleBinding{}, &models.AuditLogEntry{})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -706,7 +712,7 @@ func TestGithubHandleIssueCommentEvent(t *testing.T) {
 	var payload github.IssueCommentEvent
 	err := json.Unmarshal([]byte(issueCommentPayload), &payload)
 	assert.NoError(t, err)
-	err = handleIssueCommentEvent(gh, &payload, nil)
+	err = handleIssueCommentEvent(context.Background(), gh, &payload, nil)
 	assert.NoError(t, err)
 
 	jobs, err := models.DB.GetPendingParentDiggerJobs(nil)
@@ -731,7 +737,7 @@ func TestJobsTreeWithOneJobsAndTwoProjects(t *testing.T) {
 	graph, err := configuration.CreateProjectDependencyGraph(projects)
 	assert.NoError(t, err)
 
-	_, result, err := utils.ConvertJobsToDiggerJobs("", 1, jobs, projectMap, graph, 41584295, "", 2, "diggerhq", "parallel_jobs_demo", "diggerhq/parallel_jobs_demo", "", 123, "test")
+	_, result, err := utils.ConvertJobsToDiggerJobs(context.Background(), "", 1, jobs, projectMap, graph, 41584295, "", 2, "diggerhq", "parallel_jobs_demo", "diggerhq/parallel_jobs_demo", "", 123, "test")
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(result))
 	parentLinks, err := models.DB.GetDiggerJobParentLinksChildId(&result["dev"].DiggerJobID)
@@ -760,7 +766,7 @@ func TestJobsTreeWithTwoDependantJobs(t *testing.T) {
 	projectMap["dev"] = project1
 	projectMap["prod"] = project2
 
-	_, result, err := utils.ConvertJobsToDiggerJobs("", 1, jobs, projectMap, graph, 123, "", 2, "", "", "test", "", 123, "test")
+	_, result, err := utils.ConvertJobsToDiggerJobs(context.Background(), "", 1, jobs, projectMap, graph, 123, "", 2, "", "", "test", "", 123, "test")
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(result))
 
@@ -793,7 +799,7 @@ func TestJobsTreeWithTwoIndependentJobs(t *testing.T) {
 	projectMap["dev"] = project1
 	projectMap["prod"] = project2
 
-	_, result, err := utils.ConvertJobsToDiggerJobs("", 1, jobs, projectMap, graph, 123, "", 2, "", "", "test", "", 123, "test")
+	_, result, err := utils.ConvertJobsToDiggerJobs(context.Background(), "", 1, jobs, projectMap, graph, 123, "", 2, "", "", "test", "", 123, "test")
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(result))
 	parentLinks, err := models.DB.GetDiggerJobParentLinksChildId(&result["dev"].DiggerJobID)
@@ -838,7 +844,7 @@ func TestJobsTreeWithThreeLevels(t *testing.T) {
 	projectMap["555"] = project5
 	projectMap["666"] = project6
 
-	_, result, err := utils.ConvertJobsToDiggerJobs("", 1, jobs, projectMap, graph, 123, "", 2, "", "", "test", "", 123, "test")
+	_, result, err := utils.ConvertJobsToDiggerJobs(context.Background(), "", 1, jobs, projectMap, graph, 123, "", 2, "", "", "test", "", 123, "test")
 	assert.NoError(t, err)
 	assert.Equal(t, 6, len(result))
 	parentLinks, err := models.DB.GetDiggerJobParentLinksChildId(&result["111"].DiggerJobID)
@@ -1065,3 +1071,71 @@ func TestGithubHandleInstallationCreatedEvent(t *testing.T) {
 	err = handleInstallationCreatedEvent(&event)
 	assert.NoError(t, err)
 }
+
+// enterpriseServer fakes a GitHub Enterprise Server host and points the github urls at it
+func enterpriseServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	t.Setenv("GITHUB_SERVER_URL", server.URL)
+	t.Setenv("GITHUB_API_URL", "")
+	t.Setenv("GITHUB_UPLOAD_URL", "")
+	return server
+}
+
+func TestGithubAppManifestTarget(t *testing.T) {
+	target, err := githubAppManifestTarget(dg_github.GithubUrls{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/settings/apps/new", target.String())
+
+	urls := dg_github.GithubUrls{WebUrl: "https://ghes.example.com"}
+	target, err = githubAppManifestTarget(urls, "platform")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ghes.example.com/organizations/platform/settings/apps/new", target.String())
+}
+
+func TestValidateGithubCallbackAgainstEnterpriseHost(t *testing.T) {
+	enterpriseServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/login/oauth/access_token":
+			assert.Equal(t, "the-code", r.URL.Query().Get("code"))
+			w.Write([]byte(`{"access_token": "user-token"}`))
+		case "/api/v3/user/installations":
+			assert.Equal(t, "Bearer user-token", r.Header.Get("Authorization"))
+			w.Write([]byte(`{"total_count": 1, "installations": [{"id": 42}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	valid, err := validateGithubCallback("client", "secret", "the-code", 42)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	valid, err = validateGithubCallback("client", "secret", "the-code", 43)
+	assert.Error(t, err)
+	assert.False(t, valid)
+}
+
+func TestGithubSetupExchangeCodeAgainstEnterpriseHost(t *testing.T) {
+	teardownSuite, _ := setupSuite(t)
+	defer teardownSuite(t)
+
+	var requestedPath string
+	enterpriseServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": 7, "name": "Digger app", "html_url": "https://ghes.example.com/apps/digger-app", "pem": "key", "client_id": "client", "client_secret": "secret", "webhook_secret": "whsec"}`))
+	})
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, engine := gin.CreateTestContext(w)
+	engine.SetHTMLTemplate(template.Must(template.New("github_setup.tmpl").Parse("{{.ID}} {{.URL}}")))
+	c.Request = httptest.NewRequest(http.MethodGet, "/github/exchange-code?code=the-code", nil)
+	GithubSetupExchangeCode(c)
+
+	assert.Equal(t, "/api/v3/app-manifests/the-code/conversions", requestedPath)
+	assert.Empty(t, c.Errors)
+	assert.Equal(t, "7 https://ghes.example.com/apps/digger-app", w.Body.String())
+}
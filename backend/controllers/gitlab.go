@@ -0,0 +1,390 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"github.com/diggerhq/digger/backend/ci_backends"
+	"github.com/diggerhq/digger/backend/locking"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/backend/segment"
+	"github.com/diggerhq/digger/backend/utils"
+	comment_updater "github.com/diggerhq/digger/libs/comment_utils/reporting"
+	dg_configuration "github.com/diggerhq/digger/libs/digger_config"
+	dg_locking "github.com/diggerhq/digger/libs/locking"
+	"github.com/diggerhq/digger/libs/orchestrator"
+	dg_gitlab "github.com/diggerhq/digger/libs/orchestrator/gitlab"
+	"github.com/diggerhq/digger/libs/tracing"
+	"github.com/dominikbraun/graph"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/xanzy/go-gitlab"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type GitlabController struct {
+	CiBackendProvider ci_backends.CiBackendProvider
+}
+
+// GitlabWebhookHandler handles merge request and note events of projects whose webhook is configured with
+// GITLAB_WEBHOOK_SECRET as the secret token
+func (g GitlabController) GitlabWebhookHandler(c *gin.Context) {
+	c.Header("Content-Type", "application/json")
+	log.Printf("GitlabWebhookHandler")
+
+	secret := os.Getenv("GITLAB_WEBHOOK_SECRET")
+	token := c.GetHeader("X-Gitlab-Token")
+	if secret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		log.Printf("Invalid gitlab webhook token")
+		c.String(http.StatusForbidden, "Invalid gitlab webhook token")
+		return
+	}
+
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		log.Printf("Error reading gitlab webhook's payload: %v", err)
+		c.String(http.StatusBadRequest, "Error reading gitlab webhook's payload")
+		return
+	}
+
+	eventType := gitlab.HookEventType(c.Request)
+	ctx, span := tracing.Tracer().Start(c.Request.Context(), "GitlabWebhookHandler", trace.WithAttributes(attribute.String("gitlab.event", string(eventType))))
+	defer span.End()
+	event, err := gitlab.ParseWebhook(eventType, payload)
+	if err != nil {
+		log.Printf("Failed to parse Gitlab Event. :%v\n", err)
+		c.String(http.StatusBadRequest, "Failed to parse Gitlab Event")
+		return
+	}
+
+	log.Printf("gitlab event type: %v\n", reflect.TypeOf(event))
+
+	switch event := event.(type) {
+	case *gitlab.MergeEvent:
+		log.Printf("Got merge request event for %v!%d", event.Project.PathWithNamespace, event.ObjectAttributes.IID)
+		err := handleGitlabMergeRequestEvent(ctx, event, g.CiBackendProvider)
+		if err != nil {
+			log.Printf("handleGitlabMergeRequestEvent error: %v", err)
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+	case *gitlab.MergeCommentEvent:
+		log.Printf("Got merge request note event for %v!%d", event.Project.PathWithNamespace, event.MergeRequest.IID)
+		err := handleGitlabNoteEvent(ctx, event, g.CiBackendProvider)
+		if err != nil {
+			log.Printf("handleGitlabNoteEvent error: %v", err)
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+	default:
+		log.Printf("Unhandled event, event type %v", reflect.TypeOf(event))
+	}
+
+	c.JSON(200, "ok")
+}
+
+// splitGitlabProjectPath splits group/subgroup/project into the namespace and the project path
+func splitGitlabProjectPath(pathWithNamespace string) (string, string) {
+	i := strings.LastIndex(pathWithNamespace, "/")
+	if i < 0 {
+		return "", pathWithNamespace
+	}
+	return pathWithNamespace[:i], pathWithNamespace[i+1:]
+}
+
+func getDiggerConfigForGitlabBranch(cloneUrl string, branch string) (string, *dg_configuration.DiggerConfig, graph.Graph[string, dg_configuration.Project], error) {
+	var config *dg_configuration.DiggerConfig
+	var diggerYmlStr string
+	var dependencyGraph graph.Graph[string, dg_configuration.Project]
+	err := utils.CloneGitRepoAndDoAction(cloneUrl, branch, os.Getenv("GITLAB_TOKEN"), func(dir string) error {
+		diggerYmlBytes, err := os.ReadFile(path.Join(dir, "digger.yml"))
+		diggerYmlStr = string(diggerYmlBytes)
+		config, _, dependencyGraph, err = dg_configuration.LoadDiggerConfig(dir, true)
+		if err != nil {
+			log.Printf("Error loading digger config: %v", err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error generating projects: %v", err)
+		return "", nil, nil, fmt.Errorf("error generating projects")
+	}
+
+	log.Printf("Digger config loadded successfully\n")
+	return diggerYmlStr, config, dependencyGraph, nil
+}
+
+// markBatchAsGitlab records that the batch was created for a merge request so that status updates, comments and
+// follow up jobs go to gitlab
+func markBatchAsGitlab(batchId *uuid.UUID) error {
+	batch, err := models.DB.GetDiggerBatch(batchId)
+	if err != nil {
+		return fmt.Errorf("error getting digger batch: %v", err)
+	}
+	batch.VCS = models.DiggerVCSGitlab
+	return models.DB.UpdateDiggerBatch(batch)
+}
+
+// performGitlabLockingActions locks or unlocks the impacted projects in the backend, it returns true if the command
+// was a lock or unlock and no jobs need to run
+func performGitlabLockingActions(orgId uint, glService *dg_gitlab.GitLabService, diggerCommand orchestrator.DiggerCommand, impactedProjects []dg_configuration.Project, repoFullName string, prNumber int, actor string) (bool, error) {
+	for _, project := range impactedProjects {
+		prLock := dg_locking.PullRequestLock{
+			InternalLock: locking.BackendDBLock{
+				OrgId: orgId,
+			},
+			CIService:        glService,
+			Reporter:         comment_updater.NoopReporter{},
+			ProjectName:      project.Name,
+			ProjectNamespace: repoFullName,
+			PrNumber:         prNumber,
+		}
+		err := PerformLockingActionFromCommand(prLock, diggerCommand)
+		if err != nil {
+			utils.InitCommentReporter(glService, prNumber, fmt.Sprintf(":x: Failed perform lock action on project: %v %v", project.Name, err))
+			return false, fmt.Errorf("failed to perform lock action on project: %v, %v", project.Name, err)
+		}
+		if diggerCommand == orchestrator.DiggerCommandUnlock {
+			recordWebhookAuditEvent(orgId, "gitlab:"+actor, models.AuditActionProjectUnlock, fmt.Sprintf("repos/%v/projects/%v", repoFullName, project.Name))
+		}
+	}
+
+	if diggerCommand == orchestrator.DiggerCommandUnlock || diggerCommand == orchestrator.DiggerCommandLock {
+		utils.InitCommentReporter(glService, prNumber, fmt.Sprintf(":white_check_mark: Command %v completed successfully", diggerCommand))
+		return true, nil
+	}
+	return false, nil
+}
+
+// scheduleGitlabJobs stores the jobs as a batch of the merge request and triggers the ones without dependencies
+func scheduleGitlabJobs(ctx context.Context, ciBackendProvider ci_backends.CiBackendProvider, glService *dg_gitlab.GitLabService, orgId uint, diggerCommand orchestrator.DiggerCommand, jobs []orchestrator.Job, impactedProjects []dg_configuration.Project, projectsGraph graph.Graph[string, dg_configuration.Project], diggerYmlStr string, repoFullName string, prNumber int, branch string, commitSha string) error {
+	repoOwner, repoName := splitGitlabProjectPath(repoFullName)
+
+	commentReporter, err := utils.InitCommentReporter(glService, prNumber, ":construction_worker: Digger starting...")
+	if err != nil {
+		log.Printf("Error initializing comment reporter: %v", err)
+		return fmt.Errorf("error initializing comment reporter")
+	}
+
+	err = utils.ReportInitialJobsStatus(commentReporter, jobs)
+	if err != nil {
+		log.Printf("Failed to comment initial status for jobs: %v", err)
+		utils.InitCommentReporter(glService, prNumber, fmt.Sprintf(":x: Failed to comment initial status for jobs: %v", err))
+		return fmt.Errorf("failed to comment initial status for jobs")
+	}
+
+	err = utils.SetPRStatusForJobs(glService, prNumber, jobs)
+	if err != nil {
+		log.Printf("error setting status for MR: %v", err)
+		utils.InitCommentReporter(glService, prNumber, fmt.Sprintf(":x: error setting status for MR: %v", err))
+	}
+
+	impactedProjectsMap := make(map[string]dg_configuration.Project)
+	for _, p := range impactedProjects {
+		impactedProjectsMap[p.Name] = p
+	}
+
+	impactedJobsMap := make(map[string]orchestrator.Job)
+	for _, j := range jobs {
+		impactedJobsMap[j.ProjectName] = j
+	}
+
+	batchId, _, err := utils.ConvertJobsToDiggerJobs(ctx, diggerCommand, orgId, impactedJobsMap, impactedProjectsMap, projectsGraph, 0, branch, prNumber, repoOwner, repoName, repoFullName, commitSha, commentReporter.CommentId, diggerYmlStr)
+	if err != nil {
+		log.Printf("ConvertJobsToDiggerJobs error: %v", err)
+		utils.InitCommentReporter(glService, prNumber, fmt.Sprintf(":x: ConvertJobsToDiggerJobs error: %v", err))
+		return fmt.Errorf("error converting jobs")
+	}
+
+	err = markBatchAsGitlab(batchId)
+	if err != nil {
+		log.Printf("markBatchAsGitlab error: %v", err)
+		utils.InitCommentReporter(glService, prNumber, fmt.Sprintf(":x: UpdateDiggerBatch error: %v", err))
+		return fmt.Errorf("error updating digger batch")
+	}
+
+	segment.Track(strconv.Itoa(int(orgId)), "backend_trigger_job")
+
+	ciBackend, err := ciBackendProvider.GetCiBackend(
+		ci_backends.CiBackendOptions{
+			RepoName:     repoName,
+			RepoOwner:    repoOwner,
+			RepoFullName: repoFullName,
+			VCS:          models.DiggerVCSGitlab,
+		},
+	)
+	if err != nil {
+		log.Printf("GetCiBackend error: %v", err)
+		utils.InitCommentReporter(glService, prNumber, fmt.Sprintf(":x: GetCiBackend error: %v", err))
+		return fmt.Errorf("error fetching ci backed %v", err)
+	}
+
+	err = TriggerDiggerJobs(ciBackend, repoOwner, repoName, batchId, prNumber, glService)
+	if err != nil {
+		log.Printf("TriggerDiggerJobs error: %v", err)
+		utils.InitCommentReporter(glService, prNumber, fmt.Sprintf(":x: TriggerDiggerJobs error: %v", err))
+		return fmt.Errorf("error triggerring Digger Jobs")
+	}
+	return nil
+}
+
+func handleGitlabMergeRequestEvent(ctx context.Context, payload *gitlab.MergeEvent, ciBackendProvider ci_backends.CiBackendProvider) error {
+	repoFullName := payload.Project.PathWithNamespace
+	prNumber := payload.ObjectAttributes.IID
+	branch := payload.ObjectAttributes.SourceBranch
+	commitSha := payload.ObjectAttributes.LastCommit.ID
+	isDraft := payload.ObjectAttributes.Draft || payload.ObjectAttributes.WorkInProgress
+
+	org, err := utils.GitlabOrganisation()
+	if err != nil {
+		log.Printf("Error getting gitlab organisation: %v", err)
+		return fmt.Errorf("error getting gitlab organisation")
+	}
+
+	glService, err := utils.GetGitlabService(repoFullName)
+	if err != nil {
+		log.Printf("Error getting gitlab service: %v", err)
+		return fmt.Errorf("error getting gitlab service")
+	}
+
+	diggerYmlStr, config, projectsGraph, err := getDiggerConfigForGitlabBranch(payload.Project.GitHTTPURL, branch)
+	if err != nil {
+		log.Printf("getDiggerConfigForGitlabBranch error: %v", err)
+		return fmt.Errorf("error getting digger config")
+	}
+
+	impactedProjects, _, err := dg_gitlab.ProcessGitLabMergeRequestEvent(payload, config, projectsGraph, glService)
+	if err != nil {
+		log.Printf("Error processing event: %v", err)
+		utils.InitCommentReporter(glService, prNumber, fmt.Sprintf(":x: Error processing event: %v", err))
+		return fmt.Errorf("error processing event")
+	}
+
+	jobs, err := dg_gitlab.ConvertGitLabMergeRequestEventToJobs(payload, impactedProjects, *config)
+	if err != nil {
+		log.Printf("Error converting event to jobs: %v", err)
+		utils.InitCommentReporter(glService, prNumber, fmt.Sprintf(":x: Error converting event to jobs: %v", err))
+		return fmt.Errorf("error converting event to jobs")
+	}
+
+	if len(jobs) == 0 {
+		log.Printf("No projects impacted; not starting any jobs")
+		utils.SetPRStatusForJobs(glService, prNumber, jobs)
+		return nil
+	}
+
+	diggerCommand, err := orchestrator.GetCommandFromJob(jobs[0])
+	if err != nil {
+		log.Printf("could not determine digger command from job: %v", jobs[0].Commands)
+		utils.InitCommentReporter(glService, prNumber, fmt.Sprintf(":x: could not determine digger command from job: %v", err))
+		return fmt.Errorf("unkown digger command in job %v", err)
+	}
+
+	if *diggerCommand == orchestrator.DiggerCommandNoop {
+		log.Printf("job is of type noop, no actions top perform")
+		return nil
+	}
+
+	done, err := performGitlabLockingActions(org.ID, glService, *diggerCommand, impactedProjects, repoFullName, prNumber, payload.User.Username)
+	if err != nil || done {
+		return err
+	}
+
+	if !config.AllowDraftPRs && isDraft {
+		log.Printf("Draft MRs are disabled, skipping MR: %v", prNumber)
+		return nil
+	}
+
+	return scheduleGitlabJobs(ctx, ciBackendProvider, glService, org.ID, *diggerCommand, jobs, impactedProjects, projectsGraph, diggerYmlStr, repoFullName, prNumber, branch, commitSha)
+}
+
+func handleGitlabNoteEvent(ctx context.Context, payload *gitlab.MergeCommentEvent, ciBackendProvider ci_backends.CiBackendProvider) error {
+	repoFullName := payload.Project.PathWithNamespace
+	prNumber := payload.MergeRequest.IID
+	branch := payload.MergeRequest.SourceBranch
+	commitSha := payload.MergeRequest.LastCommit.ID
+	comment := strings.TrimSpace(payload.ObjectAttributes.Note)
+
+	if payload.ObjectAttributes.System {
+		log.Printf("note is a system note, ignoring")
+		return nil
+	}
+
+	if !strings.HasPrefix(comment, "digger") {
+		log.Printf("note is not a Digger command, ignoring")
+		return nil
+	}
+
+	org, err := utils.GitlabOrganisation()
+	if err != nil {
+		log.Printf("Error getting gitlab organisation: %v", err)
+		return fmt.Errorf("error getting gitlab organisation")
+	}
+
+	glService, err := utils.GetGitlabService(repoFullName)
+	if err != nil {
+		log.Printf("Error getting gitlab service: %v", err)
+		return fmt.Errorf("error getting gitlab service")
+	}
+
+	diggerYmlStr, config, projectsGraph, err := getDiggerConfigForGitlabBranch(payload.Project.GitHTTPURL, branch)
+	if err != nil {
+		log.Printf("getDiggerConfigForGitlabBranch error: %v", err)
+		return fmt.Errorf("error getting digger config")
+	}
+
+	err = glService.CreateCommentReaction(dg_gitlab.MergeRequestNote{MergeRequestIID: prNumber, NoteID: payload.ObjectAttributes.ID}, "eyes")
+	if err != nil {
+		log.Printf("CreateCommentReaction error: %v", err)
+	}
+
+	if !config.AllowDraftPRs && payload.MergeRequest.WorkInProgress {
+		log.Printf("AllowDraftPRs is disabled, skipping MR: %v", prNumber)
+		return nil
+	}
+
+	diggerCommand, err := orchestrator.GetCommandFromComment(comment)
+	if err != nil {
+		log.Printf("unkown digger command in note: %v", comment)
+		utils.InitCommentReporter(glService, prNumber, fmt.Sprintf(":x: Could not recognise comment, error: %v", err))
+		return fmt.Errorf("unkown digger command in comment %v", err)
+	}
+
+	impactedProjects, _, requestedProject, err := dg_gitlab.ProcessGitLabNoteEvent(payload, config, projectsGraph, glService)
+	if err != nil {
+		log.Printf("Error processing event: %v", err)
+		utils.InitCommentReporter(glService, prNumber, fmt.Sprintf(":x: Error processing event: %v", err))
+		return fmt.Errorf("error processing event")
+	}
+
+	done, err := performGitlabLockingActions(org.ID, glService, *diggerCommand, impactedProjects, repoFullName, prNumber, payload.User.Username)
+	if err != nil || done {
+		return err
+	}
+
+	jobs, _, err := dg_gitlab.ConvertGitLabNoteEventToJobs(payload, impactedProjects, requestedProject, config.Workflows)
+	if err != nil {
+		log.Printf("Error converting event to jobs: %v", err)
+		utils.InitCommentReporter(glService, prNumber, fmt.Sprintf(":x: Error converting event to jobs: %v", err))
+		return fmt.Errorf("error converting event to jobs")
+	}
+
+	if len(jobs) == 0 {
+		log.Printf("no projects impacated, succeeding")
+		utils.InitCommentReporter(glService, prNumber, ":white_check_mark: No projects impacted")
+		utils.SetPRStatusForJobs(glService, prNumber, jobs)
+		return nil
+	}
+
+	return scheduleGitlabJobs(ctx, ciBackendProvider, glService, org.ID, *diggerCommand, jobs, impactedProjects, projectsGraph, diggerYmlStr, repoFullName, prNumber, branch, commitSha)
+}
@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diggerhq/digger/backend/ci_backends"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+)
+
+type triggeredJob struct {
+	repoOwner string
+	repoName  string
+	job       models.DiggerJob
+}
+
+type recordingCiBackend struct {
+	triggered *[]triggeredJob
+}
+
+func (r recordingCiBackend) TriggerWorkflow(repoOwner string, repoName string, job models.DiggerJob, jobString string, commentId int64) error {
+	*r.triggered = append(*r.triggered, triggeredJob{repoOwner, repoName, job})
+	return nil
+}
+
+type recordingCiBackendProvider struct {
+	options   *ci_backends.CiBackendOptions
+	triggered *[]triggeredJob
+}
+
+func (r recordingCiBackendProvider) GetCiBackend(options ci_backends.CiBackendOptions) (ci_backends.CiBackend, error) {
+	*r.options = options
+	return recordingCiBackend{r.triggered}, nil
+}
+
+func gitlabWebhookRequest(t *testing.T, controller GitlabController, token string, event string, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/gitlab-webhook", controller.GitlabWebhookHandler)
+	req := httptest.NewRequest(http.MethodPost, "/gitlab-webhook", bytes.NewBufferString(body))
+	req.Header.Set("X-Gitlab-Event", event)
+	if token != "" {
+		req.Header.Set("X-Gitlab-Token", token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestGitlabWebhookRejectsInvalidToken(t *testing.T) {
+	controller := GitlabController{}
+
+	t.Setenv("GITLAB_WEBHOOK_SECRET", "")
+	assert.Equal(t, http.StatusForbidden, gitlabWebhookRequest(t, controller, "", "Merge Request Hook", "{}").Code)
+
+	t.Setenv("GITLAB_WEBHOOK_SECRET", "secret")
+	assert.Equal(t, http.StatusForbidden, gitlabWebhookRequest(t, controller, "", "Merge Request Hook", "{}").Code)
+	assert.Equal(t, http.StatusForbidden, gitlabWebhookRequest(t, controller, "wrong", "Merge Request Hook", "{}").Code)
+	assert.Equal(t, http.StatusBadRequest, gitlabWebhookRequest(t, controller, "secret", "Merge Request Hook", "not json").Code)
+}
+
+// gitlabTestRepo creates a repository with a digger.yml on the feature branch and returns its path
+func gitlabTestRepo(t *testing.T) string {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	assert.NoError(t, err)
+	files := map[string]string{
+		"digger.yml":   "projects:\n- name: dev\n  dir: dev\n- name: prod\n  dir: prod\n  depends_on: [\"dev\"]\n",
+		"dev/main.tf":  "",
+		"prod/main.tf": "",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.MkdirAll(path.Dir(path.Join(dir, name)), 0755))
+		assert.NoError(t, os.WriteFile(path.Join(dir, name), []byte(content), 0644))
+	}
+	worktree, err := repo.Worktree()
+	assert.NoError(t, err)
+	assert.NoError(t, worktree.AddGlob("."))
+	_, err = worktree.Commit("init", &git.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}})
+	assert.NoError(t, err)
+	assert.NoError(t, worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true}))
+	return dir
+}
+
+func TestGitlabMergeRequestEventCreatesBatch(t *testing.T) {
+	teardownSuite, _ := setupSuite(t)
+	defer teardownSuite(t)
+	assert.NoError(t, models.DB.GormDB.AutoMigrate(&models.DiggerBatch{}, &models.DiggerLock{}))
+
+	requests := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.EscapedPath())
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "GET /api/v4/projects/group%2Fsub%2Fdemo/merge_requests/3/diffs":
+			w.Write([]byte(`[{"old_path": "dev/main.tf", "new_path": "dev/main.tf"}, {"old_path": "prod/main.tf", "new_path": "prod/main.tf"}]`))
+		case "GET /api/v4/projects/group%2Fsub%2Fdemo/merge_requests/3":
+			w.Write([]byte(`{"iid": 3, "sha": "abc123", "source_branch": "feature"}`))
+		default:
+			w.Write([]byte(`{"id": 1}`))
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("GITLAB_WEBHOOK_SECRET", "secret")
+	t.Setenv("GITLAB_TOKEN", "")
+	t.Setenv("GITLAB_BASE_URL", server.URL)
+	t.Setenv("DIGGER_GITLAB_ORGANISATION", "11111111-1111-1111-1111-111111111111")
+
+	payload := map[string]interface{}{
+		"object_kind": "merge_request",
+		"user":        map[string]interface{}{"username": "alice"},
+		"project": map[string]interface{}{
+			"path_with_namespace": "group/sub/demo",
+			"default_branch":      "main",
+			"git_http_url":        gitlabTestRepo(t),
+		},
+		"object_attributes": map[string]interface{}{
+			"iid":           3,
+			"action":        "open",
+			"source_branch": "feature",
+			"target_branch": "main",
+			"last_commit":   map[string]interface{}{"id": "abc123"},
+		},
+	}
+	body, err := json.Marshal(payload)
+	assert.NoError(t, err)
+
+	// GetGitlabService refuses to run without a token, the local clone doesn't need one
+	options := ci_backends.CiBackendOptions{}
+	triggered := make([]triggeredJob, 0)
+	controller := GitlabController{CiBackendProvider: recordingCiBackendProvider{&options, &triggered}}
+	w := gitlabWebhookRequest(t, controller, "secret", "Merge Request Hook", string(body))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	t.Setenv("GITLAB_TOKEN", "token")
+	w = gitlabWebhookRequest(t, controller, "secret", "Merge Request Hook", string(body))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	assert.Equal(t, models.DiggerVCSGitlab, options.VCS)
+	assert.Equal(t, "group/sub", options.RepoOwner)
+	assert.Equal(t, "demo", options.RepoName)
+
+	// prod depends on dev so only dev starts
+	assert.Equal(t, 1, len(triggered))
+	assert.Equal(t, "group/sub", triggered[0].repoOwner)
+	assert.Equal(t, "demo", triggered[0].repoName)
+	batch, err := models.DB.GetDiggerBatch(&triggered[0].job.Batch.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DiggerVCSGitlab, batch.VCS)
+	assert.Equal(t, "group/sub/demo", batch.RepoFullName)
+	assert.Equal(t, 3, batch.PrNumber)
+	assert.Equal(t, "feature", batch.BranchName)
+
+	// one status per project and the aggregate one
+	statuses := 0
+	for _, request := range requests {
+		if strings.HasPrefix(request, "POST /api/v4/projects/group%2Fsub%2Fdemo/statuses/") {
+			statuses++
+		}
+	}
+	assert.Equal(t, 3, statuses)
+}
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggerhq/digger/backend/middleware"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/backend/webhooks"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type CreateWebhookRequest struct {
+	Url    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret is generated when empty
+	Secret string `json:"secret"`
+}
+
+func (r CreateWebhookRequest) Validate() error {
+	u, err := url.Parse(r.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https url")
+	}
+	for _, event := range r.Events {
+		known := false
+		for _, eventType := range webhooks.EventTypes {
+			if event == eventType {
+				known = true
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown event type: %v", event)
+		}
+	}
+	return nil
+}
+
+func ListWebhooks(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+
+	orgWebhooks, err := models.DB.GetOrgWebhooks(orgId)
+	if err != nil {
+		log.Printf("Error fetching webhooks: %v", err)
+		c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+		return
+	}
+
+	response := make([]interface{}, 0)
+	for _, w := range orgWebhooks {
+		response = append(response, w.MapToJsonStruct())
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateWebhook registers a webhook, the secret is only returned in this response
+func CreateWebhook(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+
+	var request CreateWebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := request.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret := request.Secret
+	if secret == "" {
+		var err error
+		secret, err = webhooks.GenerateSecret()
+		if err != nil {
+			log.Printf("Error generating webhook secret: %v", err)
+			c.String(http.StatusInternalServerError, "Error creating webhook")
+			return
+		}
+	}
+
+	webhook, err := models.DB.CreateOrgWebhook(orgId.(uint), request.Url, secret, request.Events)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error creating webhook")
+		return
+	}
+	recordAuditEvent(c, webhook.OrganisationID, models.AuditActionWebhookCreate, fmt.Sprintf("webhooks/%v", webhook.ID), webhook.Url)
+	c.JSON(http.StatusCreated, gin.H{
+		"webhook": webhook.MapToJsonStruct(),
+		"secret":  secret,
+	})
+}
+
+func DeleteWebhook(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+
+	webhookId, err := strconv.Atoi(c.Param("webhookId"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid webhook id")
+		return
+	}
+
+	err = models.DB.DeleteOrgWebhook(orgId, uint(webhookId))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.String(http.StatusNotFound, "Could not find webhook")
+		} else {
+			log.Printf("Error deleting webhook: %v", err)
+			c.String(http.StatusInternalServerError, "Error deleting webhook")
+		}
+		return
+	}
+	recordAuditEvent(c, orgId.(uint), models.AuditActionWebhookDelete, fmt.Sprintf("webhooks/%v", webhookId), "")
+	c.Status(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries returns the most recent deliveries of a webhook, newest first
+func ListWebhookDeliveries(c *gin.Context) {
+	webhook, ok := webhookFromParam(c)
+	if !ok {
+		return
+	}
+
+	limit := 100
+	if c.Query("limit") != "" {
+		l, err := strconv.Atoi(c.Query("limit"))
+		if err != nil || l <= 0 {
+			c.String(http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = l
+	}
+
+	deliveries, err := models.DB.GetWebhookDeliveries(webhook.ID, limit)
+	if err != nil {
+		log.Printf("Error fetching webhook deliveries: %v", err)
+		c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+		return
+	}
+
+	response := make([]interface{}, 0)
+	for _, d := range deliveries {
+		response = append(response, d.MapToJsonStruct())
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RedeliverWebhookDelivery attempts a delivery again right away regardless of its status, restarting its retry schedule
+func RedeliverWebhookDelivery(c *gin.Context) {
+	webhook, ok := webhookFromParam(c)
+	if !ok {
+		return
+	}
+
+	deliveryId, err := strconv.Atoi(c.Param("deliveryId"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid delivery id")
+		return
+	}
+
+	delivery, err := models.DB.GetWebhookDelivery(webhook.ID, uint(deliveryId))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.String(http.StatusNotFound, "Could not find delivery")
+		} else {
+			log.Printf("Error fetching webhook delivery: %v", err)
+			c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+		}
+		return
+	}
+
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.Attempts = 0
+	webhooks.Deliver(delivery)
+	c.JSON(http.StatusOK, delivery.MapToJsonStruct())
+}
+
+func webhookFromParam(c *gin.Context) (*models.OrgWebhook, bool) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return nil, false
+	}
+
+	webhookId, err := strconv.Atoi(c.Param("webhookId"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid webhook id")
+		return nil, false
+	}
+
+	webhook, err := models.DB.GetOrgWebhook(orgId, uint(webhookId))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.String(http.StatusNotFound, "Could not find webhook")
+		} else {
+			log.Printf("Error fetching webhook: %v", err)
+			c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+		}
+		return nil, false
+	}
+	return webhook, true
+}
@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/diggerhq/digger/backend/blobstore"
+	"github.com/diggerhq/digger/backend/middleware"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/backend/services"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// maxPlanSize caps the size of uploaded plan files
+const maxPlanSize = 256 << 20
+
+// planPath returns the repo and the stored plan path of the request, both are part of the blob key so they have to be
+// valid keys on their own
+func planPath(c *gin.Context) (string, string, bool) {
+	repo := c.Param("repo")
+	if err := blobstore.ValidateKey(repo); err != nil {
+		c.String(http.StatusBadRequest, "Invalid repo")
+		return "", "", false
+	}
+	path := strings.TrimPrefix(c.Param("planPath"), "/")
+	if err := blobstore.ValidateKey(path); err != nil {
+		c.String(http.StatusBadRequest, "Invalid plan path")
+		return "", "", false
+	}
+	return repo, path, true
+}
+
+func planStore(c *gin.Context) (blobstore.Store, bool) {
+	store, err := services.GetPlanStore()
+	if err != nil {
+		log.Printf("Error getting plan store: %v", err)
+		c.String(http.StatusInternalServerError, "Plan storage is not configured")
+		return nil, false
+	}
+	return store, true
+}
+
+// UploadPlan stores the request body as the plan at the path of the repo, replacing an earlier upload
+func UploadPlan(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+	repo, path, ok := planPath(c)
+	if !ok {
+		return
+	}
+	store, ok := planStore(c)
+	if !ok {
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPlanSize))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			c.String(http.StatusRequestEntityTooLarge, "Plan is larger than %v bytes", maxPlanSize)
+			return
+		}
+		c.String(http.StatusBadRequest, "Could not read plan")
+		return
+	}
+
+	err = store.Put(c, services.PlanArtifactKey(orgId.(uint), repo, path), data)
+	if err != nil {
+		log.Printf("Error storing plan %v: %v", path, err)
+		c.String(http.StatusInternalServerError, "Error storing plan")
+		return
+	}
+	hash := sha256.Sum256(data)
+	artifact, err := models.DB.UpsertPlanArtifact(orgId.(uint), repo, path, c.Query("artifact"), int64(len(data)), hex.EncodeToString(hash[:]))
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error recording plan")
+		return
+	}
+	c.JSON(http.StatusOK, artifact.MapToJsonStruct())
+}
+
+func DownloadPlan(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+	repo, path, ok := planPath(c)
+	if !ok {
+		return
+	}
+	artifact, err := models.DB.GetPlanArtifact(orgId, repo, path)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+		return
+	}
+	if artifact == nil {
+		c.String(http.StatusNotFound, "Plan not found")
+		return
+	}
+	store, ok := planStore(c)
+	if !ok {
+		return
+	}
+
+	data, err := store.Get(c, services.PlanArtifactKey(artifact.OrganisationID, artifact.Repo, artifact.Path))
+	if errors.Is(err, blobstore.ErrNotFound) {
+		c.String(http.StatusNotFound, "Plan not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading plan %v: %v", path, err)
+		c.String(http.StatusInternalServerError, "Error reading plan")
+		return
+	}
+	c.Header("X-Plan-Sha256", artifact.Sha256)
+	c.Data(http.StatusOK, "application/octet-stream", data)
+}
+
+// PlanExists answers a HEAD request with 200 if the plan is stored and 404 otherwise
+func PlanExists(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+	repo, path, ok := planPath(c)
+	if !ok {
+		return
+	}
+	artifact, err := models.DB.GetPlanArtifact(orgId, repo, path)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+		return
+	}
+	if artifact == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.Header("X-Plan-Sha256", artifact.Sha256)
+	c.Status(http.StatusOK)
+}
+
+func DeletePlan(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+	repo, path, ok := planPath(c)
+	if !ok {
+		return
+	}
+	artifact, err := models.DB.GetPlanArtifact(orgId, repo, path)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+		return
+	}
+	if artifact == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	store, ok := planStore(c)
+	if !ok {
+		return
+	}
+
+	err = store.Delete(c, services.PlanArtifactKey(artifact.OrganisationID, artifact.Repo, artifact.Path))
+	if err != nil {
+		log.Printf("Error deleting plan %v: %v", path, err)
+		c.String(http.StatusInternalServerError, "Error deleting plan")
+		return
+	}
+	err = models.DB.DeletePlanArtifact(artifact)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error deleting plan")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+type PlanStorageSettings struct {
+	// RetentionDays of nil restores the default of the backend
+	RetentionDays *int `json:"retention_days"`
+}
+
+func GetPlanStorageSettings(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+	org, err := models.DB.GetOrganisationById(orgId)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"retention_days":           org.PlanRetentionDays,
+		"effective_retention_days": services.PlanRetentionDays(org),
+	})
+}
+
+func UpdatePlanStorageSettings(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+	var request PlanStorageSettings
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.RetentionDays != nil && *request.RetentionDays < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "retention_days must be at least 1"})
+		return
+	}
+	org, err := models.DB.GetOrganisationById(orgId)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+		return
+	}
+
+	previous := fmt.Sprintf("%v", services.PlanRetentionDays(org))
+	err = models.DB.UpdateOrgPlanRetention(org, request.RetentionDays)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error updating plan storage settings")
+		return
+	}
+	recordAuditEvent(c, org.ID, models.AuditActionPlanRetentionUpdate, "plan-storage/settings", fmt.Sprintf("%v -> %v days", previous, services.PlanRetentionDays(org)))
+	c.JSON(http.StatusOK, gin.H{
+		"retention_days":           org.PlanRetentionDays,
+		"effective_retention_days": services.PlanRetentionDays(org),
+	})
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/diggerhq/digger/backend/blobstore"
+	"github.com/diggerhq/digger/backend/middleware"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/backend/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func planRequest(orgId uint, method string, path string, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(middleware.ORGANISATION_ID_KEY, orgId)
+	})
+	r.PUT("/repos/:repo/plans/*planPath", UploadPlan)
+	r.GET("/repos/:repo/plans/*planPath", DownloadPlan)
+	r.HEAD("/repos/:repo/plans/*planPath", PlanExists)
+	r.DELETE("/repos/:repo/plans/*planPath", DeletePlan)
+	r.PUT("/plan-storage/settings", UpdatePlanStorageSettings)
+
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func setupPlanTest(t *testing.T) (func(tb testing.TB), *models.Organisation, *models.Organisation) {
+	teardownSuite, database := setupSuite(t)
+	assert.NoError(t, database.GormDB.AutoMigrate(&models.PlanArtifact{}, &models.AuditLogEntry{}))
+	services.PlanStore = &blobstore.LocalStore{Dir: t.TempDir()}
+
+	org, err := database.GetOrganisation("11111111-1111-1111-1111-111111111111")
+	assert.NoError(t, err)
+	otherOrg, err := database.CreateOrganisation("otherOrg", "test", "22222222-2222-2222-2222-222222222222")
+	assert.NoError(t, err)
+	return teardownSuite, org, otherOrg
+}
+
+func TestPlansAreStoredPerOrg(t *testing.T) {
+	teardownSuite, org, otherOrg := setupPlanTest(t)
+	defer teardownSuite(t)
+
+	assert.Equal(t, http.StatusNotFound, planRequest(org.ID, "HEAD", "/repos/diggerhq-demo/plans/digger-1-prod.tfplan", "").Code)
+	assert.Equal(t, http.StatusNotFound, planRequest(org.ID, "GET", "/repos/diggerhq-demo/plans/digger-1-prod.tfplan", "").Code)
+
+	w := planRequest(org.ID, "PUT", "/repos/diggerhq-demo/plans/digger-1-prod.tfplan?artifact=plan-prod", "plan")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"artifact_name":"plan-prod"`)
+	assert.Equal(t, http.StatusOK, planRequest(org.ID, "PUT", "/repos/diggerhq-demo/plans/digger-1-prod.tfplan", "new plan").Code)
+
+	assert.Equal(t, http.StatusOK, planRequest(org.ID, "HEAD", "/repos/diggerhq-demo/plans/digger-1-prod.tfplan", "").Code)
+	w = planRequest(org.ID, "GET", "/repos/diggerhq-demo/plans/digger-1-prod.tfplan", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "new plan", w.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, planRequest(otherOrg.ID, "HEAD", "/repos/diggerhq-demo/plans/digger-1-prod.tfplan", "").Code)
+	assert.Equal(t, http.StatusNotFound, planRequest(otherOrg.ID, "GET", "/repos/diggerhq-demo/plans/digger-1-prod.tfplan", "").Code)
+	assert.Equal(t, http.StatusNoContent, planRequest(otherOrg.ID, "DELETE", "/repos/diggerhq-demo/plans/digger-1-prod.tfplan", "").Code)
+	assert.Equal(t, http.StatusOK, planRequest(org.ID, "HEAD", "/repos/diggerhq-demo/plans/digger-1-prod.tfplan", "").Code)
+
+	assert.Equal(t, http.StatusBadRequest, planRequest(org.ID, "PUT", "/repos/diggerhq-demo/plans/a/../b.tfplan", "plan").Code)
+
+	assert.Equal(t, http.StatusNoContent, planRequest(org.ID, "DELETE", "/repos/diggerhq-demo/plans/digger-1-prod.tfplan", "").Code)
+	assert.Equal(t, http.StatusNotFound, planRequest(org.ID, "GET", "/repos/diggerhq-demo/plans/digger-1-prod.tfplan", "").Code)
+}
+
+func TestExpiredPlansAreDeleted(t *testing.T) {
+	teardownSuite, org, otherOrg := setupPlanTest(t)
+	defer teardownSuite(t)
+
+	assert.Equal(t, http.StatusBadRequest, planRequest(org.ID, "PUT", "/plan-storage/settings", `{"retention_days": 0}`).Code)
+	assert.Equal(t, http.StatusOK, planRequest(org.ID, "PUT", "/plan-storage/settings", `{"retention_days": 2}`).Code)
+	assert.Equal(t, http.StatusOK, planRequest(org.ID, "PUT", "/repos/diggerhq-demo/plans/old.tfplan", "plan").Code)
+	assert.Equal(t, http.StatusOK, planRequest(org.ID, "PUT", "/repos/diggerhq-demo/plans/new.tfplan", "plan").Code)
+	assert.Equal(t, http.StatusOK, planRequest(otherOrg.ID, "PUT", "/repos/diggerhq-demo/plans/old.tfplan", "plan").Code)
+
+	threeDaysAgo := time.Now().AddDate(0, 0, -3)
+	assert.NoError(t, models.DB.GormDB.Model(&models.PlanArtifact{}).Where("path = ?", "old.tfplan").UpdateColumn("updated_at", threeDaysAgo).Error)
+
+	services.DeleteExpiredPlanArtifacts(time.Now())
+
+	assert.Equal(t, http.StatusNotFound, planRequest(org.ID, "HEAD", "/repos/diggerhq-demo/plans/old.tfplan", "").Code)
+	assert.Equal(t, http.StatusOK, planRequest(org.ID, "HEAD", "/repos/diggerhq-demo/plans/new.tfplan", "").Code)
+	// the other org keeps plans for the default retention
+	assert.Equal(t, http.StatusOK, planRequest(otherOrg.ID, "GET", "/repos/diggerhq-demo/plans/old.tfplan", "").Code)
+	exists, err := services.PlanStore.Exists(context.Background(), services.PlanArtifactKey(org.ID, "diggerhq-demo", "old.tfplan"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
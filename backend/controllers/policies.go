@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/diggerhq/digger/backend/middleware"
@@ -8,13 +10,15 @@ import (
 	dg_configuration "github.com/diggerhq/digger/libs/digger_config"
 	"github.com/dominikbraun/graph"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 )
 
+const PolicyVersionHeader = "Digger-Policy-Version"
+
 type CreatePolicyInput struct {
 	Policy string
 }
@@ -62,8 +66,24 @@ func findPolicy(c *gin.Context, policyType string) {
 		return
 	}
 
+	writePolicy(c, policy.Policy, policy.VersionID)
+}
+
+// writePolicy responds with the policy and its ETag, clients revalidate cached policies with If-None-Match.
+// The id of the current version is sent along so that jobs can report which version they evaluated
+func writePolicy(c *gin.Context, policy string, versionId *uint) {
+	if versionId != nil {
+		c.Header(PolicyVersionHeader, strconv.FormatUint(uint64(*versionId), 10))
+	}
+	digest := sha256.Sum256([]byte(policy))
+	etag := fmt.Sprintf("\"%v\"", hex.EncodeToString(digest[:]))
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
 	c.Header("Content-Type", "text/plain; charset=utf-8")
-	c.String(http.StatusOK, policy.Policy)
+	c.String(http.StatusOK, policy)
 }
 
 func FindAccessPolicyForOrg(c *gin.Context) {
@@ -103,8 +123,7 @@ func findPolicyForOrg(c *gin.Context, policyType string) {
 		return
 	}
 
-	c.Header("Content-Type", "text/plain; charset=utf-8")
-	c.String(http.StatusOK, policy.Policy)
+	writePolicy(c, policy.Policy, policy.VersionID)
 }
 
 func JoinedOrganisationRepoProjectQuery() *gorm.DB {
@@ -155,27 +174,21 @@ func upsertPolicyForOrg(c *gin.Context, policyType string) {
 
 	policyResult := models.DB.GormDB.Where("organisation_id = ? AND (repo_id IS NULL AND project_id IS NULL) AND type = ?", org.ID, policyType).Take(&policy)
 
+	previousPolicy := policy.Policy
 	if policyResult.RowsAffected == 0 {
-		err := models.DB.GormDB.Create(&models.Policy{
+		policy = models.Policy{
 			OrganisationID: org.ID,
 			Type:           policyType,
-			Policy:         string(policyData),
-		}).Error
-
-		if err != nil {
-			log.Printf("Error creating policy: %v", err)
-			c.String(http.StatusInternalServerError, "Error creating policy")
-			return
-		}
-	} else {
-		err := policyResult.Update("policy", string(policyData)).Error
-		if err != nil {
-			log.Printf("Error updating policy: %v", err)
-			c.String(http.StatusInternalServerError, "Error updating policy")
-			return
 		}
 	}
+	_, err = models.DB.SavePolicyVersion(&policy, string(policyData), auditActor(c))
+	if err != nil {
+		log.Printf("Error saving policy: %v", err)
+		c.String(http.StatusInternalServerError, "Error saving policy")
+		return
+	}
 
+	recordAuditEvent(c, org.ID, models.AuditActionPolicyUpsert, fmt.Sprintf("orgs/%v/%v-policy", organisation, policyType), models.AuditDiff(previousPolicy, string(policyData)))
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
@@ -245,63 +258,24 @@ func upsertPolicyForRepoAndProject(c *gin.Context, policyType string) {
 
 	policyResult := models.DB.GormDB.Where("organisation_id = ? AND repo_id = ? AND project_id = ? AND type = ?", orgID, repoModel.ID, projectModel.ID, policyType).Take(&policy)
 
+	previousPolicy := policy.Policy
 	if policyResult.RowsAffected == 0 {
-		err := models.DB.GormDB.Create(&models.Policy{
+		policy = models.Policy{
 			OrganisationID: orgID.(uint),
 			RepoID:         &repoModel.ID,
 			ProjectID:      &projectModel.ID,
 			Type:           policyType,
-			Policy:         string(policyData),
-		}).Error
-		if err != nil {
-			log.Printf("Error creating policy: %v", err)
-			c.String(http.StatusInternalServerError, "Error creating policy")
-			return
 		}
-	} else {
-		err := policyResult.Update("policy", string(policyData)).Error
-		if err != nil {
-			log.Printf("Error updating policy: %v", err)
-			c.String(http.StatusInternalServerError, "Error updating policy")
-			return
-		}
-	}
-
-	c.JSON(http.StatusOK, gin.H{"success": true})
-}
-
-func IssueAccessTokenForOrg(c *gin.Context) {
-	organisation_ID, exists := c.Get(middleware.ORGANISATION_ID_KEY)
-
-	if !exists {
-		c.String(http.StatusUnauthorized, "Not authorized")
-		return
-	}
-
-	org := models.Organisation{}
-	orgResult := models.DB.GormDB.Where("id = ?", organisation_ID).Take(&org)
-	if orgResult.RowsAffected == 0 {
-		log.Printf("Could not find organisation: %v", organisation_ID)
-		c.String(http.StatusInternalServerError, "Unexpected error")
-		return
 	}
-
-	// prefixing token to make easier to retire this type of tokens later
-	token := "t:" + uuid.New().String()
-
-	err := models.DB.GormDB.Create(&models.Token{
-		Value:          token,
-		OrganisationID: org.ID,
-		Type:           models.AccessPolicyType,
-	}).Error
-
+	_, err = models.DB.SavePolicyVersion(&policy, string(policyData), auditActor(c))
 	if err != nil {
-		log.Printf("Error creating token: %v", err)
-		c.String(http.StatusInternalServerError, "Unexpected error")
+		log.Printf("Error saving policy: %v", err)
+		c.String(http.StatusInternalServerError, "Error saving policy")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	recordAuditEvent(c, orgID.(uint), models.AuditActionPolicyUpsert, fmt.Sprintf("repos/%v/projects/%v/%v-policy", repo, projectName, policyType), models.AuditDiff(previousPolicy, string(policyData)))
+	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
 func loadDiggerConfig(configYaml *dg_configuration.DiggerConfigYaml) (*dg_configuration.DiggerConfig, graph.Graph[string, dg_configuration.Project], error) {
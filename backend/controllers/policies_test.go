@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/diggerhq/digger/backend/middleware"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePolicyRevalidatesWithETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	policy := "package digger\ndefault allow = true\n"
+	r.GET("/policy", func(c *gin.Context) {
+		writePolicy(c, policy, nil)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/policy", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, policy, w.Body.String())
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	req := httptest.NewRequest("GET", "/policy", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	policy = "package digger\ndefault allow = false\n"
+	req = httptest.NewRequest("GET", "/policy", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+}
+
+func policyRequest(orgId uint, method string, path string, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(middleware.ORGANISATION_ID_KEY, orgId)
+		c.Set(middleware.SUBJECT_KEY, "user:alice")
+	})
+	r.GET("/orgs/:organisation/plan-policy", FindPlanPolicyForOrg)
+	r.PUT("/orgs/:organisation/plan-policy", UpsertPlanPolicyForOrg)
+	r.GET("/orgs/:organisation/policies/:policyType/versions", ListPolicyVersions)
+	r.GET("/orgs/:organisation/policies/:policyType/versions/:version", GetPolicyVersion)
+	r.GET("/orgs/:organisation/policies/:policyType/diff", DiffPolicyVersions)
+	r.POST("/orgs/:organisation/policies/:policyType/versions/:version/rollback", RollbackPolicy)
+
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestPolicyVersionsAndRollback(t *testing.T) {
+	teardownSuite, database := setupSuite(t)
+	defer teardownSuite(t)
+	assert.NoError(t, database.GormDB.AutoMigrate(&models.PolicyVersion{}))
+	org, err := database.GetOrganisation("11111111-1111-1111-1111-111111111111")
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusNotFound, policyRequest(org.ID, "GET", "/orgs/testOrg/policies/plan/versions", "").Code)
+	assert.Equal(t, http.StatusBadRequest, policyRequest(org.ID, "GET", "/orgs/testOrg/policies/admin/versions", "").Code)
+
+	first := "package digger\ndeny[msg] {\n\tmsg := \"no\"\n}\n"
+	second := "package digger\n"
+	assert.Equal(t, http.StatusOK, policyRequest(org.ID, "PUT", "/orgs/testOrg/plan-policy", first).Code)
+	assert.Equal(t, http.StatusOK, policyRequest(org.ID, "PUT", "/orgs/testOrg/plan-policy", second).Code)
+
+	var listed struct {
+		CurrentVersionId uint `json:"current_version_id"`
+		Versions         []struct {
+			Id      uint   `json:"id"`
+			Version uint   `json:"version"`
+			Author  string `json:"author"`
+			Policy  string `json:"policy"`
+		} `json:"versions"`
+	}
+	w := policyRequest(org.ID, "GET", "/orgs/testOrg/policies/plan/versions", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	assert.Len(t, listed.Versions, 2)
+	assert.Equal(t, uint(2), listed.Versions[0].Version)
+	assert.Equal(t, "user:alice", listed.Versions[0].Author)
+	assert.Equal(t, listed.Versions[0].Id, listed.CurrentVersionId)
+
+	w = policyRequest(org.ID, "GET", "/orgs/testOrg/plan-policy", "")
+	assert.Equal(t, second, w.Body.String())
+	assert.Equal(t, strconv.FormatUint(uint64(listed.CurrentVersionId), 10), w.Header().Get(PolicyVersionHeader))
+
+	w = policyRequest(org.ID, "GET", "/orgs/testOrg/policies/plan/diff?from=1&to=2", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "-deny[msg] {")
+	assert.Equal(t, http.StatusNotFound, policyRequest(org.ID, "GET", "/orgs/testOrg/policies/plan/diff?from=5", "").Code)
+
+	w = policyRequest(org.ID, "POST", "/orgs/testOrg/policies/plan/versions/1/rollback", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"version":3`)
+	assert.Equal(t, first, policyRequest(org.ID, "GET", "/orgs/testOrg/plan-policy", "").Body.String())
+
+	// history is kept
+	w = policyRequest(org.ID, "GET", "/orgs/testOrg/policies/plan/versions/2", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"version":2`)
+	assert.Equal(t, http.StatusNotFound, policyRequest(org.ID+1, "GET", "/orgs/testOrg/policies/plan/versions", "").Code)
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/diggerhq/digger/backend/middleware"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PolicyDecisionReport is a policy evaluation as reported by the cli along with the job status
+type PolicyDecisionReport struct {
+	Timestamp       time.Time       `json:"timestamp"`
+	PolicyType      string          `json:"policy_type"`
+	PolicyHash      string          `json:"policy_hash"`
+	PolicyVersionId *uint           `json:"policy_version_id"`
+	Project         string          `json:"project"`
+	PrNumber        *int            `json:"pr_number"`
+	Input           json.RawMessage `json:"input"`
+	Allowed         bool            `json:"allowed"`
+	Messages        []string        `json:"messages"`
+	Warnings        []string        `json:"warnings"`
+	Error           string          `json:"error"`
+}
+
+// recordPolicyDecisions stores the decisions reported by the job, failures are logged and don't fail the status update
+func recordPolicyDecisions(orgId uint, job *models.DiggerJob, reports []PolicyDecisionReport) {
+	decisions := make([]models.PolicyDecision, 0, len(reports))
+	for _, report := range reports {
+		decision := models.PolicyDecision{
+			OrganisationID:  orgId,
+			DiggerJobID:     job.DiggerJobID,
+			PrNumber:        report.PrNumber,
+			Project:         report.Project,
+			PolicyType:      report.PolicyType,
+			PolicyHash:      report.PolicyHash,
+			PolicyVersionID: report.PolicyVersionId,
+			EvaluatedAt:     report.Timestamp,
+			Input:           string(report.Input),
+			Allowed:         report.Allowed,
+			Error:           report.Error,
+		}
+		if job.Batch != nil {
+			decision.RepoFullName = job.Batch.RepoFullName
+			if decision.PrNumber == nil && job.Batch.PrNumber != 0 {
+				prNumber := job.Batch.PrNumber
+				decision.PrNumber = &prNumber
+			}
+		}
+		messages, _ := json.Marshal(report.Messages)
+		decision.Messages = string(messages)
+		warnings, _ := json.Marshal(report.Warnings)
+		decision.Warnings = string(warnings)
+		decisions = append(decisions, decision)
+	}
+	err := models.DB.CreatePolicyDecisions(decisions)
+	if err != nil {
+		log.Printf("Failed to record policy decisions of job %v: %v", job.DiggerJobID, err)
+	}
+}
+
+// GetPolicyDecisions lists the policy decisions of the org, filtered by job, repo and pull request, project or policy type
+func GetPolicyDecisions(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+
+	filter := models.PolicyDecisionFilter{
+		JobId:        c.Query("job_id"),
+		RepoFullName: c.Query("repo"),
+		Project:      c.Query("project"),
+		PolicyType:   c.Query("policy_type"),
+	}
+	// repo restricted tokens only see the decisions of their repo
+	if restrictedRepo := c.GetString(middleware.REPO_RESTRICTION_KEY); restrictedRepo != "" {
+		repo, err := models.DB.GetRepo(orgId, restrictedRepo)
+		if err != nil {
+			log.Printf("Error fetching repo: %v", err)
+			c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+			return
+		}
+		if repo == nil || repo.RepoFullName == "" || (filter.RepoFullName != "" && filter.RepoFullName != repo.RepoFullName) {
+			c.String(http.StatusForbidden, "Not allowed to access the decisions of this repo")
+			return
+		}
+		filter.RepoFullName = repo.RepoFullName
+	}
+	if prNumber := c.Query("pr_number"); prNumber != "" {
+		n, err := strconv.Atoi(prNumber)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Invalid pr_number")
+			return
+		}
+		filter.PrNumber = &n
+	}
+	if limit := c.Query("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l < 0 {
+			c.String(http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = l
+	}
+
+	decisions, err := models.DB.GetPolicyDecisions(orgId, filter)
+	if err != nil {
+		log.Printf("Error fetching policy decisions: %v", err)
+		c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+		return
+	}
+
+	response := make([]interface{}, 0)
+	for _, decision := range decisions {
+		response = append(response, decision.MapToJsonStruct())
+	}
+	c.JSON(http.StatusOK, response)
+}
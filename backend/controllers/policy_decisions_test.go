@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/diggerhq/digger/backend/middleware"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func policyDecisionsRequest(orgId uint, query string) *httptest.ResponseRecorder {
+	return restrictedPolicyDecisionsRequest(orgId, "", query)
+}
+
+func restrictedPolicyDecisionsRequest(orgId uint, restrictedRepo string, query string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(middleware.ORGANISATION_ID_KEY, orgId)
+		if restrictedRepo != "" {
+			c.Set(middleware.REPO_RESTRICTION_KEY, restrictedRepo)
+		}
+	})
+	r.GET("/policy-decisions", GetPolicyDecisions)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/policy-decisions"+query, nil))
+	return w
+}
+
+func TestPolicyDecisionsPerJobAndPullRequest(t *testing.T) {
+	teardownSuite, database := setupSuite(t)
+	defer teardownSuite(t)
+	assert.NoError(t, database.GormDB.AutoMigrate(&models.PolicyDecision{}))
+	org, err := database.GetOrganisation("11111111-1111-1111-1111-111111111111")
+	assert.NoError(t, err)
+
+	var reports []PolicyDecisionReport
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{"policy_type": "plan", "policy_hash": "sha256:abc", "project": "prod", "input": {"terraform": {}}, "allowed": false, "messages": ["aws_s3_bucket.state can't be deleted"]},
+		{"policy_type": "access", "policy_hash": "sha256:def", "project": "prod", "pr_number": 12, "input": {"user": "motatoes"}, "allowed": false}
+	]`), &reports))
+	batch := &models.DiggerBatch{RepoFullName: "diggerhq/demo", PrNumber: 12}
+	recordPolicyDecisions(org.ID, &models.DiggerJob{DiggerJobID: "job-1", Batch: batch}, reports)
+	recordPolicyDecisions(org.ID, &models.DiggerJob{DiggerJobID: "job-2", Batch: &models.DiggerBatch{RepoFullName: "diggerhq/demo", PrNumber: 13}}, reports[:1])
+
+	var decisions []struct {
+		JobId      string          `json:"job_id"`
+		PrNumber   *int            `json:"pr_number"`
+		PolicyType string          `json:"policy_type"`
+		Input      json.RawMessage `json:"input"`
+		Allowed    bool            `json:"allowed"`
+		Messages   []string        `json:"messages"`
+	}
+	w := policyDecisionsRequest(org.ID, "?job_id=job-1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &decisions))
+	assert.Len(t, decisions, 2)
+	assert.Equal(t, 12, *decisions[0].PrNumber)
+	assert.Equal(t, []string{"aws_s3_bucket.state can't be deleted"}, decisions[0].Messages)
+	assert.JSONEq(t, `{"user": "motatoes"}`, string(decisions[1].Input))
+	assert.Equal(t, []string{}, decisions[1].Messages)
+
+	w = policyDecisionsRequest(org.ID, "?repo=diggerhq/demo&pr_number=13")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &decisions))
+	assert.Len(t, decisions, 1)
+	assert.Equal(t, "job-2", decisions[0].JobId)
+
+	assert.Equal(t, http.StatusBadRequest, policyDecisionsRequest(org.ID, "?pr_number=abc").Code)
+	assert.Equal(t, "[]", policyDecisionsRequest(org.ID+1, "").Body.String())
+}
+
+func TestPolicyDecisionsOfRepoRestrictedTokens(t *testing.T) {
+	teardownSuite, database := setupSuite(t)
+	defer teardownSuite(t)
+	assert.NoError(t, database.GormDB.AutoMigrate(&models.PolicyDecision{}))
+	org, err := database.GetOrganisation("11111111-1111-1111-1111-111111111111")
+	assert.NoError(t, err)
+	_, err = database.CreateRepo("diggerhq-demo", "diggerhq/demo", "diggerhq", "demo", "", org, "")
+	assert.NoError(t, err)
+
+	reports := []PolicyDecisionReport{{PolicyType: "plan", PolicyHash: "sha256:abc", Project: "prod", Input: json.RawMessage(`{}`), Allowed: true}}
+	recordPolicyDecisions(org.ID, &models.DiggerJob{DiggerJobID: "job-1", Batch: &models.DiggerBatch{RepoFullName: "diggerhq/demo", PrNumber: 12}}, reports)
+	recordPolicyDecisions(org.ID, &models.DiggerJob{DiggerJobID: "job-2", Batch: &models.DiggerBatch{RepoFullName: "diggerhq/other", PrNumber: 12}}, reports)
+
+	var decisions []struct {
+		JobId string `json:"job_id"`
+	}
+	w := restrictedPolicyDecisionsRequest(org.ID, "diggerhq-demo", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &decisions))
+	assert.Len(t, decisions, 1)
+	assert.Equal(t, "job-1", decisions[0].JobId)
+
+	assert.Equal(t, http.StatusOK, restrictedPolicyDecisionsRequest(org.ID, "diggerhq-demo", "?repo=diggerhq/demo").Code)
+	assert.Equal(t, http.StatusForbidden, restrictedPolicyDecisionsRequest(org.ID, "diggerhq-demo", "?repo=diggerhq/other").Code)
+	assert.Equal(t, http.StatusForbidden, restrictedPolicyDecisionsRequest(org.ID, "diggerhq-unknown", "").Code)
+}
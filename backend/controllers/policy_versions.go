@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggerhq/digger/backend/middleware"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// findVersionedPolicy looks up the policy addressed by the request, either an org policy or a project policy,
+// it responds with an error and returns nil when it can't be found
+func findVersionedPolicy(c *gin.Context) (*models.Policy, string) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return nil, ""
+	}
+
+	policyType := c.Param("policyType")
+	switch policyType {
+	case models.POLICY_TYPE_ACCESS, models.POLICY_TYPE_PLAN, models.POLICY_TYPE_DRIFT:
+	default:
+		c.String(http.StatusBadRequest, "Policy type should be access, plan or drift")
+		return nil, ""
+	}
+
+	var policy models.Policy
+	var target string
+	query := JoinedOrganisationRepoProjectQuery()
+	if organisation := c.Param("organisation"); organisation != "" {
+		query = query.Where("organisations.name = ? AND (repos.id IS NULL AND projects.id IS NULL) AND policies.organisation_id = ? AND policies.type = ?", organisation, orgId, policyType)
+		target = fmt.Sprintf("orgs/%v/%v-policy", organisation, policyType)
+	} else {
+		repo := c.Param("repo")
+		projectName := c.Param("projectName")
+		query = query.Where("repos.name = ? AND projects.name = ? AND policies.organisation_id = ? AND policies.type = ?", repo, projectName, orgId, policyType)
+		target = fmt.Sprintf("repos/%v/projects/%v/%v-policy", repo, projectName, policyType)
+	}
+
+	err := query.First(&policy).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.String(http.StatusNotFound, "Could not find policy: "+target)
+		} else {
+			log.Printf("Error fetching policy %v: %v", target, err)
+			c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+		}
+		return nil, ""
+	}
+	return &policy, target
+}
+
+// findPolicyVersion looks up a version of the policy by its number, it responds with an error and returns nil when it can't be found
+func findPolicyVersion(c *gin.Context, policy *models.Policy, versionParam string) *models.PolicyVersion {
+	number, err := strconv.ParseUint(versionParam, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid version: "+versionParam)
+		return nil
+	}
+	version, err := models.DB.GetPolicyVersion(policy.ID, uint(number))
+	if err != nil {
+		log.Printf("Error fetching version %v of policy %v: %v", number, policy.ID, err)
+		c.String(http.StatusInternalServerError, "Error fetching policy version")
+		return nil
+	}
+	if version == nil {
+		c.String(http.StatusNotFound, fmt.Sprintf("Could not find version %v", number))
+		return nil
+	}
+	return version
+}
+
+// ListPolicyVersions lists the versions of a policy, newest first
+func ListPolicyVersions(c *gin.Context) {
+	policy, _ := findVersionedPolicy(c)
+	if policy == nil {
+		return
+	}
+
+	versions, err := models.DB.GetPolicyVersions(policy.ID)
+	if err != nil {
+		log.Printf("Error fetching versions of policy %v: %v", policy.ID, err)
+		c.String(http.StatusInternalServerError, "Error fetching policy versions")
+		return
+	}
+
+	response := make([]interface{}, 0, len(versions))
+	for _, v := range versions {
+		response = append(response, v.MapToJsonStruct())
+	}
+	c.JSON(http.StatusOK, gin.H{"current_version_id": policy.VersionID, "versions": response})
+}
+
+func GetPolicyVersion(c *gin.Context) {
+	policy, _ := findVersionedPolicy(c)
+	if policy == nil {
+		return
+	}
+	version := findPolicyVersion(c, policy, c.Param("version"))
+	if version == nil {
+		return
+	}
+	c.JSON(http.StatusOK, version.MapToJsonStruct())
+}
+
+// DiffPolicyVersions responds with a unified diff between the versions in the from and to query params,
+// to defaults to the current version
+func DiffPolicyVersions(c *gin.Context) {
+	policy, _ := findVersionedPolicy(c)
+	if policy == nil {
+		return
+	}
+	from := findPolicyVersion(c, policy, c.Query("from"))
+	if from == nil {
+		return
+	}
+	to := policy.Policy
+	if c.Query("to") != "" {
+		toVersion := findPolicyVersion(c, policy, c.Query("to"))
+		if toVersion == nil {
+			return
+		}
+		to = toVersion.Content
+	}
+	c.String(http.StatusOK, models.AuditDiff(from.Content, to))
+}
+
+// RollbackPolicy makes an earlier version current again, it is stored as a new version so the history stays intact
+func RollbackPolicy(c *gin.Context) {
+	policy, target := findVersionedPolicy(c)
+	if policy == nil {
+		return
+	}
+	version := findPolicyVersion(c, policy, c.Param("version"))
+	if version == nil {
+		return
+	}
+
+	previousPolicy := policy.Policy
+	rolledBack, err := models.DB.SavePolicyVersion(policy, version.Content, auditActor(c))
+	if err != nil {
+		log.Printf("Error rolling back policy %v: %v", policy.ID, err)
+		c.String(http.StatusInternalServerError, "Error rolling back policy")
+		return
+	}
+
+	recordAuditEvent(c, policy.OrganisationID, models.AuditActionPolicyRollback, target, models.AuditDiff(previousPolicy, version.Content))
+	c.JSON(http.StatusOK, rolledBack.MapToJsonStruct())
+}
@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/diggerhq/digger/backend/ci_backends"
 	"github.com/diggerhq/digger/backend/middleware"
 	"github.com/diggerhq/digger/backend/models"
 	"github.com/diggerhq/digger/backend/services"
 	"github.com/diggerhq/digger/backend/utils"
+	"github.com/diggerhq/digger/backend/webhooks"
 	"github.com/diggerhq/digger/libs/comment_utils/reporting"
 	"github.com/diggerhq/digger/libs/digger_config"
 	"github.com/diggerhq/digger/libs/orchestrator"
@@ -18,7 +20,6 @@ import (
 	"log"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 )
 
@@ -319,9 +320,39 @@ type SetJobStatusRequest struct {
 	Footprint       *terraform_utils.TerraformPlanFootprint `json:"job_plan_footprint"`
 	PrCommentUrl    string                                  `json:"pr_comment_url"`
 	TerraformOutput string                                  `json:"terraform_output""`
+	// PolicyVersions are the ids of the policy versions the job evaluated, keyed by policy type
+	PolicyVersions map[string]uint `json:"policy_versions"`
+	// PolicyDecisions are the policy evaluations of the job that weren't reported yet
+	PolicyDecisions []PolicyDecisionReport `json:"policy_decisions"`
+	// DestroyConfirmations are the protected resources the apply was confirmed to destroy
+	DestroyConfirmations []DestroyConfirmationReport `json:"destroy_confirmations"`
 }
 
-func SetJobStatusForProject(c *gin.Context) {
+type DestroyConfirmationReport struct {
+	Address     string `json:"address"`
+	ConfirmedBy string `json:"confirmed_by"`
+}
+
+// recordDestroyConfirmations adds the confirmations of protected resource destruction to the audit log
+func recordDestroyConfirmations(orgId uint, job *models.DiggerJob, projectName string, confirmations []DestroyConfirmationReport) {
+	vcs := models.DiggerVCSGithub
+	repoFullName := ""
+	if job.Batch != nil {
+		vcs = job.Batch.VCS
+		repoFullName = job.Batch.RepoFullName
+	}
+	for _, confirmation := range confirmations {
+		log.Printf("%v confirmed the destruction of %v in project %v", confirmation.ConfirmedBy, confirmation.Address, projectName)
+		recordWebhookAuditEvent(orgId, string(vcs)+":"+confirmation.ConfirmedBy, models.AuditActionDestroyConfirm, fmt.Sprintf("repos/%v/projects/%v/resources/%v", repoFullName, projectName, confirmation.Address))
+	}
+}
+
+// JobStatusController receives the status updates of jobs and dispatches the jobs depending on them
+type JobStatusController struct {
+	CiBackendProvider ci_backends.CiBackendProvider
+}
+
+func (j JobStatusController) SetJobStatusForProject(c *gin.Context) {
 	jobId := c.Param("jobId")
 
 	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
@@ -348,6 +379,21 @@ func SetJobStatusForProject(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching job"})
 		return
 	}
+	previousStatus := job.Status
+
+	if len(request.PolicyDecisions) > 0 {
+		recordPolicyDecisions(orgId.(uint), job, request.PolicyDecisions)
+	}
+	if len(request.DestroyConfirmations) > 0 {
+		recordDestroyConfirmations(orgId.(uint), job, c.Param("projectName"), request.DestroyConfirmations)
+	}
+	// failed jobs evaluated policies as well, the versions are kept whatever the status is
+	if versionId, ok := request.PolicyVersions[models.POLICY_TYPE_ACCESS]; ok {
+		job.AccessPolicyVersionID = &versionId
+	}
+	if versionId, ok := request.PolicyVersions[models.POLICY_TYPE_PLAN]; ok {
+		job.PlanPolicyVersionID = &versionId
+	}
 
 	switch request.Status {
 	case "started":
@@ -359,6 +405,9 @@ func SetJobStatusForProject(c *gin.Context) {
 			return
 		}
 
+		if job.Batch.VCS == models.DiggerVCSGitlab {
+			break
+		}
 		client, _, err := utils.GetGithubClient(&utils.DiggerGithubRealClientProvider{}, job.Batch.GithubInstallationId, job.Batch.RepoFullName)
 		if err != nil {
 			log.Printf("Error Creating github client: %v", err)
@@ -397,41 +446,20 @@ func SetJobStatusForProject(c *gin.Context) {
 					log.Printf("Recovered from panic while executing goroutine dispatching digger jobs: %v ", r)
 				}
 			}()
-			ghClientProvider := &utils.DiggerGithubRealClientProvider{}
-			installationLink, err := models.DB.GetGithubInstallationLinkForOrg(orgId)
-			if err != nil {
-				log.Printf("Error fetching installation link: %v", err)
-				return
-			}
-
-			installations, err := models.DB.GetGithubAppInstallations(installationLink.GithubInstallationId)
-			if err != nil {
-				log.Printf("Error fetching installation: %v", err)
-				return
-			}
-
-			if len(installations) == 0 {
-				log.Printf("No installations found for installation id %v", installationLink.GithubInstallationId)
-				return
-			}
-
-			jobLink, err := models.DB.GetDiggerJobLink(jobId)
-
-			if err != nil {
-				log.Printf("Error fetching job link: %v", err)
-				return
-			}
-
 			workflowFileName := "digger_workflow.yml"
-
-			if !strings.Contains(jobLink.RepoFullName, "/") {
-				log.Printf("Repo full name %v does not contain a slash", jobLink.RepoFullName)
+			// dependent jobs run on the same backend the webhooks dispatch jobs to
+			ciBackend, err := j.CiBackendProvider.GetCiBackend(ci_backends.CiBackendOptions{
+				GithubInstallationId: job.Batch.GithubInstallationId,
+				RepoFullName:         job.Batch.RepoFullName,
+				RepoOwner:            job.Batch.RepoOwner,
+				RepoName:             job.Batch.RepoName,
+				VCS:                  job.Batch.VCS,
+			})
+			if err != nil {
+				log.Printf("Error creating ci backend: %v", err)
 				return
 			}
-
-			repoFullNameSplit := strings.Split(jobLink.RepoFullName, "/")
-			client, _, err := ghClientProvider.Get(installations[0].GithubAppId, installationLink.GithubInstallationId)
-			err = services.DiggerJobCompleted(client, &job.Batch.ID, job, repoFullNameSplit[0], repoFullNameSplit[1], workflowFileName)
+			err = services.DiggerJobCompleted(ciBackend, &job.Batch.ID, job, job.Batch.RepoOwner, job.Batch.RepoName, workflowFileName)
 			if err != nil {
 				log.Printf("Error triggering job: %v", err)
 				return
@@ -440,7 +468,16 @@ func SetJobStatusForProject(c *gin.Context) {
 
 		// store digger job summary
 		if request.JobSummary != nil {
-			models.DB.UpdateDiggerJobSummary(job.DiggerJobID, request.JobSummary.ResourcesCreated, request.JobSummary.ResourcesUpdated, request.JobSummary.ResourcesDeleted)
+			updatedJob, err := models.DB.UpdateDiggerJobSummary(job.DiggerJobID, request.JobSummary.ResourcesCreated, request.JobSummary.ResourcesUpdated, request.JobSummary.ResourcesDeleted)
+			if err == nil {
+				job.DiggerJobSummary = updatedJob.DiggerJobSummary
+			}
+			if request.JobSummary.MonthlyCost != nil {
+				updatedJob, err := models.DB.UpdateDiggerJobCostEstimate(job.DiggerJobID, request.JobSummary.MonthlyCost, request.JobSummary.MonthlyCostDelta, request.JobSummary.CostCurrency)
+				if err == nil {
+					job.DiggerJobSummary = updatedJob.DiggerJobSummary
+				}
+			}
 		}
 
 	case "failed":
@@ -465,17 +502,20 @@ func SetJobStatusForProject(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving job"})
 		return
 	}
+	webhooks.JobStatusChanged(job, nil, previousStatus)
 
 	// get batch ID
 	// check if all jobs have succeeded at this point
 	// if so, perform merge of PR (if configured to do so)
 	batch := job.Batch
+	previousBatchStatus := batch.Status
 	err = models.DB.UpdateBatchStatus(batch)
 	if err != nil {
 		log.Printf("Error updating batch status: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating batch status"})
 		return
 	}
+	webhooks.BatchStatusChanged(batch, previousBatchStatus)
 
 	err = AutomergePRforBatchIfEnabled(&utils.DiggerGithubRealClientProvider{}, batch)
 	if err != nil {
@@ -591,13 +631,11 @@ func UpdateCommentsForBatchGroup(gh utils.GithubClientProvider, batch *models.Di
 		return nil
 	}
 
-	ghService, _, err := utils.GetGithubService(
-		gh,
-		batch.GithubInstallationId,
-		batch.RepoFullName,
-		batch.RepoOwner,
-		batch.RepoName,
-	)
+	prService, err := prServiceForBatch(gh, batch)
+	if err != nil {
+		log.Printf("Error getting pull request service: %v", err)
+		return fmt.Errorf("error getting pull request service: %v", err)
+	}
 
 	var sourceDetails []reporting.SourceDetails
 	err = json.Unmarshal(batch.SourceDetails, &sourceDetails)
@@ -618,7 +656,7 @@ func UpdateCommentsForBatchGroup(gh utils.GithubClientProvider, batch *models.Di
 	}
 
 	for _, detail := range sourceDetails {
-		reporter := reporting.SourceGroupingReporter{serializedJobs, batch.PrNumber, ghService}
+		reporter := reporting.SourceGroupingReporter{serializedJobs, batch.PrNumber, prService}
 		reporter.UpdateComment(sourceDetails, detail.SourceLocation, projectToTerraformOutput)
 	}
 	return nil
@@ -639,18 +677,12 @@ func AutomergePRforBatchIfEnabled(gh utils.GithubClientProvider, batch *models.D
 		automerge = false
 	}
 	if batch.Status == orchestrator_scheduler.BatchJobSucceeded && batch.BatchType == orchestrator.DiggerCommandApply && automerge == true {
-		ghService, _, err := utils.GetGithubService(
-			gh,
-			batch.GithubInstallationId,
-			batch.RepoFullName,
-			batch.RepoOwner,
-			batch.RepoName,
-		)
+		prService, err := prServiceForBatch(gh, batch)
 		if err != nil {
-			log.Printf("Error getting github service: %v", err)
-			return fmt.Errorf("error getting github service: %v", err)
+			log.Printf("Error getting pull request service: %v", err)
+			return fmt.Errorf("error getting pull request service: %v", err)
 		}
-		err = ghService.MergePullRequest(batch.PrNumber)
+		err = prService.MergePullRequest(batch.PrNumber)
 		if err != nil {
 			log.Printf("Error merging pull request: %v", err)
 			return fmt.Errorf("error merging pull request: %v", err)
@@ -658,3 +690,19 @@ func AutomergePRforBatchIfEnabled(gh utils.GithubClientProvider, batch *models.D
 	}
 	return nil
 }
+
+// prServiceForBatch returns the service of the pull or merge request the batch was created for
+func prServiceForBatch(gh utils.GithubClientProvider, batch *models.DiggerBatch) (orchestrator.PullRequestService, error) {
+	if batch.VCS == models.DiggerVCSGitlab {
+		glService, err := utils.GetGitlabService(batch.RepoFullName)
+		if err != nil {
+			return nil, err
+		}
+		return glService, nil
+	}
+	ghService, _, err := utils.GetGithubService(gh, batch.GithubInstallationId, batch.RepoFullName, batch.RepoOwner, batch.RepoName)
+	if err != nil {
+		return nil, err
+	}
+	return ghService, nil
+}
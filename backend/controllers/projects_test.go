@@ -1,16 +1,26 @@
 package controllers
 
 import (
+	"context"
+	"encoding/json"
+	"github.com/diggerhq/digger/backend/ci_backends"
+	"github.com/diggerhq/digger/backend/middleware"
 	"github.com/diggerhq/digger/backend/models"
 	"github.com/diggerhq/digger/backend/utils"
 	"github.com/diggerhq/digger/libs/orchestrator"
 	orchestrator_scheduler "github.com/diggerhq/digger/libs/orchestrator/scheduler"
+	"github.com/gin-gonic/gin"
 	"github.com/google/go-github/v61/github"
 	"github.com/google/uuid"
 	"github.com/migueleliasweb/go-github-mock/src/mock"
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestAutomergeWhenBatchIsSuccessfulStatus(t *testing.T) {
@@ -93,3 +103,121 @@ func TestAutomergeWhenBatchIsSuccessfulStatus(t *testing.T) {
 	assert.True(t, isMergeCalled)
 
 }
+
+func TestRecordDestroyConfirmations(t *testing.T) {
+	teardownSuite, database := setupSuite(t)
+	defer teardownSuite(t)
+	assert.NoError(t, database.GormDB.AutoMigrate(&models.AuditLogEntry{}))
+	org, err := database.GetOrganisation("11111111-1111-1111-1111-111111111111")
+	assert.NoError(t, err)
+
+	job := &models.DiggerJob{DiggerJobID: "job-1", Batch: &models.DiggerBatch{RepoFullName: "diggerhq/demo", VCS: models.DiggerVCSGitlab}}
+	recordDestroyConfirmations(org.ID, job, "prod", []DestroyConfirmationReport{{Address: "aws_db_instance.main", ConfirmedBy: "alice"}})
+
+	entries, err := database.GetAuditLogEntries(org.ID, models.AuditLogFilter{Action: models.AuditActionDestroyConfirm})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "gitlab:alice", entries[0].Actor)
+	assert.Equal(t, "repos/diggerhq/demo/projects/prod/resources/aws_db_instance.main", entries[0].Target)
+}
+
+func setJobStatus(controller JobStatusController, orgId uint, jobId string, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/repos/:repo/projects/:projectName/jobs/:jobId/set-status", func(c *gin.Context) {
+		c.Set(middleware.ORGANISATION_ID_KEY, orgId)
+		c.Next()
+	}, controller.SetJobStatusForProject)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/repos/diggerhq-github-job-scheduler/projects/dev/jobs/"+jobId+"/set-status", strings.NewReader(body))
+	r.ServeHTTP(w, req)
+	return w
+}
+
+type kubernetesBackendProvider struct {
+	client *fake.Clientset
+}
+
+func (p kubernetesBackendProvider) GetCiBackend(options ci_backends.CiBackendOptions) (ci_backends.CiBackend, error) {
+	return ci_backends.KubernetesJobsCi{
+		Client: p.client,
+		Config: ci_backends.KubernetesJobsConfig{
+			Namespace:               "digger",
+			Image:                   "ghcr.io/diggerhq/digger:latest",
+			CloneImage:              "alpine/git",
+			ServiceAccount:          "terraform",
+			CpuLimit:                "2",
+			MemoryRequest:           "512Mi",
+			TTLSecondsAfterFinished: 600,
+		},
+		GetToken: func(job models.DiggerJob) (string, error) { return "ghs_token", nil },
+	}, nil
+}
+
+func TestSucceededJobTriggersDependentJobOnKubernetesBackend(t *testing.T) {
+	teardownSuite, database := setupSuite(t)
+	defer teardownSuite(t)
+	assert.NoError(t, database.GormDB.AutoMigrate(&models.DiggerBatch{}, &models.DiggerJobSummary{}))
+	org, err := database.GetOrganisation("11111111-1111-1111-1111-111111111111")
+	assert.NoError(t, err)
+
+	commentId := int64(1)
+	diggerConfig := "" +
+		"projects:\n" +
+		"  - name: dev\n" +
+		"    dir: dev\n" +
+		"  - name: prod\n" +
+		"    dir: prod\n" +
+		"    depends_on: [\"dev\"]\n" +
+		"comment_render_mode: basic"
+	batch, err := database.CreateDiggerBatch(41584295, "diggerhq", "github-job-scheduler", "diggerhq/github-job-scheduler", 2, diggerConfig, "main", orchestrator.DiggerCommandPlan, &commentId)
+	assert.NoError(t, err)
+
+	prNumber := 2
+	devSpec, _ := json.Marshal(orchestrator.JobJson{ProjectName: "dev", PullRequestNumber: &prNumber})
+	prodSpec, _ := json.Marshal(orchestrator.JobJson{ProjectName: "prod", PullRequestNumber: &prNumber})
+	dev, err := database.CreateDiggerJob(batch.ID, devSpec, "digger_workflow.yml")
+	assert.NoError(t, err)
+	prod, err := database.CreateDiggerJob(batch.ID, prodSpec, "digger_workflow.yml")
+	assert.NoError(t, err)
+	assert.NoError(t, database.CreateDiggerJobParentLink(dev.DiggerJobID, prod.DiggerJobID))
+
+	client := fake.NewSimpleClientset()
+	controller := JobStatusController{CiBackendProvider: kubernetesBackendProvider{client: client}}
+	w := setJobStatus(controller, org.ID, dev.DiggerJobID, `{"status": "succeeded"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// the dependent job is dispatched in the background
+	jobName := "digger-" + strings.ToLower(prod.DiggerJobID)
+	assert.Eventually(t, func() bool {
+		_, err := client.BatchV1().Jobs("digger").Get(context.Background(), jobName, metav1.GetOptions{})
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestFailedJobRecordsPolicyVersions(t *testing.T) {
+	teardownSuite, database := setupSuite(t)
+	defer teardownSuite(t)
+	assert.NoError(t, database.GormDB.AutoMigrate(&models.DiggerBatch{}, &models.DiggerJobSummary{}))
+	org, err := database.GetOrganisation("11111111-1111-1111-1111-111111111111")
+	assert.NoError(t, err)
+
+	commentId := int64(1)
+	batch, err := database.CreateDiggerBatch(41584295, "diggerhq", "github-job-scheduler", "diggerhq/github-job-scheduler", 2, "comment_render_mode: basic", "main", orchestrator.DiggerCommandApply, &commentId)
+	assert.NoError(t, err)
+	job, err := database.CreateDiggerJob(batch.ID, []byte("{}"), "digger_workflow.yml")
+	assert.NoError(t, err)
+
+	// the access policy denied the apply
+	w := setJobStatus(JobStatusController{}, org.ID, job.DiggerJobID, `{"status": "failed", "policy_versions": {"access": 3, "plan": 4}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	job, err = database.GetDiggerJob(job.DiggerJobID)
+	assert.NoError(t, err)
+	assert.Equal(t, orchestrator_scheduler.DiggerJobFailed, job.Status)
+	if assert.NotNil(t, job.AccessPolicyVersionID) && assert.NotNil(t, job.PlanPolicyVersionID) {
+		assert.Equal(t, uint(3), *job.AccessPolicyVersionID)
+		assert.Equal(t, uint(4), *job.PlanPolicyVersionID)
+	}
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggerhq/digger/backend/middleware"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type CreateRoleBindingRequest struct {
+	Subject   string `json:"subject"`
+	Role      string `json:"role"`
+	ScopeType string `json:"scope_type"`
+	Repo      string `json:"repo"`
+	Project   string `json:"project"`
+}
+
+func (r CreateRoleBindingRequest) Validate() error {
+	if r.Subject == "" {
+		return fmt.Errorf("subject is required")
+	}
+	if !models.IsValidRole(r.Role) {
+		return fmt.Errorf("unknown role: %v", r.Role)
+	}
+	switch r.ScopeType {
+	case models.RoleScopeOrg:
+		if r.Repo != "" || r.Project != "" {
+			return fmt.Errorf("repo and project must be empty for org scope")
+		}
+	case models.RoleScopeRepo:
+		if r.Repo == "" || r.Project != "" {
+			return fmt.Errorf("repo scope requires repo only")
+		}
+	case models.RoleScopeProject:
+		if r.Repo == "" || r.Project == "" {
+			return fmt.Errorf("project scope requires both repo and project")
+		}
+	default:
+		return fmt.Errorf("unknown scope type: %v", r.ScopeType)
+	}
+	return nil
+}
+
+func ListRoleBindings(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+
+	bindings, err := models.DB.GetRoleBindingsForOrg(orgId)
+	if err != nil {
+		log.Printf("Error fetching role bindings: %v", err)
+		c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+		return
+	}
+
+	response := make([]interface{}, 0)
+	for _, b := range bindings {
+		response = append(response, b.MapToJsonStruct())
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func CreateRoleBinding(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+
+	var request CreateRoleBindingRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := request.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	binding, err := models.DB.CreateRoleBinding(orgId.(uint), request.Subject, request.Role, request.ScopeType, request.Repo, request.Project)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error creating role binding")
+		return
+	}
+	recordAuditEvent(c, binding.OrganisationID, models.AuditActionRoleBindingCreate, fmt.Sprintf("role-bindings/%v", binding.ID),
+		fmt.Sprintf("%v: %v on %v %v %v", binding.Subject, binding.Role, binding.ScopeType, binding.RepoName, binding.ProjectName))
+	c.JSON(http.StatusCreated, binding.MapToJsonStruct())
+}
+
+func DeleteRoleBinding(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+
+	bindingId, err := strconv.Atoi(c.Param("bindingId"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid binding id")
+		return
+	}
+
+	err = models.DB.DeleteRoleBinding(orgId, uint(bindingId))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.String(http.StatusNotFound, "Could not find role binding")
+		} else {
+			log.Printf("Error deleting role binding: %v", err)
+			c.String(http.StatusInternalServerError, "Error deleting role binding")
+		}
+		return
+	}
+	recordAuditEvent(c, orgId.(uint), models.AuditActionRoleBindingDelete, fmt.Sprintf("role-bindings/%v", bindingId), "")
+	c.Status(http.StatusNoContent)
+}
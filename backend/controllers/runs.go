@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/diggerhq/digger/backend/middleware"
 	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/backend/webhooks"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 	"log"
@@ -178,14 +179,22 @@ func ApproveRun(c *gin.Context) {
 	}
 
 	if run.IsApproved == false {
-		run.ApprovalAuthor = "a_user"
+		run.ApprovalAuthor = auditActor(c)
 		run.IsApproved = true
 		run.ApprovalDate = time.Now()
 		err := models.DB.UpdateDiggerRun(run)
 		if err != nil {
 			log.Printf("Could update run: %v", err)
 			c.String(http.StatusInternalServerError, "Could not update approval")
+			return
 		}
+		recordAuditEvent(c, org.ID, models.AuditActionRunApprove, fmt.Sprintf("runs/%v", run.ID), "")
+		webhooks.Emit(org.ID, webhooks.EventRunApproved, webhooks.RunEventData{
+			RunId:        run.ID,
+			RepoFullName: run.Repo.RepoFullName,
+			Project:      run.ProjectName,
+			ApprovedBy:   run.ApprovalAuthor,
+		})
 	} else {
 		log.Printf("Run has already been approved")
 	}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggerhq/digger/backend/middleware"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type CreateTokenRequest struct {
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+func (r CreateTokenRequest) Validate() error {
+	if r.Type != models.AccessPolicyType && r.Type != models.AdminPolicyType {
+		return fmt.Errorf("unknown token type: %v", r.Type)
+	}
+	for _, scope := range r.Scopes {
+		if !models.IsValidPermission(scope) {
+			return fmt.Errorf("unknown scope: %v", scope)
+		}
+	}
+	if r.ExpiresInDays < 0 {
+		return fmt.Errorf("expires_in_days must not be negative")
+	}
+	return nil
+}
+
+func (r CreateTokenRequest) ExpiresAt() *time.Time {
+	if r.ExpiresInDays == 0 {
+		return nil
+	}
+	expiresAt := time.Now().Add(time.Duration(r.ExpiresInDays) * 24 * time.Hour)
+	return &expiresAt
+}
+
+// IssueAccessTokenForOrg creates an access token, the request body is optional
+func IssueAccessTokenForOrg(c *gin.Context) {
+	request := CreateTokenRequest{Type: models.AccessPolicyType}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		request.Type = models.AccessPolicyType
+	}
+	createToken(c, request)
+}
+
+func CreateToken(c *gin.Context) {
+	var request CreateTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Type == "" {
+		request.Type = models.AccessPolicyType
+	}
+	createToken(c, request)
+}
+
+func createToken(c *gin.Context, request CreateTokenRequest) {
+	organisation_ID, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+
+	if !exists {
+		c.String(http.StatusUnauthorized, "Not authorized")
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org, err := models.DB.GetOrganisationById(organisation_ID)
+	if err != nil {
+		log.Printf("Could not find organisation: %v", organisation_ID)
+		c.String(http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	token, value, err := models.DB.CreateToken(org.ID, request.Name, request.Type, request.Scopes, request.ExpiresAt())
+	if err != nil {
+		log.Printf("Error creating token: %v", err)
+		c.String(http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	recordAuditEvent(c, org.ID, models.AuditActionTokenIssue, fmt.Sprintf("tokens/%v", token.ID), "")
+	c.JSON(http.StatusOK, gin.H{"token": value, "id": token.ID, "expires_at": token.ExpiresAt})
+}
+
+func ListTokens(c *gin.Context) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return
+	}
+
+	tokens, err := models.DB.GetTokensForOrg(orgId)
+	if err != nil {
+		log.Printf("Error fetching tokens: %v", err)
+		c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+		return
+	}
+
+	response := make([]interface{}, 0)
+	for _, t := range tokens {
+		response = append(response, t.MapToJsonStruct())
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func RotateToken(c *gin.Context) {
+	token, ok := tokenFromParam(c)
+	if !ok {
+		return
+	}
+
+	newToken, value, err := models.DB.RotateToken(token)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error rotating token")
+		return
+	}
+	recordAuditEvent(c, token.OrganisationID, models.AuditActionTokenRotate, fmt.Sprintf("tokens/%v", token.ID), fmt.Sprintf("replaced by tokens/%v", newToken.ID))
+	c.JSON(http.StatusOK, gin.H{"token": value, "id": newToken.ID, "expires_at": newToken.ExpiresAt})
+}
+
+func RevokeToken(c *gin.Context) {
+	token, ok := tokenFromParam(c)
+	if !ok {
+		return
+	}
+
+	err := models.DB.RevokeToken(token)
+	if err != nil {
+		log.Printf("Error revoking token: %v", err)
+		c.String(http.StatusInternalServerError, "Error revoking token")
+		return
+	}
+	recordAuditEvent(c, token.OrganisationID, models.AuditActionTokenRevoke, fmt.Sprintf("tokens/%v", token.ID), "")
+	c.Status(http.StatusNoContent)
+}
+
+func tokenFromParam(c *gin.Context) (*models.Token, bool) {
+	orgId, exists := c.Get(middleware.ORGANISATION_ID_KEY)
+	if !exists {
+		c.String(http.StatusForbidden, "Not allowed to access this resource")
+		return nil, false
+	}
+
+	tokenId, err := strconv.Atoi(c.Param("tokenId"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid token id")
+		return nil, false
+	}
+
+	token, err := models.DB.GetTokenById(orgId, uint(tokenId))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.String(http.StatusNotFound, "Could not find token")
+		} else {
+			log.Printf("Error fetching token: %v", err)
+			c.String(http.StatusInternalServerError, "Unknown error occurred while fetching database")
+		}
+		return nil, false
+	}
+	return token, true
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/backend/webhooks"
 	"gorm.io/gorm"
 )
 
@@ -16,6 +17,7 @@ func (lock BackendDBLock) Lock(lockId int, resource string) (bool, error) {
 	if err != nil {
 		return false, fmt.Errorf("could not create lock record: %v", err)
 	}
+	webhooks.Emit(lock.OrgId, webhooks.EventLockAcquired, webhooks.LockEventData{Resource: resource, PrNumber: lockId})
 	return true, nil
 }
 
@@ -31,6 +33,7 @@ func (lock BackendDBLock) Unlock(resource string) (bool, error) {
 	if err != nil {
 		return false, fmt.Errorf("could not delete lock record: %v", err)
 	}
+	webhooks.Emit(lock.OrgId, webhooks.EventLockReleased, webhooks.LockEventData{Resource: resource, PrNumber: theLock.LockId})
 
 	return true, nil
 }
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"github.com/diggerhq/digger/backend/models"
+	orchestrator_scheduler "github.com/diggerhq/digger/libs/orchestrator/scheduler"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	WebhookEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "digger_webhook_events_total",
+		Help: "Webhook events received, by source, event type and result.",
+	}, []string{"source", "event", "result"})
+
+	DispatchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "digger_ci_dispatch_duration_seconds",
+		Help:    "Time taken to dispatch a job to a CI backend.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"backend"})
+
+	DispatchFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "digger_ci_dispatch_failures_total",
+		Help: "Jobs that could not be dispatched to a CI backend.",
+	}, []string{"backend"})
+
+	LockContention = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "digger_lock_contention_total",
+		Help: "Lock attempts on projects already locked by another pull request.",
+	})
+
+	GithubApiRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "digger_github_api_requests_total",
+		Help: "Requests made to the GitHub API, by method and status code.",
+	}, []string{"method", "code"})
+)
+
+var (
+	jobsDesc       = prometheus.NewDesc("digger_jobs", "Digger jobs by status.", []string{"status"}, nil)
+	batchesDesc    = prometheus.NewDesc("digger_batches", "Digger batches by status.", []string{"status"}, nil)
+	queueDepthDesc = prometheus.NewDesc("digger_job_queue_depth", "Jobs waiting for a run to be dequeued.", nil, nil)
+)
+
+// statusCollector reads job and batch counts from the database on every scrape
+type statusCollector struct{}
+
+func (statusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- jobsDesc
+	ch <- batchesDesc
+	ch <- queueDepthDesc
+}
+
+func (statusCollector) Collect(ch chan<- prometheus.Metric) {
+	if models.DB == nil {
+		return
+	}
+
+	jobCounts, err := models.DB.CountDiggerJobsByStatus()
+	if err != nil {
+		log.Printf("Failed to count jobs for metrics: %v", err)
+	} else {
+		for status, name := range models.JobStatusNames {
+			ch <- prometheus.MustNewConstMetric(jobsDesc, prometheus.GaugeValue, float64(jobCounts[status]), name)
+		}
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(jobCounts[orchestrator_scheduler.DiggerJobQueuedForRun]))
+	}
+
+	batchCounts, err := models.DB.CountDiggerBatchesByStatus()
+	if err != nil {
+		log.Printf("Failed to count batches for metrics: %v", err)
+	} else {
+		for status, name := range models.BatchStatusNames {
+			ch <- prometheus.MustNewConstMetric(batchesDesc, prometheus.GaugeValue, float64(batchCounts[status]), name)
+		}
+	}
+}
+
+func init() {
+	prometheus.MustRegister(statusCollector{})
+}
+
+// Handler serves the metrics in the prometheus exposition format
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// WebhookMiddleware counts webhook events, the event type is read from eventHeader
+func WebhookMiddleware(source string, eventHeader string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		result := "success"
+		if c.Writer.Status() >= http.StatusBadRequest {
+			result = "error"
+		}
+		event := c.GetHeader(eventHeader)
+		if event == "" {
+			event = "unknown"
+		}
+		WebhookEvents.WithLabelValues(source, event, result).Inc()
+	}
+}
+
+// ObserveDispatch records the latency and outcome of dispatching a job to a CI backend
+func ObserveDispatch(backend string, startedAt time.Time, err error) {
+	DispatchDuration.WithLabelValues(backend).Observe(time.Since(startedAt).Seconds())
+	if err != nil {
+		DispatchFailures.WithLabelValues(backend).Inc()
+	}
+}
+
+// GithubTransport counts the requests going through the wrapped transport
+type GithubTransport struct {
+	Base http.RoundTripper
+}
+
+func (t GithubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Base.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	GithubApiRequests.WithLabelValues(req.Method, code).Inc()
+	return resp, err
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/libs/orchestrator"
+	orchestrator_scheduler "github.com/diggerhq/digger/libs/orchestrator/scheduler"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWebhookMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/webhook", WebhookMiddleware("github", "X-GitHub-Event"), func(c *gin.Context) {
+		if c.Query("fail") != "" {
+			c.String(http.StatusInternalServerError, "failed")
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+
+	send := func(path string) {
+		req := httptest.NewRequest("POST", path, nil)
+		req.Header.Set("X-GitHub-Event", "pull_request")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	before := testutil.ToFloat64(WebhookEvents.WithLabelValues("github", "pull_request", "success"))
+	send("/webhook")
+	send("/webhook")
+	send("/webhook?fail=1")
+
+	assert.Equal(t, before+2, testutil.ToFloat64(WebhookEvents.WithLabelValues("github", "pull_request", "success")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(WebhookEvents.WithLabelValues("github", "pull_request", "error")))
+}
+
+func TestGithubTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: GithubTransport{Base: http.DefaultTransport}}
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(GithubApiRequests.WithLabelValues("GET", "404")))
+}
+
+func TestStatusCollector(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "metrics.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, gdb.AutoMigrate(&models.DiggerBatch{}, &models.DiggerJob{}, &models.DiggerJobSummary{}))
+	models.DB = &models.Database{GormDB: gdb}
+	defer func() { models.DB = nil }()
+
+	batch, err := models.DB.CreateDiggerBatch(1, "diggerhq", "demo", "diggerhq/demo", 1, "", "main", orchestrator.DiggerCommandPlan, nil)
+	assert.NoError(t, err)
+	for _, status := range []orchestrator_scheduler.DiggerJobStatus{orchestrator_scheduler.DiggerJobQueuedForRun,
+		orchestrator_scheduler.DiggerJobQueuedForRun, orchestrator_scheduler.DiggerJobSucceeded} {
+		job, err := models.DB.CreateDiggerJob(batch.ID, []byte("{}"), "digger_workflow.yml")
+		assert.NoError(t, err)
+		job.Status = status
+		assert.NoError(t, models.DB.UpdateDiggerJob(job))
+	}
+
+	expected := `
+# HELP digger_job_queue_depth Jobs waiting for a run to be dequeued.
+# TYPE digger_job_queue_depth gauge
+digger_job_queue_depth 2
+`
+	assert.NoError(t, testutil.CollectAndCompare(statusCollector{}, strings.NewReader(expected), "digger_job_queue_depth"))
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(statusCollector{})
+	count, err := testutil.GatherAndCount(registry, "digger_jobs", "digger_batches")
+	assert.NoError(t, err)
+	assert.Equal(t, len(models.JobStatusNames)+len(models.BatchStatusNames), count)
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const AGENT_POOL_KEY = "agent_pool"
+
+// AgentTokenAuth only accepts agent tokens, the routes of agents can't be called with any other credentials
+func AgentTokenAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
+		if !strings.HasPrefix(token, "a:") {
+			c.String(http.StatusForbidden, "Could not find agent token in Authorization header")
+			c.Abort()
+			return
+		}
+		if _, err := CheckAgentToken(c, token); err != nil {
+			c.String(http.StatusForbidden, err.Error())
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func CheckAgentToken(c *gin.Context, value string) (*models.AgentToken, error) {
+	token, err := models.DB.GetAgentToken(value)
+	if err != nil {
+		log.Printf("Error while fetching agent token from database: %v", err)
+		return nil, fmt.Errorf("could not fetch token")
+	}
+	if token == nil {
+		return nil, fmt.Errorf("invalid agent token")
+	}
+
+	// only record usage once a minute to avoid a write for every poll
+	if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > time.Minute {
+		if err := models.DB.UpdateAgentTokenLastUsed(token); err != nil {
+			log.Printf("Error while updating agent token last used time: %v", err)
+		}
+	}
+
+	c.Set(ORGANISATION_ID_KEY, token.OrganisationID)
+	c.Set(AGENT_POOL_KEY, token.Pool)
+	c.Set(SUBJECT_KEY, fmt.Sprintf("agent_token:%v", token.ID))
+	return token, nil
+}
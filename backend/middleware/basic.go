@@ -3,6 +3,7 @@ package middleware
 import (
 	"fmt"
 	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/backend/services"
 	"github.com/gin-gonic/gin"
 	"log"
 	"net/http"
@@ -59,6 +60,22 @@ func HttpBasicApiAuth() gin.HandlerFunc {
 			} else {
 				setDefaultOrganisationId(c)
 				c.Set(ACCESS_LEVEL_KEY, jobToken.Type)
+				// job tokens may only be used for the repo of their job
+				if jobToken.RepoName != "" {
+					c.Set(REPO_RESTRICTION_KEY, jobToken.RepoName)
+				}
+			}
+		} else if strings.HasPrefix(token, "t:") {
+			if _, err := CheckApiToken(c, token); err != nil {
+				c.String(http.StatusForbidden, err.Error())
+				c.Abort()
+				return
+			}
+		} else if provider, ok := services.FindOidcProvider(token, oidcProviders); ok {
+			if err := CheckOidcToken(c, *provider, token); err != nil {
+				c.String(http.StatusForbidden, err.Error())
+				c.Abort()
+				return
 			}
 		} else if token == os.Getenv("BEARER_AUTH_TOKEN") {
 			setDefaultOrganisationId(c)
@@ -47,6 +47,14 @@ func SetContextParameters(c *gin.Context, auth services.Auth, token *jwt.Token)
 
 		tokenType := claims["type"].(string)
 
+		if sub, ok := claims["sub"].(string); ok && sub != "" {
+			if tokenType == "tenantAccessToken" {
+				c.Set(SUBJECT_KEY, "tenant-token:"+sub)
+			} else {
+				c.Set(SUBJECT_KEY, "user:"+sub)
+			}
+		}
+
 		permissions := make([]string, 0)
 		if tokenType == "tenantAccessToken" {
 			permission, err := auth.FetchTokenPermissions(claims["sub"].(string))
@@ -206,25 +214,23 @@ func JWTBearerTokenAuth(auth services.Auth) gin.HandlerFunc {
 			} else {
 				c.Set(ORGANISATION_ID_KEY, jobToken.OrganisationID)
 				c.Set(ACCESS_LEVEL_KEY, jobToken.Type)
+				// job tokens may only be used for the repo of their job
+				if jobToken.RepoName != "" {
+					c.Set(REPO_RESTRICTION_KEY, jobToken.RepoName)
+				}
 			}
 		} else if strings.HasPrefix(token, "t:") {
-			var dbToken models.Token
-
-			token, err := models.DB.GetToken(token)
-			if token == nil {
-				c.String(http.StatusForbidden, "Invalid bearer token")
+			if _, err := CheckApiToken(c, token); err != nil {
+				c.String(http.StatusForbidden, err.Error())
 				c.Abort()
 				return
 			}
-
-			if err != nil {
-				log.Printf("Error while fetching token from database: %v", err)
-				c.String(http.StatusInternalServerError, "Error occurred while fetching database")
+		} else if provider, ok := services.FindOidcProvider(token, oidcProviders); ok {
+			if err := CheckOidcToken(c, *provider, token); err != nil {
+				c.String(http.StatusForbidden, err.Error())
 				c.Abort()
 				return
 			}
-			c.Set(ORGANISATION_ID_KEY, dbToken.OrganisationID)
-			c.Set(ACCESS_LEVEL_KEY, dbToken.Type)
 		} else {
 			jwtPublicKey := os.Getenv("JWT_PUBLIC_KEY")
 			if jwtPublicKey == "" {
@@ -309,3 +315,6 @@ func CORSMiddleware() gin.HandlerFunc {
 
 const ORGANISATION_ID_KEY = "organisation_ID"
 const ACCESS_LEVEL_KEY = "access_level"
+const SUBJECT_KEY = "subject"
+const TOKEN_SCOPES_KEY = "token_scopes"
+const REPO_RESTRICTION_KEY = "repo_restriction"
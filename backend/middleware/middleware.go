@@ -34,6 +34,11 @@ func GetWebMiddleware() gin.HandlerFunc {
 }
 
 func GetApiMiddleware() gin.HandlerFunc {
+	oidcProviders = services.GetOidcProviders()
+	for _, provider := range oidcProviders {
+		log.Printf("Accepting %v oidc tokens from issuer %v", provider.Name, provider.Issuer)
+	}
+
 	if _, ok := os.LookupEnv("JWT_AUTH"); ok {
 		log.Printf("Using JWT middleware for API routes")
 		auth := services.Auth{
@@ -79,3 +84,32 @@ func CheckJobToken(c *gin.Context, token string) (*models.JobToken, error) {
 	log.Printf("Token: %v access level: %v", jobToken.Value, jobToken.Type)
 	return jobToken, nil
 }
+
+// CheckApiToken validates a "t:" api token and sets the organisation, access level, subject and scopes
+func CheckApiToken(c *gin.Context, value string) (*models.Token, error) {
+	token, err := models.DB.GetToken(value)
+	if err != nil {
+		log.Printf("Error while fetching token from database: %v", err)
+		return nil, fmt.Errorf("could not fetch token")
+	}
+	if token == nil {
+		return nil, fmt.Errorf("invalid bearer token")
+	}
+	if token.IsExpired() {
+		log.Printf("Token %v has already expired", token.Prefix)
+		return nil, fmt.Errorf("token has expired")
+	}
+
+	// only record usage once a minute to avoid a write for every request
+	if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > time.Minute {
+		if err := models.DB.UpdateTokenLastUsed(token); err != nil {
+			log.Printf("Error while updating token last used time: %v", err)
+		}
+	}
+
+	c.Set(ORGANISATION_ID_KEY, token.OrganisationID)
+	c.Set(ACCESS_LEVEL_KEY, token.Type)
+	c.Set(SUBJECT_KEY, fmt.Sprintf("token:%v", token.ID))
+	c.Set(TOKEN_SCOPES_KEY, token.ScopeList())
+	return token, nil
+}
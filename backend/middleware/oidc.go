@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/backend/services"
+	"github.com/gin-gonic/gin"
+	"log"
+)
+
+var oidcProviders []services.OidcProvider
+var jwksCache = services.NewJwksCache()
+
+// CheckOidcToken validates a CI identity token and maps its repository claim to the organisation.
+// If the route targets a job, the job must belong to the same repository and branch.
+func CheckOidcToken(c *gin.Context, provider services.OidcProvider, tokenString string) error {
+	claims, err := provider.ValidateToken(tokenString, jwksCache)
+	if err != nil {
+		log.Printf("Invalid %v oidc token: %v", provider.Name, err)
+		return fmt.Errorf("invalid oidc token")
+	}
+
+	repoFullName := provider.Repo(claims)
+	repos, err := models.DB.GetReposByFullName(repoFullName)
+	if err != nil {
+		return fmt.Errorf("could not fetch repository")
+	}
+	if len(repos) != 1 {
+		log.Printf("Found %v repos for oidc repository claim %v", len(repos), repoFullName)
+		return fmt.Errorf("repository %v is not registered in exactly one organisation", repoFullName)
+	}
+	repo := repos[0]
+
+	if jobId := c.Param("jobId"); jobId != "" {
+		job, err := models.DB.GetDiggerJob(jobId)
+		if err != nil || job.Batch == nil {
+			return fmt.Errorf("could not find job %v", jobId)
+		}
+		if job.Batch.RepoFullName != repoFullName {
+			log.Printf("Job %v belongs to %v, oidc token is for %v", jobId, job.Batch.RepoFullName, repoFullName)
+			return fmt.Errorf("token is not valid for job %v", jobId)
+		}
+		if ref := provider.Ref(claims); ref != job.Batch.BranchName {
+			log.Printf("Job %v runs on branch %v, oidc token is for %v", jobId, job.Batch.BranchName, ref)
+			return fmt.Errorf("token is not valid for job %v", jobId)
+		}
+	}
+
+	c.Set(ORGANISATION_ID_KEY, repo.OrganisationID)
+	c.Set(ACCESS_LEVEL_KEY, models.CliJobAccessType)
+	c.Set(SUBJECT_KEY, fmt.Sprintf("oidc:%v:%v", provider.Name, repoFullName))
+	c.Set(REPO_RESTRICTION_KEY, repo.Name)
+	return nil
+}
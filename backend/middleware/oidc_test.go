@@ -0,0 +1,245 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/backend/services"
+	"github.com/diggerhq/digger/libs/orchestrator"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testIssuer = "https://token.actions.githubusercontent.com"
+
+func setupSuite(tb testing.TB) (func(tb testing.TB), *models.Organisation, *models.DiggerJob) {
+	dbName := "database_middleware_test.db"
+
+	e := os.Remove(dbName)
+	if e != nil {
+		if !strings.Contains(e.Error(), "no such file or directory") {
+			log.Fatal(e)
+		}
+	}
+
+	gdb, err := gorm.Open(sqlite.Open(dbName), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = gdb.AutoMigrate(&models.Organisation{}, &models.Repo{}, &models.Project{}, &models.DiggerBatch{},
+		&models.DiggerJob{}, &models.DiggerJobSummary{}, &models.RoleBinding{}, &models.JobToken{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	database := &models.Database{GormDB: gdb}
+	models.DB = database
+
+	org, err := database.CreateOrganisation("testOrg", "test", "11111111-1111-1111-1111-111111111111")
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = database.CreateRepo("diggerhq-demo", "diggerhq/demo", "diggerhq", "demo", "", org, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	batch, err := database.CreateDiggerBatch(1, "diggerhq", "demo", "diggerhq/demo", 1, "", "feature", orchestrator.DiggerCommandPlan, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	job, err := database.CreateDiggerJob(batch.ID, []byte("{}"), "digger_workflow.yml")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return func(tb testing.TB) {
+		e := os.Remove(dbName)
+		if e != nil {
+			log.Fatal(e)
+		}
+	}, org, job
+}
+
+// setupJwks serves a locally generated jwks and configures it as the github provider
+func setupJwks(t *testing.T) (*rsa.PrivateKey, func()) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": "test-key",
+			"kty": "RSA",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwks)
+	}))
+
+	oidcProviders = []services.OidcProvider{{
+		Name:       "github",
+		Issuer:     testIssuer,
+		JwksUrl:    server.URL,
+		Audience:   "digger",
+		RepoClaims: []string{"repository"},
+		RefClaim:   "ref",
+	}}
+	jwksCache = services.NewJwksCache()
+
+	return key, func() {
+		server.Close()
+		oidcProviders = nil
+	}
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+	signed, err := token.SignedString(key)
+	assert.NoError(t, err)
+	return signed
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":        testIssuer,
+		"aud":        "digger",
+		"repository": "diggerhq/demo",
+		"ref":        "refs/heads/feature",
+		"exp":        time.Now().Add(5 * time.Minute).Unix(),
+		"iat":        time.Now().Unix(),
+	}
+}
+
+func oidcRequest(token string, jobId string) (*httptest.ResponseRecorder, *gin.Context) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/", nil)
+	c.Request.Header.Set("Authorization", "Bearer "+token)
+	if jobId != "" {
+		c.Params = gin.Params{{Key: "jobId", Value: jobId}}
+	}
+	return w, c
+}
+
+func TestOidcTokenMapsToOrganisation(t *testing.T) {
+	teardown, org, job := setupSuite(t)
+	defer teardown(t)
+	key, cleanup := setupJwks(t)
+	defer cleanup()
+
+	token := signToken(t, key, validClaims())
+	provider, ok := services.FindOidcProvider(token, oidcProviders)
+	assert.True(t, ok)
+
+	_, c := oidcRequest(token, job.DiggerJobID)
+	err := CheckOidcToken(c, *provider, token)
+	assert.NoError(t, err)
+	assert.Equal(t, org.ID, c.GetUint(ORGANISATION_ID_KEY))
+	assert.Equal(t, models.CliJobAccessType, c.GetString(ACCESS_LEVEL_KEY))
+	assert.Equal(t, "oidc:github:diggerhq/demo", c.GetString(SUBJECT_KEY))
+	assert.Equal(t, "diggerhq-demo", c.GetString(REPO_RESTRICTION_KEY))
+}
+
+func TestOidcTokenValidation(t *testing.T) {
+	teardown, _, job := setupSuite(t)
+	defer teardown(t)
+	key, cleanup := setupJwks(t)
+	defer cleanup()
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	withClaim := func(name string, value interface{}) jwt.MapClaims {
+		claims := validClaims()
+		claims[name] = value
+		return claims
+	}
+
+	cases := []struct {
+		name   string
+		token  string
+		jobId  string
+		errMsg string
+	}{
+		{"wrong signature", signToken(t, otherKey, validClaims()), "", "invalid oidc token"},
+		{"wrong audience", signToken(t, key, withClaim("aud", "someone-else")), "", "invalid oidc token"},
+		{"expired", signToken(t, key, withClaim("exp", time.Now().Add(-time.Minute).Unix())), "", "invalid oidc token"},
+		{"unknown repository", signToken(t, key, withClaim("repository", "diggerhq/other")), "", "is not registered"},
+		{"wrong branch", signToken(t, key, withClaim("ref", "refs/heads/main")), job.DiggerJobID, "not valid for job"},
+		{"unknown job", signToken(t, key, validClaims()), "missing", "could not find job"},
+	}
+
+	for _, tc := range cases {
+		_, c := oidcRequest(tc.token, tc.jobId)
+		err := CheckOidcToken(c, oidcProviders[0], tc.token)
+		if assert.Error(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.errMsg, tc.name)
+		}
+	}
+
+	_, ok := services.FindOidcProvider(signToken(t, key, withClaim("iss", "https://example.com")), oidcProviders)
+	assert.False(t, ok)
+}
+
+func TestOidcTokenThroughApiMiddleware(t *testing.T) {
+	teardown, _, job := setupSuite(t)
+	defer teardown(t)
+	key, cleanup := setupJwks(t)
+	defer cleanup()
+
+	token := signToken(t, key, validClaims())
+	w, c := oidcRequest(token, job.DiggerJobID)
+	JWTBearerTokenAuth(services.Auth{})(c)
+	assert.False(t, c.IsAborted(), w.Body.String())
+
+	// a restricted token may not act on other repos
+	allowed, err := HasPermission(c, c.GetUint(ORGANISATION_ID_KEY), models.PermissionOperate, "diggerhq-demo", "")
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+	allowed, err = HasPermission(c, c.GetUint(ORGANISATION_ID_KEY), models.PermissionOperate, "diggerhq-other", "")
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	allowed, err = HasPermission(c, c.GetUint(ORGANISATION_ID_KEY), models.PermissionPolicyWrite, "diggerhq-demo", "")
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestJobTokenIsRestrictedToItsRepo(t *testing.T) {
+	teardown, org, _ := setupSuite(t)
+	defer teardown(t)
+
+	jobToken, err := models.DB.CreateDiggerJobToken(org.ID, "diggerhq/demo")
+	assert.NoError(t, err)
+	w, c := oidcRequest(jobToken.Value, "")
+	JWTBearerTokenAuth(services.Auth{})(c)
+	assert.False(t, c.IsAborted(), w.Body.String())
+	assert.Equal(t, "diggerhq-demo", c.GetString(REPO_RESTRICTION_KEY))
+
+	allowed, err := HasPermission(c, org.ID, models.PermissionOperate, "diggerhq-demo", "")
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+	allowed, err = HasPermission(c, org.ID, models.PermissionOperate, "diggerhq-other", "")
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// RequirePermission checks the role bindings of the current subject against the repo / project the route is scoped to.
+// Subjects without any bindings fall back to the role implied by their token access level.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgId, exists := c.Get(ORGANISATION_ID_KEY)
+		if !exists {
+			c.String(http.StatusForbidden, "Not allowed to access this resource")
+			c.Abort()
+			return
+		}
+
+		repoName, projectName := resolveRouteScope(c, orgId)
+		allowed, err := HasPermission(c, orgId, permission, repoName, projectName)
+		if err != nil {
+			log.Printf("Error while checking permission %v: %v", permission, err)
+			c.String(http.StatusInternalServerError, "Error occurred while checking permissions")
+			c.Abort()
+			return
+		}
+		if !allowed {
+			c.String(http.StatusForbidden, fmt.Sprintf("Permission %v is required to access this resource", permission))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// REPO_FILTERED_KEY is set on routes without a repo scope that repo restricted tokens may use
+const REPO_FILTERED_KEY = "repo_filtered"
+
+// AllowRepoRestricted lets repo restricted tokens use a route without a repo scope, either because the data is shared by
+// all repos of the organisation or because the handler filters by the restricted repo itself
+func AllowRepoRestricted() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(REPO_FILTERED_KEY, true)
+		c.Next()
+	}
+}
+
+func HasPermission(c *gin.Context, orgId any, permission string, repoName string, projectName string) (bool, error) {
+	if scopes := c.GetStringSlice(TOKEN_SCOPES_KEY); len(scopes) > 0 && !lo.Contains(scopes, permission) {
+		return false, nil
+	}
+
+	// repo restricted tokens may only use routes of their repo, or org routes that are marked as safe for them
+	if restrictedRepo := c.GetString(REPO_RESTRICTION_KEY); restrictedRepo != "" {
+		if repoName == "" && !c.GetBool(REPO_FILTERED_KEY) {
+			return false, nil
+		}
+		if repoName != "" && repoName != restrictedRepo {
+			return false, nil
+		}
+	}
+
+	subject := c.GetString(SUBJECT_KEY)
+	if subject != "" {
+		bindings, err := models.DB.GetRoleBindingsForSubject(orgId, subject)
+		if err != nil {
+			return false, err
+		}
+		if len(bindings) > 0 {
+			for _, binding := range bindings {
+				if binding.Covers(repoName, projectName) && models.RoleHasPermission(binding.Role, permission) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+	}
+
+	role, ok := models.LegacyAccessLevelRoles[c.GetString(ACCESS_LEVEL_KEY)]
+	if !ok {
+		return false, nil
+	}
+	return models.RoleHasPermission(role, permission), nil
+}
+
+// resolveRouteScope returns the repo and project name a request targets, empty values mean the whole org
+func resolveRouteScope(c *gin.Context, orgId any) (string, string) {
+	if projectIdStr := c.Param("project_id"); projectIdStr != "" {
+		projectId, err := strconv.Atoi(projectIdStr)
+		if err != nil {
+			return "", ""
+		}
+		project, err := models.DB.GetProject(uint(projectId))
+		if err != nil || project.Repo == nil || fmt.Sprint(project.OrganisationID) != fmt.Sprint(orgId) {
+			return "", ""
+		}
+		return project.Repo.Name, project.Name
+	}
+
+	if runIdStr := c.Param("run_id"); runIdStr != "" {
+		runId, err := strconv.Atoi(runIdStr)
+		if err != nil {
+			return "", ""
+		}
+		run, err := models.DB.GetDiggerRun(uint(runId))
+		if err != nil || run.Repo == nil || fmt.Sprint(run.Repo.OrganisationID) != fmt.Sprint(orgId) {
+			return "", ""
+		}
+		return run.Repo.Name, run.ProjectName
+	}
+
+	return c.Param("repo"), c.Param("projectName")
+}
@@ -0,0 +1,19 @@
+-- Create "role_bindings" table
+CREATE TABLE "public"."role_bindings" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "subject" text NULL,
+  "role" text NULL,
+  "scope_type" text NULL,
+  "repo_name" text NULL,
+  "project_name" text NULL,
+  "organisation_id" bigint NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_role_bindings_organisation" FOREIGN KEY ("organisation_id") REFERENCES "public"."organisations" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_role_binding_subject" to table: "role_bindings"
+CREATE INDEX "idx_role_binding_subject" ON "public"."role_bindings" ("subject", "organisation_id");
+-- Create index "idx_role_bindings_deleted_at" to table: "role_bindings"
+CREATE INDEX "idx_role_bindings_deleted_at" ON "public"."role_bindings" ("deleted_at");
@@ -0,0 +1,10 @@
+-- Modify "tokens" table
+ALTER TABLE "public"."tokens" ADD COLUMN "name" text NULL, ADD COLUMN "hash" text NULL, ADD COLUMN "prefix" text NULL, ADD COLUMN "scopes" text NULL, ADD COLUMN "expires_at" timestamptz NULL, ADD COLUMN "last_used_at" timestamptz NULL;
+-- Hash existing plaintext tokens
+UPDATE "public"."tokens" SET "hash" = encode(sha256("value"::bytea), 'hex'), "prefix" = left("value", 10);
+-- Drop index "idx_token" from table: "tokens"
+DROP INDEX "public"."idx_token";
+-- Modify "tokens" table
+ALTER TABLE "public"."tokens" DROP COLUMN "value";
+-- Create index "idx_token_hash" to table: "tokens"
+CREATE UNIQUE INDEX "idx_token_hash" ON "public"."tokens" ("hash");
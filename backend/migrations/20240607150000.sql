@@ -0,0 +1,22 @@
+-- Create "audit_log_entries" table
+CREATE TABLE "public"."audit_log_entries" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "organisation_id" bigint NULL,
+  "actor" text NULL,
+  "action" text NULL,
+  "target" text NULL,
+  "diff" text NULL,
+  "source_ip" text NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_audit_log_entries_organisation" FOREIGN KEY ("organisation_id") REFERENCES "public"."organisations" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_audit_log_org_created" to table: "audit_log_entries"
+CREATE INDEX "idx_audit_log_org_created" ON "public"."audit_log_entries" ("created_at", "organisation_id");
+-- Reject updates and deletes so the audit log stays append-only
+CREATE FUNCTION "public"."audit_log_entries_append_only"() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_log_entries is append-only';
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER "audit_log_entries_append_only" BEFORE UPDATE OR DELETE ON "public"."audit_log_entries" FOR EACH ROW EXECUTE FUNCTION "public"."audit_log_entries_append_only"();
@@ -0,0 +1,39 @@
+-- Create "org_webhooks" table
+CREATE TABLE "public"."org_webhooks" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "organisation_id" bigint NULL,
+  "url" text NULL,
+  "secret" text NULL,
+  "events" text NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_org_webhooks_organisation" FOREIGN KEY ("organisation_id") REFERENCES "public"."organisations" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_org_webhook_org" to table: "org_webhooks"
+CREATE INDEX "idx_org_webhook_org" ON "public"."org_webhooks" ("organisation_id");
+-- Create index "idx_org_webhooks_deleted_at" to table: "org_webhooks"
+CREATE INDEX "idx_org_webhooks_deleted_at" ON "public"."org_webhooks" ("deleted_at");
+-- Create "webhook_deliveries" table
+CREATE TABLE "public"."webhook_deliveries" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "org_webhook_id" bigint NULL,
+  "event_id" text NULL,
+  "event_type" text NULL,
+  "payload" text NULL,
+  "status" text NULL,
+  "attempts" bigint NULL,
+  "response_code" bigint NULL,
+  "last_error" text NULL,
+  "next_attempt_at" timestamptz NULL,
+  "delivered_at" timestamptz NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_webhook_deliveries_org_webhook" FOREIGN KEY ("org_webhook_id") REFERENCES "public"."org_webhooks" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_webhook_delivery_next_attempt" to table: "webhook_deliveries"
+CREATE INDEX "idx_webhook_delivery_next_attempt" ON "public"."webhook_deliveries" ("next_attempt_at");
+-- Create index "idx_webhook_delivery_webhook" to table: "webhook_deliveries"
+CREATE INDEX "idx_webhook_delivery_webhook" ON "public"."webhook_deliveries" ("org_webhook_id");
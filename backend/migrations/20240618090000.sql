@@ -0,0 +1,43 @@
+-- Create "agent_tokens" table
+CREATE TABLE "public"."agent_tokens" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "name" text NULL,
+  "hash" text NULL,
+  "prefix" text NULL,
+  "organisation_id" bigint NULL,
+  "pool" text NULL,
+  "last_used_at" timestamptz NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_agent_tokens_organisation" FOREIGN KEY ("organisation_id") REFERENCES "public"."organisations" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_agent_token_hash" to table: "agent_tokens"
+CREATE UNIQUE INDEX "idx_agent_token_hash" ON "public"."agent_tokens" ("hash");
+-- Create index "idx_agent_tokens_deleted_at" to table: "agent_tokens"
+CREATE INDEX "idx_agent_tokens_deleted_at" ON "public"."agent_tokens" ("deleted_at");
+-- Create "agent_jobs" table
+CREATE TABLE "public"."agent_jobs" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "organisation_id" bigint NULL,
+  "pool" text NULL,
+  "status" text NULL,
+  "digger_job_id" text NULL,
+  "spec" text NULL,
+  "agent_name" text NULL,
+  "claimed_at" timestamptz NULL,
+  "heartbeat_at" timestamptz NULL,
+  "finished_at" timestamptz NULL,
+  "exit_code" bigint NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_agent_job_digger_job" to table: "agent_jobs"
+CREATE UNIQUE INDEX "idx_agent_job_digger_job" ON "public"."agent_jobs" ("digger_job_id");
+-- Create index "idx_agent_job_queue" to table: "agent_jobs"
+CREATE INDEX "idx_agent_job_queue" ON "public"."agent_jobs" ("organisation_id", "pool", "status");
+-- Create index "idx_agent_jobs_deleted_at" to table: "agent_jobs"
+CREATE INDEX "idx_agent_jobs_deleted_at" ON "public"."agent_jobs" ("deleted_at");
@@ -0,0 +1,19 @@
+-- Modify "organisations" table
+ALTER TABLE "public"."organisations" ADD COLUMN "plan_retention_days" bigint NULL;
+-- Create "plan_artifacts" table
+CREATE TABLE "public"."plan_artifacts" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "organisation_id" bigint NULL,
+  "path" text NULL,
+  "artifact_name" text NULL,
+  "size" bigint NULL,
+  "sha256" text NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_plan_artifact_org_path" to table: "plan_artifacts"
+CREATE UNIQUE INDEX "idx_plan_artifact_org_path" ON "public"."plan_artifacts" ("organisation_id", "path");
+-- Create index "idx_plan_artifacts_deleted_at" to table: "plan_artifacts"
+CREATE INDEX "idx_plan_artifacts_deleted_at" ON "public"."plan_artifacts" ("deleted_at");
@@ -0,0 +1,22 @@
+-- Modify "digger_jobs" table
+ALTER TABLE "public"."digger_jobs" ADD COLUMN "access_policy_version_id" bigint NULL, ADD COLUMN "plan_policy_version_id" bigint NULL;
+-- Modify "policies" table
+ALTER TABLE "public"."policies" ADD COLUMN "version_id" bigint NULL;
+-- Create "policy_versions" table
+CREATE TABLE "public"."policy_versions" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "policy_id" bigint NULL,
+  "organisation_id" bigint NULL,
+  "version" bigint NULL,
+  "content" text NULL,
+  "author" text NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_policy_versions_policy" FOREIGN KEY ("policy_id") REFERENCES "public"."policies" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_policy_version" to table: "policy_versions"
+CREATE UNIQUE INDEX "idx_policy_version" ON "public"."policy_versions" ("policy_id", "version");
+-- Existing policies become their first version
+INSERT INTO "public"."policy_versions" ("created_at", "policy_id", "organisation_id", "version", "content", "author")
+SELECT "updated_at", "id", "organisation_id", 1, "policy", '' FROM "public"."policies" WHERE "deleted_at" IS NULL;
+UPDATE "public"."policies" SET "version_id" = (SELECT "id" FROM "public"."policy_versions" WHERE "policy_versions"."policy_id" = "policies"."id" AND "policy_versions"."version" = 1) WHERE "deleted_at" IS NULL;
@@ -0,0 +1,25 @@
+-- Create "policy_decisions" table
+CREATE TABLE "public"."policy_decisions" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "organisation_id" bigint NULL,
+  "digger_job_id" text NULL,
+  "repo_full_name" text NULL,
+  "pr_number" bigint NULL,
+  "project" text NULL,
+  "policy_type" text NULL,
+  "policy_hash" text NULL,
+  "policy_version_id" bigint NULL,
+  "evaluated_at" timestamptz NULL,
+  "input" text NULL,
+  "allowed" boolean NULL,
+  "messages" text NULL,
+  "warnings" text NULL,
+  "error" text NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_policy_decisions_organisation" FOREIGN KEY ("organisation_id") REFERENCES "public"."organisations" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_policy_decision_job" to table: "policy_decisions"
+CREATE INDEX "idx_policy_decision_job" ON "public"."policy_decisions" ("digger_job_id");
+-- Create index "idx_policy_decision_org_pr" to table: "policy_decisions"
+CREATE INDEX "idx_policy_decision_org_pr" ON "public"."policy_decisions" ("organisation_id", "repo_full_name", "pr_number");
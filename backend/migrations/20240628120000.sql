@@ -0,0 +1,2 @@
+-- Modify "digger_job_summaries" table
+ALTER TABLE "public"."digger_job_summaries" ADD COLUMN "monthly_cost" numeric NULL, ADD COLUMN "monthly_cost_delta" numeric NULL, ADD COLUMN "cost_currency" text NULL;
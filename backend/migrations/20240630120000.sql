@@ -0,0 +1,8 @@
+-- Modify "job_tokens" table
+ALTER TABLE "public"."job_tokens" ADD COLUMN "repo_name" text NULL;
+-- Modify "plan_artifacts" table
+ALTER TABLE "public"."plan_artifacts" ADD COLUMN "repo" text NULL;
+-- Drop index "idx_plan_artifact_org_path" from table: "plan_artifacts"
+DROP INDEX "public"."idx_plan_artifact_org_path";
+-- Create index "idx_plan_artifact_org_repo_path" to table: "plan_artifacts"
+CREATE UNIQUE INDEX "idx_plan_artifact_org_repo_path" ON "public"."plan_artifacts" ("organisation_id", "repo", "path");
@@ -0,0 +1,366 @@
+-- Create "organisations" table
+CREATE TABLE `organisations` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `name` text,
+  `external_source` text,
+  `external_id` text
+);
+-- Create index "idx_external_source" to table: "organisations"
+CREATE UNIQUE INDEX `idx_external_source` ON `organisations`(`external_source`,`external_id`);
+-- Create index "idx_organisation" to table: "organisations"
+CREATE UNIQUE INDEX `idx_organisation` ON `organisations`(`name`);
+-- Create index "idx_organisations_deleted_at" to table: "organisations"
+CREATE INDEX `idx_organisations_deleted_at` ON `organisations`(`deleted_at`);
+-- Create "repos" table
+CREATE TABLE `repos` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `name` text,
+  `repo_full_name` text,
+  `repo_organisation` text,
+  `repo_name` text,
+  `repo_url` text,
+  `organisation_id` integer,
+  `digger_config` text,
+  CONSTRAINT `fk_repos_organisation` FOREIGN KEY (`organisation_id`) REFERENCES `organisations`(`id`)
+);
+-- Create index "idx_org_repo" to table: "repos"
+CREATE UNIQUE INDEX `idx_org_repo` ON `repos`(`name`,`organisation_id`);
+-- Create index "idx_repos_deleted_at" to table: "repos"
+CREATE INDEX `idx_repos_deleted_at` ON `repos`(`deleted_at`);
+-- Create "projects" table
+CREATE TABLE `projects` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `name` text,
+  `organisation_id` integer,
+  `repo_id` integer,
+  `configuration_yaml` text,
+  `status` integer,
+  CONSTRAINT `fk_projects_organisation` FOREIGN KEY (`organisation_id`) REFERENCES `organisations`(`id`),
+  CONSTRAINT `fk_projects_repo` FOREIGN KEY (`repo_id`) REFERENCES `repos`(`id`)
+);
+-- Create index "idx_project" to table: "projects"
+CREATE UNIQUE INDEX `idx_project` ON `projects`(`name`,`organisation_id`,`repo_id`);
+-- Create index "idx_projects_deleted_at" to table: "projects"
+CREATE INDEX `idx_projects_deleted_at` ON `projects`(`deleted_at`);
+-- Create "users" table
+CREATE TABLE `users` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `username` text
+);
+-- Create index "idx_user" to table: "users"
+CREATE UNIQUE INDEX `idx_user` ON `users`(`username`);
+-- Create index "idx_users_deleted_at" to table: "users"
+CREATE INDEX `idx_users_deleted_at` ON `users`(`deleted_at`);
+-- Create "policies" table
+CREATE TABLE `policies` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `project_id` integer,
+  `policy` text,
+  `type` text,
+  `created_by_id` integer,
+  `organisation_id` integer,
+  `repo_id` integer,
+  CONSTRAINT `fk_policies_project` FOREIGN KEY (`project_id`) REFERENCES `projects`(`id`),
+  CONSTRAINT `fk_policies_created_by` FOREIGN KEY (`created_by_id`) REFERENCES `users`(`id`),
+  CONSTRAINT `fk_policies_organisation` FOREIGN KEY (`organisation_id`) REFERENCES `organisations`(`id`),
+  CONSTRAINT `fk_policies_repo` FOREIGN KEY (`repo_id`) REFERENCES `repos`(`id`)
+);
+-- Create index "idx_policies_deleted_at" to table: "policies"
+CREATE INDEX `idx_policies_deleted_at` ON `policies`(`deleted_at`);
+-- Create "tokens" table
+CREATE TABLE `tokens` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `name` text,
+  `hash` text,
+  `prefix` text,
+  `organisation_id` integer,
+  `type` text,
+  `scopes` text,
+  `expires_at` datetime,
+  `last_used_at` datetime,
+  CONSTRAINT `fk_tokens_organisation` FOREIGN KEY (`organisation_id`) REFERENCES `organisations`(`id`)
+);
+-- Create index "idx_token_hash" to table: "tokens"
+CREATE UNIQUE INDEX `idx_token_hash` ON `tokens`(`hash`);
+-- Create index "idx_tokens_deleted_at" to table: "tokens"
+CREATE INDEX `idx_tokens_deleted_at` ON `tokens`(`deleted_at`);
+-- Create "project_runs" table
+CREATE TABLE `project_runs` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `project_id` integer,
+  `started_at` integer,
+  `ended_at` integer,
+  `status` text,
+  `command` text,
+  `output` text,
+  CONSTRAINT `fk_project_runs_project` FOREIGN KEY (`project_id`) REFERENCES `projects`(`id`)
+);
+-- Create index "idx_project_runs_deleted_at" to table: "project_runs"
+CREATE INDEX `idx_project_runs_deleted_at` ON `project_runs`(`deleted_at`);
+-- Create "github_app_installations" table
+CREATE TABLE `github_app_installations` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `github_installation_id` integer,
+  `github_app_id` integer,
+  `account_id` integer,
+  `login` text,
+  `repo` text,
+  `status` integer
+);
+-- Create index "idx_github_app_installations_deleted_at" to table: "github_app_installations"
+CREATE INDEX `idx_github_app_installations_deleted_at` ON `github_app_installations`(`deleted_at`);
+-- Create "github_apps" table
+CREATE TABLE `github_apps` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `github_id` integer,
+  `name` text,
+  `github_app_url` text
+);
+-- Create index "idx_github_apps_deleted_at" to table: "github_apps"
+CREATE INDEX `idx_github_apps_deleted_at` ON `github_apps`(`deleted_at`);
+-- Create "github_app_installation_links" table
+CREATE TABLE `github_app_installation_links` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `github_installation_id` integer,
+  `organisation_id` integer,
+  `status` integer,
+  CONSTRAINT `fk_github_app_installation_links_organisation` FOREIGN KEY (`organisation_id`) REFERENCES `organisations`(`id`)
+);
+-- Create index "idx_github_app_installation_links_deleted_at" to table: "github_app_installation_links"
+CREATE INDEX `idx_github_app_installation_links_deleted_at` ON `github_app_installation_links`(`deleted_at`);
+-- Create index "idx_github_installation_org" to table: "github_app_installation_links"
+CREATE INDEX `idx_github_installation_org` ON `github_app_installation_links`(`github_installation_id`,`organisation_id`);
+-- Create "github_digger_job_links" table
+CREATE TABLE `github_digger_job_links` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `digger_job_id` text,
+  `repo_full_name` text,
+  `github_job_id` integer,
+  `github_workflow_run_id` integer,
+  `status` integer
+);
+-- Create index "idx_github_job_id" to table: "github_digger_job_links"
+CREATE INDEX `idx_github_job_id` ON `github_digger_job_links`(`github_job_id`);
+-- Create index "idx_github_digger_job_links_deleted_at" to table: "github_digger_job_links"
+CREATE INDEX `idx_github_digger_job_links_deleted_at` ON `github_digger_job_links`(`deleted_at`);
+-- Create "digger_batches" table
+CREATE TABLE `digger_batches` (
+  `id` text,
+  `pr_number` integer,
+  `comment_id` integer,
+  `status` integer,
+  `branch_name` text,
+  `digger_config` text,
+  `github_installation_id` integer,
+  `repo_full_name` text,
+  `repo_owner` text,
+  `repo_name` text,
+  `batch_type` text,
+  `source_details` blob,
+  PRIMARY KEY (`id`)
+);
+-- Create "digger_job_summaries" table
+CREATE TABLE `digger_job_summaries` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `resources_created` integer,
+  `resources_deleted` integer,
+  `resources_updated` integer
+);
+-- Create index "idx_digger_job_summaries_deleted_at" to table: "digger_job_summaries"
+CREATE INDEX `idx_digger_job_summaries_deleted_at` ON `digger_job_summaries`(`deleted_at`);
+-- Create "digger_jobs" table
+CREATE TABLE `digger_jobs` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `digger_job_id` text,
+  `status` integer,
+  `batch_id` text,
+  `pr_comment_url` text,
+  `digger_job_summary_id` integer,
+  `serialized_job_spec` blob,
+  `terraform_output` text,
+  `plan_footprint` blob,
+  `workflow_file` text,
+  `workflow_run_url` text,
+  `status_updated_at` datetime,
+  CONSTRAINT `fk_digger_jobs_batch` FOREIGN KEY (`batch_id`) REFERENCES `digger_batches`(`id`),
+  CONSTRAINT `fk_digger_jobs_digger_job_summary` FOREIGN KEY (`digger_job_summary_id`) REFERENCES `digger_job_summaries`(`id`)
+);
+-- Create index "idx_digger_job_id" to table: "digger_jobs"
+CREATE INDEX `idx_digger_job_id` ON `digger_jobs`(`batch_id`);
+-- Create index "idx_digger_jobs_deleted_at" to table: "digger_jobs"
+CREATE INDEX `idx_digger_jobs_deleted_at` ON `digger_jobs`(`deleted_at`);
+-- Create "digger_job_parent_links" table
+CREATE TABLE `digger_job_parent_links` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `digger_job_id` text,
+  `parent_digger_job_id` text
+);
+-- Create index "idx_digger_job_parent_links_deleted_at" to table: "digger_job_parent_links"
+CREATE INDEX `idx_digger_job_parent_links_deleted_at` ON `digger_job_parent_links`(`deleted_at`);
+-- Create "job_tokens" table
+CREATE TABLE `job_tokens` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `value` text,
+  `expiry` datetime,
+  `organisation_id` integer,
+  `type` text,
+  CONSTRAINT `fk_job_tokens_organisation` FOREIGN KEY (`organisation_id`) REFERENCES `organisations`(`id`)
+);
+-- Create index "idx_job_tokens_deleted_at" to table: "job_tokens"
+CREATE INDEX `idx_job_tokens_deleted_at` ON `job_tokens`(`deleted_at`);
+-- Create "digger_run_stages" table
+CREATE TABLE `digger_run_stages` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `batch_id` text,
+  CONSTRAINT `fk_digger_run_stages_batch` FOREIGN KEY (`batch_id`) REFERENCES `digger_batches`(`id`)
+);
+-- Create index "idx_digger_run_batch_id" to table: "digger_run_stages"
+CREATE INDEX `idx_digger_run_batch_id` ON `digger_run_stages`(`batch_id`);
+-- Create index "idx_digger_run_stages_deleted_at" to table: "digger_run_stages"
+CREATE INDEX `idx_digger_run_stages_deleted_at` ON `digger_run_stages`(`deleted_at`);
+-- Create "digger_runs" table
+CREATE TABLE `digger_runs` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `triggertype` text,
+  `pr_number` integer,
+  `status` text,
+  `commit_id` text,
+  `digger_config` text,
+  `github_installation_id` integer,
+  `repo_id` integer,
+  `project_name` text,
+  `run_type` text,
+  `plan_stage_id` integer,
+  `apply_stage_id` integer,
+  `is_approved` numeric,
+  `approval_author` text,
+  `approval_date` datetime,
+  CONSTRAINT `fk_digger_runs_repo` FOREIGN KEY (`repo_id`) REFERENCES `repos`(`id`),
+  CONSTRAINT `fk_digger_runs_plan_stage` FOREIGN KEY (`plan_stage_id`) REFERENCES `digger_run_stages`(`id`),
+  CONSTRAINT `fk_digger_runs_apply_stage` FOREIGN KEY (`apply_stage_id`) REFERENCES `digger_run_stages`(`id`)
+);
+-- Create index "idx_digger_runs_deleted_at" to table: "digger_runs"
+CREATE INDEX `idx_digger_runs_deleted_at` ON `digger_runs`(`deleted_at`);
+-- Create "digger_run_queue_items" table
+CREATE TABLE `digger_run_queue_items` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `digger_run_id` integer,
+  `project_id` integer,
+  CONSTRAINT `fk_digger_run_queue_items_digger_run` FOREIGN KEY (`digger_run_id`) REFERENCES `digger_runs`(`id`),
+  CONSTRAINT `fk_digger_run_queue_items_project` FOREIGN KEY (`project_id`) REFERENCES `projects`(`id`)
+);
+-- Create index "idx_digger_run_queue_items_deleted_at" to table: "digger_run_queue_items"
+CREATE INDEX `idx_digger_run_queue_items_deleted_at` ON `digger_run_queue_items`(`deleted_at`);
+-- Create index "idx_digger_run_queue_run_id" to table: "digger_run_queue_items"
+CREATE INDEX `idx_digger_run_queue_run_id` ON `digger_run_queue_items`(`digger_run_id`);
+-- Create "digger_locks" table
+CREATE TABLE `digger_locks` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `resource` text,
+  `lock_id` integer,
+  `organisation_id` integer,
+  CONSTRAINT `fk_digger_locks_organisation` FOREIGN KEY (`organisation_id`) REFERENCES `organisations`(`id`)
+);
+-- Create index "idx_digger_locked_resource" to table: "digger_locks"
+CREATE INDEX `idx_digger_locked_resource` ON `digger_locks`(`resource`);
+-- Create index "idx_digger_locks_deleted_at" to table: "digger_locks"
+CREATE INDEX `idx_digger_locks_deleted_at` ON `digger_locks`(`deleted_at`);
+-- Create "role_bindings" table
+CREATE TABLE `role_bindings` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `subject` text,
+  `role` text,
+  `scope_type` text,
+  `repo_name` text,
+  `project_name` text,
+  `organisation_id` integer,
+  CONSTRAINT `fk_role_bindings_organisation` FOREIGN KEY (`organisation_id`) REFERENCES `organisations`(`id`)
+);
+-- Create index "idx_role_binding_subject" to table: "role_bindings"
+CREATE INDEX `idx_role_binding_subject` ON `role_bindings`(`subject`,`organisation_id`);
+-- Create index "idx_role_bindings_deleted_at" to table: "role_bindings"
+CREATE INDEX `idx_role_bindings_deleted_at` ON `role_bindings`(`deleted_at`);
+-- Create "audit_log_entries" table
+CREATE TABLE `audit_log_entries` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `organisation_id` integer,
+  `actor` text,
+  `action` text,
+  `target` text,
+  `diff` text,
+  `source_ip` text,
+  CONSTRAINT `fk_audit_log_entries_organisation` FOREIGN KEY (`organisation_id`) REFERENCES `organisations`(`id`)
+);
+-- Create index "idx_audit_log_org_created" to table: "audit_log_entries"
+CREATE INDEX `idx_audit_log_org_created` ON `audit_log_entries`(`created_at`,`organisation_id`);
+-- Create trigger "audit_log_entries_no_update" to table: "audit_log_entries"
+CREATE TRIGGER `audit_log_entries_no_update` BEFORE UPDATE ON `audit_log_entries`
+BEGIN
+  SELECT RAISE(ABORT, 'audit_log_entries is append-only');
+END;
+-- Create trigger "audit_log_entries_no_delete" to table: "audit_log_entries"
+CREATE TRIGGER `audit_log_entries_no_delete` BEFORE DELETE ON `audit_log_entries`
+BEGIN
+  SELECT RAISE(ABORT, 'audit_log_entries is append-only');
+END;
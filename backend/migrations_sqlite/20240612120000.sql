@@ -0,0 +1,37 @@
+-- Create "org_webhooks" table
+CREATE TABLE `org_webhooks` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `organisation_id` integer,
+  `url` text,
+  `secret` text,
+  `events` text,
+  CONSTRAINT `fk_org_webhooks_organisation` FOREIGN KEY (`organisation_id`) REFERENCES `organisations`(`id`)
+);
+-- Create index "idx_org_webhook_org" to table: "org_webhooks"
+CREATE INDEX `idx_org_webhook_org` ON `org_webhooks`(`organisation_id`);
+-- Create index "idx_org_webhooks_deleted_at" to table: "org_webhooks"
+CREATE INDEX `idx_org_webhooks_deleted_at` ON `org_webhooks`(`deleted_at`);
+-- Create "webhook_deliveries" table
+CREATE TABLE `webhook_deliveries` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `org_webhook_id` integer,
+  `event_id` text,
+  `event_type` text,
+  `payload` text,
+  `status` text,
+  `attempts` integer,
+  `response_code` integer,
+  `last_error` text,
+  `next_attempt_at` datetime,
+  `delivered_at` datetime,
+  CONSTRAINT `fk_webhook_deliveries_org_webhook` FOREIGN KEY (`org_webhook_id`) REFERENCES `org_webhooks`(`id`)
+);
+-- Create index "idx_webhook_delivery_next_attempt" to table: "webhook_deliveries"
+CREATE INDEX `idx_webhook_delivery_next_attempt` ON `webhook_deliveries`(`next_attempt_at`);
+-- Create index "idx_webhook_delivery_webhook" to table: "webhook_deliveries"
+CREATE INDEX `idx_webhook_delivery_webhook` ON `webhook_deliveries`(`org_webhook_id`);
@@ -0,0 +1,2 @@
+-- Add column "vcs" to table: "digger_batches"
+ALTER TABLE `digger_batches` ADD COLUMN `vcs` text NULL DEFAULT 'github';
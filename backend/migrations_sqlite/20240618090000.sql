@@ -0,0 +1,41 @@
+-- Create "agent_tokens" table
+CREATE TABLE `agent_tokens` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `name` text,
+  `hash` text,
+  `prefix` text,
+  `organisation_id` integer,
+  `pool` text,
+  `last_used_at` datetime,
+  CONSTRAINT `fk_agent_tokens_organisation` FOREIGN KEY (`organisation_id`) REFERENCES `organisations`(`id`)
+);
+-- Create index "idx_agent_token_hash" to table: "agent_tokens"
+CREATE UNIQUE INDEX `idx_agent_token_hash` ON `agent_tokens`(`hash`);
+-- Create index "idx_agent_tokens_deleted_at" to table: "agent_tokens"
+CREATE INDEX `idx_agent_tokens_deleted_at` ON `agent_tokens`(`deleted_at`);
+-- Create "agent_jobs" table
+CREATE TABLE `agent_jobs` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `organisation_id` integer,
+  `pool` text,
+  `status` text,
+  `digger_job_id` text,
+  `spec` text,
+  `agent_name` text,
+  `claimed_at` datetime,
+  `heartbeat_at` datetime,
+  `finished_at` datetime,
+  `exit_code` integer
+);
+-- Create index "idx_agent_job_digger_job" to table: "agent_jobs"
+CREATE UNIQUE INDEX `idx_agent_job_digger_job` ON `agent_jobs`(`digger_job_id`);
+-- Create index "idx_agent_job_queue" to table: "agent_jobs"
+CREATE INDEX `idx_agent_job_queue` ON `agent_jobs`(`organisation_id`, `pool`, `status`);
+-- Create index "idx_agent_jobs_deleted_at" to table: "agent_jobs"
+CREATE INDEX `idx_agent_jobs_deleted_at` ON `agent_jobs`(`deleted_at`);
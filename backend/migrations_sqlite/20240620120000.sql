@@ -0,0 +1,18 @@
+-- Add column "plan_retention_days" to table: "organisations"
+ALTER TABLE `organisations` ADD COLUMN `plan_retention_days` integer NULL;
+-- Create "plan_artifacts" table
+CREATE TABLE `plan_artifacts` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `organisation_id` integer,
+  `path` text,
+  `artifact_name` text,
+  `size` integer,
+  `sha256` text
+);
+-- Create index "idx_plan_artifact_org_path" to table: "plan_artifacts"
+CREATE UNIQUE INDEX `idx_plan_artifact_org_path` ON `plan_artifacts`(`organisation_id`, `path`);
+-- Create index "idx_plan_artifacts_deleted_at" to table: "plan_artifacts"
+CREATE INDEX `idx_plan_artifacts_deleted_at` ON `plan_artifacts`(`deleted_at`);
@@ -0,0 +1,23 @@
+-- Add column "access_policy_version_id" to table: "digger_jobs"
+ALTER TABLE `digger_jobs` ADD COLUMN `access_policy_version_id` integer NULL;
+-- Add column "plan_policy_version_id" to table: "digger_jobs"
+ALTER TABLE `digger_jobs` ADD COLUMN `plan_policy_version_id` integer NULL;
+-- Add column "version_id" to table: "policies"
+ALTER TABLE `policies` ADD COLUMN `version_id` integer NULL;
+-- Create "policy_versions" table
+CREATE TABLE `policy_versions` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `policy_id` integer,
+  `organisation_id` integer,
+  `version` integer,
+  `content` text,
+  `author` text,
+  CONSTRAINT `fk_policy_versions_policy` FOREIGN KEY (`policy_id`) REFERENCES `policies` (`id`) ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_policy_version" to table: "policy_versions"
+CREATE UNIQUE INDEX `idx_policy_version` ON `policy_versions`(`policy_id`, `version`);
+-- Existing policies become their first version
+INSERT INTO `policy_versions` (`created_at`, `policy_id`, `organisation_id`, `version`, `content`, `author`)
+SELECT `updated_at`, `id`, `organisation_id`, 1, `policy`, '' FROM `policies` WHERE `deleted_at` IS NULL;
+UPDATE `policies` SET `version_id` = (SELECT `id` FROM `policy_versions` WHERE `policy_versions`.`policy_id` = `policies`.`id` AND `policy_versions`.`version` = 1) WHERE `deleted_at` IS NULL;
@@ -0,0 +1,24 @@
+-- Create "policy_decisions" table
+CREATE TABLE `policy_decisions` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `created_at` datetime,
+  `organisation_id` integer,
+  `digger_job_id` text,
+  `repo_full_name` text,
+  `pr_number` integer,
+  `project` text,
+  `policy_type` text,
+  `policy_hash` text,
+  `policy_version_id` integer,
+  `evaluated_at` datetime,
+  `input` text,
+  `allowed` numeric,
+  `messages` text,
+  `warnings` text,
+  `error` text,
+  CONSTRAINT `fk_policy_decisions_organisation` FOREIGN KEY (`organisation_id`) REFERENCES `organisations` (`id`) ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_policy_decision_job" to table: "policy_decisions"
+CREATE INDEX `idx_policy_decision_job` ON `policy_decisions`(`digger_job_id`);
+-- Create index "idx_policy_decision_org_pr" to table: "policy_decisions"
+CREATE INDEX `idx_policy_decision_org_pr` ON `policy_decisions`(`organisation_id`, `repo_full_name`, `pr_number`);
@@ -0,0 +1,6 @@
+-- Add column "monthly_cost" to table: "digger_job_summaries"
+ALTER TABLE `digger_job_summaries` ADD COLUMN `monthly_cost` real NULL;
+-- Add column "monthly_cost_delta" to table: "digger_job_summaries"
+ALTER TABLE `digger_job_summaries` ADD COLUMN `monthly_cost_delta` real NULL;
+-- Add column "cost_currency" to table: "digger_job_summaries"
+ALTER TABLE `digger_job_summaries` ADD COLUMN `cost_currency` text NULL;
@@ -0,0 +1,8 @@
+-- Add column "repo_name" to table: "job_tokens"
+ALTER TABLE `job_tokens` ADD COLUMN `repo_name` text NULL;
+-- Add column "repo" to table: "plan_artifacts"
+ALTER TABLE `plan_artifacts` ADD COLUMN `repo` text NULL;
+-- Drop index "idx_plan_artifact_org_path" from table: "plan_artifacts"
+DROP INDEX `idx_plan_artifact_org_path`;
+-- Create index "idx_plan_artifact_org_repo_path" to table: "plan_artifacts"
+CREATE UNIQUE INDEX `idx_plan_artifact_org_repo_path` ON `plan_artifacts`(`organisation_id`, `repo`, `path`);
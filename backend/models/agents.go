@@ -0,0 +1,62 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+const DefaultAgentPool = "default"
+
+const (
+	AgentJobQueued   = "queued"
+	AgentJobClaimed  = "claimed"
+	AgentJobFinished = "finished"
+	// AgentJobLost is set when the agent stopped sending heartbeats before finishing the job
+	AgentJobLost = "lost"
+)
+
+// AgentToken lets agents of a pool claim jobs of the organisation, only the sha256 hash of its value is stored
+type AgentToken struct {
+	gorm.Model
+	Name           string
+	Hash           string `gorm:"uniqueIndex:idx_agent_token_hash"`
+	Prefix         string
+	OrganisationID uint
+	Organisation   *Organisation
+	Pool           string
+	LastUsedAt     *time.Time
+}
+
+func (t *AgentToken) MapToJsonStruct() interface{} {
+	return struct {
+		Id         uint       `json:"id"`
+		Name       string     `json:"name"`
+		Prefix     string     `json:"prefix"`
+		Pool       string     `json:"pool"`
+		CreatedAt  time.Time  `json:"created_at"`
+		LastUsedAt *time.Time `json:"last_used_at"`
+	}{
+		Id:         t.ID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Pool:       t.Pool,
+		CreatedAt:  t.CreatedAt,
+		LastUsedAt: t.LastUsedAt,
+	}
+}
+
+// AgentJob is a digger job waiting for, or being run by, an agent of its pool
+type AgentJob struct {
+	gorm.Model
+	OrganisationID uint   `gorm:"index:idx_agent_job_queue"`
+	Pool           string `gorm:"index:idx_agent_job_queue"`
+	Status         string `gorm:"index:idx_agent_job_queue"`
+	DiggerJobID    string `gorm:"uniqueIndex:idx_agent_job_digger_job"`
+	// Spec is the json of the spec.Spec the agent runs
+	Spec        string
+	AgentName   string
+	ClaimedAt   *time.Time
+	HeartbeatAt *time.Time
+	FinishedAt  *time.Time
+	ExitCode    *int
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"github.com/pmezard/go-difflib/difflib"
+	"time"
+)
+
+const (
+	AuditActionPolicyUpsert        = "policy.upsert"
+	AuditActionPolicyRollback      = "policy.rollback"
+	AuditActionRunApprove          = "run.approve"
+	AuditActionProjectUnlock       = "project.unlock"
+	AuditActionTokenIssue          = "token.issue"
+	AuditActionTokenRotate         = "token.rotate"
+	AuditActionTokenRevoke         = "token.revoke"
+	AuditActionRoleBindingCreate   = "role_binding.create"
+	AuditActionRoleBindingDelete   = "role_binding.delete"
+	AuditActionWebhookCreate       = "webhook.create"
+	AuditActionWebhookDelete       = "webhook.delete"
+	AuditActionAgentTokenCreate    = "agent_token.create"
+	AuditActionAgentTokenDelete    = "agent_token.delete"
+	AuditActionPlanRetentionUpdate = "plan_retention.update"
+	AuditActionDestroyConfirm      = "destroy.confirm"
+)
+
+// AuditLogEntry records a state changing action, entries are never updated or deleted
+type AuditLogEntry struct {
+	ID             uint      `gorm:"primarykey"`
+	CreatedAt      time.Time `gorm:"index:idx_audit_log_org_created"`
+	OrganisationID uint      `gorm:"index:idx_audit_log_org_created"`
+	Organisation   *Organisation
+	Actor          string
+	Action         string
+	Target         string
+	Diff           string
+	SourceIp       string
+}
+
+type AuditLogFilter struct {
+	Action string
+	Actor  string
+	Target string
+	Since  *time.Time
+	Until  *time.Time
+	Limit  int
+}
+
+// AuditDiff returns a unified diff between two versions of a document such as a policy
+func AuditDiff(before string, after string) string {
+	if before == after {
+		return ""
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(before),
+		B:        difflib.SplitLines(after),
+		FromFile: "before",
+		ToFile:   "after",
+		Context:  3,
+	})
+	if err != nil {
+		return ""
+	}
+	return diff
+}
+
+func (a *AuditLogEntry) MapToJsonStruct() interface{} {
+	return struct {
+		Id             uint      `json:"id"`
+		Timestamp      time.Time `json:"timestamp"`
+		OrganisationId uint      `json:"organisation_id"`
+		Actor          string    `json:"actor"`
+		Action         string    `json:"action"`
+		Target         string    `json:"target"`
+		Diff           string    `json:"diff,omitempty"`
+		SourceIp       string    `json:"source_ip"`
+	}{
+		Id:             a.ID,
+		Timestamp:      a.CreatedAt,
+		OrganisationId: a.OrganisationID,
+		Actor:          a.Actor,
+		Action:         a.Action,
+		Target:         a.Target,
+		Diff:           a.Diff,
+		SourceIp:       a.SourceIp,
+	}
+}
@@ -1,7 +1,10 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"gorm.io/gorm"
+	"strings"
 	"time"
 )
 
@@ -10,6 +13,8 @@ type Organisation struct {
 	Name           string `gorm:"uniqueIndex:idx_organisation"`
 	ExternalSource string `gorm:"uniqueIndex:idx_external_source"`
 	ExternalId     string `gorm:"uniqueIndex:idx_external_source"`
+	// PlanRetentionDays overrides how long stored plans are kept, see DefaultPlanRetentionDays
+	PlanRetentionDays *int
 }
 
 type Repo struct {
@@ -112,12 +117,57 @@ func (p *Project) MapToJsonStruct() interface{} {
 
 }
 
+// Token is an api token, only the sha256 hash of its value is stored
 type Token struct {
 	gorm.Model
-	Value          string `gorm:"uniqueIndex:idx_token"`
+	Name           string
+	Hash           string `gorm:"uniqueIndex:idx_token_hash"`
+	Prefix         string
 	OrganisationID uint
 	Organisation   *Organisation
 	Type           string
+	// comma separated permissions the token is restricted to, empty means no restriction
+	Scopes     string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+}
+
+func HashToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t *Token) ScopeList() []string {
+	if t.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(t.Scopes, ",")
+}
+
+func (t *Token) IsExpired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
+
+func (t *Token) MapToJsonStruct() interface{} {
+	return struct {
+		Id         uint       `json:"id"`
+		Name       string     `json:"name"`
+		Prefix     string     `json:"prefix"`
+		Type       string     `json:"type"`
+		Scopes     []string   `json:"scopes"`
+		CreatedAt  time.Time  `json:"created_at"`
+		ExpiresAt  *time.Time `json:"expires_at"`
+		LastUsedAt *time.Time `json:"last_used_at"`
+	}{
+		Id:         t.ID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Type:       t.Type,
+		Scopes:     t.ScopeList(),
+		CreatedAt:  t.CreatedAt,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+	}
 }
 
 const (
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// DefaultPlanRetentionDays applies to organisations without their own setting when PLAN_RETENTION_DAYS is not set
+const DefaultPlanRetentionDays = 30
+
+// PlanArtifact records a plan file kept in the blob store of the backend, Path is the stored plan path of the cli
+// and Repo the digger repo name the plan belongs to, it is empty for plans uploaded before plans were scoped by repo
+type PlanArtifact struct {
+	gorm.Model
+	OrganisationID uint   `gorm:"uniqueIndex:idx_plan_artifact_org_repo_path"`
+	Repo           string `gorm:"uniqueIndex:idx_plan_artifact_org_repo_path"`
+	Path           string `gorm:"uniqueIndex:idx_plan_artifact_org_repo_path"`
+	ArtifactName   string
+	Size           int64
+	Sha256         string
+}
+
+func (p *PlanArtifact) MapToJsonStruct() interface{} {
+	return struct {
+		Repo         string    `json:"repo"`
+		Path         string    `json:"path"`
+		ArtifactName string    `json:"artifact_name"`
+		Size         int64     `json:"size"`
+		Sha256       string    `json:"sha256"`
+		UpdatedAt    time.Time `json:"updated_at"`
+	}{
+		Repo:         p.Repo,
+		Path:         p.Path,
+		ArtifactName: p.ArtifactName,
+		Size:         p.Size,
+		Sha256:       p.Sha256,
+		UpdatedAt:    p.UpdatedAt,
+	}
+}
@@ -1,6 +1,9 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"gorm.io/gorm"
+	"time"
+)
 
 const (
 	POLICY_TYPE_ACCESS = "access"
@@ -20,4 +23,34 @@ type Policy struct {
 	OrganisationID uint
 	Repo           *Repo
 	RepoID         *uint
+	// VersionID is the id of the current PolicyVersion
+	VersionID *uint
+}
+
+// PolicyVersion is an immutable snapshot of a policy, every upsert and rollback adds one
+type PolicyVersion struct {
+	ID             uint `gorm:"primarykey"`
+	CreatedAt      time.Time
+	PolicyID       uint `gorm:"uniqueIndex:idx_policy_version"`
+	Policy         *Policy
+	OrganisationID uint
+	Version        uint `gorm:"uniqueIndex:idx_policy_version"`
+	Content        string
+	Author         string
+}
+
+func (v *PolicyVersion) MapToJsonStruct() interface{} {
+	return struct {
+		Id        uint      `json:"id"`
+		Version   uint      `json:"version"`
+		Author    string    `json:"author"`
+		CreatedAt time.Time `json:"created_at"`
+		Policy    string    `json:"policy"`
+	}{
+		Id:        v.ID,
+		Version:   v.Version,
+		Author:    v.Author,
+		CreatedAt: v.CreatedAt,
+		Policy:    v.Content,
+	}
 }
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PolicyDecision records a policy evaluation reported by a job, the input is redacted by the cli before it is sent
+type PolicyDecision struct {
+	ID              uint `gorm:"primarykey"`
+	CreatedAt       time.Time
+	OrganisationID  uint `gorm:"index:idx_policy_decision_org_pr"`
+	Organisation    *Organisation
+	DiggerJobID     string `gorm:"index:idx_policy_decision_job"`
+	RepoFullName    string `gorm:"index:idx_policy_decision_org_pr"`
+	PrNumber        *int   `gorm:"index:idx_policy_decision_org_pr"`
+	Project         string
+	PolicyType      string
+	PolicyHash      string
+	PolicyVersionID *uint
+	EvaluatedAt     time.Time
+	Input           string
+	Allowed         bool
+	// Messages and Warnings are JSON lists
+	Messages string
+	Warnings string
+	Error    string
+}
+
+type PolicyDecisionFilter struct {
+	JobId        string
+	RepoFullName string
+	PrNumber     *int
+	Project      string
+	PolicyType   string
+	Limit        int
+}
+
+func (d *PolicyDecision) MapToJsonStruct() interface{} {
+	var messages, warnings []string
+	json.Unmarshal([]byte(d.Messages), &messages)
+	json.Unmarshal([]byte(d.Warnings), &warnings)
+	if messages == nil {
+		messages = []string{}
+	}
+	if warnings == nil {
+		warnings = []string{}
+	}
+	return struct {
+		Id              uint            `json:"id"`
+		JobId           string          `json:"job_id"`
+		RepoFullName    string          `json:"repo_full_name"`
+		PrNumber        *int            `json:"pr_number"`
+		Project         string          `json:"project"`
+		PolicyType      string          `json:"policy_type"`
+		PolicyHash      string          `json:"policy_hash"`
+		PolicyVersionId *uint           `json:"policy_version_id"`
+		EvaluatedAt     time.Time       `json:"evaluated_at"`
+		Input           json.RawMessage `json:"input"`
+		Allowed         bool            `json:"allowed"`
+		Messages        []string        `json:"messages"`
+		Warnings        []string        `json:"warnings"`
+		Error           string          `json:"error,omitempty"`
+	}{
+		Id:              d.ID,
+		JobId:           d.DiggerJobID,
+		RepoFullName:    d.RepoFullName,
+		PrNumber:        d.PrNumber,
+		Project:         d.Project,
+		PolicyType:      d.PolicyType,
+		PolicyHash:      d.PolicyHash,
+		PolicyVersionId: d.PolicyVersionID,
+		EvaluatedAt:     d.EvaluatedAt,
+		Input:           json.RawMessage(d.Input),
+		Allowed:         d.Allowed,
+		Messages:        messages,
+		Warnings:        warnings,
+		Error:           d.Error,
+	}
+}
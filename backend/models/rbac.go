@@ -0,0 +1,106 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	RoleViewer      = "viewer"
+	RoleOperator    = "operator"
+	RolePolicyAdmin = "policy-admin"
+	RoleOrgAdmin    = "org-admin"
+)
+
+const (
+	RoleScopeOrg     = "org"
+	RoleScopeRepo    = "repo"
+	RoleScopeProject = "project"
+)
+
+const (
+	// PermissionRead allows reading projects, runs and policies
+	PermissionRead = "read"
+	// PermissionOperate allows creating runs, reporting projects and job statuses, approving runs
+	PermissionOperate = "operate"
+	// PermissionPolicyWrite allows creating and updating policies
+	PermissionPolicyWrite = "policy:write"
+	// PermissionAdmin allows managing tokens and role bindings
+	PermissionAdmin = "admin"
+)
+
+var RolePermissions = map[string][]string{
+	RoleViewer:      {PermissionRead},
+	RoleOperator:    {PermissionRead, PermissionOperate},
+	RolePolicyAdmin: {PermissionRead, PermissionPolicyWrite},
+	RoleOrgAdmin:    {PermissionRead, PermissionOperate, PermissionPolicyWrite, PermissionAdmin},
+}
+
+// LegacyAccessLevelRoles maps the coarse token access levels to a role, used when a subject has no bindings.
+// Access tokens are used by the cli to report runs and job statuses hence they map to operator.
+var LegacyAccessLevelRoles = map[string]string{
+	AccessPolicyType: RoleOperator,
+	CliJobAccessType: RoleOperator,
+	AdminPolicyType:  RoleOrgAdmin,
+}
+
+// RoleBinding grants a role to a subject (e.g. "user:<id>" or "token:<id>") within an org, repo or project
+type RoleBinding struct {
+	gorm.Model
+	Subject        string `gorm:"index:idx_role_binding_subject"`
+	Role           string
+	ScopeType      string
+	RepoName       string
+	ProjectName    string
+	OrganisationID uint `gorm:"index:idx_role_binding_subject"`
+	Organisation   *Organisation
+}
+
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+func IsValidPermission(permission string) bool {
+	return RoleHasPermission(RoleOrgAdmin, permission)
+}
+
+func RoleHasPermission(role string, permission string) bool {
+	for _, p := range RolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers returns true if the binding scope includes the given repo and project, empty values mean org level
+func (b *RoleBinding) Covers(repoName string, projectName string) bool {
+	switch b.ScopeType {
+	case RoleScopeOrg:
+		return true
+	case RoleScopeRepo:
+		return repoName != "" && b.RepoName == repoName
+	case RoleScopeProject:
+		return repoName != "" && projectName != "" && b.RepoName == repoName && b.ProjectName == projectName
+	default:
+		return false
+	}
+}
+
+func (b *RoleBinding) MapToJsonStruct() interface{} {
+	return struct {
+		Id          uint   `json:"id"`
+		Subject     string `json:"subject"`
+		Role        string `json:"role"`
+		ScopeType   string `json:"scope_type"`
+		RepoName    string `json:"repo,omitempty"`
+		ProjectName string `json:"project,omitempty"`
+	}{
+		Id:          b.ID,
+		Subject:     b.Subject,
+		Role:        b.Role,
+		ScopeType:   b.ScopeType,
+		RepoName:    b.RepoName,
+		ProjectName: b.ProjectName,
+	}
+}
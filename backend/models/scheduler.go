@@ -11,12 +11,38 @@ import (
 	"time"
 )
 
+// JobStatusNames are the names used for job statuses in metrics and events
+var JobStatusNames = map[orchestrator_scheduler.DiggerJobStatus]string{
+	orchestrator_scheduler.DiggerJobCreated:      "created",
+	orchestrator_scheduler.DiggerJobTriggered:    "triggered",
+	orchestrator_scheduler.DiggerJobFailed:       "failed",
+	orchestrator_scheduler.DiggerJobStarted:      "started",
+	orchestrator_scheduler.DiggerJobSucceeded:    "succeeded",
+	orchestrator_scheduler.DiggerJobQueuedForRun: "queued_for_run",
+}
+
+// BatchStatusNames are the names used for batch statuses in metrics and events
+var BatchStatusNames = map[orchestrator_scheduler.DiggerBatchStatus]string{
+	orchestrator_scheduler.BatchJobCreated:     "created",
+	orchestrator_scheduler.BatchJobStarted:     "started",
+	orchestrator_scheduler.BatchJobFailed:      "failed",
+	orchestrator_scheduler.BatchJobSucceeded:   "succeeded",
+	orchestrator_scheduler.BatchJobInvalidated: "invalidated",
+}
+
 type DiggerJobParentLink struct {
 	gorm.Model
 	DiggerJobId       string `gorm:"size:50,index:idx_digger_job_id"`
 	ParentDiggerJobId string `gorm:"size:50,index:idx_parent_digger_job_id"`
 }
 
+type DiggerVCSType string
+
+const (
+	DiggerVCSGithub DiggerVCSType = "github"
+	DiggerVCSGitlab DiggerVCSType = "gitlab"
+)
+
 type DiggerBatch struct {
 	ID                   uuid.UUID `gorm:"primary_key"`
 	PrNumber             int
@@ -29,6 +55,8 @@ type DiggerBatch struct {
 	RepoOwner            string
 	RepoName             string
 	BatchType            orchestrator.DiggerCommand
+	// VCS is where the pull request lives, batches created before it was added are github batches
+	VCS DiggerVCSType `gorm:"default:github"`
 	// used for module source grouping comments
 	SourceDetails []byte
 }
@@ -49,6 +77,9 @@ type DiggerJob struct {
 	WorkflowFile    string
 	WorkflowRunUrl  *string
 	StatusUpdatedAt time.Time
+	// versions of the policies the job was evaluated against
+	AccessPolicyVersionID *uint
+	PlanPolicyVersionID   *uint
 }
 
 type DiggerJobSummary struct {
@@ -56,6 +87,10 @@ type DiggerJobSummary struct {
 	ResourcesCreated uint
 	ResourcesDeleted uint
 	ResourcesUpdated uint
+	// estimated monthly cost after applying the plan and its change, not set when the plan was not estimated
+	MonthlyCost      *float64
+	MonthlyCostDelta *float64
+	CostCurrency     string
 }
 
 // These tokens will be pre
@@ -66,6 +101,7 @@ type JobToken struct {
 	OrganisationID uint
 	Organisation   Organisation
 	Type           string // AccessTokenType starts with j:
+	RepoName       string // the digger repo name of the job, tokens created before it was recorded have none
 }
 
 type DiggerJobLinkStatus int8
@@ -92,16 +128,21 @@ func (j *DiggerJob) MapToJsonStruct() (orchestrator_scheduler.SerializedJob, err
 		log.Printf("Failed to convert unmarshall Serialized job, %v", err)
 	}
 	return orchestrator_scheduler.SerializedJob{
-		DiggerJobId:      j.DiggerJobID,
-		Status:           j.Status,
-		JobString:        j.SerializedJobSpec,
-		PlanFootprint:    j.PlanFootprint,
-		ProjectName:      job.ProjectName,
-		WorkflowRunUrl:   j.WorkflowRunUrl,
-		PRCommentUrl:     j.PRCommentUrl,
-		ResourcesCreated: j.DiggerJobSummary.ResourcesCreated,
-		ResourcesUpdated: j.DiggerJobSummary.ResourcesUpdated,
-		ResourcesDeleted: j.DiggerJobSummary.ResourcesDeleted,
+		DiggerJobId:           j.DiggerJobID,
+		Status:                j.Status,
+		JobString:             j.SerializedJobSpec,
+		PlanFootprint:         j.PlanFootprint,
+		ProjectName:           job.ProjectName,
+		WorkflowRunUrl:        j.WorkflowRunUrl,
+		PRCommentUrl:          j.PRCommentUrl,
+		ResourcesCreated:      j.DiggerJobSummary.ResourcesCreated,
+		ResourcesUpdated:      j.DiggerJobSummary.ResourcesUpdated,
+		ResourcesDeleted:      j.DiggerJobSummary.ResourcesDeleted,
+		MonthlyCost:           j.DiggerJobSummary.MonthlyCost,
+		MonthlyCostDelta:      j.DiggerJobSummary.MonthlyCostDelta,
+		CostCurrency:          j.DiggerJobSummary.CostCurrency,
+		AccessPolicyVersionId: j.AccessPolicyVersionID,
+		PlanPolicyVersionId:   j.PlanPolicyVersionID,
 	}, nil
 }
 func (b *DiggerBatch) MapToJsonStruct() (orchestrator_scheduler.SerializedBatch, error) {
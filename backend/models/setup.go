@@ -1,12 +1,16 @@
 package models
 
 import (
+	"fmt"
 	"gorm.io/driver/postgres"
 	_ "gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"log"
+	"net/url"
 	"os"
+	"strings"
 )
 
 type Database struct {
@@ -20,7 +24,13 @@ var DB *Database
 
 func ConnectDatabase() {
 
-	database, err := gorm.Open(postgres.Open(os.Getenv("DATABASE_URL")), &gorm.Config{
+	dialector, err := GetDialector(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Printf("Invalid DATABASE_URL: %v", err)
+		panic("Failed to connect to database!")
+	}
+
+	database, err := gorm.Open(dialector, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	})
 
@@ -38,3 +48,28 @@ func ConnectDatabase() {
 	}
 
 }
+
+// GetDialector picks the gorm driver from the scheme of the database url, sqlite://path/to/digger.db selects sqlite
+// and anything else is passed to postgres. Sqlite databases are migrated with the migrations_sqlite directory
+func GetDialector(databaseUrl string) (gorm.Dialector, error) {
+	if !strings.HasPrefix(databaseUrl, "sqlite://") {
+		return postgres.Open(databaseUrl), nil
+	}
+
+	path, rawQuery, _ := strings.Cut(strings.TrimPrefix(databaseUrl, "sqlite://"), "?")
+	if path == "" {
+		return nil, fmt.Errorf("sqlite database url has no path: %v", databaseUrl)
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse sqlite database url options: %v", err)
+	}
+	// sqlite doesn't enforce foreign keys by default and fails immediately on a locked database
+	if !query.Has("_foreign_keys") && !query.Has("_fk") {
+		query.Set("_foreign_keys", "on")
+	}
+	if !query.Has("_busy_timeout") && !query.Has("_timeout") {
+		query.Set("_busy_timeout", "5000")
+	}
+	return sqlite.Open(fmt.Sprintf("file:%v?%v", path, query.Encode())), nil
+}
@@ -13,6 +13,7 @@ import (
 	"gorm.io/gorm"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -299,6 +300,16 @@ func (db *Database) GetRepo(orgIdKey any, repoName string) (*Repo, error) {
 }
 
 // GetRepoById returns digger repo by organisationId and repo name (diggerhq-digger)
+func (db *Database) GetReposByFullName(repoFullName string) ([]Repo, error) {
+	var repos []Repo
+	err := db.GormDB.Where("repo_full_name = ?", repoFullName).Find(&repos).Error
+	if err != nil {
+		log.Printf("Failed to find digger repos for repoFullName: %v, error: %v\n", repoFullName, err)
+		return nil, err
+	}
+	return repos, nil
+}
+
 func (db *Database) GetRepoById(orgIdKey any, repoId any) (*Repo, error) {
 	var repo Repo
 
@@ -870,6 +881,25 @@ func (db *Database) UpdateDiggerJobSummary(diggerJobId string, resourcesCreated
 	return diggerJob, nil
 }
 
+func (db *Database) UpdateDiggerJobCostEstimate(diggerJobId string, monthlyCost *float64, monthlyCostDelta *float64, costCurrency string) (*DiggerJob, error) {
+	diggerJob, err := db.GetDiggerJob(diggerJobId)
+	if err != nil {
+		return nil, fmt.Errorf("Could not get digger job")
+	}
+	jobSummary := &diggerJob.DiggerJobSummary
+	jobSummary.MonthlyCost = monthlyCost
+	jobSummary.MonthlyCostDelta = monthlyCostDelta
+	jobSummary.CostCurrency = costCurrency
+
+	result := db.GormDB.Save(jobSummary)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	log.Printf("DiggerJob %v cost estimate has been updated successfully\n", diggerJobId)
+	return diggerJob, nil
+}
+
 func (db *Database) UpdateDiggerJob(job *DiggerJob) error {
 	result := db.GormDB.Save(job)
 	if result.Error != nil {
@@ -924,6 +954,38 @@ func (db *Database) GetDiggerJobsWithStatus(status scheduler.DiggerJobStatus) ([
 	return jobs, nil
 }
 
+func (db *Database) CountDiggerJobsByStatus() (map[scheduler.DiggerJobStatus]int64, error) {
+	var rows []struct {
+		Status scheduler.DiggerJobStatus
+		Count  int64
+	}
+	result := db.GormDB.Model(&DiggerJob{}).Select("status, count(*) as count").Group("status").Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	counts := make(map[scheduler.DiggerJobStatus]int64)
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+func (db *Database) CountDiggerBatchesByStatus() (map[scheduler.DiggerBatchStatus]int64, error) {
+	var rows []struct {
+		Status scheduler.DiggerBatchStatus
+		Count  int64
+	}
+	result := db.GormDB.Model(&DiggerBatch{}).Select("status, count(*) as count").Group("status").Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	counts := make(map[scheduler.DiggerBatchStatus]int64)
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
 func (db *Database) GetPendingParentDiggerJobs(batchId *uuid.UUID) ([]DiggerJob, error) {
 	jobs := make([]DiggerJob, 0)
 
@@ -1055,9 +1117,33 @@ func (db *Database) CreateRepo(name string, repoFullName string, repoOrganisatio
 	return &repo, nil
 }
 
-func (db *Database) GetToken(tenantId any) (*Token, error) {
+// CreateToken creates an api token and returns it along with its plaintext value, which is not stored
+func (db *Database) CreateToken(orgId uint, name string, tokenType string, scopes []string, expiresAt *time.Time) (*Token, string, error) {
+	// prefixing token to make easier to retire this type of tokens later
+	value := "t:" + uuid.New().String()
+	token := &Token{
+		Name:           name,
+		Hash:           HashToken(value),
+		Prefix:         value[:10],
+		OrganisationID: orgId,
+		Type:           tokenType,
+		Scopes:         strings.Join(scopes, ","),
+		ExpiresAt:      expiresAt,
+	}
+	result := db.GormDB.Create(token)
+	if result.Error != nil {
+		log.Printf("Failed to create token: %v, error: %v\n", name, result.Error)
+		return nil, "", result.Error
+	}
+	log.Printf("Token %v (id: %v) has been created successfully\n", token.Prefix, token.ID)
+	return token, value, nil
+}
+
+// GetToken looks up a token by its plaintext value
+// if record doesn't exist return nil
+func (db *Database) GetToken(value string) (*Token, error) {
 	token := &Token{}
-	result := db.GormDB.Take(token, "value = ?", tenantId)
+	result := db.GormDB.Take(token, "hash = ?", HashToken(value))
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -1068,7 +1154,90 @@ func (db *Database) GetToken(tenantId any) (*Token, error) {
 	return token, nil
 }
 
-func (db *Database) CreateDiggerJobToken(organisationId uint) (*JobToken, error) {
+func (db *Database) GetTokenById(orgId any, tokenId uint) (*Token, error) {
+	token := &Token{}
+	result := db.GormDB.Take(token, "organisation_id = ? AND id = ?", orgId, tokenId)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return token, nil
+}
+
+func (db *Database) GetTokensForOrg(orgId any) ([]Token, error) {
+	var tokens []Token
+	result := db.GormDB.Where("organisation_id = ?", orgId).Order("id").Find(&tokens)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return tokens, nil
+}
+
+func (db *Database) UpdateTokenLastUsed(token *Token) error {
+	now := time.Now()
+	result := db.GormDB.Model(token).UpdateColumn("last_used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	token.LastUsedAt = &now
+	return nil
+}
+
+// RevokeToken soft deletes the token so it is kept for auditing
+func (db *Database) RevokeToken(token *Token) error {
+	result := db.GormDB.Delete(token)
+	if result.Error != nil {
+		return result.Error
+	}
+	log.Printf("Token %v (id: %v) has been revoked\n", token.Prefix, token.ID)
+	return nil
+}
+
+// RotateToken revokes the token and issues a new one with the same name, type, scopes and lifetime
+func (db *Database) RotateToken(token *Token) (*Token, string, error) {
+	var expiresAt *time.Time
+	if token.ExpiresAt != nil {
+		lifetime := token.ExpiresAt.Sub(token.CreatedAt)
+		newExpiry := time.Now().Add(lifetime)
+		expiresAt = &newExpiry
+	}
+
+	var newToken *Token
+	var value string
+	err := db.GormDB.Transaction(func(tx *gorm.DB) error {
+		txDb := &Database{GormDB: tx}
+		var err error
+		newToken, value, err = txDb.CreateToken(token.OrganisationID, token.Name, token.Type, token.ScopeList(), expiresAt)
+		if err != nil {
+			return err
+		}
+		return txDb.RevokeToken(token)
+	})
+	if err != nil {
+		log.Printf("Failed to rotate token %v: %v\n", token.ID, err)
+		return nil, "", err
+	}
+	return newToken, value, nil
+}
+
+// DeleteExpiredTokens revokes expired api tokens and removes expired job tokens
+func (db *Database) DeleteExpiredTokens() (int64, int64, error) {
+	now := time.Now()
+	result := db.GormDB.Where("expires_at IS NOT NULL AND expires_at < ?", now).Delete(&Token{})
+	if result.Error != nil {
+		return 0, 0, result.Error
+	}
+	tokensRevoked := result.RowsAffected
+
+	result = db.GormDB.Unscoped().Where("expiry < ?", now).Delete(&JobToken{})
+	if result.Error != nil {
+		return tokensRevoked, 0, result.Error
+	}
+	log.Printf("Revoked %v expired tokens and deleted %v expired job tokens\n", tokensRevoked, result.RowsAffected)
+	return tokensRevoked, result.RowsAffected, nil
+}
+
+// CreateDiggerJobToken creates a token for a job of the repo, the token can't be used for the routes of other repos
+func (db *Database) CreateDiggerJobToken(organisationId uint, repoFullName string) (*JobToken, error) {
 
 	// create a digger job token
 	// prefixing token to make easier to retire this type of tokens later
@@ -1076,6 +1245,7 @@ func (db *Database) CreateDiggerJobToken(organisationId uint) (*JobToken, error)
 	jobToken := &JobToken{
 		Value:          token,
 		OrganisationID: organisationId,
+		RepoName:       strings.ReplaceAll(repoFullName, "/", "-"),
 		Type:           CliJobAccessType,
 		Expiry:         time.Now().Add(time.Hour * 2), // some jobs can take >30 mins (k8s cluster)
 	}
@@ -1224,3 +1394,519 @@ func (db *Database) DeleteDiggerLock(lock *DiggerLock) error {
 	log.Printf("DeleteDiggerLock %v %v has been deleted successfully\n", lock.LockId, lock.Resource)
 	return nil
 }
+
+func (db *Database) CreateRoleBinding(orgId uint, subject string, role string, scopeType string, repoName string, projectName string) (*RoleBinding, error) {
+	binding := &RoleBinding{
+		Subject:        subject,
+		Role:           role,
+		ScopeType:      scopeType,
+		RepoName:       repoName,
+		ProjectName:    projectName,
+		OrganisationID: orgId,
+	}
+	result := db.GormDB.Create(binding)
+	if result.Error != nil {
+		log.Printf("Failed to create role binding for subject: %v, error: %v\n", subject, result.Error)
+		return nil, result.Error
+	}
+	log.Printf("RoleBinding %v (id: %v) has been created successfully\n", role, binding.ID)
+	return binding, nil
+}
+
+func (db *Database) GetRoleBindingsForOrg(orgId any) ([]RoleBinding, error) {
+	var bindings []RoleBinding
+	result := db.GormDB.Where("organisation_id = ?", orgId).Order("id").Find(&bindings)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return bindings, nil
+}
+
+func (db *Database) GetRoleBindingsForSubject(orgId any, subject string) ([]RoleBinding, error) {
+	var bindings []RoleBinding
+	result := db.GormDB.Where("organisation_id = ? AND subject = ?", orgId, subject).Find(&bindings)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return bindings, nil
+}
+
+func (db *Database) DeleteRoleBinding(orgId any, bindingId uint) error {
+	result := db.GormDB.Where("organisation_id = ? AND id = ?", orgId, bindingId).Delete(&RoleBinding{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	log.Printf("RoleBinding %v has been deleted successfully\n", bindingId)
+	return nil
+}
+
+func (db *Database) CreateAuditLogEntry(orgId uint, actor string, action string, target string, diff string, sourceIp string) (*AuditLogEntry, error) {
+	entry := &AuditLogEntry{
+		OrganisationID: orgId,
+		Actor:          actor,
+		Action:         action,
+		Target:         target,
+		Diff:           diff,
+		SourceIp:       sourceIp,
+	}
+	result := db.GormDB.Create(entry)
+	if result.Error != nil {
+		log.Printf("Failed to create audit log entry for action: %v, error: %v\n", action, result.Error)
+		return nil, result.Error
+	}
+	return entry, nil
+}
+
+func (db *Database) GetAuditLogEntries(orgId any, filter AuditLogFilter) ([]AuditLogEntry, error) {
+	var entries []AuditLogEntry
+	query := db.GormDB.Where("organisation_id = ?", orgId)
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Target != "" {
+		query = query.Where("target = ?", filter.Target)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	result := query.Order("id").Find(&entries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return entries, nil
+}
+
+func (db *Database) CreateOrgWebhook(orgId uint, url string, secret string, events []string) (*OrgWebhook, error) {
+	webhook := &OrgWebhook{
+		OrganisationID: orgId,
+		Url:            url,
+		Secret:         secret,
+		Events:         strings.Join(events, ","),
+	}
+	result := db.GormDB.Create(webhook)
+	if result.Error != nil {
+		log.Printf("Failed to create webhook for org: %v, error: %v\n", orgId, result.Error)
+		return nil, result.Error
+	}
+	log.Printf("OrgWebhook %v has been created successfully\n", webhook.ID)
+	return webhook, nil
+}
+
+func (db *Database) GetOrgWebhooks(orgId any) ([]OrgWebhook, error) {
+	var webhooks []OrgWebhook
+	result := db.GormDB.Where("organisation_id = ?", orgId).Order("id").Find(&webhooks)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return webhooks, nil
+}
+
+func (db *Database) GetOrgWebhook(orgId any, webhookId uint) (*OrgWebhook, error) {
+	webhook := &OrgWebhook{}
+	result := db.GormDB.Take(webhook, "organisation_id = ? AND id = ?", orgId, webhookId)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return webhook, nil
+}
+
+func (db *Database) DeleteOrgWebhook(orgId any, webhookId uint) error {
+	result := db.GormDB.Where("organisation_id = ? AND id = ?", orgId, webhookId).Delete(&OrgWebhook{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	log.Printf("OrgWebhook %v has been deleted successfully\n", webhookId)
+	return nil
+}
+
+func (db *Database) CreateWebhookDelivery(webhook *OrgWebhook, eventId string, eventType string, payload string, nextAttemptAt time.Time) (*WebhookDelivery, error) {
+	delivery := &WebhookDelivery{
+		OrgWebhookID:  webhook.ID,
+		OrgWebhook:    webhook,
+		EventId:       eventId,
+		EventType:     eventType,
+		Payload:       payload,
+		Status:        WebhookDeliveryPending,
+		NextAttemptAt: &nextAttemptAt,
+	}
+	result := db.GormDB.Omit("OrgWebhook").Create(delivery)
+	if result.Error != nil {
+		log.Printf("Failed to create delivery of event %v for webhook %v, error: %v\n", eventId, webhook.ID, result.Error)
+		return nil, result.Error
+	}
+	return delivery, nil
+}
+
+func (db *Database) GetWebhookDelivery(webhookId uint, deliveryId uint) (*WebhookDelivery, error) {
+	delivery := &WebhookDelivery{}
+	result := db.GormDB.Preload("OrgWebhook").Take(delivery, "org_webhook_id = ? AND id = ?", webhookId, deliveryId)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return delivery, nil
+}
+
+func (db *Database) GetWebhookDeliveries(webhookId uint, limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	query := db.GormDB.Where("org_webhook_id = ?", webhookId).Order("id desc")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	result := query.Find(&deliveries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return deliveries, nil
+}
+
+// GetDueWebhookDeliveries returns pending deliveries whose next attempt is due, deliveries of deleted webhooks are skipped
+func (db *Database) GetDueWebhookDeliveries(now time.Time) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	result := db.GormDB.InnerJoins("OrgWebhook").
+		Where("webhook_deliveries.status = ? AND webhook_deliveries.next_attempt_at <= ?", WebhookDeliveryPending, now).
+		Order("webhook_deliveries.id").Find(&deliveries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return deliveries, nil
+}
+
+func (db *Database) UpdateWebhookDelivery(delivery *WebhookDelivery) error {
+	result := db.GormDB.Omit("OrgWebhook").Save(delivery)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (db *Database) CreateAgentToken(orgId uint, name string, pool string) (*AgentToken, string, error) {
+	value := "a:" + uuid.New().String()
+	token := &AgentToken{
+		Name:           name,
+		Hash:           HashToken(value),
+		Prefix:         value[:10],
+		OrganisationID: orgId,
+		Pool:           pool,
+	}
+	result := db.GormDB.Create(token)
+	if result.Error != nil {
+		log.Printf("Failed to create agent token: %v, error: %v\n", name, result.Error)
+		return nil, "", result.Error
+	}
+	log.Printf("Agent token %v (id: %v, pool: %v) has been created successfully\n", token.Prefix, token.ID, pool)
+	return token, value, nil
+}
+
+// GetAgentToken looks up an agent token by its plaintext value
+// if record doesn't exist return nil
+func (db *Database) GetAgentToken(value string) (*AgentToken, error) {
+	token := &AgentToken{}
+	result := db.GormDB.Take(token, "hash = ?", HashToken(value))
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return token, nil
+}
+
+func (db *Database) GetAgentTokens(orgId any) ([]AgentToken, error) {
+	var tokens []AgentToken
+	result := db.GormDB.Where("organisation_id = ?", orgId).Order("id").Find(&tokens)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return tokens, nil
+}
+
+func (db *Database) DeleteAgentToken(orgId any, tokenId uint) error {
+	result := db.GormDB.Where("organisation_id = ?", orgId).Delete(&AgentToken{}, tokenId)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (db *Database) UpdateAgentTokenLastUsed(token *AgentToken) error {
+	now := time.Now()
+	result := db.GormDB.Model(token).UpdateColumn("last_used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	token.LastUsedAt = &now
+	return nil
+}
+
+func (db *Database) CreateAgentJob(orgId uint, pool string, diggerJobId string, spec string) (*AgentJob, error) {
+	job := &AgentJob{
+		OrganisationID: orgId,
+		Pool:           pool,
+		Status:         AgentJobQueued,
+		DiggerJobID:    diggerJobId,
+		Spec:           spec,
+	}
+	result := db.GormDB.Create(job)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	log.Printf("Agent job for %v has been queued in pool %v\n", diggerJobId, pool)
+	return job, nil
+}
+
+// ClaimAgentJob assigns the oldest queued job of the pool to the agent, it returns nil if there is none. The
+// status is compared when updating so that two agents can never claim the same job.
+func (db *Database) ClaimAgentJob(orgId uint, pool string, agentName string) (*AgentJob, error) {
+	for {
+		job := &AgentJob{}
+		result := db.GormDB.Where("organisation_id = ? AND pool = ? AND status = ?", orgId, pool, AgentJobQueued).Order("id").Take(job)
+		if result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				return nil, nil
+			}
+			return nil, result.Error
+		}
+
+		now := time.Now()
+		result = db.GormDB.Model(&AgentJob{}).Where("id = ? AND status = ?", job.ID, AgentJobQueued).Updates(map[string]interface{}{
+			"status":       AgentJobClaimed,
+			"agent_name":   agentName,
+			"claimed_at":   now,
+			"heartbeat_at": now,
+		})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			job.Status = AgentJobClaimed
+			job.AgentName = agentName
+			job.ClaimedAt = &now
+			job.HeartbeatAt = &now
+			return job, nil
+		}
+		// another agent claimed it first, try the next one
+	}
+}
+
+// GetClaimedAgentJob returns the job if it is claimed by an agent of the pool
+// if record doesn't exist return nil
+func (db *Database) GetClaimedAgentJob(orgId uint, pool string, agentJobId uint) (*AgentJob, error) {
+	job := &AgentJob{}
+	result := db.GormDB.Take(job, "organisation_id = ? AND pool = ? AND id = ? AND status = ?", orgId, pool, agentJobId, AgentJobClaimed)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return job, nil
+}
+
+func (db *Database) UpdateAgentJob(job *AgentJob) error {
+	result := db.GormDB.Save(job)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// GetStaleAgentJobs returns claimed jobs whose last heartbeat is older than the cutoff
+func (db *Database) GetStaleAgentJobs(cutoff time.Time) ([]AgentJob, error) {
+	var jobs []AgentJob
+	result := db.GormDB.Where("status = ? AND heartbeat_at < ?", AgentJobClaimed, cutoff).Order("id").Find(&jobs)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return jobs, nil
+}
+
+// UpsertPlanArtifact records a plan upload, uploading to the same path of the repo again replaces the previous record
+func (db *Database) UpsertPlanArtifact(orgId uint, repo string, path string, artifactName string, size int64, sha256 string) (*PlanArtifact, error) {
+	artifact := &PlanArtifact{}
+	result := db.GormDB.Where(PlanArtifact{OrganisationID: orgId, Repo: repo, Path: path}).FirstOrInit(artifact)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	artifact.ArtifactName = artifactName
+	artifact.Size = size
+	artifact.Sha256 = sha256
+	result = db.GormDB.Save(artifact)
+	if result.Error != nil {
+		log.Printf("Failed to save plan artifact: %v, error: %v\n", path, result.Error)
+		return nil, result.Error
+	}
+	return artifact, nil
+}
+
+// GetPlanArtifact returns the plan stored at the path for the repo of the organisation
+// if record doesn't exist return nil
+func (db *Database) GetPlanArtifact(orgId any, repo string, path string) (*PlanArtifact, error) {
+	artifact := &PlanArtifact{}
+	result := db.GormDB.Take(artifact, "organisation_id = ? AND repo = ? AND path = ?", orgId, repo, path)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return artifact, nil
+}
+
+// DeletePlanArtifact removes the record for good so that the path can be uploaded again
+func (db *Database) DeletePlanArtifact(artifact *PlanArtifact) error {
+	result := db.GormDB.Unscoped().Delete(artifact)
+	if result.Error != nil {
+		return result.Error
+	}
+	log.Printf("Plan artifact %v of repo %v of org %v has been deleted\n", artifact.Path, artifact.Repo, artifact.OrganisationID)
+	return nil
+}
+
+// GetPlanArtifactOrgIds returns the organisations that have stored plans
+func (db *Database) GetPlanArtifactOrgIds() ([]uint, error) {
+	var orgIds []uint
+	result := db.GormDB.Model(&PlanArtifact{}).Distinct().Order("organisation_id").Pluck("organisation_id", &orgIds)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return orgIds, nil
+}
+
+// GetPlanArtifactsUpdatedBefore returns the plans of the organisation last uploaded before the cutoff
+func (db *Database) GetPlanArtifactsUpdatedBefore(orgId uint, cutoff time.Time) ([]PlanArtifact, error) {
+	var artifacts []PlanArtifact
+	result := db.GormDB.Where("organisation_id = ? AND updated_at < ?", orgId, cutoff).Order("id").Find(&artifacts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return artifacts, nil
+}
+
+// UpdateOrgPlanRetention sets how many days plans of the organisation are kept, nil restores the default
+func (db *Database) UpdateOrgPlanRetention(org *Organisation, days *int) error {
+	result := db.GormDB.Model(org).Update("plan_retention_days", days)
+	if result.Error != nil {
+		return result.Error
+	}
+	org.PlanRetentionDays = days
+	return nil
+}
+
+// SavePolicyVersion stores the content as a new version of the policy and makes it the current one,
+// the policy is created if it doesn't exist yet
+func (db *Database) SavePolicyVersion(policy *Policy, content string, author string) (*PolicyVersion, error) {
+	var version *PolicyVersion
+	err := db.GormDB.Transaction(func(tx *gorm.DB) error {
+		if policy.ID == 0 {
+			if err := tx.Create(policy).Error; err != nil {
+				return err
+			}
+		}
+		var latest uint
+		err := tx.Model(&PolicyVersion{}).Where("policy_id = ?", policy.ID).Select("COALESCE(MAX(version), 0)").Scan(&latest).Error
+		if err != nil {
+			return err
+		}
+		version = &PolicyVersion{
+			PolicyID:       policy.ID,
+			OrganisationID: policy.OrganisationID,
+			Version:        latest + 1,
+			Content:        content,
+			Author:         author,
+		}
+		if err := tx.Create(version).Error; err != nil {
+			return err
+		}
+		policy.Policy = content
+		policy.VersionID = &version.ID
+		return tx.Model(policy).Select("policy", "version_id").Updates(policy).Error
+	})
+	if err != nil {
+		log.Printf("Failed to save version of policy %v, error: %v\n", policy.ID, err)
+		return nil, err
+	}
+	return version, nil
+}
+
+// GetPolicyVersions returns all versions of the policy, newest first
+func (db *Database) GetPolicyVersions(policyId uint) ([]PolicyVersion, error) {
+	var versions []PolicyVersion
+	result := db.GormDB.Where("policy_id = ?", policyId).Order("version desc").Find(&versions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return versions, nil
+}
+
+// GetPolicyVersion returns a version of the policy
+// if record doesn't exist return nil
+func (db *Database) GetPolicyVersion(policyId uint, version uint) (*PolicyVersion, error) {
+	policyVersion := &PolicyVersion{}
+	result := db.GormDB.Take(policyVersion, "policy_id = ? AND version = ?", policyId, version)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return policyVersion, nil
+}
+
+func (db *Database) CreatePolicyDecisions(decisions []PolicyDecision) error {
+	if len(decisions) == 0 {
+		return nil
+	}
+	result := db.GormDB.Create(&decisions)
+	if result.Error != nil {
+		log.Printf("Failed to store %v policy decisions, error: %v\n", len(decisions), result.Error)
+		return result.Error
+	}
+	return nil
+}
+
+// GetPolicyDecisions returns the decisions of the organisation matching the filter in the order they were evaluated
+func (db *Database) GetPolicyDecisions(orgId any, filter PolicyDecisionFilter) ([]PolicyDecision, error) {
+	var decisions []PolicyDecision
+	query := db.GormDB.Where("organisation_id = ?", orgId)
+	if filter.JobId != "" {
+		query = query.Where("digger_job_id = ?", filter.JobId)
+	}
+	if filter.RepoFullName != "" {
+		query = query.Where("repo_full_name = ?", filter.RepoFullName)
+	}
+	if filter.PrNumber != nil {
+		query = query.Where("pr_number = ?", *filter.PrNumber)
+	}
+	if filter.Project != "" {
+		query = query.Where("project = ?", filter.Project)
+	}
+	if filter.PolicyType != "" {
+		query = query.Where("policy_type = ?", filter.PolicyType)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	result := query.Order("id").Find(&decisions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return decisions, nil
+}
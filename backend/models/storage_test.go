@@ -10,6 +10,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func setupSuite(tb testing.TB) (func(tb testing.TB), *Database, *Organisation) {
@@ -37,7 +38,7 @@ func setupSuite(tb testing.TB) (func(tb testing.TB), *Database, *Organisation) {
 	// migrate tables
 	err = gdb.AutoMigrate(&Policy{}, &Organisation{}, &Repo{}, &Project{}, &Token{},
 		&User{}, &ProjectRun{}, &GithubAppInstallation{}, &GithubApp{}, &GithubAppInstallationLink{},
-		&GithubDiggerJobLink{}, &DiggerJob{}, &DiggerJobParentLink{})
+		&GithubDiggerJobLink{}, &DiggerJob{}, &DiggerJobParentLink{}, &JobToken{}, &RoleBinding{}, &AuditLogEntry{})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -164,4 +165,198 @@ func TestGetDiggerJobsForBatchPreloadsSummary(t *testing.T) {
 	assert.Equal(t, jobssss[0].DiggerJobSummary.ResourcesCreated, resourcesCreated)
 	assert.Equal(t, jobssss[0].DiggerJobSummary.ResourcesUpdated, resourcesUpdated)
 	assert.Equal(t, jobssss[0].DiggerJobSummary.ResourcesDeleted, resourcesDeleted)
+	assert.Nil(t, jobssss[0].DiggerJobSummary.MonthlyCost)
+
+	monthlyCost, monthlyCostDelta := 73.22, 7.23
+	_, err = DB.UpdateDiggerJobCostEstimate(job.DiggerJobID, &monthlyCost, &monthlyCostDelta, "USD")
+	assert.NoError(t, err)
+
+	jobssss, err = DB.GetDiggerJobsForBatch(batch.ID)
+	assert.NoError(t, err)
+	serialized, err := jobssss[0].MapToJsonStruct()
+	assert.NoError(t, err)
+	assert.Equal(t, monthlyCost, *serialized.MonthlyCost)
+	assert.Equal(t, monthlyCostDelta, *serialized.MonthlyCostDelta)
+	assert.Equal(t, "USD", serialized.CostCurrency)
+	assert.Equal(t, resourcesCreated, serialized.ResourcesCreated)
+}
+
+func TestTokenIsStoredHashed(t *testing.T) {
+	teardownSuite, _, org := setupSuite(t)
+	defer teardownSuite(t)
+
+	token, value, err := DB.CreateToken(org.ID, "ci", AccessPolicyType, []string{PermissionRead}, nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, value, token.Hash)
+	assert.Equal(t, HashToken(value), token.Hash)
+	assert.True(t, strings.HasPrefix(value, token.Prefix))
+
+	found, err := DB.GetToken(value)
+	assert.NoError(t, err)
+	assert.Equal(t, token.ID, found.ID)
+	assert.Equal(t, []string{PermissionRead}, found.ScopeList())
+
+	found, err = DB.GetToken(token.Hash)
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+}
+
+func TestRotateAndRevokeToken(t *testing.T) {
+	teardownSuite, _, org := setupSuite(t)
+	defer teardownSuite(t)
+
+	expiresAt := time.Now().Add(24 * time.Hour)
+	token, value, err := DB.CreateToken(org.ID, "ci", AdminPolicyType, nil, &expiresAt)
+	assert.NoError(t, err)
+
+	rotated, newValue, err := DB.RotateToken(token)
+	assert.NoError(t, err)
+	assert.NotEqual(t, value, newValue)
+	assert.Equal(t, "ci", rotated.Name)
+	assert.Equal(t, AdminPolicyType, rotated.Type)
+	assert.NotNil(t, rotated.ExpiresAt)
+
+	found, err := DB.GetToken(value)
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+
+	err = DB.RevokeToken(rotated)
+	assert.NoError(t, err)
+	found, err = DB.GetToken(newValue)
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+
+	tokens, err := DB.GetTokensForOrg(org.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(tokens))
+}
+
+func TestDeleteExpiredTokens(t *testing.T) {
+	teardownSuite, _, org := setupSuite(t)
+	defer teardownSuite(t)
+
+	expired := time.Now().Add(-time.Hour)
+	_, expiredValue, err := DB.CreateToken(org.ID, "expired", AccessPolicyType, nil, &expired)
+	assert.NoError(t, err)
+	_, validValue, err := DB.CreateToken(org.ID, "valid", AccessPolicyType, nil, nil)
+	assert.NoError(t, err)
+
+	jobToken, err := DB.CreateDiggerJobToken(org.ID, "diggerhq/demo")
+	assert.NoError(t, err)
+	expiredJobToken, err := DB.CreateDiggerJobToken(org.ID, "diggerhq/demo")
+	assert.NoError(t, err)
+	expiredJobToken.Expiry = expired
+	assert.NoError(t, DB.GormDB.Save(expiredJobToken).Error)
+
+	tokensRevoked, jobTokensDeleted, err := DB.DeleteExpiredTokens()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), tokensRevoked)
+	assert.Equal(t, int64(1), jobTokensDeleted)
+
+	found, err := DB.GetToken(expiredValue)
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+	found, err = DB.GetToken(validValue)
+	assert.NoError(t, err)
+	assert.NotNil(t, found)
+
+	foundJobToken, err := DB.GetJobToken(jobToken.Value)
+	assert.NoError(t, err)
+	assert.NotNil(t, foundJobToken)
+	foundJobToken, err = DB.GetJobToken(expiredJobToken.Value)
+	assert.NoError(t, err)
+	assert.Nil(t, foundJobToken)
+}
+
+func TestAuditLogEntries(t *testing.T) {
+	teardownSuite, _, org := setupSuite(t)
+	defer teardownSuite(t)
+
+	_, err := DB.CreateAuditLogEntry(org.ID, "user:alice", AuditActionPolicyUpsert, "orgs/test/plan-policy", AuditDiff("", "package digger\n"), "10.0.0.1")
+	assert.NoError(t, err)
+	_, err = DB.CreateAuditLogEntry(org.ID, "token:1", AuditActionRunApprove, "runs/1", "", "10.0.0.2")
+	assert.NoError(t, err)
+
+	entries, err := DB.GetAuditLogEntries(org.ID, AuditLogFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Contains(t, entries[0].Diff, "+package digger")
+
+	entries, err = DB.GetAuditLogEntries(org.ID, AuditLogFilter{Actor: "token:1"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, AuditActionRunApprove, entries[0].Action)
+
+	future := time.Now().Add(time.Hour)
+	entries, err = DB.GetAuditLogEntries(org.ID, AuditLogFilter{Since: &future})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+
+	entries, err = DB.GetAuditLogEntries(org.ID+1, AuditLogFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+}
+
+func TestPolicyVersions(t *testing.T) {
+	teardownSuite, _, org := setupSuite(t)
+	defer teardownSuite(t)
+	assert.NoError(t, DB.GormDB.AutoMigrate(&PolicyVersion{}))
+
+	policy := &Policy{OrganisationID: org.ID, Type: POLICY_TYPE_PLAN}
+	first, err := DB.SavePolicyVersion(policy, "package digger\n", "user:alice")
+	assert.NoError(t, err)
+	assert.NotZero(t, policy.ID)
+	assert.Equal(t, uint(1), first.Version)
+	second, err := DB.SavePolicyVersion(policy, "package digger\ndefault allow = false\n", "token:1")
+	assert.NoError(t, err)
+	assert.Equal(t, uint(2), second.Version)
+
+	var stored Policy
+	assert.NoError(t, DB.GormDB.First(&stored, policy.ID).Error)
+	assert.Equal(t, "package digger\ndefault allow = false\n", stored.Policy)
+	assert.Equal(t, second.ID, *stored.VersionID)
+
+	versions, err := DB.GetPolicyVersions(policy.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(versions))
+	assert.Equal(t, "token:1", versions[0].Author)
+
+	version, err := DB.GetPolicyVersion(policy.ID, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "package digger\n", version.Content)
+	version, err = DB.GetPolicyVersion(policy.ID, 3)
+	assert.NoError(t, err)
+	assert.Nil(t, version)
+}
+
+func TestClaimAgentJob(t *testing.T) {
+	teardownSuite, _, org := setupSuite(t)
+	defer teardownSuite(t)
+	assert.NoError(t, DB.GormDB.AutoMigrate(&AgentJob{}))
+
+	_, err := DB.CreateAgentJob(org.ID, "private", "job-1", "{}")
+	assert.NoError(t, err)
+	_, err = DB.CreateAgentJob(org.ID, DefaultAgentPool, "job-2", "{}")
+	assert.NoError(t, err)
+	_, err = DB.CreateAgentJob(org.ID, "private", "job-3", "{}")
+	assert.NoError(t, err)
+
+	claimed, err := DB.ClaimAgentJob(org.ID, "private", "agent-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "job-1", claimed.DiggerJobID)
+	assert.Equal(t, AgentJobClaimed, claimed.Status)
+	claimed, err = DB.ClaimAgentJob(org.ID, "private", "agent-2")
+	assert.NoError(t, err)
+	assert.Equal(t, "job-3", claimed.DiggerJobID)
+	claimed, err = DB.ClaimAgentJob(org.ID, "private", "agent-1")
+	assert.NoError(t, err)
+	assert.Nil(t, claimed)
+
+	claimed, err = DB.ClaimAgentJob(org.ID+1, DefaultAgentPool, "agent-1")
+	assert.NoError(t, err)
+	assert.Nil(t, claimed)
+
+	stale, err := DB.GetStaleAgentJobs(time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(stale))
 }
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// OrgWebhook is an endpoint that receives digger events for an organisation
+type OrgWebhook struct {
+	gorm.Model
+	OrganisationID uint `gorm:"index:idx_org_webhook_org"`
+	Organisation   *Organisation
+	Url            string
+	// Secret is used to sign payloads, it is stored as is since it is needed for every delivery
+	Secret string
+	// comma separated event types the webhook is subscribed to, empty means all events
+	Events string
+}
+
+func (w *OrgWebhook) EventList() []string {
+	if w.Events == "" {
+		return []string{}
+	}
+	return strings.Split(w.Events, ",")
+}
+
+func (w *OrgWebhook) IsSubscribedTo(eventType string) bool {
+	if w.Events == "" {
+		return true
+	}
+	for _, e := range w.EventList() {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *OrgWebhook) MapToJsonStruct() interface{} {
+	return struct {
+		Id        uint      `json:"id"`
+		Url       string    `json:"url"`
+		Events    []string  `json:"events"`
+		CreatedAt time.Time `json:"created_at"`
+	}{
+		Id:        w.ID,
+		Url:       w.Url,
+		Events:    w.EventList(),
+		CreatedAt: w.CreatedAt,
+	}
+}
+
+// WebhookDelivery is a single event sent to a webhook along with the outcome of the last attempt
+type WebhookDelivery struct {
+	ID           uint `gorm:"primarykey"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	OrgWebhookID uint `gorm:"index:idx_webhook_delivery_webhook"`
+	OrgWebhook   *OrgWebhook
+	EventId      string
+	EventType    string
+	Payload      string
+	Status       string
+	Attempts     int
+	ResponseCode int
+	LastError    string
+	// NextAttemptAt is set while the delivery is pending
+	NextAttemptAt *time.Time `gorm:"index:idx_webhook_delivery_next_attempt"`
+	DeliveredAt   *time.Time
+}
+
+func (d *WebhookDelivery) MapToJsonStruct() interface{} {
+	return struct {
+		Id            uint       `json:"id"`
+		EventId       string     `json:"event_id"`
+		EventType     string     `json:"event_type"`
+		Status        string     `json:"status"`
+		Attempts      int        `json:"attempts"`
+		ResponseCode  int        `json:"response_code,omitempty"`
+		LastError     string     `json:"last_error,omitempty"`
+		CreatedAt     time.Time  `json:"created_at"`
+		NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+		DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	}{
+		Id:            d.ID,
+		EventId:       d.EventId,
+		EventType:     d.EventType,
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		ResponseCode:  d.ResponseCode,
+		LastError:     d.LastError,
+		CreatedAt:     d.CreatedAt,
+		NextAttemptAt: d.NextAttemptAt,
+		DeliveredAt:   d.DeliveredAt,
+	}
+}
@@ -1,16 +1,22 @@
 #!/bin/bash
 set -e
 
+# sqlite databases have their own migration set
+MIGRATIONS_DIR="file://migrations"
+if [[ "${DATABASE_URL}" == sqlite://* ]]; then
+  MIGRATIONS_DIR="file://migrations_sqlite"
+fi
+
 if [[ -z "${BASELINE_MIGRATION}" ]]; then
   cd /app
   if [[ "${ALLOW_DIRTY}" == "true" ]]; then
-    atlas migrate apply --url $DATABASE_URL --allow-dirty
+    atlas migrate apply --url $DATABASE_URL --dir $MIGRATIONS_DIR --allow-dirty
   else
-    atlas migrate apply --url $DATABASE_URL
+    atlas migrate apply --url $DATABASE_URL --dir $MIGRATIONS_DIR
   fi
   ./backend
 else
   cd /app
-  atlas migrate apply --url $DATABASE_URL --baseline $BASELINE_MIGRATION
+  atlas migrate apply --url $DATABASE_URL --dir $MIGRATIONS_DIR --baseline $BASELINE_MIGRATION
   ./backend
-fi
\ No newline at end of file
+fi
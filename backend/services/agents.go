@@ -0,0 +1,67 @@
+package services
+
+import (
+	"fmt"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/backend/webhooks"
+	orchestrator_scheduler "github.com/diggerhq/digger/libs/orchestrator/scheduler"
+	"log"
+	"time"
+)
+
+// AgentJobHeartbeatTimeout is how long a claimed job may go without a heartbeat before the agent is considered gone
+const AgentJobHeartbeatTimeout = 2 * time.Minute
+
+// FailUnfinishedDiggerJob marks the job and its batch failed when the agent stopped before the job reported a result
+func FailUnfinishedDiggerJob(diggerJobId string) error {
+	job, err := models.DB.GetDiggerJob(diggerJobId)
+	if err != nil {
+		return fmt.Errorf("could not fetch job %v: %v", diggerJobId, err)
+	}
+	if job.ID == 0 || job.Batch == nil {
+		return fmt.Errorf("job %v not found", diggerJobId)
+	}
+	if job.Status == orchestrator_scheduler.DiggerJobSucceeded || job.Status == orchestrator_scheduler.DiggerJobFailed {
+		return nil
+	}
+
+	previousStatus := job.Status
+	job.Status = orchestrator_scheduler.DiggerJobFailed
+	job.StatusUpdatedAt = time.Now()
+	err = models.DB.UpdateDiggerJob(job)
+	if err != nil {
+		return fmt.Errorf("could not update job %v: %v", diggerJobId, err)
+	}
+	webhooks.JobStatusChanged(job, nil, previousStatus)
+
+	previousBatchStatus := job.Batch.Status
+	err = models.DB.UpdateBatchStatus(job.Batch)
+	if err != nil {
+		return fmt.Errorf("could not update batch of job %v: %v", diggerJobId, err)
+	}
+	webhooks.BatchStatusChanged(job.Batch, previousBatchStatus)
+	return nil
+}
+
+// ExpireStaleAgentJobs marks the jobs of agents that stopped sending heartbeats as lost and fails their digger jobs
+func ExpireStaleAgentJobs(now time.Time) {
+	agentJobs, err := models.DB.GetStaleAgentJobs(now.Add(-AgentJobHeartbeatTimeout))
+	if err != nil {
+		log.Printf("Failed to fetch stale agent jobs: %v", err)
+		return
+	}
+	for _, agentJob := range agentJobs {
+		log.Printf("Agent %v stopped sending heartbeats for job %v", agentJob.AgentName, agentJob.DiggerJobID)
+		agentJob.Status = models.AgentJobLost
+		agentJob.FinishedAt = &now
+		err := models.DB.UpdateAgentJob(&agentJob)
+		if err != nil {
+			log.Printf("Failed to update agent job %v: %v", agentJob.ID, err)
+			continue
+		}
+		err = FailUnfinishedDiggerJob(agentJob.DiggerJobID)
+		if err != nil {
+			log.Printf("Failed to fail job of lost agent job %v: %v", agentJob.ID, err)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OidcProvider describes a CI system issuing OIDC identity tokens to its jobs
+type OidcProvider struct {
+	Name     string
+	Issuer   string
+	JwksUrl  string
+	Audience string
+	// claims joined with "/" to build the repository full name
+	RepoClaims []string
+	RefClaim   string
+}
+
+var defaultOidcProviders = map[string]OidcProvider{
+	"github": {
+		Name:       "github",
+		Issuer:     "https://token.actions.githubusercontent.com",
+		JwksUrl:    "https://token.actions.githubusercontent.com/.well-known/jwks",
+		RepoClaims: []string{"repository"},
+		RefClaim:   "ref",
+	},
+	"gitlab": {
+		Name:       "gitlab",
+		Issuer:     "https://gitlab.com",
+		JwksUrl:    "https://gitlab.com/oauth/discovery/keys",
+		RepoClaims: []string{"project_path"},
+		RefClaim:   "ref",
+	},
+	"buildkite": {
+		Name:       "buildkite",
+		Issuer:     "https://agent.buildkite.com",
+		JwksUrl:    "https://agent.buildkite.com/.well-known/jwks",
+		RepoClaims: []string{"organization_slug", "pipeline_slug"},
+		RefClaim:   "build_branch",
+	},
+}
+
+// GetOidcProviders returns the providers enabled with OIDC_PROVIDERS (e.g. "github,gitlab").
+// Issuer, jwks url and audience can be overridden with OIDC_<NAME>_ISSUER, OIDC_<NAME>_JWKS_URL and OIDC_<NAME>_AUDIENCE
+func GetOidcProviders() []OidcProvider {
+	providers := make([]OidcProvider, 0)
+	enabled := os.Getenv("OIDC_PROVIDERS")
+	if enabled == "" {
+		return providers
+	}
+
+	defaultAudience := os.Getenv("OIDC_AUDIENCE")
+	if defaultAudience == "" {
+		defaultAudience = "digger"
+	}
+
+	for _, name := range strings.Split(enabled, ",") {
+		name = strings.TrimSpace(name)
+		provider, ok := defaultOidcProviders[name]
+		if !ok {
+			log.Printf("Unknown OIDC provider %v, skipping", name)
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		if issuer := os.Getenv(prefix + "ISSUER"); issuer != "" {
+			provider.Issuer = issuer
+			if name == "gitlab" {
+				provider.JwksUrl = strings.TrimSuffix(issuer, "/") + "/oauth/discovery/keys"
+			}
+		}
+		if jwksUrl := os.Getenv(prefix + "JWKS_URL"); jwksUrl != "" {
+			provider.JwksUrl = jwksUrl
+		}
+		provider.Audience = defaultAudience
+		if audience := os.Getenv(prefix + "AUDIENCE"); audience != "" {
+			provider.Audience = audience
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+// FindOidcProvider matches the unverified issuer of a token against the providers
+func FindOidcProvider(tokenString string, providers []OidcProvider) (*OidcProvider, bool) {
+	if len(providers) == 0 {
+		return nil, false
+	}
+	claims := jwt.MapClaims{}
+	_, _, err := new(jwt.Parser).ParseUnverified(tokenString, claims)
+	if err != nil {
+		return nil, false
+	}
+	issuer, _ := claims["iss"].(string)
+	for i := range providers {
+		if providers[i].Issuer == issuer {
+			return &providers[i], true
+		}
+	}
+	return nil, false
+}
+
+// ValidateToken checks the signature against the provider's jwks as well as the expiry, issuer and audience
+func (p OidcProvider) ValidateToken(tokenString string, keys *JwksCache) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return keys.GetKey(p.JwksUrl, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("token is invalid")
+	}
+	if !claims.VerifyIssuer(p.Issuer, true) {
+		return nil, fmt.Errorf("unexpected issuer: %v", claims["iss"])
+	}
+	if !claims.VerifyAudience(p.Audience, true) {
+		return nil, fmt.Errorf("unexpected audience: %v", claims["aud"])
+	}
+	if p.Repo(claims) == "" {
+		return nil, fmt.Errorf("token has no repository claim")
+	}
+	return claims, nil
+}
+
+func (p OidcProvider) Repo(claims jwt.MapClaims) string {
+	parts := make([]string, 0)
+	for _, claim := range p.RepoClaims {
+		value, _ := claims[claim].(string)
+		if value == "" {
+			return ""
+		}
+		parts = append(parts, value)
+	}
+	return strings.Join(parts, "/")
+}
+
+// Ref returns the branch the job runs on
+func (p OidcProvider) Ref(claims jwt.MapClaims) string {
+	ref, _ := claims[p.RefClaim].(string)
+	return strings.TrimPrefix(ref, "refs/heads/")
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type JwksCache struct {
+	HttpClient *http.Client
+	// how long fetched keys are used before being refreshed
+	Ttl       time.Duration
+	mu        sync.Mutex
+	keys      map[string]map[string]interface{}
+	fetchedAt map[string]time.Time
+}
+
+func NewJwksCache() *JwksCache {
+	return &JwksCache{
+		HttpClient: &http.Client{Timeout: 10 * time.Second},
+		Ttl:        time.Hour,
+	}
+}
+
+// GetKey returns the public key for kid, the jwks is refetched when it is stale or the kid is unknown
+func (j *JwksCache) GetKey(jwksUrl string, kid string) (interface{}, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.keys == nil {
+		j.keys = make(map[string]map[string]interface{})
+		j.fetchedAt = make(map[string]time.Time)
+	}
+
+	keys, ok := j.keys[jwksUrl]
+	stale := time.Since(j.fetchedAt[jwksUrl]) > j.Ttl
+	// unknown kids trigger a refetch at most once a minute so that bogus tokens can't hammer the issuer
+	unknownKid := ok && keys[kid] == nil && time.Since(j.fetchedAt[jwksUrl]) > time.Minute
+	if !ok || stale || unknownKid {
+		fetched, err := j.fetch(jwksUrl)
+		if err != nil {
+			if !ok {
+				return nil, err
+			}
+			log.Printf("Failed to refresh jwks from %v, using cached keys: %v", jwksUrl, err)
+		} else {
+			keys = fetched
+			j.keys[jwksUrl] = fetched
+			j.fetchedAt[jwksUrl] = time.Now()
+		}
+	}
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("no key found for kid %v", kid)
+	}
+	return key, nil
+}
+
+func (j *JwksCache) fetch(jwksUrl string) (map[string]interface{}, error) {
+	resp, err := j.HttpClient.Get(jwksUrl)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch jwks: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch jwks, status code: %v", resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, fmt.Errorf("could not decode jwks: %v", err)
+	}
+
+	keys := make(map[string]interface{})
+	for _, k := range jwks.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			log.Printf("Skipping jwk %v: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %v", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %v", k.Kty)
+	}
+}
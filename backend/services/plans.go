@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/diggerhq/digger/backend/blobstore"
+	"github.com/diggerhq/digger/backend/models"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PlanStore keeps the plans uploaded by jobs, it is created from the environment on first use unless set
+var PlanStore blobstore.Store
+var planStoreMutex sync.Mutex
+
+func GetPlanStore() (blobstore.Store, error) {
+	planStoreMutex.Lock()
+	defer planStoreMutex.Unlock()
+	if PlanStore == nil {
+		store, err := blobstore.NewStoreFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("could not create plan store: %v", err)
+		}
+		PlanStore = store
+	}
+	return PlanStore, nil
+}
+
+// PlanArtifactKey places the plans of each repo under their own prefix of the store, plans uploaded before they were
+// scoped by repo stay under the prefix of the organisation
+func PlanArtifactKey(orgId uint, repo string, path string) string {
+	if repo == "" {
+		return fmt.Sprintf("orgs/%v/%v", orgId, path)
+	}
+	return fmt.Sprintf("orgs/%v/%v/%v", orgId, repo, path)
+}
+
+// PlanRetentionDays is the setting of the organisation, falling back to PLAN_RETENTION_DAYS and then the default
+func PlanRetentionDays(org *models.Organisation) int {
+	if org.PlanRetentionDays != nil {
+		return *org.PlanRetentionDays
+	}
+	if days, err := strconv.Atoi(os.Getenv("PLAN_RETENTION_DAYS")); err == nil && days > 0 {
+		return days
+	}
+	return models.DefaultPlanRetentionDays
+}
+
+// DeleteExpiredPlanArtifacts removes the plans that were last uploaded longer ago than the retention of their organisation
+func DeleteExpiredPlanArtifacts(now time.Time) {
+	orgIds, err := models.DB.GetPlanArtifactOrgIds()
+	if err != nil {
+		log.Printf("could not fetch organisations with stored plans: %v", err)
+		return
+	}
+	if len(orgIds) == 0 {
+		return
+	}
+	store, err := GetPlanStore()
+	if err != nil {
+		log.Printf("could not delete expired plans: %v", err)
+		return
+	}
+
+	for _, orgId := range orgIds {
+		org, err := models.DB.GetOrganisationById(orgId)
+		if err != nil {
+			log.Printf("could not fetch organisation %v: %v", orgId, err)
+			continue
+		}
+		cutoff := now.AddDate(0, 0, -PlanRetentionDays(org))
+		artifacts, err := models.DB.GetPlanArtifactsUpdatedBefore(orgId, cutoff)
+		if err != nil {
+			log.Printf("could not fetch expired plans of organisation %v: %v", orgId, err)
+			continue
+		}
+		for _, artifact := range artifacts {
+			err := store.Delete(context.Background(), PlanArtifactKey(orgId, artifact.Repo, artifact.Path))
+			if err != nil {
+				log.Printf("could not delete plan %v of organisation %v: %v", artifact.Path, orgId, err)
+				continue
+			}
+			err = models.DB.DeletePlanArtifact(&artifact)
+			if err != nil {
+				log.Printf("could not delete record of plan %v: %v", artifact.Path, err)
+			}
+		}
+	}
+}
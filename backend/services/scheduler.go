@@ -5,13 +5,16 @@ import (
 	"github.com/diggerhq/digger/backend/ci_backends"
 	"github.com/diggerhq/digger/backend/config"
 	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/backend/utils"
+	"github.com/diggerhq/digger/backend/webhooks"
 	orchestrator_scheduler "github.com/diggerhq/digger/libs/orchestrator/scheduler"
-	"github.com/google/go-github/v61/github"
+	"github.com/diggerhq/digger/libs/tracing"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 	"log"
 )
 
-func DiggerJobCompleted(client *github.Client, batchId *uuid.UUID, parentJob *models.DiggerJob, repoOwner string, repoName string, workflowFileName string) error {
+func DiggerJobCompleted(ciBackend ci_backends.CiBackend, batchId *uuid.UUID, parentJob *models.DiggerJob, repoOwner string, repoName string, workflowFileName string) error {
 	log.Printf("DiggerJobCompleted parentJobId: %v", parentJob.DiggerJobID)
 
 	jobLinksForParent, err := models.DB.GetDiggerJobParentLinksByParentId(&parentJob.DiggerJobID)
@@ -44,7 +47,6 @@ func DiggerJobCompleted(client *github.Client, batchId *uuid.UUID, parentJob *mo
 			if err != nil {
 				return err
 			}
-			ciBackend := ci_backends.GithubActionCi{Client: client}
 			ScheduleJob(ciBackend, repoOwner, repoName, batchId, job)
 		}
 
@@ -75,8 +77,10 @@ func ScheduleJob(ciBackend ci_backends.CiBackend, repoOwner string, repoName str
 		log.Printf("Length of jobs: %v", len(jobs))
 		if len(jobs) >= maxConcurrencyForBatch {
 			log.Printf("max concurrency for jobs reached: %v, queuing until more jobs succeed", len(jobs))
+			previousStatus := job.Status
 			job.Status = orchestrator_scheduler.DiggerJobQueuedForRun
 			models.DB.UpdateDiggerJob(job)
+			webhooks.JobStatusChanged(job, nil, previousStatus)
 			return nil
 		} else {
 			err := TriggerJob(ciBackend, repoOwner, repoName, batchId, job)
@@ -105,18 +109,22 @@ func TriggerJob(ciBackend ci_backends.CiBackend, repoOwner string, repoName stri
 	jobString := string(job.SerializedJobSpec)
 	log.Printf("jobString: %v \n", jobString)
 
+	_, span, jobString := utils.StartJobSpan(jobString, "TriggerJob", attribute.String("digger.job_id", job.DiggerJobID))
 	err = ciBackend.TriggerWorkflow(repoOwner, repoName, *job, jobString, *batch.CommentId)
+	tracing.EndSpan(span, err)
 	if err != nil {
 		log.Printf("TriggerJob err: %v\n", err)
 		return err
 	}
 
+	previousStatus := job.Status
 	job.Status = orchestrator_scheduler.DiggerJobTriggered
 	err = models.DB.UpdateDiggerJob(job)
 	if err != nil {
 		log.Printf("failed to Update digger job state: %v\n", err)
 		return err
 	}
+	webhooks.JobStatusChanged(job, batch, previousStatus)
 
 	return nil
 }
@@ -5,10 +5,13 @@ import (
 	"github.com/diggerhq/digger/backend/models"
 	"github.com/diggerhq/digger/backend/services"
 	"github.com/diggerhq/digger/backend/utils"
+	"github.com/diggerhq/digger/backend/webhooks"
 	"github.com/diggerhq/digger/libs/orchestrator/scheduler"
+	"github.com/diggerhq/digger/libs/tracing"
 	"github.com/robfig/cron"
 	"log"
 	"os"
+	"time"
 )
 
 func initLogging() {
@@ -19,6 +22,7 @@ func initLogging() {
 
 func main() {
 	initLogging()
+	tracing.Init("digger-tasks")
 	models.ConnectDatabase()
 
 	c := cron.New()
@@ -68,6 +72,29 @@ func main() {
 		}
 	})
 
+	// Sweep expired api tokens and job tokens
+	c.AddFunc("0 0 * * * *", func() {
+		_, _, err := models.DB.DeleteExpiredTokens()
+		if err != nil {
+			log.Printf("Failed to sweep expired tokens: %v", err)
+		}
+	})
+
+	// Retry failed webhook deliveries
+	c.AddFunc("15 * * * * *", func() {
+		webhooks.RetryDueDeliveries()
+	})
+
+	// Fail the jobs of agents that stopped sending heartbeats
+	c.AddFunc("45 * * * * *", func() {
+		services.ExpireStaleAgentJobs(time.Now())
+	})
+
+	// Delete stored plans that are past the retention of their organisation
+	c.AddFunc("0 30 * * * *", func() {
+		services.DeleteExpiredPlanArtifacts(time.Now())
+	})
+
 	// Start the Cron job scheduler
 	c.Start()
 
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"github.com/bradleyfalzon/ghinstallation/v2"
+	"github.com/diggerhq/digger/backend/metrics"
 	"github.com/diggerhq/digger/backend/models"
 	"github.com/diggerhq/digger/libs/orchestrator"
 	github2 "github.com/diggerhq/digger/libs/orchestrator/github"
@@ -93,17 +94,22 @@ func (gh DiggerGithubRealClientProvider) Get(githubAppId int64, installationId i
 		}
 	}
 
+	urls := github2.GithubUrlsFromEnv()
 	tr := net.DefaultTransport
 	itr, err := ghinstallation.New(tr, githubAppId, installationId, []byte(githubAppPrivateKey))
 	if err != nil {
 		return nil, nil, fmt.Errorf("error initialising github app installation: %v\n", err)
 	}
+	itr.BaseURL = urls.ApiUrl
 
 	token, err := itr.Token(context.Background())
 	if err != nil {
 		return nil, nil, fmt.Errorf("error initialising git app token: %v\n", err)
 	}
-	ghClient := github.NewClient(&net.Client{Transport: itr})
+	ghClient, err := urls.NewClient(&net.Client{Transport: metrics.GithubTransport{Base: itr}})
+	if err != nil {
+		return nil, nil, err
+	}
 	return ghClient, &token, nil
 }
 
@@ -145,7 +151,7 @@ func GetGithubService(gh GithubClientProvider, installationId int64, repoFullNam
 	return &ghService, token, nil
 }
 
-func SetPRStatusForJobs(prService *github2.GithubService, prNumber int, jobs []orchestrator.Job) error {
+func SetPRStatusForJobs(prService orchestrator.PullRequestService, prNumber int, jobs []orchestrator.Job) error {
 	for _, job := range jobs {
 		for _, command := range job.Commands {
 			var err error
@@ -209,7 +215,7 @@ func GetWorkflowIdAndUrlFromDiggerJobId(client *github.Client, repoOwner string,
 		for _, workflowjob := range workflowjobs.Jobs {
 			for _, step := range workflowjob.Steps {
 				if strings.Contains(*step.Name, diggerJobID) {
-					return *workflowRun.ID, fmt.Sprintf("https://github.com/%v/%v/actions/runs/%v", repoOwner, repoName, *workflowRun.ID), nil
+					return *workflowRun.ID, fmt.Sprintf("%v/actions/runs/%v", github2.GithubUrlsFromEnv().RepoUrl(repoOwner+"/"+repoName), *workflowRun.ID), nil
 				}
 			}
 
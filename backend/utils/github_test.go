@@ -1,8 +1,16 @@
 package utils
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"github.com/stretchr/testify/assert"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 )
@@ -31,3 +39,38 @@ func TestGithubCloneWithInvalidBranchThrowsError(t *testing.T) {
 	err := CloneGitRepoAndDoAction("https://github.com/diggerhq/digger", "not-a-branch", token, f)
 	assert.NotNil(t, err)
 }
+
+func TestGithubClientProviderUsesEnterpriseHost(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	t.Setenv("GITHUB_APP_PRIVATE_KEY_BASE64", base64.StdEncoding.EncodeToString(keyPem))
+
+	requests := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v3/app/installations/5/access_tokens":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"token": "ghs_installation", "expires_at": "2099-01-01T00:00:00Z"}`))
+		case "/api/v3/repos/diggerhq/demo":
+			assert.Equal(t, "token ghs_installation", r.Header.Get("Authorization"))
+			w.Write([]byte(`{"full_name": "diggerhq/demo"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("GITHUB_SERVER_URL", server.URL)
+	t.Setenv("GITHUB_API_URL", "")
+	t.Setenv("GITHUB_UPLOAD_URL", "")
+
+	client, token, err := DiggerGithubRealClientProvider{}.Get(1, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, "ghs_installation", *token)
+	repo, _, err := client.Repositories.Get(context.Background(), "diggerhq", "demo")
+	assert.NoError(t, err)
+	assert.Equal(t, "diggerhq/demo", repo.GetFullName())
+	assert.Equal(t, []string{"POST /api/v3/app/installations/5/access_tokens", "GET /api/v3/repos/diggerhq/demo"}, requests)
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/diggerhq/digger/backend/models"
+	dg_gitlab "github.com/diggerhq/digger/libs/orchestrator/gitlab"
+	"os"
+	"strings"
+)
+
+// GetGitlabService returns a service for the merge requests of the project, authenticated with GITLAB_TOKEN
+func GetGitlabService(projectFullName string) (*dg_gitlab.GitLabService, error) {
+	token := os.Getenv("GITLAB_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("GITLAB_TOKEN is not set")
+	}
+	return dg_gitlab.NewGitLabService(token, os.Getenv("GITLAB_BASE_URL"), projectFullName)
+}
+
+// GitlabRepoUrl is the http url of the project on GITLAB_BASE_URL, or gitlab.com when it isn't set
+func GitlabRepoUrl(projectFullName string) string {
+	baseUrl := os.Getenv("GITLAB_BASE_URL")
+	if baseUrl == "" {
+		baseUrl = "https://gitlab.com"
+	}
+	baseUrl = strings.TrimSuffix(strings.TrimSuffix(baseUrl, "/"), "/api/v4")
+	return baseUrl + "/" + projectFullName
+}
+
+// GitlabOrganisation is the organisation gitlab merge requests are processed for, DIGGER_GITLAB_ORGANISATION holds
+// its external id and defaults to the default organisation
+func GitlabOrganisation() (*models.Organisation, error) {
+	externalId := os.Getenv("DIGGER_GITLAB_ORGANISATION")
+	if externalId == "" {
+		externalId = models.DEFAULT_ORG_NAME
+	}
+	org, err := models.DB.GetOrganisation(externalId)
+	if err != nil {
+		return nil, fmt.Errorf("could not get gitlab organisation %v: %v", externalId, err)
+	}
+	if org == nil {
+		return nil, fmt.Errorf("gitlab organisation %v does not exist", externalId)
+	}
+	return org, nil
+}
@@ -1,20 +1,28 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/diggerhq/digger/backend/models"
 	configuration "github.com/diggerhq/digger/libs/digger_config"
 	"github.com/diggerhq/digger/libs/orchestrator"
+	"github.com/diggerhq/digger/libs/tracing"
 	"github.com/dominikbraun/graph"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"log"
 	"os"
 )
 
 // ConvertJobsToDiggerJobs jobs is map with project name as a key and a Job as a value
-func ConvertJobsToDiggerJobs(jobType orchestrator.DiggerCommand, organisationId uint, jobsMap map[string]orchestrator.Job, projectMap map[string]configuration.Project, projectsGraph graph.Graph[string, configuration.Project], githubInstallationId int64, branch string, prNumber int, repoOwner string, repoName string, repoFullName string, commitSha string, commentId int64, diggerConfigStr string) (*uuid.UUID, map[string]*models.DiggerJob, error) {
+func ConvertJobsToDiggerJobs(ctx context.Context, jobType orchestrator.DiggerCommand, organisationId uint, jobsMap map[string]orchestrator.Job, projectMap map[string]configuration.Project, projectsGraph graph.Graph[string, configuration.Project], githubInstallationId int64, branch string, prNumber int, repoOwner string, repoName string, repoFullName string, commitSha string, commentId int64, diggerConfigStr string) (*uuid.UUID, map[string]*models.DiggerJob, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ConvertJobsToDiggerJobs", trace.WithAttributes(attribute.String("digger.repo", repoFullName)))
+	defer span.End()
+	traceParent := tracing.TraceParent(ctx)
+
 	result := make(map[string]*models.DiggerJob)
 	organisation, err := models.DB.GetOrganisationById(organisationId)
 	if err != nil {
@@ -28,12 +36,13 @@ func ConvertJobsToDiggerJobs(jobType orchestrator.DiggerCommand, organisationId
 	log.Printf("Number of Jobs: %v\n", len(jobsMap))
 	marshalledJobsMap := map[string][]byte{}
 	for projectName, job := range jobsMap {
-		jobToken, err := models.DB.CreateDiggerJobToken(organisationId)
+		jobToken, err := models.DB.CreateDiggerJobToken(organisationId, repoFullName)
 		if err != nil {
 			log.Printf("Error creating job token: %v %v", projectName, err)
 			return nil, nil, fmt.Errorf("error creating job token")
 		}
 
+		job.TraceParent = traceParent
 		marshalled, err := json.Marshal(orchestrator.JobToJson(job, jobType, organisationName, branch, commitSha, jobToken.Value, backendHostName, projectMap[projectName]))
 		if err != nil {
 			return nil, nil, err
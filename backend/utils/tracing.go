@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/diggerhq/digger/libs/orchestrator"
+	"github.com/diggerhq/digger/libs/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"log"
+)
+
+// StartJobSpan continues the trace stored in a serialized job and returns the job pointing at the new span,
+// so that whatever runs the job next becomes its child
+func StartJobSpan(jobString string, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span, string) {
+	var jobSpec orchestrator.JobJson
+	err := json.Unmarshal([]byte(jobString), &jobSpec)
+	if err != nil {
+		log.Printf("could not read trace parent from job: %v", err)
+		ctx, span := tracing.StartSpan("", name, attrs...)
+		return ctx, span, jobString
+	}
+
+	ctx, span := tracing.StartSpan(jobSpec.TraceParent, name, attrs...)
+	span.SetAttributes(attribute.String("digger.project", jobSpec.ProjectName))
+	traceParent := tracing.TraceParent(ctx)
+	if traceParent == "" || traceParent == jobSpec.TraceParent {
+		return ctx, span, jobString
+	}
+	jobSpec.TraceParent = traceParent
+	updated, err := json.Marshal(jobSpec)
+	if err != nil {
+		log.Printf("could not write trace parent to job: %v", err)
+		return ctx, span, jobString
+	}
+	return ctx, span, string(updated)
+}
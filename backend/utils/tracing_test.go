@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"encoding/json"
+	"github.com/diggerhq/digger/libs/orchestrator"
+	"github.com/diggerhq/digger/libs/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func TestStartJobSpanContinuesJobTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	ctx, parent := tracing.StartSpan("", "ConvertJobsToDiggerJobs")
+	jobString, err := json.Marshal(orchestrator.JobJson{ProjectName: "prod", TraceParent: tracing.TraceParent(ctx)})
+	assert.NoError(t, err)
+
+	_, span, updated := StartJobSpan(string(jobString), "TriggerJob")
+	span.End()
+	parent.End()
+
+	var job orchestrator.JobJson
+	assert.NoError(t, json.Unmarshal([]byte(updated), &job))
+	assert.Equal(t, "prod", job.ProjectName)
+	assert.Equal(t, span.SpanContext().SpanID().String(), job.TraceParent[36:52])
+	assert.Equal(t, parent.SpanContext().SpanID(), recorder.Ended()[0].Parent().SpanID())
+}
+
+func TestStartJobSpanKeepsInvalidJob(t *testing.T) {
+	_, span, updated := StartJobSpan("not json", "TriggerJob")
+	span.End()
+	assert.Equal(t, "not json", updated)
+}
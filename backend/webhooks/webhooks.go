@@ -0,0 +1,302 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/backend/utils"
+	"github.com/diggerhq/digger/libs/orchestrator"
+	orchestrator_scheduler "github.com/diggerhq/digger/libs/orchestrator/scheduler"
+	"github.com/google/uuid"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	EventJobStatusChanged   = "job.status_changed"
+	EventBatchStatusChanged = "batch.status_changed"
+	EventLockAcquired       = "lock.acquired"
+	EventLockReleased       = "lock.released"
+	EventRunApproved        = "run.approved"
+)
+
+var EventTypes = []string{EventJobStatusChanged, EventBatchStatusChanged, EventLockAcquired, EventLockReleased, EventRunApproved}
+
+const (
+	SignatureHeader = "X-Digger-Signature-256"
+	EventHeader     = "X-Digger-Event"
+	DeliveryHeader  = "X-Digger-Delivery"
+)
+
+// MaxAttempts is the number of times a delivery is attempted before it is marked as failed
+const MaxAttempts = 5
+
+// RetryDelay is the wait before the first retry, it doubles with every attempt
+var RetryDelay = 30 * time.Second
+
+var Client = &http.Client{Timeout: 10 * time.Second}
+
+// Event is the payload posted to webhooks
+type Event struct {
+	Id             string      `json:"id"`
+	Type           string      `json:"type"`
+	CreatedAt      time.Time   `json:"created_at"`
+	OrganisationId uint        `json:"organisation_id"`
+	Data           interface{} `json:"data"`
+}
+
+type JobEventData struct {
+	JobId            string `json:"job_id"`
+	BatchId          string `json:"batch_id"`
+	RepoFullName     string `json:"repo_full_name"`
+	PrNumber         int    `json:"pr_number"`
+	Project          string `json:"project"`
+	Command          string `json:"command"`
+	Status           string `json:"status"`
+	PreviousStatus   string `json:"previous_status"`
+	WorkflowRunUrl   string `json:"workflow_run_url,omitempty"`
+	ResourcesCreated uint   `json:"resources_created"`
+	ResourcesUpdated uint   `json:"resources_updated"`
+	ResourcesDeleted uint   `json:"resources_deleted"`
+}
+
+type BatchEventData struct {
+	BatchId        string `json:"batch_id"`
+	RepoFullName   string `json:"repo_full_name"`
+	PrNumber       int    `json:"pr_number"`
+	Command        string `json:"command"`
+	Status         string `json:"status"`
+	PreviousStatus string `json:"previous_status"`
+}
+
+type LockEventData struct {
+	Resource string `json:"resource"`
+	PrNumber int    `json:"pr_number"`
+}
+
+type RunEventData struct {
+	RunId        uint   `json:"run_id"`
+	RepoFullName string `json:"repo_full_name"`
+	Project      string `json:"project"`
+	ApprovedBy   string `json:"approved_by"`
+}
+
+// GenerateSecret returns a random secret used to sign payloads
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate secret: %v", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the value of the signature header, a hex encoded HMAC-SHA256 of the payload
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Emit records a delivery of the event for every webhook of the org subscribed to it and attempts them in the background.
+// Failures are logged since webhooks should never fail the action that triggered them.
+func Emit(orgId uint, eventType string, data interface{}) []*models.WebhookDelivery {
+	if models.DB == nil {
+		return nil
+	}
+	webhooks, err := models.DB.GetOrgWebhooks(orgId)
+	if err != nil {
+		log.Printf("Failed to fetch webhooks for org %v: %v", orgId, err)
+		return nil
+	}
+
+	event := Event{
+		Id:             uuid.New().String(),
+		Type:           eventType,
+		CreatedAt:      time.Now().UTC(),
+		OrganisationId: orgId,
+		Data:           data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal %v event: %v", eventType, err)
+		return nil
+	}
+
+	deliveries := make([]*models.WebhookDelivery, 0)
+	for i := range webhooks {
+		webhook := &webhooks[i]
+		if !webhook.IsSubscribedTo(eventType) {
+			continue
+		}
+		// the retry is scheduled upfront so that the tasks service picks the delivery up if this attempt never happens
+		delivery, err := models.DB.CreateWebhookDelivery(webhook, event.Id, eventType, string(payload), time.Now().Add(RetryDelay))
+		if err != nil {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+		go Deliver(delivery)
+	}
+	return deliveries
+}
+
+// Deliver makes one attempt to post the delivery and records the outcome, failed attempts are retried with exponential backoff
+func Deliver(delivery *models.WebhookDelivery) {
+	webhook := delivery.OrgWebhook
+	delivery.Attempts++
+	responseCode, err := post(webhook, delivery)
+	delivery.ResponseCode = responseCode
+	if err == nil {
+		now := time.Now()
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+	} else {
+		log.Printf("Delivery %v of event %v to webhook %v failed: %v", delivery.ID, delivery.EventId, webhook.ID, err)
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= MaxAttempts {
+			delivery.Status = models.WebhookDeliveryFailed
+			delivery.NextAttemptAt = nil
+		} else {
+			nextAttemptAt := time.Now().Add(RetryDelay * time.Duration(1<<(delivery.Attempts-1)))
+			delivery.NextAttemptAt = &nextAttemptAt
+		}
+	}
+	if err := models.DB.UpdateWebhookDelivery(delivery); err != nil {
+		log.Printf("Failed to update webhook delivery %v: %v", delivery.ID, err)
+	}
+}
+
+func post(webhook *models.OrgWebhook, delivery *models.WebhookDelivery) (int, error) {
+	payload := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("could not create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Digger-Webhooks")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.EventId)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, payload))
+
+	resp, err := Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code: %v", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// RetryDueDeliveries attempts the pending deliveries whose retry is due, it is run periodically by the tasks service
+func RetryDueDeliveries() {
+	deliveries, err := models.DB.GetDueWebhookDeliveries(time.Now())
+	if err != nil {
+		log.Printf("Failed to fetch due webhook deliveries: %v", err)
+		return
+	}
+	for i := range deliveries {
+		Deliver(&deliveries[i])
+	}
+}
+
+// orgIdForBatch resolves the organisation through the github installation the batch was created for, gitlab
+// batches belong to the configured gitlab organisation
+func orgIdForBatch(batch *models.DiggerBatch) (uint, bool) {
+	if batch.VCS == models.DiggerVCSGitlab {
+		org, err := utils.GitlabOrganisation()
+		if err != nil {
+			log.Printf("Failed to fetch gitlab organisation for batch %v: %v", batch.ID, err)
+			return 0, false
+		}
+		return org.ID, true
+	}
+	link, err := models.DB.GetGithubAppInstallationLink(batch.GithubInstallationId)
+	if err != nil {
+		log.Printf("Failed to fetch installation link for batch %v: %v", batch.ID, err)
+		return 0, false
+	}
+	if link == nil {
+		return 0, false
+	}
+	return link.OrganisationId, true
+}
+
+// JobStatusChanged emits a job.status_changed event, the batch is loaded if it is nil and the job was loaded without it
+func JobStatusChanged(job *models.DiggerJob, batch *models.DiggerBatch, previous orchestrator_scheduler.DiggerJobStatus) {
+	if models.DB == nil || job.Status == previous {
+		return
+	}
+	if batch == nil {
+		batch = job.Batch
+	}
+	if batch == nil && job.BatchID != nil {
+		batchId, err := uuid.Parse(*job.BatchID)
+		if err != nil {
+			log.Printf("Invalid batch id of job %v: %v", job.DiggerJobID, err)
+			return
+		}
+		batch, err = models.DB.GetDiggerBatch(&batchId)
+		if err != nil {
+			log.Printf("Failed to fetch batch of job %v: %v", job.DiggerJobID, err)
+			return
+		}
+	}
+	if batch == nil {
+		return
+	}
+	orgId, ok := orgIdForBatch(batch)
+	if !ok {
+		return
+	}
+
+	var jobSpec orchestrator.JobJson
+	if err := json.Unmarshal(job.SerializedJobSpec, &jobSpec); err != nil {
+		log.Printf("Failed to read job spec of %v: %v", job.DiggerJobID, err)
+	}
+	data := JobEventData{
+		JobId:            job.DiggerJobID,
+		BatchId:          batch.ID.String(),
+		RepoFullName:     batch.RepoFullName,
+		PrNumber:         batch.PrNumber,
+		Project:          jobSpec.ProjectName,
+		Command:          string(batch.BatchType),
+		Status:           models.JobStatusNames[job.Status],
+		PreviousStatus:   models.JobStatusNames[previous],
+		ResourcesCreated: job.DiggerJobSummary.ResourcesCreated,
+		ResourcesUpdated: job.DiggerJobSummary.ResourcesUpdated,
+		ResourcesDeleted: job.DiggerJobSummary.ResourcesDeleted,
+	}
+	if job.WorkflowRunUrl != nil && *job.WorkflowRunUrl != "#" {
+		data.WorkflowRunUrl = *job.WorkflowRunUrl
+	}
+	Emit(orgId, EventJobStatusChanged, data)
+}
+
+func BatchStatusChanged(batch *models.DiggerBatch, previous orchestrator_scheduler.DiggerBatchStatus) {
+	if models.DB == nil || batch.Status == previous {
+		return
+	}
+	orgId, ok := orgIdForBatch(batch)
+	if !ok {
+		return
+	}
+	Emit(orgId, EventBatchStatusChanged, BatchEventData{
+		BatchId:        batch.ID.String(),
+		RepoFullName:   batch.RepoFullName,
+		PrNumber:       batch.PrNumber,
+		Command:        string(batch.BatchType),
+		Status:         models.BatchStatusNames[batch.Status],
+		PreviousStatus: models.BatchStatusNames[previous],
+	})
+}
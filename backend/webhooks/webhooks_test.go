@@ -0,0 +1,215 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"github.com/diggerhq/digger/backend/models"
+	"github.com/diggerhq/digger/libs/orchestrator"
+	orchestrator_scheduler "github.com/diggerhq/digger/libs/orchestrator/scheduler"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver records the requests it gets and answers with the status codes in turn, the last one is repeated
+func receiver(t *testing.T, statusCodes ...int) (*httptest.Server, func() []receivedRequest) {
+	var mu sync.Mutex
+	requests := make([]receivedRequest, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		mu.Lock()
+		requests = append(requests, receivedRequest{r.Header, body})
+		code := statusCodes[min(len(requests), len(statusCodes))-1]
+		mu.Unlock()
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []receivedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func setupDatabase(t *testing.T) *models.Organisation {
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "webhooks.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	assert.NoError(t, err)
+	err = gdb.AutoMigrate(&models.Organisation{}, &models.OrgWebhook{}, &models.WebhookDelivery{}, &models.DiggerBatch{},
+		&models.DiggerJob{}, &models.DiggerJobSummary{}, &models.GithubAppInstallationLink{})
+	assert.NoError(t, err)
+	models.DB = &models.Database{GormDB: gdb}
+	t.Cleanup(func() { models.DB = nil })
+
+	org, err := models.DB.CreateOrganisation("diggerhq", "test", "diggerhq")
+	assert.NoError(t, err)
+	return org
+}
+
+func waitForDelivery(t *testing.T, delivery *models.WebhookDelivery, status string) *models.WebhookDelivery {
+	var result *models.WebhookDelivery
+	assert.Eventually(t, func() bool {
+		var err error
+		result, err = models.DB.GetWebhookDelivery(delivery.OrgWebhookID, delivery.ID)
+		return err == nil && result.Attempts > 0 && result.Status == status
+	}, 5*time.Second, 10*time.Millisecond)
+	return result
+}
+
+func TestEmitSignsAndDeliversEvent(t *testing.T) {
+	org := setupDatabase(t)
+	server, requests := receiver(t, http.StatusOK)
+	webhook, err := models.DB.CreateOrgWebhook(org.ID, server.URL, "s3cret", []string{})
+	assert.NoError(t, err)
+
+	deliveries := Emit(org.ID, EventLockAcquired, LockEventData{Resource: "diggerhq/demo#prod", PrNumber: 7})
+	if !assert.Equal(t, 1, len(deliveries)) {
+		return
+	}
+	delivery := waitForDelivery(t, deliveries[0], models.WebhookDeliverySucceeded)
+	assert.Equal(t, webhook.ID, delivery.OrgWebhookID)
+	assert.Equal(t, 200, delivery.ResponseCode)
+	assert.Nil(t, delivery.NextAttemptAt)
+
+	request := requests()[0]
+	assert.Equal(t, Sign("s3cret", request.body), request.header.Get(SignatureHeader))
+	assert.Equal(t, EventLockAcquired, request.header.Get(EventHeader))
+	assert.Equal(t, delivery.EventId, request.header.Get(DeliveryHeader))
+
+	var event struct {
+		Type           string        `json:"type"`
+		OrganisationId uint          `json:"organisation_id"`
+		Data           LockEventData `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(request.body, &event))
+	assert.Equal(t, EventLockAcquired, event.Type)
+	assert.Equal(t, org.ID, event.OrganisationId)
+	assert.Equal(t, LockEventData{Resource: "diggerhq/demo#prod", PrNumber: 7}, event.Data)
+}
+
+func TestEmitOnlyToSubscribedWebhooks(t *testing.T) {
+	org := setupDatabase(t)
+	server, _ := receiver(t, http.StatusOK)
+	_, err := models.DB.CreateOrgWebhook(org.ID, server.URL, "s3cret", []string{EventRunApproved})
+	assert.NoError(t, err)
+	other, err := models.DB.CreateOrganisation("other", "test", "other")
+	assert.NoError(t, err)
+	_, err = models.DB.CreateOrgWebhook(other.ID, server.URL, "s3cret", []string{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 0, len(Emit(org.ID, EventLockReleased, LockEventData{})))
+	deliveries := Emit(org.ID, EventRunApproved, RunEventData{RunId: 1})
+	if assert.Equal(t, 1, len(deliveries)) {
+		waitForDelivery(t, deliveries[0], models.WebhookDeliverySucceeded)
+	}
+}
+
+func TestFailedDeliveriesAreRetried(t *testing.T) {
+	org := setupDatabase(t)
+	server, requests := receiver(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusNoContent)
+	webhook, err := models.DB.CreateOrgWebhook(org.ID, server.URL, "s3cret", []string{})
+	assert.NoError(t, err)
+
+	delivery, err := models.DB.CreateWebhookDelivery(webhook, "event-1", EventRunApproved, "{}", time.Now())
+	assert.NoError(t, err)
+	Deliver(delivery)
+	assert.Equal(t, models.WebhookDeliveryPending, delivery.Status)
+	assert.Equal(t, 500, delivery.ResponseCode)
+	assert.Equal(t, "unexpected status code: 500", delivery.LastError)
+	assert.WithinDuration(t, time.Now().Add(RetryDelay), *delivery.NextAttemptAt, time.Second)
+
+	// nothing is due until the backoff has passed
+	RetryDueDeliveries()
+	assert.Equal(t, 1, len(requests()))
+
+	for attempts := 2; attempts <= 3; attempts++ {
+		assert.NoError(t, models.DB.GormDB.Model(delivery).Update("next_attempt_at", time.Now().Add(-time.Second)).Error)
+		RetryDueDeliveries()
+		delivery, err = models.DB.GetWebhookDelivery(webhook.ID, delivery.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, attempts, delivery.Attempts)
+	}
+	assert.Equal(t, models.WebhookDeliverySucceeded, delivery.Status)
+	assert.Equal(t, 204, delivery.ResponseCode)
+	assert.NotNil(t, delivery.DeliveredAt)
+	assert.Equal(t, 3, len(requests()))
+}
+
+func TestDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	org := setupDatabase(t)
+	server, _ := receiver(t, http.StatusBadGateway)
+	webhook, err := models.DB.CreateOrgWebhook(org.ID, server.URL, "s3cret", []string{})
+	assert.NoError(t, err)
+
+	delivery, err := models.DB.CreateWebhookDelivery(webhook, "event-1", EventRunApproved, "{}", time.Now())
+	assert.NoError(t, err)
+	for i := 0; i < MaxAttempts; i++ {
+		Deliver(delivery)
+	}
+	assert.Equal(t, models.WebhookDeliveryFailed, delivery.Status)
+	assert.Nil(t, delivery.NextAttemptAt)
+
+	// deliveries of deleted webhooks are not retried either
+	_, err = models.DB.CreateWebhookDelivery(webhook, "event-2", EventRunApproved, "{}", time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.NoError(t, models.DB.DeleteOrgWebhook(org.ID, webhook.ID))
+	due, err := models.DB.GetDueWebhookDeliveries(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(due))
+}
+
+func TestJobStatusChanged(t *testing.T) {
+	org := setupDatabase(t)
+	server, requests := receiver(t, http.StatusOK)
+	_, err := models.DB.CreateOrgWebhook(org.ID, server.URL, "s3cret", []string{EventJobStatusChanged})
+	assert.NoError(t, err)
+	assert.NoError(t, models.DB.GormDB.Create(&models.GithubAppInstallationLink{GithubInstallationId: 42, OrganisationId: org.ID,
+		Status: models.GithubAppInstallationLinkActive}).Error)
+
+	batch, err := models.DB.CreateDiggerBatch(42, "diggerhq", "demo", "diggerhq/demo", 3, "", "main", orchestrator.DiggerCommandPlan, nil)
+	assert.NoError(t, err)
+	spec, err := json.Marshal(orchestrator.JobJson{ProjectName: "prod"})
+	assert.NoError(t, err)
+	job, err := models.DB.CreateDiggerJob(batch.ID, spec, "digger_workflow.yml")
+	assert.NoError(t, err)
+	job.Status = orchestrator_scheduler.DiggerJobSucceeded
+	job.DiggerJobSummary.ResourcesDeleted = 2
+
+	JobStatusChanged(job, nil, orchestrator_scheduler.DiggerJobStarted)
+	assert.Eventually(t, func() bool { return len(requests()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	var event struct {
+		Data JobEventData `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(requests()[0].body, &event))
+	assert.Equal(t, JobEventData{
+		JobId:            job.DiggerJobID,
+		BatchId:          batch.ID.String(),
+		RepoFullName:     "diggerhq/demo",
+		PrNumber:         3,
+		Project:          "prod",
+		Command:          "plan",
+		Status:           "succeeded",
+		PreviousStatus:   "started",
+		ResourcesDeleted: 2,
+	}, event.Data)
+
+	// unchanged statuses are not sent
+	JobStatusChanged(job, batch, orchestrator_scheduler.DiggerJobSucceeded)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, len(requests()))
+}
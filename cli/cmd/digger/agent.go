@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"github.com/diggerhq/digger/cli/pkg/agent"
+	"github.com/diggerhq/digger/cli/pkg/usage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var viperAgent *viper.Viper
+
+type AgentConfig struct {
+	Hostname    string `mapstructure:"hostname"`
+	Token       string `mapstructure:"token"`
+	Name        string `mapstructure:"name"`
+	WaitSeconds int    `mapstructure:"wait-seconds"`
+}
+
+var agentCmd = &cobra.Command{
+	Use:   "agent [flags]",
+	Short: "run jobs queued for the agent pool of the token",
+	Long:  `poll the backend for jobs of the agent pool of the token and run them one at a time`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var agentConfig AgentConfig
+		viperAgent.Unmarshal(&agentConfig)
+		if agentConfig.Hostname == "" {
+			agentConfig.Hostname = os.Getenv("DIGGER_HOSTNAME")
+		}
+		if agentConfig.Hostname == "" || agentConfig.Token == "" {
+			usage.ReportErrorAndExit("", "the backend hostname and an agent token are required", 1)
+		}
+		if agentConfig.Name == "" {
+			agentConfig.Name, _ = os.Hostname()
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		a := agent.NewAgent(agentConfig.Hostname, agentConfig.Token, agentConfig.Name, time.Duration(agentConfig.WaitSeconds)*time.Second)
+		a.Run(ctx)
+	},
+}
+
+func init() {
+	viperAgent = viper.New()
+	viperAgent.SetEnvPrefix("DIGGER_AGENT")
+	viperAgent.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	viperAgent.AutomaticEnv()
+
+	agentCmd.Flags().String("hostname", "", "url of the digger backend, defaults to DIGGER_HOSTNAME")
+	agentCmd.Flags().String("token", "", "agent token issued by the backend")
+	agentCmd.Flags().String("name", "", "name of the agent, defaults to the host name")
+	agentCmd.Flags().Int("wait-seconds", 30, "how long each poll waits for a job")
+	for _, flag := range []string{"hostname", "token", "name", "wait-seconds"} {
+		viperAgent.BindPFlag(flag, agentCmd.Flags().Lookup(flag))
+	}
+
+	rootCmd.AddCommand(agentCmd)
+}
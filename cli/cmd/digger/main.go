@@ -20,6 +20,7 @@ import (
 	"github.com/diggerhq/digger/cli/pkg/gitlab"
 	"github.com/diggerhq/digger/cli/pkg/storage"
 	"github.com/diggerhq/digger/cli/pkg/usage"
+	"github.com/diggerhq/digger/cli/pkg/utils"
 	"github.com/diggerhq/digger/libs/digger_config"
 	orchestrator "github.com/diggerhq/digger/libs/orchestrator"
 	"gopkg.in/yaml.v3"
@@ -102,14 +103,14 @@ func gitLabCI(lock core_locking.Lock, policyChecker core_policy.Checker, backend
 		log.Printf("command: %s, project: %s\n", strings.Join(v.Commands, ", "), v.ProjectName)
 	}
 
-	planStorage := storage.NewPlanStorage("", "", "", gitLabContext.GitlabUserName, gitLabContext.MergeRequestIId)
+	planStorage := storage.NewPlanStorage("", gitLabContext.ProjectNamespace, gitLabContext.ProjectName, gitLabContext.GitlabUserName, gitLabContext.MergeRequestIId)
 	reporter := &reporting.CiReporter{
 		CiService:      gitlabService,
 		PrNumber:       *gitLabContext.MergeRequestIId,
 		ReportStrategy: reportingStrategy,
 	}
 	jobs = digger.SortedCommandsByDependency(jobs, &dependencyGraph)
-	allAppliesSuccess, atLeastOneApply, err := digger.RunJobs(jobs, gitlabService, gitlabService, lock, reporter, planStorage, policyChecker, comment_updater.NoopCommentUpdater{}, backendApi, "", false, false, 0, currentDir)
+	allAppliesSuccess, atLeastOneApply, err := digger.RunJobs(jobs, gitlabService, gitlabService, lock, reporter, planStorage, policyChecker, comment_updater.NoopCommentUpdater{}, backendApi, "", nil, false, false, 0, currentDir)
 
 	if err != nil {
 		log.Printf("failed to execute command, %v", err)
@@ -197,7 +198,7 @@ func azureCI(lock core_locking.Lock, policyChecker core_policy.Checker, backendA
 		ReportStrategy: reportingStrategy,
 	}
 	jobs = digger.SortedCommandsByDependency(jobs, &dependencyGraph)
-	allAppliesSuccess, atLeastOneApply, err := digger.RunJobs(jobs, azureService, azureService, lock, reporter, planStorage, policyChecker, comment_updater.NoopCommentUpdater{}, backendApi, "", false, false, 0, currentDir)
+	allAppliesSuccess, atLeastOneApply, err := digger.RunJobs(jobs, azureService, azureService, lock, reporter, planStorage, policyChecker, comment_updater.NoopCommentUpdater{}, backendApi, "", nil, false, false, 0, currentDir)
 	if err != nil {
 		usage.ReportErrorAndExit(parsedAzureContext.BaseUrl, fmt.Sprintf("Failed to run commands. %s", err), 8)
 	}
@@ -281,6 +282,9 @@ func bitbucketCI(lock core_locking.Lock, policyChecker core_policy.Checker, back
 		jobs := orchestrator.Job{
 			ProjectName:       project,
 			ProjectDir:        projectConfig.Dir,
+			IncludePatterns:   projectConfig.IncludePatterns,
+			RequireCodeowners: projectConfig.RequireCodeownersApproval,
+			Protect:           projectConfig.Protect,
 			ProjectWorkspace:  projectConfig.Workspace,
 			Terragrunt:        projectConfig.Terragrunt,
 			OpenTofu:          projectConfig.OpenTofu,
@@ -313,6 +317,9 @@ func bitbucketCI(lock core_locking.Lock, policyChecker core_policy.Checker, back
 			job := orchestrator.Job{
 				ProjectName:        projectConfig.Name,
 				ProjectDir:         projectConfig.Dir,
+				IncludePatterns:    projectConfig.IncludePatterns,
+				RequireCodeowners:  projectConfig.RequireCodeownersApproval,
+				Protect:            projectConfig.Protect,
 				ProjectWorkspace:   projectConfig.Workspace,
 				Terragrunt:         projectConfig.Terragrunt,
 				OpenTofu:           projectConfig.OpenTofu,
@@ -343,19 +350,22 @@ func bitbucketCI(lock core_locking.Lock, policyChecker core_policy.Checker, back
 				stateEnvVars, commandEnvVars := digger_config.CollectTerraformEnvConfig(workflow.EnvVars)
 
 				job := orchestrator.Job{
-					ProjectName:      projectConfig.Name,
-					ProjectDir:       projectConfig.Dir,
-					ProjectWorkspace: projectConfig.Workspace,
-					Terragrunt:       projectConfig.Terragrunt,
-					OpenTofu:         projectConfig.OpenTofu,
-					Commands:         workflow.Configuration.OnCommitToDefault,
-					ApplyStage:       orchestrator.ToConfigStage(workflow.Apply),
-					PlanStage:        orchestrator.ToConfigStage(workflow.Plan),
-					CommandEnvVars:   commandEnvVars,
-					StateEnvVars:     stateEnvVars,
-					RequestedBy:      actor,
-					Namespace:        repository,
-					EventName:        "commit_to_default",
+					ProjectName:       projectConfig.Name,
+					ProjectDir:        projectConfig.Dir,
+					IncludePatterns:   projectConfig.IncludePatterns,
+					RequireCodeowners: projectConfig.RequireCodeownersApproval,
+					Protect:           projectConfig.Protect,
+					ProjectWorkspace:  projectConfig.Workspace,
+					Terragrunt:        projectConfig.Terragrunt,
+					OpenTofu:          projectConfig.OpenTofu,
+					Commands:          workflow.Configuration.OnCommitToDefault,
+					ApplyStage:        orchestrator.ToConfigStage(workflow.Apply),
+					PlanStage:         orchestrator.ToConfigStage(workflow.Plan),
+					CommandEnvVars:    commandEnvVars,
+					StateEnvVars:      stateEnvVars,
+					RequestedBy:       actor,
+					Namespace:         repository,
+					EventName:         "commit_to_default",
 				}
 				err := digger.RunJob(job, repository, actor, &bitbucketService, policyChecker, nil, backendApi, nil, currentDir)
 				if err != nil {
@@ -370,19 +380,22 @@ func bitbucketCI(lock core_locking.Lock, policyChecker core_policy.Checker, back
 				stateEnvVars, commandEnvVars := digger_config.CollectTerraformEnvConfig(workflow.EnvVars)
 
 				job := orchestrator.Job{
-					ProjectName:      projectConfig.Name,
-					ProjectDir:       projectConfig.Dir,
-					ProjectWorkspace: projectConfig.Workspace,
-					Terragrunt:       projectConfig.Terragrunt,
-					OpenTofu:         projectConfig.OpenTofu,
-					Commands:         []string{"digger plan"},
-					ApplyStage:       orchestrator.ToConfigStage(workflow.Apply),
-					PlanStage:        orchestrator.ToConfigStage(workflow.Plan),
-					CommandEnvVars:   commandEnvVars,
-					StateEnvVars:     stateEnvVars,
-					RequestedBy:      actor,
-					Namespace:        repository,
-					EventName:        "commit_to_default",
+					ProjectName:       projectConfig.Name,
+					ProjectDir:        projectConfig.Dir,
+					IncludePatterns:   projectConfig.IncludePatterns,
+					RequireCodeowners: projectConfig.RequireCodeownersApproval,
+					Protect:           projectConfig.Protect,
+					ProjectWorkspace:  projectConfig.Workspace,
+					Terragrunt:        projectConfig.Terragrunt,
+					OpenTofu:          projectConfig.OpenTofu,
+					Commands:          []string{"digger plan"},
+					ApplyStage:        orchestrator.ToConfigStage(workflow.Apply),
+					PlanStage:         orchestrator.ToConfigStage(workflow.Plan),
+					CommandEnvVars:    commandEnvVars,
+					StateEnvVars:      stateEnvVars,
+					RequestedBy:       actor,
+					Namespace:         repository,
+					EventName:         "commit_to_default",
 				}
 				err := digger.RunJob(job, repository, actor, &bitbucketService, policyChecker, nil, backendApi, nil, currentDir)
 				if err != nil {
@@ -417,6 +430,9 @@ func bitbucketCI(lock core_locking.Lock, policyChecker core_policy.Checker, back
 				job := orchestrator.Job{
 					ProjectName:       project.Name,
 					ProjectDir:        project.Dir,
+					IncludePatterns:   project.IncludePatterns,
+					RequireCodeowners: project.RequireCodeownersApproval,
+					Protect:           project.Protect,
 					ProjectWorkspace:  project.Workspace,
 					Terragrunt:        project.Terragrunt,
 					OpenTofu:          project.OpenTofu,
@@ -447,7 +463,7 @@ func bitbucketCI(lock core_locking.Lock, policyChecker core_policy.Checker, back
 
 			jobs = digger.SortedCommandsByDependency(jobs, &dependencyGraph)
 
-			_, _, err = digger.RunJobs(jobs, &bitbucketService, &bitbucketService, lock, &reporter, planStorage, policyChecker, comment_updater.NoopCommentUpdater{}, backendApi, "", false, false, 0, currentDir)
+			_, _, err = digger.RunJobs(jobs, &bitbucketService, &bitbucketService, lock, &reporter, planStorage, policyChecker, comment_updater.NoopCommentUpdater{}, backendApi, "", nil, false, false, 0, currentDir)
 			if err != nil {
 				usage.ReportErrorAndExit(actor, fmt.Sprintf("Failed to run commands. %s", err), 8)
 			}
@@ -470,7 +486,8 @@ func exec(actor string, projectName string, repoNamespace string, command string
 
 	}
 
-	planStorage := storage.NewPlanStorage("", "", "", actor, nil)
+	repoOwner, repositoryName := utils.ParseRepoNamespace(repoNamespace)
+	planStorage := storage.NewPlanStorage("", repoOwner, repositoryName, actor, nil)
 
 	diggerConfig, _, dependencyGraph, err := digger_config.LoadDiggerConfig("./", true)
 	if err != nil {
@@ -484,7 +501,7 @@ func exec(actor string, projectName string, repoNamespace string, command string
 	}
 
 	jobs = digger.SortedCommandsByDependency(jobs, &dependencyGraph)
-	_, _, err = digger.RunJobs(jobs, prService, orgService, lock, reporter, planStorage, policyChecker, comment_updater.NoopCommentUpdater{}, backendApi, "", false, false, 123, currentDir)
+	_, _, err = digger.RunJobs(jobs, prService, orgService, lock, reporter, planStorage, policyChecker, comment_updater.NoopCommentUpdater{}, backendApi, "", nil, false, false, 123, currentDir)
 }
 
 /*
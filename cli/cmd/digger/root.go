@@ -12,6 +12,7 @@ import (
 	locking2 "github.com/diggerhq/digger/libs/locking"
 	"github.com/diggerhq/digger/libs/orchestrator"
 	orchestrator_github "github.com/diggerhq/digger/libs/orchestrator/github"
+	"github.com/diggerhq/digger/libs/tracing"
 	"github.com/spf13/cobra"
 	"log"
 	"net/http"
@@ -84,12 +85,16 @@ var ReportStrategy reporting.ReportStrategy
 var lock locking2.Lock
 
 func PreRun(cmd *cobra.Command, args []string) {
-	if cmd.Name() == "run_spec" {
+	tracing.Init("digger-cli")
+	if cmd.Name() == "run_spec" || cmd.Name() == "agent" || cmd.Name() == "sweep-plans" {
 		return
 	}
 
 	hostName := os.Getenv("DIGGER_HOSTNAME")
 	token := os.Getenv("DIGGER_TOKEN")
+	if os.Getenv("DIGGER_USE_OIDC") == "true" {
+		token = backend.OidcAuthToken
+	}
 	orgName := os.Getenv("DIGGER_ORGANISATION")
 	BackendApi = backend.NewBackendApi(hostName, token)
 	PolicyChecker = policy.NewPolicyChecker(hostName, orgName, token)
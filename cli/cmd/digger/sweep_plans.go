@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"github.com/diggerhq/digger/cli/pkg/storage"
+	"github.com/diggerhq/digger/cli/pkg/usage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+var vipSweepPlans *viper.Viper
+
+type SweepPlansConfig struct {
+	RunConfig     `mapstructure:",squash"`
+	RetentionDays int `mapstructure:"retention-days"`
+}
+
+var sweepPlansCmd = &cobra.Command{
+	Use:   "sweep-plans [flags]",
+	Short: "Delete stored plans that are too old or belong to closed pull requests",
+	Long: `Delete plans of the plan storage selected with PLAN_UPLOAD_DESTINATION that are older than the retention,
+and with a reporter and repo namespace also the plans of closed pull requests`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var sweepConfig SweepPlansConfig
+		vipSweepPlans.Unmarshal(&sweepConfig)
+
+		destination := strings.ToLower(os.Getenv("PLAN_UPLOAD_DESTINATION"))
+		if destination == "github" || destination == "backend" {
+			usage.ReportErrorAndExit(sweepConfig.Actor, fmt.Sprintf("plans stored in %v expire on their own", destination), 1)
+		}
+		planStorage := storage.NewPlanStorage("", "", "", sweepConfig.Actor, nil)
+		if planStorage == nil {
+			usage.ReportErrorAndExit(sweepConfig.Actor, "PLAN_UPLOAD_DESTINATION does not select a plan storage", 1)
+		}
+
+		retention := storage.PlanRetention{
+			MaxAge: time.Duration(sweepConfig.RetentionDays) * 24 * time.Hour,
+		}
+		if sweepConfig.Reporter != "" && sweepConfig.Reporter != "stdout" && sweepConfig.RepoNamespace != "" {
+			prService, _, _, err := sweepConfig.GetServices()
+			if err != nil {
+				usage.ReportErrorAndExit(sweepConfig.Actor, fmt.Sprintf("could not create pull request service: %v", err), 1)
+			}
+			retention.RepoNamespace = sweepConfig.RepoNamespace
+			retention.PrService = *prService
+		}
+		if retention.MaxAge == 0 && retention.PrService == nil {
+			usage.ReportErrorAndExit(sweepConfig.Actor, "either a retention or a reporter with the repo namespace is required", 1)
+		}
+
+		deleted, err := storage.SweepPlans(planStorage, retention, time.Now())
+		if err != nil {
+			usage.ReportErrorAndExit(sweepConfig.Actor, fmt.Sprintf("failed to sweep plans: %v", err), 1)
+		}
+		log.Printf("Deleted %v expired plans", len(deleted))
+	},
+}
+
+func init() {
+	vipSweepPlans = viper.New()
+	vipSweepPlans.SetEnvPrefix("DIGGER")
+	vipSweepPlans.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	vipSweepPlans.AutomaticEnv()
+
+	sweepPlansCmd.Flags().String("github-token", "", "Github token (for github reporter)")
+	sweepPlansCmd.Flags().String("bitbucket-token", "", "Bitbucket token (for bitbucket reporter)")
+	sweepPlansCmd.Flags().String("repo-namespace", "", "The namespace of this repo")
+	sweepPlansCmd.Flags().String("actor", "", "The actor of this command")
+	sweepPlansCmd.Flags().String("reporter", "", "The service to check pull requests with (github or bitbucket)")
+	sweepPlansCmd.Flags().Int("retention-days", 0, "Delete plans older than this many days, 0 keeps them")
+	for _, flag := range []string{"github-token", "bitbucket-token", "repo-namespace", "actor", "reporter", "retention-days"} {
+		vipSweepPlans.BindPFlag(flag, sweepPlansCmd.Flags().Lookup(flag))
+	}
+
+	rootCmd.AddCommand(sweepPlansCmd)
+}
@@ -0,0 +1,259 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ClaimedJob is a job handed to the agent by the backend
+type ClaimedJob struct {
+	Id       uint   `json:"id"`
+	JobId    string `json:"job_id"`
+	Spec     string `json:"spec"`
+	VcsType  string `json:"vcs_type"`
+	CloneUrl string `json:"clone_url"`
+	Branch   string `json:"branch"`
+	Token    string `json:"token"`
+}
+
+// Runner runs a claimed job and returns the exit code of `digger run_spec`
+type Runner func(ctx context.Context, job ClaimedJob) (int, error)
+
+var errJobLost = errors.New("job is no longer claimed by this agent")
+
+// Agent claims the jobs of the pool of its token one at a time, heartbeats while running them and reports their exit code
+type Agent struct {
+	Hostname string
+	Token    string
+	Name     string
+	// Wait is how long the backend holds a claim request when there is no job queued
+	Wait              time.Duration
+	HeartbeatInterval time.Duration
+	// RetryInterval is how long the agent waits after the backend could not be reached
+	RetryInterval time.Duration
+	HttpClient    *http.Client
+	Runner        Runner
+}
+
+func NewAgent(hostname string, token string, name string, wait time.Duration) *Agent {
+	return &Agent{
+		Hostname:          hostname,
+		Token:             token,
+		Name:              name,
+		Wait:              wait,
+		HeartbeatInterval: 30 * time.Second,
+		RetryInterval:     10 * time.Second,
+		HttpClient:        &http.Client{Timeout: wait + 30*time.Second},
+		Runner:            RunSpecProcess,
+	}
+}
+
+// Run claims and runs jobs until the context is cancelled
+func (a *Agent) Run(ctx context.Context) error {
+	log.Printf("Agent %v polling %v for jobs", a.Name, a.Hostname)
+	for ctx.Err() == nil {
+		_, err := a.RunOnce(ctx)
+		if err != nil {
+			log.Printf("Agent error: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(a.RetryInterval):
+			}
+		}
+	}
+	return nil
+}
+
+// RunOnce claims a job and runs it, it returns false when there was no job to claim
+func (a *Agent) RunOnce(ctx context.Context) (bool, error) {
+	job, err := a.claim(ctx)
+	if err != nil {
+		return false, err
+	}
+	if job == nil {
+		return false, nil
+	}
+
+	log.Printf("Claimed job %v", job.JobId)
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go a.heartbeat(jobCtx, cancel, job.Id)
+
+	exitCode, err := a.Runner(jobCtx, *job)
+	if err != nil {
+		log.Printf("Could not run job %v: %v", job.JobId, err)
+		if exitCode == 0 {
+			exitCode = 1
+		}
+	}
+	log.Printf("Job %v exited with code %v", job.JobId, exitCode)
+
+	// the job is reported even if the agent is stopping, otherwise it would only fail once it is declared lost
+	finishCtx, finishCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer finishCancel()
+	err = a.post(finishCtx, fmt.Sprintf("agents/jobs/%v/finish", job.Id), map[string]interface{}{"exit_code": exitCode}, nil)
+	if err != nil && !errors.Is(err, errJobLost) {
+		return true, fmt.Errorf("could not report result of job %v: %v", job.JobId, err)
+	}
+	return true, nil
+}
+
+func (a *Agent) heartbeat(ctx context.Context, cancel context.CancelFunc, id uint) {
+	ticker := time.NewTicker(a.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := a.post(ctx, fmt.Sprintf("agents/jobs/%v/heartbeat", id), nil, nil)
+			if errors.Is(err, errJobLost) {
+				log.Printf("Job was declared lost by the backend, stopping it")
+				cancel()
+				return
+			}
+			if err != nil {
+				log.Printf("Could not send heartbeat: %v", err)
+			}
+		}
+	}
+}
+
+func (a *Agent) claim(ctx context.Context) (*ClaimedJob, error) {
+	request := map[string]interface{}{
+		"agent_name":   a.Name,
+		"wait_seconds": int(a.Wait.Seconds()),
+	}
+	var job ClaimedJob
+	err := a.post(ctx, "agents/jobs/claim", request, &job)
+	if err != nil {
+		return nil, err
+	}
+	if job.JobId == "" {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+// post sends the body as json, the response is decoded into response unless the backend returned no content
+func (a *Agent) post(ctx context.Context, path string, body interface{}, response interface{}) error {
+	u, err := url.Parse(a.Hostname)
+	if err != nil {
+		return fmt.Errorf("invalid hostname: %v", err)
+	}
+	u = u.JoinPath(path)
+
+	if body == nil {
+		body = map[string]interface{}{}
+	}
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("could not marshal request: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("error while creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+
+	resp, err := a.HttpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error while sending request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if response != nil {
+			return json.NewDecoder(resp.Body).Decode(response)
+		}
+		return nil
+	case http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return errJobLost
+	default:
+		message, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %v from %v: %v", resp.StatusCode, path, string(message))
+	}
+}
+
+// RunSpecProcess clones the branch of the job into a temporary directory and runs `digger run_spec` in it, the
+// spec runs in its own process since it exits once done
+func RunSpecProcess(ctx context.Context, job ClaimedJob) (int, error) {
+	dir, err := os.MkdirTemp("", "digger-agent-")
+	if err != nil {
+		return 1, fmt.Errorf("could not create working directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cloneUrl, err := url.Parse(job.CloneUrl)
+	if err != nil {
+		return 1, fmt.Errorf("invalid clone url: %v", err)
+	}
+	env := jobEnv(os.Environ(), job)
+	token, username := job.Token, "x-access-token"
+	if job.VcsType == "gitlab" {
+		token, username = os.Getenv("GITLAB_TOKEN"), "oauth2"
+	}
+	if token != "" {
+		cloneUrl.User = url.UserPassword(username, token)
+	}
+
+	clone := exec.CommandContext(ctx, "git", "clone", "--depth", "1", "--branch", job.Branch, cloneUrl.String(), dir)
+	clone.Stdout = os.Stdout
+	clone.Stderr = os.Stderr
+	if err := clone.Run(); err != nil {
+		return 1, fmt.Errorf("could not clone %v: %v", job.CloneUrl, err)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return 1, fmt.Errorf("could not find digger executable: %v", err)
+	}
+	runSpec := exec.CommandContext(ctx, executable, "run_spec")
+	runSpec.Dir = dir
+	runSpec.Env = env
+	runSpec.Stdout = os.Stdout
+	runSpec.Stderr = os.Stderr
+	err = runSpec.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// jobEnv is the environment of `digger run_spec`, the agent token is not passed on
+func jobEnv(environ []string, job ClaimedJob) []string {
+	env := make([]string, 0, len(environ)+2)
+	for _, e := range environ {
+		if strings.HasPrefix(e, "DIGGER_AGENT_") || strings.HasPrefix(e, "DIGGER_SPEC=") {
+			continue
+		}
+		if job.Token != "" && strings.HasPrefix(e, "GITHUB_TOKEN=") {
+			continue
+		}
+		env = append(env, e)
+	}
+	env = append(env, "DIGGER_SPEC="+job.Spec)
+	if job.Token != "" {
+		env = append(env, "GITHUB_TOKEN="+job.Token)
+	}
+	return env
+}
@@ -0,0 +1,126 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeBackend struct {
+	mu         sync.Mutex
+	jobs       []ClaimedJob
+	heartbeats int
+	exitCodes  map[string]int
+	lost       bool
+}
+
+func (f *fakeBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer a:token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch r.URL.Path {
+	case "/agents/jobs/claim":
+		if len(f.jobs) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(f.jobs[0])
+		f.jobs = f.jobs[1:]
+	case "/agents/jobs/7/heartbeat":
+		f.heartbeats++
+		if f.lost {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "/agents/jobs/7/finish":
+		var body struct {
+			ExitCode int `json:"exit_code"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.exitCodes["7"] = body.ExitCode
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func testAgent(url string, runner Runner) *Agent {
+	agent := NewAgent(url, "a:token", "agent-1", 0)
+	agent.HeartbeatInterval = 10 * time.Millisecond
+	agent.Runner = runner
+	return agent
+}
+
+func TestAgentRunsClaimedJobAndReportsExitCode(t *testing.T) {
+	backend := &fakeBackend{jobs: []ClaimedJob{{Id: 7, JobId: "job-7", Spec: "{}"}}, exitCodes: map[string]int{}}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+
+	var ran ClaimedJob
+	agent := testAgent(server.URL, func(ctx context.Context, job ClaimedJob) (int, error) {
+		ran = job
+		time.Sleep(50 * time.Millisecond)
+		return 2, nil
+	})
+
+	claimed, err := agent.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	assert.Equal(t, "job-7", ran.JobId)
+	assert.Equal(t, 2, backend.exitCodes["7"])
+	assert.Greater(t, backend.heartbeats, 0)
+
+	claimed, err = agent.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+}
+
+func TestAgentStopsLostJob(t *testing.T) {
+	backend := &fakeBackend{jobs: []ClaimedJob{{Id: 7, JobId: "job-7"}}, exitCodes: map[string]int{}, lost: true}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+
+	agent := testAgent(server.URL, func(ctx context.Context, job ClaimedJob) (int, error) {
+		select {
+		case <-ctx.Done():
+			return 1, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return 0, nil
+		}
+	})
+
+	start := time.Now()
+	claimed, err := agent.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestAgentRejectedToken(t *testing.T) {
+	server := httptest.NewServer(&fakeBackend{exitCodes: map[string]int{}})
+	defer server.Close()
+
+	agent := testAgent(server.URL, nil)
+	agent.Token = "a:wrong"
+	_, err := agent.RunOnce(context.Background())
+	assert.Error(t, err)
+}
+
+func TestJobEnvReplacesCredentials(t *testing.T) {
+	environ := []string{"PATH=/bin", "DIGGER_AGENT_TOKEN=a:token", "GITHUB_TOKEN=old"}
+	env := jobEnv(environ, ClaimedJob{Spec: `{"job_id": "1"}`, Token: "ghs_new"})
+	assert.Equal(t, []string{"PATH=/bin", `DIGGER_SPEC={"job_id": "1"}`, "GITHUB_TOKEN=ghs_new"}, env)
+
+	env = jobEnv(environ, ClaimedJob{Spec: "{}"})
+	assert.Equal(t, []string{"PATH=/bin", "GITHUB_TOKEN=old", "DIGGER_SPEC={}"}, env)
+}
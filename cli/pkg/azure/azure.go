@@ -349,6 +349,37 @@ func (a *AzureReposService) GetBranchName(prNumber int) (string, string, error)
 	return "", "", nil
 }
 
+func (a *AzureReposService) GetPullRequestDetails(prNumber int) (*orchestrator.PullRequestDetails, error) {
+	pullRequest, err := a.Client.GetPullRequestById(context.Background(), git.GetPullRequestByIdArgs{
+		Project:       &a.ProjectName,
+		PullRequestId: &prNumber,
+	})
+	if err != nil {
+		return nil, err
+	}
+	details := &orchestrator.PullRequestDetails{Labels: make([]string, 0)}
+	if pullRequest.Labels != nil {
+		for _, label := range *pullRequest.Labels {
+			if label.Name != nil {
+				details.Labels = append(details.Labels, *label.Name)
+			}
+		}
+	}
+	if pullRequest.CreatedBy != nil && pullRequest.CreatedBy.UniqueName != nil {
+		details.Author = *pullRequest.CreatedBy.UniqueName
+	}
+	if pullRequest.SourceRefName != nil {
+		details.Branch = strings.TrimPrefix(*pullRequest.SourceRefName, "refs/heads/")
+	}
+	if pullRequest.TargetRefName != nil {
+		details.BaseBranch = strings.TrimPrefix(*pullRequest.TargetRefName, "refs/heads/")
+	}
+	if pullRequest.LastMergeSourceCommit != nil && pullRequest.LastMergeSourceCommit.CommitId != nil {
+		details.CommitSha = *pullRequest.LastMergeSourceCommit.CommitId
+	}
+	return details, nil
+}
+
 func (svc *AzureReposService) SetOutput(prNumber int, key string, value string) error {
 	//TODO implement me
 	return nil
@@ -439,6 +470,9 @@ func ConvertAzureEventToCommands(parseAzureContext Azure, impactedProjects []dig
 			jobs = append(jobs, orchestrator.Job{
 				ProjectName:        project.Name,
 				ProjectDir:         project.Dir,
+				IncludePatterns:    project.IncludePatterns,
+				RequireCodeowners:  project.RequireCodeownersApproval,
+				Protect:            project.Protect,
 				ProjectWorkspace:   project.Workspace,
 				Terragrunt:         project.Terragrunt,
 				OpenTofu:           project.OpenTofu,
@@ -469,6 +503,9 @@ func ConvertAzureEventToCommands(parseAzureContext Azure, impactedProjects []dig
 			jobs = append(jobs, orchestrator.Job{
 				ProjectName:        project.Name,
 				ProjectDir:         project.Dir,
+				IncludePatterns:    project.IncludePatterns,
+				RequireCodeowners:  project.RequireCodeownersApproval,
+				Protect:            project.Protect,
 				ProjectWorkspace:   project.Workspace,
 				Terragrunt:         project.Terragrunt,
 				OpenTofu:           project.OpenTofu,
@@ -499,6 +536,9 @@ func ConvertAzureEventToCommands(parseAzureContext Azure, impactedProjects []dig
 				jobs = append(jobs, orchestrator.Job{
 					ProjectName:        project.Name,
 					ProjectDir:         project.Dir,
+					IncludePatterns:    project.IncludePatterns,
+					RequireCodeowners:  project.RequireCodeownersApproval,
+					Protect:            project.Protect,
 					ProjectWorkspace:   project.Workspace,
 					Terragrunt:         project.Terragrunt,
 					OpenTofu:           project.OpenTofu,
@@ -554,6 +594,9 @@ func ConvertAzureEventToCommands(parseAzureContext Azure, impactedProjects []dig
 					jobs = append(jobs, orchestrator.Job{
 						ProjectName:        project.Name,
 						ProjectDir:         project.Dir,
+						IncludePatterns:    project.IncludePatterns,
+						RequireCodeowners:  project.RequireCodeownersApproval,
+						Protect:            project.Protect,
 						ProjectWorkspace:   workspace,
 						Terragrunt:         project.Terragrunt,
 						OpenTofu:           project.OpenTofu,
@@ -564,6 +607,7 @@ func ConvertAzureEventToCommands(parseAzureContext Azure, impactedProjects []dig
 						EventName:          parseAzureContext.EventType,
 						RequestedBy:        parseAzureContext.BaseUrl,
 						Namespace:          parseAzureContext.BaseUrl + "/" + parseAzureContext.ProjectName,
+						ConfirmDestroy:     orchestrator.ParseConfirmDestroy(parseAzureContext.Event.(AzureCommentEvent).Resource.Comment.Content),
 						StateEnvVars:       stateEnvVars,
 						CommandEnvVars:     commandEnvVars,
 						StateEnvProvider:   StateEnvProvider,
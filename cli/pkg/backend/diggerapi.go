@@ -28,7 +28,7 @@ func (n NoopApi) ReportProjectRun(namespace string, projectName string, startedA
 	return nil
 }
 
-func (n NoopApi) ReportProjectJobStatus(repo string, projectName string, jobId string, status string, timestamp time.Time, summary *execution.DiggerExecutorPlanResult, PrCommentUrl string, terraformOutput string) (*scheduler.SerializedBatch, error) {
+func (n NoopApi) ReportProjectJobStatus(repo string, projectName string, jobId string, status string, timestamp time.Time, summary *execution.DiggerExecutorPlanResult, PrCommentUrl string, terraformOutput string, report *backend.JobReport) (*scheduler.SerializedBatch, error) {
 	return nil, nil
 }
 
@@ -62,7 +62,11 @@ func (d DiggerApi) ReportProject(namespace string, projectName string, configura
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", d.AuthToken))
+	authToken, err := ResolveAuthToken(d.AuthToken)
+	if err != nil {
+		return fmt.Errorf("could not get auth token: %v", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", authToken))
 
 	resp, err := d.HttpClient.Do(req)
 
@@ -105,7 +109,11 @@ func (d DiggerApi) ReportProjectRun(namespace string, projectName string, starte
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", d.AuthToken))
+	authToken, err := ResolveAuthToken(d.AuthToken)
+	if err != nil {
+		return fmt.Errorf("could not get auth token: %v", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", authToken))
 
 	resp, err := d.HttpClient.Do(req)
 
@@ -120,7 +128,7 @@ func (d DiggerApi) ReportProjectRun(namespace string, projectName string, starte
 	return nil
 }
 
-func (d DiggerApi) ReportProjectJobStatus(repo string, projectName string, jobId string, status string, timestamp time.Time, planResult *execution.DiggerExecutorPlanResult, PrCommentUrl string, terraformOutput string) (*scheduler.SerializedBatch, error) {
+func (d DiggerApi) ReportProjectJobStatus(repo string, projectName string, jobId string, status string, timestamp time.Time, planResult *execution.DiggerExecutorPlanResult, PrCommentUrl string, terraformOutput string, report *backend.JobReport) (*scheduler.SerializedBatch, error) {
 	u, err := url.Parse(d.DiggerHost)
 	if err != nil {
 		log.Fatalf("Not able to parse digger cloud url: %v", err)
@@ -143,14 +151,21 @@ func (d DiggerApi) ReportProjectJobStatus(repo string, projectName string, jobId
 		}
 	}
 
+	if report == nil {
+		report = backend.NewJobReport()
+	}
+
 	u.Path = filepath.Join(u.Path, "repos", repo, "projects", projectName, "jobs", jobId, "set-status")
 	request := map[string]interface{}{
-		"status":             status,
-		"timestamp":          timestamp,
-		"job_summary":        planSummaryJson,
-		"job_plan_footprint": planFootprint.ToJson(),
-		"pr_comment_url":     PrCommentUrl,
-		"terraform_output":   terraformOutput,
+		"status":                status,
+		"timestamp":             timestamp,
+		"job_summary":           planSummaryJson,
+		"job_plan_footprint":    planFootprint.ToJson(),
+		"pr_comment_url":        PrCommentUrl,
+		"terraform_output":      terraformOutput,
+		"policy_versions":       report.PolicyVersions,
+		"policy_decisions":      report.PolicyDecisions,
+		"destroy_confirmations": report.DestroyConfirmations,
 	}
 
 	jsonData, err := json.Marshal(request)
@@ -165,7 +180,11 @@ func (d DiggerApi) ReportProjectJobStatus(repo string, projectName string, jobId
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", d.AuthToken))
+	authToken, err := ResolveAuthToken(d.AuthToken)
+	if err != nil {
+		return nil, fmt.Errorf("could not get auth token: %v", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", authToken))
 
 	resp, err := d.HttpClient.Do(req)
 
@@ -176,6 +195,7 @@ func (d DiggerApi) ReportProjectJobStatus(repo string, projectName string, jobId
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("unexpected status when reporting a project job status: %v", resp.StatusCode)
 	}
+	report.Reported()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
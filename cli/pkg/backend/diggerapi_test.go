@@ -0,0 +1,49 @@
+package backend
+
+import (
+	"encoding/json"
+	"github.com/diggerhq/digger/cli/pkg/core/backend"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReportProjectJobStatusSendsJobReport(t *testing.T) {
+	var request map[string]json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/diggerhq-demo/projects/prod/jobs/job-1/set-status", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	api := DiggerApi{DiggerHost: server.URL, AuthToken: "token", HttpClient: server.Client()}
+
+	report := backend.NewJobReport()
+	report.RecordPolicyVersion("plan", 4)
+	report.RecordPolicyVersion("access", 0)
+	report.RecordPolicyDecision(backend.PolicyDecision{PolicyType: "plan", Project: "prod", Allowed: true, Messages: []string{}})
+	report.RecordDestroyConfirmations("alice", []string{"aws_s3_bucket.state"})
+	_, err := api.ReportProjectJobStatus("diggerhq-demo", "prod", "job-1", "failed", time.Now(), nil, "", "", report)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"plan": 4}`, string(request["policy_versions"]))
+	var decisions []backend.PolicyDecision
+	assert.NoError(t, json.Unmarshal(request["policy_decisions"], &decisions))
+	assert.Len(t, decisions, 1)
+	assert.JSONEq(t, `[{"address": "aws_s3_bucket.state", "confirmed_by": "alice"}]`, string(request["destroy_confirmations"]))
+
+	// a later status of the job doesn't report them again
+	_, err = api.ReportProjectJobStatus("diggerhq-demo", "prod", "job-1", "failed", time.Now(), nil, "", "", report)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"plan": 4}`, string(request["policy_versions"]))
+	assert.JSONEq(t, `[]`, string(request["policy_decisions"]))
+	assert.JSONEq(t, `[]`, string(request["destroy_confirmations"]))
+
+	// statuses reported before the job ran have nothing to report
+	_, err = api.ReportProjectJobStatus("diggerhq-demo", "prod", "job-1", "started", time.Now(), nil, "", "", nil)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(request["policy_versions"]))
+	assert.JSONEq(t, `[]`, string(request["policy_decisions"]))
+	assert.JSONEq(t, `[]`, string(request["destroy_confirmations"]))
+}
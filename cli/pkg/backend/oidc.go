@@ -0,0 +1,123 @@
+package backend
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OidcAuthToken is used in place of DIGGER_TOKEN when DIGGER_USE_OIDC is set,
+// requests then authenticate with a short-lived identity token issued by the CI provider
+const OidcAuthToken = "oidc"
+
+var oidcTokenMutex sync.Mutex
+var cachedOidcToken string
+var cachedOidcTokenExpiry time.Time
+
+// ResolveAuthToken returns the token to send to the backend, fetching a fresh CI identity token if needed
+func ResolveAuthToken(authToken string) (string, error) {
+	if authToken != OidcAuthToken {
+		return authToken, nil
+	}
+
+	oidcTokenMutex.Lock()
+	defer oidcTokenMutex.Unlock()
+
+	// identity tokens are short-lived, refresh them a minute before they expire
+	if cachedOidcToken != "" && time.Now().Add(time.Minute).Before(cachedOidcTokenExpiry) {
+		return cachedOidcToken, nil
+	}
+
+	audience := os.Getenv("DIGGER_OIDC_AUDIENCE")
+	if audience == "" {
+		audience = "digger"
+	}
+	token, err := GetOidcToken(audience)
+	if err != nil {
+		return "", err
+	}
+	cachedOidcToken = token
+	cachedOidcTokenExpiry = tokenExpiry(token)
+	return token, nil
+}
+
+// GetOidcToken requests an identity token from GitHub Actions, GitLab or Buildkite
+func GetOidcToken(audience string) (string, error) {
+	// GitLab exposes tokens configured with the id_tokens keyword as environment variables
+	if token := os.Getenv("DIGGER_OIDC_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	if requestUrl := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL"); requestUrl != "" {
+		return getGithubActionsOidcToken(requestUrl, os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN"), audience)
+	}
+
+	if os.Getenv("BUILDKITE") == "true" {
+		out, err := exec.Command("buildkite-agent", "oidc", "request-token", "--audience", audience).Output()
+		if err != nil {
+			return "", fmt.Errorf("could not request buildkite oidc token: %v", err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	return "", fmt.Errorf("no oidc token available, set DIGGER_OIDC_TOKEN or grant the id-token permission to the job")
+}
+
+func getGithubActionsOidcToken(requestUrl string, requestToken string, audience string) (string, error) {
+	u, err := url.Parse(requestUrl)
+	if err != nil {
+		return "", fmt.Errorf("could not parse ACTIONS_ID_TOKEN_REQUEST_URL: %v", err)
+	}
+	query := u.Query()
+	query.Set("audience", audience)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("could not request github oidc token: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status when requesting github oidc token: %v", resp.StatusCode)
+	}
+
+	var response struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", fmt.Errorf("could not decode github oidc token response: %v", err)
+	}
+	return response.Value, nil
+}
+
+// tokenExpiry reads the exp claim without verifying the token, the backend does the verification
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
@@ -0,0 +1,46 @@
+package backend
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func fakeJwt(exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp": %v}`, exp.Unix())))
+	return "eyJhbGciOiJSUzI1NiJ9." + payload + ".c2lnbmF0dXJl"
+}
+
+func TestResolveAuthTokenFetchesGithubOidcToken(t *testing.T) {
+	token := fakeJwt(time.Now().Add(5 * time.Minute))
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "Bearer request-token", r.Header.Get("Authorization"))
+		assert.Equal(t, "digger", r.URL.Query().Get("audience"))
+		fmt.Fprintf(w, `{"value": "%v"}`, token)
+	}))
+	defer server.Close()
+
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", server.URL+"?api-version=2.0")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "request-token")
+	cachedOidcToken = ""
+
+	resolved, err := ResolveAuthToken(OidcAuthToken)
+	assert.NoError(t, err)
+	assert.Equal(t, token, resolved)
+
+	// the token is reused until shortly before it expires
+	resolved, err = ResolveAuthToken(OidcAuthToken)
+	assert.NoError(t, err)
+	assert.Equal(t, token, resolved)
+	assert.Equal(t, 1, requests)
+
+	resolved, err = ResolveAuthToken("t:static")
+	assert.NoError(t, err)
+	assert.Equal(t, "t:static", resolved)
+}
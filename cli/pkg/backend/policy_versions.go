@@ -0,0 +1,4 @@
+package backend
+
+// PolicyVersionHeader carries the id of the policy version served by the backend
+const PolicyVersionHeader = "Digger-Policy-Version"
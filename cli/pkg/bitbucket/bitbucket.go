@@ -476,6 +476,53 @@ func (b BitbucketAPI) GetBranchName(prNumber int) (string, string, error) {
 	return pullRequest.Source.Branch.Name, "", nil
 }
 
+// GetPullRequestDetails returns no labels, bitbucket pull requests don't have any
+func (b BitbucketAPI) GetPullRequestDetails(prNumber int) (*orchestrator.PullRequestDetails, error) {
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d", bitbucketBaseURL, b.RepoWorkspace, b.RepoName, prNumber)
+
+	resp, err := b.sendRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get pull request. Status code: %d", resp.StatusCode)
+	}
+
+	var pullRequest struct {
+		Author struct {
+			Nickname string `json:"nickname"`
+		} `json:"author"`
+		Source struct {
+			Branch struct {
+				Name string `json:"name"`
+			} `json:"branch"`
+			Commit struct {
+				Hash string `json:"hash"`
+			} `json:"commit"`
+		} `json:"source"`
+		Destination struct {
+			Branch struct {
+				Name string `json:"name"`
+			} `json:"branch"`
+		} `json:"destination"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&pullRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &orchestrator.PullRequestDetails{
+		Author:     pullRequest.Author.Nickname,
+		Labels:     []string{},
+		Branch:     pullRequest.Source.Branch.Name,
+		CommitSha:  pullRequest.Source.Commit.Hash,
+		BaseBranch: pullRequest.Destination.Branch.Name,
+	}, nil
+}
+
 func (svc BitbucketAPI) SetOutput(prNumber int, key string, value string) error {
 	//TODO implement me
 	return nil
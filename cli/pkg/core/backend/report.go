@@ -0,0 +1,87 @@
+package backend
+
+import "time"
+
+// JobReport collects what a job reports to the backend along with its status besides the plan, it is filled while the
+// job runs. A nil report records nothing
+type JobReport struct {
+	// PolicyVersions are the ids of the policy versions evaluated by the job, keyed by policy type
+	PolicyVersions map[string]uint
+	// PolicyDecisions are the policy evaluations of the job
+	PolicyDecisions []PolicyDecision
+	// DestroyConfirmations are the protected resources the job was confirmed to destroy
+	DestroyConfirmations []DestroyConfirmation
+}
+
+// PolicyDecision is the record of a single policy evaluation, inputs are redacted before they are recorded
+type PolicyDecision struct {
+	Timestamp       time.Time              `json:"timestamp"`
+	PolicyType      string                 `json:"policy_type"`
+	PolicyHash      string                 `json:"policy_hash"`
+	PolicyVersionId *uint                  `json:"policy_version_id,omitempty"`
+	Project         string                 `json:"project"`
+	PrNumber        *int                   `json:"pr_number,omitempty"`
+	Input           map[string]interface{} `json:"input"`
+	Allowed         bool                   `json:"allowed"`
+	Messages        []string               `json:"messages"`
+	Warnings        []string               `json:"warnings,omitempty"`
+	Error           string                 `json:"error,omitempty"`
+}
+
+// DestroyConfirmation records that a user confirmed the destruction of a protected resource with --confirm-destroy
+type DestroyConfirmation struct {
+	Address     string `json:"address"`
+	ConfirmedBy string `json:"confirmed_by"`
+}
+
+func NewJobReport() *JobReport {
+	return &JobReport{PolicyVersions: map[string]uint{}, PolicyDecisions: []PolicyDecision{}, DestroyConfirmations: []DestroyConfirmation{}}
+}
+
+// RecordPolicyVersion remembers which version of a policy was evaluated by the job, the version id is 0 when the
+// backend doesn't version policies
+func (r *JobReport) RecordPolicyVersion(policyType string, versionId uint) {
+	if r == nil || versionId == 0 {
+		return
+	}
+	if r.PolicyVersions == nil {
+		r.PolicyVersions = map[string]uint{}
+	}
+	r.PolicyVersions[policyType] = versionId
+}
+
+func (r *JobReport) PolicyVersion(policyType string) (uint, bool) {
+	if r == nil {
+		return 0, false
+	}
+	versionId, ok := r.PolicyVersions[policyType]
+	return versionId, ok
+}
+
+// RecordPolicyDecision keeps the decision to report it along with the status of the job
+func (r *JobReport) RecordPolicyDecision(decision PolicyDecision) {
+	if r == nil {
+		return
+	}
+	r.PolicyDecisions = append(r.PolicyDecisions, decision)
+}
+
+// Reported drops the decisions and confirmations the backend received, so a later status of the same job doesn't
+// report them again. Policy versions are kept for the decisions still to come
+func (r *JobReport) Reported() {
+	if r == nil {
+		return
+	}
+	r.PolicyDecisions = []PolicyDecision{}
+	r.DestroyConfirmations = []DestroyConfirmation{}
+}
+
+// RecordDestroyConfirmations keeps the confirmations to report them along with the status of the job
+func (r *JobReport) RecordDestroyConfirmations(confirmedBy string, addresses []string) {
+	if r == nil {
+		return
+	}
+	for _, address := range addresses {
+		r.DestroyConfirmations = append(r.DestroyConfirmations, DestroyConfirmation{Address: address, ConfirmedBy: confirmedBy})
+	}
+}
@@ -1,11 +1,15 @@
 package execution
 
 import (
+	"errors"
 	"fmt"
 	"github.com/diggerhq/digger/libs/comment_utils/utils"
 	"github.com/diggerhq/digger/libs/locking"
 	"github.com/diggerhq/digger/libs/terraform_utils"
+	"github.com/diggerhq/digger/libs/tracing"
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"log"
 	"os"
 	"path"
@@ -103,6 +107,8 @@ type DiggerExecutor struct {
 	Reporter          reporting.Reporter
 	PlanStorage       storage.PlanStorage
 	PlanPathProvider  PlanPathProvider
+	// TraceParent is the span that steps are traced under, if empty every step starts a new trace
+	TraceParent string
 }
 
 type DiggerExecutorResult struct {
@@ -202,11 +208,12 @@ func (d DiggerExecutor) Plan() (*terraform_utils.PlanSummary, bool, bool, string
 		}
 	}
 	for _, step := range planSteps {
+		span := d.startStep(step)
 		if step.Action == "init" {
 			_, stderr, err := d.TerraformExecutor.Init(step.ExtraArgs, d.StateEnvVars)
 			if err != nil {
 				reportError(d.Reporter, stderr)
-				return nil, false, false, "", "", fmt.Errorf("error running init: %v", err)
+				return nil, false, false, "", "", failStep(span, fmt.Errorf("error running init: %v", err))
 			}
 		}
 		if step.Action == "plan" {
@@ -214,40 +221,45 @@ func (d DiggerExecutor) Plan() (*terraform_utils.PlanSummary, bool, bool, string
 			planArgs = append(planArgs, step.ExtraArgs...)
 			_, stdout, stderr, err := d.TerraformExecutor.Plan(planArgs, d.CommandEnvVars)
 			if err != nil {
-				return nil, false, false, "", "", fmt.Errorf("error executing plan: %v", err)
+				return nil, false, false, "", "", failStep(span, fmt.Errorf("error executing plan: %v", err))
 			}
 			showArgs := []string{"-no-color", "-json", d.PlanPathProvider.LocalPlanFilePath()}
 			terraformPlanOutput, _, _ = d.TerraformExecutor.Show(showArgs, d.CommandEnvVars)
 
 			isEmptyPlan, planSummary, err = terraform_utils.GetPlanSummary(terraformPlanOutput)
 			if err != nil {
-				return nil, false, false, "", "", fmt.Errorf("error checking for empty plan: %v", err)
+				return nil, false, false, "", "", failStep(span, fmt.Errorf("error checking for empty plan: %v", err))
 			}
+			costEstimate, err := terraform_utils.GetCostEstimate(terraformPlanOutput)
+			if err != nil {
+				log.Printf("Could not estimate the cost of the plan: %v", err)
+			}
+			planSummary.SetCostEstimate(costEstimate)
 
 			if !isEmptyPlan {
 				nonEmptyPlanFilepath := strings.Replace(d.PlanPathProvider.LocalPlanFilePath(), d.PlanPathProvider.StoredPlanFilePath(), "isNonEmptyPlan.txt", 1)
 				file, err := os.Create(nonEmptyPlanFilepath)
 				if err != nil {
-					return nil, false, false, "", "", fmt.Errorf("unable to create file: %v", err)
+					return nil, false, false, "", "", failStep(span, fmt.Errorf("unable to create file: %v", err))
 				}
 				defer file.Close()
 			}
 
 			if err != nil {
-				return nil, false, false, "", "", fmt.Errorf("error executing plan: %v", err)
+				return nil, false, false, "", "", failStep(span, fmt.Errorf("error executing plan: %v", err))
 			}
 			if d.PlanStorage != nil {
 
 				fileBytes, err := os.ReadFile(d.PlanPathProvider.LocalPlanFilePath())
 				if err != nil {
 					fmt.Println("Error reading file:", err)
-					return nil, false, false, "", "", fmt.Errorf("error reading file bytes: %v", err)
+					return nil, false, false, "", "", failStep(span, fmt.Errorf("error reading file bytes: %v", err))
 				}
 
 				err = d.PlanStorage.StorePlanFile(fileBytes, d.PlanPathProvider.ArtifactName(), d.PlanPathProvider.StoredPlanFilePath())
 				if err != nil {
 					fmt.Println("Error storing artifact file:", err)
-					return nil, false, false, "", "", fmt.Errorf("error storing artifact file: %v", err)
+					return nil, false, false, "", "", failStep(span, fmt.Errorf("error storing artifact file: %v", err))
 				}
 			}
 			plan = cleanupTerraformPlan(!isEmptyPlan, err, stdout, stderr)
@@ -264,9 +276,10 @@ func (d DiggerExecutor) Plan() (*terraform_utils.PlanSummary, bool, bool, string
 			log.Printf("Running %v for **%v**\n", step.Value, d.ProjectNamespace+"#"+d.ProjectName)
 			_, _, err := d.CommandRunner.Run(d.ProjectPath, step.Shell, commands, d.RunEnvVars)
 			if err != nil {
-				return nil, false, false, "", "", fmt.Errorf("error running command: %v", err)
+				return nil, false, false, "", "", failStep(span, fmt.Errorf("error running command: %v", err))
 			}
 		}
+		span.End()
 	}
 	reportAdditionalOutput(d.Reporter, d.projectId())
 	return planSummary, true, !isEmptyPlan, plan, terraformPlanOutput, nil
@@ -293,6 +306,10 @@ func (d DiggerExecutor) Apply() (bool, string, error) {
 		var err error
 		plansFilename, err = d.PlanStorage.RetrievePlan(d.PlanPathProvider.LocalPlanFilePath(), d.PlanPathProvider.ArtifactName(), d.PlanPathProvider.StoredPlanFilePath())
 		if err != nil {
+			var verificationErr *storage.PlanVerificationError
+			if errors.As(err, &verificationErr) {
+				reportPlanVerificationError(d.Reporter, err)
+			}
 			return false, "", fmt.Errorf("error retrieving plan: %v", err)
 		}
 	}
@@ -313,11 +330,12 @@ func (d DiggerExecutor) Apply() (bool, string, error) {
 	}
 
 	for _, step := range applySteps {
+		span := d.startStep(step)
 		if step.Action == "init" {
 			stdout, stderr, err := d.TerraformExecutor.Init(step.ExtraArgs, d.StateEnvVars)
 			if err != nil {
 				reportTerraformError(d.Reporter, stderr)
-				return false, stdout, fmt.Errorf("error running init: %v", err)
+				return false, stdout, failStep(span, fmt.Errorf("error running init: %v", err))
 			}
 		}
 		if step.Action == "apply" {
@@ -328,7 +346,7 @@ func (d DiggerExecutor) Apply() (bool, string, error) {
 			reportTerraformApplyOutput(d.Reporter, d.projectId(), applyOutput)
 			if err != nil {
 				reportApplyError(d.Reporter, err)
-				return false, stdout, fmt.Errorf("error executing apply: %v", err)
+				return false, stdout, failStep(span, fmt.Errorf("error executing apply: %v", err))
 			}
 		}
 		if step.Action == "run" {
@@ -340,14 +358,30 @@ func (d DiggerExecutor) Apply() (bool, string, error) {
 			log.Printf("Running %v for **%v**\n", step.Value, d.ProjectNamespace+"#"+d.ProjectName)
 			_, stderr, err := d.CommandRunner.Run(d.ProjectPath, step.Shell, commands, d.RunEnvVars)
 			if err != nil {
-				return false, stderr, fmt.Errorf("error running command: %v", err)
+				return false, stderr, failStep(span, fmt.Errorf("error running command: %v", err))
 			}
 		}
+		span.End()
 	}
 	reportAdditionalOutput(d.Reporter, d.projectId())
 	return true, applyOutput, nil
 }
 
+func reportPlanVerificationError(r reporting.Reporter, err error) {
+	message := fmt.Sprintf("%v\n\nThe plan was not applied, run plan again to store a new one.", err)
+	if r.SupportsMarkdown() {
+		_, _, commentErr := r.Report(message, utils.AsCollapsibleComment("Stored plan failed verification.", true))
+		if commentErr != nil {
+			log.Printf("error publishing comment: %v", commentErr)
+		}
+	} else {
+		_, _, commentErr := r.Report(message, utils.AsComment("Stored plan failed verification."))
+		if commentErr != nil {
+			log.Printf("error publishing comment: %v", commentErr)
+		}
+	}
+}
+
 func reportApplyError(r reporting.Reporter, err error) {
 	if r.SupportsMarkdown() {
 		_, _, commentErr := r.Report(err.Error(), utils.AsCollapsibleComment("Error during applying.", false))
@@ -430,11 +464,12 @@ func (d DiggerExecutor) Destroy() (bool, error) {
 	}
 
 	for _, step := range destroySteps {
+		span := d.startStep(orchestrator.Step{Action: step.Action, Value: step.Value})
 		if step.Action == "init" {
 			_, stderr, err := d.TerraformExecutor.Init(step.ExtraArgs, d.StateEnvVars)
 			if err != nil {
 				reportError(d.Reporter, stderr)
-				return false, fmt.Errorf("error running init: %v", err)
+				return false, failStep(span, fmt.Errorf("error running init: %v", err))
 			}
 		}
 		if step.Action == "destroy" {
@@ -442,6 +477,7 @@ func (d DiggerExecutor) Destroy() (bool, error) {
 			applyArgs = append(applyArgs, step.ExtraArgs...)
 			d.TerraformExecutor.Destroy(applyArgs, d.CommandEnvVars)
 		}
+		span.End()
 	}
 	return true, nil
 }
@@ -506,6 +542,25 @@ func cleanupTerraformPlan(nonEmptyPlan bool, planError error, stdout string, std
 	return cleanupTerraformOutput(nonEmptyPlan, planError, stdout, stderr, &regex)
 }
 
+// startStep traces a single step of a command, init, plan and apply steps are named after the terraform command they run
+func (d DiggerExecutor) startStep(step orchestrator.Step) trace.Span {
+	name := step.Action
+	if name != "run" {
+		name = "terraform " + name
+	}
+	_, span := tracing.StartSpan(d.TraceParent, name, attribute.String("digger.project", d.ProjectName))
+	if step.Action == "run" {
+		span.SetAttributes(attribute.String("digger.run", step.Value))
+	}
+	return span
+}
+
+// failStep marks the step span as failed and ends it
+func failStep(span trace.Span, err error) error {
+	tracing.EndSpan(span, err)
+	return err
+}
+
 func (d DiggerExecutor) projectId() string {
 	return d.ProjectNamespace + "#" + d.ProjectName
 }
@@ -1,7 +1,17 @@
 package policy
 
 import (
+	"time"
+
+	"github.com/diggerhq/digger/cli/pkg/core/backend"
 	"github.com/diggerhq/digger/libs/orchestrator"
+	"github.com/diggerhq/digger/libs/terraform_utils"
+)
+
+const (
+	PolicyTypeAccess = "access"
+	PolicyTypePlan   = "plan"
+	PolicyTypeDrift  = "drift"
 )
 
 type Provider interface {
@@ -13,17 +23,42 @@ type Provider interface {
 
 type Checker interface {
 	// TODO refactor arguments - use AccessPolicyContext
-	CheckAccessPolicy(ciService orchestrator.OrgService, prService *orchestrator.PullRequestService, SCMOrganisation string, SCMrepository string, projectName string, command string, prNumber *int, requestedBy string, planPolicyViolations []string) (bool, error)
-	CheckPlanPolicy(SCMrepository string, SCMOrganisation string, projectname string, planOutput string) (bool, []string, error)
+	CheckAccessPolicy(ciService orchestrator.OrgService, prService *orchestrator.PullRequestService, SCMOrganisation string, SCMrepository string, projectName string, command string, prNumber *int, requestedBy string, planPolicyViolations []string, planPolicyWarnings []string, workspace string, planSummary *terraform_utils.PlanSummary) (bool, error)
+	// CheckPlanPolicy returns whether the plan is allowed, the deny messages and the advisory warn messages. The cost
+	// estimate of the plan is nil when it could not be estimated
+	CheckPlanPolicy(SCMrepository string, SCMOrganisation string, projectname string, planOutput string, costEstimate *terraform_utils.CostEstimate) (bool, []string, []string, error)
 	CheckDriftPolicy(SCMOrganisation string, SCMrepository string, projectname string) (bool, error)
 }
 
+// ReportingChecker is a checker recording what it evaluates in the report of a job
+type ReportingChecker interface {
+	WithReport(report *backend.JobReport) Checker
+}
+
+// ForJob returns the checker recording into the report of the job, checkers that don't report are returned as is
+func ForJob(checker Checker, report *backend.JobReport) Checker {
+	if reportingChecker, ok := checker.(ReportingChecker); ok && report != nil {
+		return reportingChecker.WithReport(report)
+	}
+	return checker
+}
+
+// AccessPolicyContext is what access policies are evaluated against
 type AccessPolicyContext struct {
-	SCMOrganisation  string
-	SCMrepository    string
-	projectName      string
-	command          string
-	prNumber         *int
-	requestedBy      string
-	policyViolations []string
+	User                 string
+	Organisation         string
+	Teams                []string
+	Approvals            []string
+	PlanPolicyViolations []string
+	PlanPolicyWarnings   []string
+	Action               string
+	Project              string
+	Workspace            string
+	ChangedFiles         []string
+	Labels               []string
+	Author               string
+	CommitSha            string
+	// PlanSummary is nil when the plan of the project isn't known
+	PlanSummary *terraform_utils.PlanSummary
+	Time        time.Time
 }
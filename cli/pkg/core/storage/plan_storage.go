@@ -1,8 +1,32 @@
 package storage
 
+import (
+	"fmt"
+	"time"
+)
+
 type PlanStorage interface {
 	StorePlanFile(fileContents []byte, artifactName string, storedPlanFilePath string) error
 	RetrievePlan(localPlanFilePath string, artifactName string, storedPlanFilePath string) (*string, error)
 	DeleteStoredPlan(artifactName string, storedPlanFilePath string) error
 	PlanExists(artifactName string, storedPlanFilePath string) (bool, error)
 }
+
+type StoredPlan struct {
+	Path         string
+	LastModified time.Time
+}
+
+// PlanLister is implemented by plan storages that can list their plans, which is needed to expire them
+type PlanLister interface {
+	ListPlans() ([]StoredPlan, error)
+}
+
+// PlanVerificationError is returned when a stored plan fails its integrity checks, such a plan must not be applied
+type PlanVerificationError struct {
+	Reason string
+}
+
+func (e *PlanVerificationError) Error() string {
+	return fmt.Sprintf("stored plan failed verification: %v", e.Reason)
+}
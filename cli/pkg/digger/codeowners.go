@@ -0,0 +1,116 @@
+package digger
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/diggerhq/digger/libs/codeowners"
+	"github.com/diggerhq/digger/libs/comment_utils/reporting"
+	coreutils "github.com/diggerhq/digger/libs/comment_utils/utils"
+	"github.com/diggerhq/digger/libs/digger_config"
+	"github.com/diggerhq/digger/libs/orchestrator"
+)
+
+// projectFiles returns the changed files in the project's dir or matching its include patterns, the dir "." is the
+// repository root
+func projectFiles(job orchestrator.Job, changedFiles []string) []string {
+	files := make([]string, 0)
+	for _, file := range changedFiles {
+		patterns := []string{path.Join(job.ProjectDir, "**")}
+		for _, pattern := range job.IncludePatterns {
+			patterns = append(patterns, path.Join(job.ProjectDir, pattern))
+		}
+		if digger_config.MatchIncludeExcludePatternsToFile(file, patterns, nil) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// projectOwners resolves the code owners of the project's files changed by the pull request. When the pull request
+// changes none of them, e.g. the project runs because of a dependency, the owners of the project's dir and of the
+// directories its include patterns point to are used
+func projectOwners(owners *codeowners.Codeowners, job orchestrator.Job, changedFiles []string) []string {
+	paths := projectFiles(job, changedFiles)
+	if len(paths) == 0 {
+		paths = append(paths, job.ProjectDir)
+		for _, pattern := range job.IncludePatterns {
+			paths = append(paths, codeowners.StaticPrefix(path.Join(job.ProjectDir, pattern)))
+		}
+	}
+
+	result := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		for _, owner := range owners.Owners(p) {
+			if !seen[strings.ToLower(owner)] {
+				seen[strings.ToLower(owner)] = true
+				result = append(result, owner)
+			}
+		}
+	}
+	return result
+}
+
+// checkCodeownersApproval reports whether a code owner of the project approved the pull request,
+// the returned message explains why the apply is blocked
+func checkCodeownersApproval(job orchestrator.Job, prService orchestrator.PullRequestService, orgService orchestrator.OrgService, SCMOrganisation string, workingDir string) (bool, string, error) {
+	details, err := prService.GetPullRequestDetails(*job.PullRequestNumber)
+	if err != nil {
+		return false, "", fmt.Errorf("could not get pull request details: %v", err)
+	}
+	if details.BaseBranch == "" {
+		return false, "", fmt.Errorf("could not find the base branch of pull request %v", *job.PullRequestNumber)
+	}
+	// the CODEOWNERS of the pull request's branch could grant its author ownership
+	owners, err := codeowners.LoadFromBranch(workingDir, details.BaseBranch)
+	if err != nil {
+		return false, "", fmt.Errorf("could not load CODEOWNERS: %v", err)
+	}
+	if owners == nil {
+		return false, fmt.Sprintf("Project %v requires code owner approval but no CODEOWNERS file was found on %v", job.ProjectName, details.BaseBranch), nil
+	}
+
+	changedFiles, err := prService.GetChangedFiles(*job.PullRequestNumber)
+	if err != nil {
+		return false, "", fmt.Errorf("could not get changed files: %v", err)
+	}
+	ownersOfProject := projectOwners(owners, job, changedFiles)
+	if len(ownersOfProject) == 0 {
+		return false, fmt.Sprintf("Project %v requires code owner approval but CODEOWNERS has no owners for %v", job.ProjectName, job.ProjectDir), nil
+	}
+
+	approvals, err := prService.GetApprovals(*job.PullRequestNumber)
+	if err != nil {
+		return false, "", fmt.Errorf("could not get approvals: %v", err)
+	}
+	for _, approver := range approvals {
+		teams, err := orgService.GetUserTeams(SCMOrganisation, approver)
+		if err != nil {
+			log.Printf("could not get teams of %v, matching the user only: %v", approver, err)
+			teams = []string{}
+		}
+		if codeowners.IsOwner(ownersOfProject, SCMOrganisation, approver, teams) {
+			log.Printf("apply of %v approved by code owner %v", job.ProjectName, approver)
+			return true, "", nil
+		}
+	}
+	return false, fmt.Sprintf("Project %v requires an approval from one of its code owners: %v", job.ProjectName, strings.Join(ownersOfProject, ", ")), nil
+}
+
+func reportCodeownersApprovalError(reporter reporting.Reporter, projectName string, msg string) {
+	log.Println(msg)
+	if reporter.SupportsMarkdown() {
+		_, _, err := reporter.Report(msg+" :x:", coreutils.AsCollapsibleComment(fmt.Sprintf("Code owner approval missing for <b>%v</b>", projectName), false))
+		if err != nil {
+			log.Printf("Error publishing comment: %v", err)
+		}
+	} else {
+		_, _, err := reporter.Report(msg, coreutils.AsComment(fmt.Sprintf("Code owner approval missing for %v", projectName)))
+		if err != nil {
+			log.Printf("Error publishing comment: %v", err)
+		}
+	}
+}
@@ -20,14 +20,17 @@ import (
 	"github.com/diggerhq/digger/cli/pkg/core/runners"
 	"github.com/diggerhq/digger/cli/pkg/core/storage"
 	"github.com/diggerhq/digger/cli/pkg/core/terraform"
+	"github.com/diggerhq/digger/cli/pkg/metrics"
 	"github.com/diggerhq/digger/cli/pkg/usage"
 	utils "github.com/diggerhq/digger/cli/pkg/utils"
 	"github.com/diggerhq/digger/libs/comment_utils/reporting"
 	config "github.com/diggerhq/digger/libs/digger_config"
 	orchestrator "github.com/diggerhq/digger/libs/orchestrator"
 	"github.com/diggerhq/digger/libs/terraform_utils"
+	"github.com/diggerhq/digger/libs/tracing"
 
 	"github.com/dominikbraun/graph"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type CIName string
@@ -66,11 +69,16 @@ func DetectCI() CIName {
 
 }
 
-func RunJobs(jobs []orchestrator.Job, prService orchestrator.PullRequestService, orgService orchestrator.OrgService, lock locking2.Lock, reporter reporting.Reporter, planStorage storage.PlanStorage, policyChecker policy.Checker, commentUpdater comment_updater.CommentUpdater, backendApi backend.Api, jobId string, reportFinalStatusToBackend bool, reportTerraformOutput bool, prCommentId int64, workingDir string) (bool, bool, error) {
+func RunJobs(jobs []orchestrator.Job, prService orchestrator.PullRequestService, orgService orchestrator.OrgService, lock locking2.Lock, reporter reporting.Reporter, planStorage storage.PlanStorage, policyChecker policy.Checker, commentUpdater comment_updater.CommentUpdater, backendApi backend.Api, jobId string, jobReport *backend.JobReport, reportFinalStatusToBackend bool, reportTerraformOutput bool, prCommentId int64, workingDir string) (bool, bool, error) {
 
+	// deferred first so that spans are exported after everything else has finished
+	defer tracing.Flush()
 	defer reporter.Flush()
+	defer metrics.Flush()
 
 	runStartedAt := time.Now()
+	// the policies evaluated by the job are reported along with its status
+	policyChecker = policy.ForJob(policyChecker, jobReport)
 
 	exectorResults := make([]execution.DiggerExecutorResult, len(jobs))
 	appliesPerProject := make(map[string]bool)
@@ -81,7 +89,7 @@ func RunJobs(jobs []orchestrator.Job, prService orchestrator.PullRequestService,
 		SCMrepository := splits[1]
 
 		for _, command := range job.Commands {
-			allowedToPerformCommand, err := policyChecker.CheckAccessPolicy(orgService, &prService, SCMOrganisation, SCMrepository, job.ProjectName, command, job.PullRequestNumber, job.RequestedBy, []string{})
+			allowedToPerformCommand, err := policyChecker.CheckAccessPolicy(orgService, &prService, SCMOrganisation, SCMrepository, job.ProjectName, command, job.PullRequestNumber, job.RequestedBy, []string{}, []string{}, job.ProjectWorkspace, nil)
 
 			if err != nil {
 				return false, false, fmt.Errorf("error checking policy: %v", err)
@@ -95,7 +103,13 @@ func RunJobs(jobs []orchestrator.Job, prService orchestrator.PullRequestService,
 				continue
 			}
 
-			executorResult, output, err := run(command, job, policyChecker, orgService, SCMOrganisation, SCMrepository, job.PullRequestNumber, job.RequestedBy, reporter, lock, prService, job.Namespace, workingDir, planStorage, appliesPerProject)
+			spanCtx, span := tracing.StartSpan(job.TraceParent, command, attribute.String("digger.project", job.ProjectName), attribute.String("digger.namespace", job.Namespace))
+			commandJob := job
+			commandJob.TraceParent = tracing.TraceParent(spanCtx)
+			commandStartedAt := time.Now()
+			executorResult, output, err := run(command, commandJob, policyChecker, orgService, SCMOrganisation, SCMrepository, job.PullRequestNumber, job.RequestedBy, reporter, lock, prService, job.Namespace, workingDir, planStorage, appliesPerProject, jobReport)
+			metrics.RecordCommand(job.Namespace, job.ProjectName, command, commandStartedAt, err)
+			tracing.EndSpan(span, err)
 			if err != nil {
 				reportErr := backendApi.ReportProjectRun(SCMOrganisation+"-"+SCMrepository, job.ProjectName, runStartedAt, time.Now(), "FAILED", command, output)
 				if reportErr != nil {
@@ -145,7 +159,7 @@ func RunJobs(jobs []orchestrator.Job, prService orchestrator.PullRequestService,
 			terraformOutput = exectorResults[0].TerraformOutput
 		}
 		prNumber := *currentJob.PullRequestNumber
-		batchResult, err := backendApi.ReportProjectJobStatus(repoNameForBackendReporting, projectNameForBackendReporting, jobId, "succeeded", time.Now(), planResult, jobPrCommentUrl, terraformOutput)
+		batchResult, err := backendApi.ReportProjectJobStatus(repoNameForBackendReporting, projectNameForBackendReporting, jobId, "succeeded", time.Now(), planResult, jobPrCommentUrl, terraformOutput, jobReport)
 		if err != nil {
 			log.Printf("error reporting Job status: %v.\n", err)
 			return false, false, fmt.Errorf("error while running command: %v", err)
@@ -185,10 +199,18 @@ func reportPolicyError(projectName string, command string, requestedBy string, r
 	return msg
 }
 
-func run(command string, job orchestrator.Job, policyChecker policy.Checker, orgService orchestrator.OrgService, SCMOrganisation string, SCMrepository string, PRNumber *int, requestedBy string, reporter reporting.Reporter, lock locking2.Lock, prService orchestrator.PullRequestService, projectNamespace string, workingDir string, planStorage storage.PlanStorage, appliesPerProject map[string]bool) (*execution.DiggerExecutorResult, string, error) {
+// planCostEstimate returns the cost estimate computed along with the plan, nil if there is none
+func planCostEstimate(planSummary *terraform_utils.PlanSummary) *terraform_utils.CostEstimate {
+	if planSummary == nil {
+		return nil
+	}
+	return planSummary.CostEstimate
+}
+
+func run(command string, job orchestrator.Job, policyChecker policy.Checker, orgService orchestrator.OrgService, SCMOrganisation string, SCMrepository string, PRNumber *int, requestedBy string, reporter reporting.Reporter, lock locking2.Lock, prService orchestrator.PullRequestService, projectNamespace string, workingDir string, planStorage storage.PlanStorage, appliesPerProject map[string]bool, jobReport *backend.JobReport) (*execution.DiggerExecutorResult, string, error) {
 	log.Printf("Running '%s' for project '%s' (workflow: %s)\n", command, job.ProjectName, job.ProjectWorkflow)
 
-	allowedToPerformCommand, err := policyChecker.CheckAccessPolicy(orgService, &prService, SCMOrganisation, SCMrepository, job.ProjectName, command, job.PullRequestNumber, requestedBy, []string{})
+	allowedToPerformCommand, err := policyChecker.CheckAccessPolicy(orgService, &prService, SCMOrganisation, SCMrepository, job.ProjectName, command, job.PullRequestNumber, requestedBy, []string{}, []string{}, job.ProjectWorkspace, nil)
 
 	if err != nil {
 		return nil, "error checking policy", fmt.Errorf("error checking policy: %v", err)
@@ -248,6 +270,7 @@ func run(command string, job orchestrator.Job, policyChecker policy.Checker, org
 			Reporter:          reporter,
 			PlanStorage:       planStorage,
 			PlanPathProvider:  planPathProvider,
+			TraceParent:       job.TraceParent,
 		},
 	}
 	executor := diggerExecutor.Executor.(execution.DiggerExecutor)
@@ -279,12 +302,20 @@ func run(command string, job orchestrator.Job, policyChecker policy.Checker, org
 		} else if planPerformed {
 			if isNonEmptyPlan {
 				reportTerraformPlanOutput(reporter, projectLock.LockId(), plan)
-				planIsAllowed, messages, err := policyChecker.CheckPlanPolicy(SCMrepository, SCMOrganisation, job.ProjectName, planJsonOutput)
+				costEstimate := planCostEstimate(planSummary)
+				planIsAllowed, messages, warnings, err := policyChecker.CheckPlanPolicy(SCMrepository, SCMOrganisation, job.ProjectName, planJsonOutput, costEstimate)
 				if err != nil {
 					msg := fmt.Sprintf("Failed to validate plan. %v", err)
 					log.Printf(msg)
 					return nil, msg, fmt.Errorf(msg)
 				}
+				if len(job.Protect) > 0 {
+					protected, err := terraform_utils.GetProtectedResourceChanges(planJsonOutput, job.Protect)
+					if err != nil {
+						log.Printf("Failed to check protected resources. %v", err)
+					}
+					warnings = append(warnings, protectedResourceWarnings(protected)...)
+				}
 				var planPolicyFormatter func(report string) string
 				summary := fmt.Sprintf("Terraform plan validation check (%v)", job.ProjectName)
 				if reporter.SupportsMarkdown() {
@@ -297,15 +328,12 @@ func run(command string, job orchestrator.Job, policyChecker policy.Checker, org
 				if err != nil {
 					log.Printf("Failed to summarize plan. %v", err)
 				}
+				if costSummary := terraform_utils.FormatCostEstimate(costEstimate); costSummary != "" {
+					planSummary = planSummary + "\n" + costSummary
+				}
 
 				if !planIsAllowed {
-					planReportMessage := "Terraform plan failed validation checks :x:<br>"
-					preformattedMessaged := make([]string, 0)
-					for _, message := range messages {
-						preformattedMessaged = append(preformattedMessaged, fmt.Sprintf("    %v", message))
-					}
-					planReportMessage = planReportMessage + strings.Join(preformattedMessaged, "<br>")
-					_, _, err = reporter.Report(planReportMessage, planPolicyFormatter)
+					_, _, err = reporter.Report(planPolicyReportMessage(messages, warnings), planPolicyFormatter)
 
 					if err != nil {
 						log.Printf("Failed to report plan. %v", err)
@@ -314,7 +342,7 @@ func run(command string, job orchestrator.Job, policyChecker policy.Checker, org
 					log.Printf(msg)
 					return nil, msg, fmt.Errorf(msg)
 				} else {
-					_, _, err := reporter.Report("Terraform plan validation checks succeeded :white_check_mark:", planPolicyFormatter)
+					_, _, err := reporter.Report(planPolicyReportMessage(messages, warnings), planPolicyFormatter)
 					if err != nil {
 						log.Printf("Failed to report plan. %v", err)
 					}
@@ -373,28 +401,43 @@ func run(command string, job orchestrator.Job, policyChecker policy.Checker, org
 
 			// checking policies (plan, access)
 			var planPolicyViolations []string
+			var planPolicyWarnings []string
+			var planSummary *terraform_utils.PlanSummary
+			var terraformPlanJsonStr string
 
 			if os.Getenv("PLAN_UPLOAD_DESTINATION") != "" {
-				terraformPlanJsonStr, err := executor.RetrievePlanJson()
+				terraformPlanJsonStr, err = executor.RetrievePlanJson()
 				if err != nil {
 					msg := fmt.Sprintf("Failed to retrieve stored plan. %v", err)
 					log.Printf(msg)
 					return nil, msg, fmt.Errorf(msg)
 				}
 
-				_, violations, err := policyChecker.CheckPlanPolicy(SCMrepository, SCMOrganisation, job.ProjectName, terraformPlanJsonStr)
+				_, planSummary, err = terraform_utils.GetPlanSummary(terraformPlanJsonStr)
+				if err != nil {
+					log.Printf("Failed to summarize stored plan for access policy checks. %v", err)
+				}
+				costEstimate, err := terraform_utils.GetCostEstimate(terraformPlanJsonStr)
+				if err != nil {
+					log.Printf("Could not estimate the cost of the stored plan: %v", err)
+				}
+				planSummary.SetCostEstimate(costEstimate)
+
+				_, violations, warnings, err := policyChecker.CheckPlanPolicy(SCMrepository, SCMOrganisation, job.ProjectName, terraformPlanJsonStr, costEstimate)
 				if err != nil {
 					msg := fmt.Sprintf("Failed to check plan policy. %v", err)
 					log.Printf(msg)
 					return nil, msg, fmt.Errorf(msg)
 				}
 				planPolicyViolations = violations
+				planPolicyWarnings = warnings
 			} else {
 				log.Printf("Skipping plan policy checks because plan storage is not configured.")
 				planPolicyViolations = []string{}
+				planPolicyWarnings = []string{}
 			}
 
-			allowedToApply, err := policyChecker.CheckAccessPolicy(orgService, &prService, SCMOrganisation, SCMrepository, job.ProjectName, command, job.PullRequestNumber, requestedBy, planPolicyViolations)
+			allowedToApply, err := policyChecker.CheckAccessPolicy(orgService, &prService, SCMOrganisation, SCMrepository, job.ProjectName, command, job.PullRequestNumber, requestedBy, planPolicyViolations, planPolicyWarnings, job.ProjectWorkspace, planSummary)
 			if err != nil {
 				msg := fmt.Sprintf("Failed to run plan policy check before apply. %v", err)
 				log.Printf(msg)
@@ -406,6 +449,24 @@ func run(command string, job orchestrator.Job, policyChecker policy.Checker, org
 				return nil, msg, errors.New(msg)
 			}
 
+			if job.RequireCodeowners {
+				approved, msg, err := checkCodeownersApproval(job, prService, orgService, SCMOrganisation, workingDir)
+				if err != nil {
+					msg := fmt.Sprintf("Failed to check code owner approval before apply. %v", err)
+					log.Print(msg)
+					return nil, msg, errors.New(msg)
+				}
+				if !approved {
+					reportCodeownersApprovalError(reporter, job.ProjectName, msg)
+					return nil, msg, errors.New(msg)
+				}
+			}
+
+			msg, err := guardProtectedResources(command, job, terraformPlanJsonStr, policyChecker, orgService, &prService, SCMOrganisation, SCMrepository, requestedBy, reporter, jobReport)
+			if err != nil {
+				return nil, msg, err
+			}
+
 			// Running apply
 
 			applyPerformed, output, err := diggerExecutor.Apply()
@@ -438,6 +499,10 @@ func run(command string, job orchestrator.Job, policyChecker policy.Checker, org
 		if err != nil {
 			log.Printf("Failed to send usage report. %v", err)
 		}
+		msg, err := guardProtectedResources(command, job, "", policyChecker, orgService, &prService, SCMOrganisation, SCMrepository, requestedBy, reporter, jobReport)
+		if err != nil {
+			return nil, msg, err
+		}
 		_, err = diggerExecutor.Destroy()
 
 		if err != nil {
@@ -528,6 +593,35 @@ func reportEmptyPlanOutput(reporter reporting.Reporter, projectId string) {
 	}
 }
 
+// planPolicyReportMessage lists the blocking deny messages, followed by the advisory warnings
+func planPolicyReportMessage(messages []string, warnings []string) string {
+	indented := func(lines []string) string {
+		preformatted := make([]string, 0, len(lines))
+		for _, line := range lines {
+			preformatted = append(preformatted, fmt.Sprintf("    %v", line))
+		}
+		return strings.Join(preformatted, "<br>")
+	}
+
+	var report string
+	if len(messages) > 0 {
+		report = "Terraform plan failed validation checks :x:<br>" + indented(messages)
+	} else if len(warnings) == 0 {
+		return "Terraform plan validation checks succeeded :white_check_mark:"
+	}
+	if len(warnings) > 0 {
+		if report != "" {
+			report += "<br><br>"
+		}
+		noun := "warnings"
+		if len(warnings) == 1 {
+			noun = "warning"
+		}
+		report += fmt.Sprintf("Terraform plan validation: %v %v :warning:<br>", len(warnings), noun) + indented(warnings)
+	}
+	return report
+}
+
 func RunJob(
 	job orchestrator.Job,
 	repo string,
@@ -545,7 +639,7 @@ func RunJob(
 
 	for _, command := range job.Commands {
 
-		allowedToPerformCommand, err := policyChecker.CheckAccessPolicy(orgService, nil, SCMOrganisation, SCMrepository, job.ProjectName, command, nil, requestedBy, []string{})
+		allowedToPerformCommand, err := policyChecker.CheckAccessPolicy(orgService, nil, SCMOrganisation, SCMrepository, job.ProjectName, command, nil, requestedBy, []string{}, []string{}, job.ProjectWorkspace, nil)
 
 		if err != nil {
 			return fmt.Errorf("error checking policy: %v", err)
@@ -610,7 +704,7 @@ func RunJob(
 			if err != nil {
 				log.Printf("Failed to send usage report. %v", err)
 			}
-			_, _, _, plan, planJsonOutput, err := diggerExecutor.Plan()
+			planSummary, _, _, plan, planJsonOutput, err := diggerExecutor.Plan()
 			if err != nil {
 				msg := fmt.Sprintf("Failed to Run digger plan command. %v", err)
 				log.Printf(msg)
@@ -620,8 +714,9 @@ func RunJob(
 				}
 				return fmt.Errorf(msg)
 			}
-			planIsAllowed, messages, err := policyChecker.CheckPlanPolicy(SCMrepository, SCMOrganisation, job.ProjectName, planJsonOutput)
-			log.Printf(strings.Join(messages, "\n"))
+			planIsAllowed, messages, warnings, err := policyChecker.CheckPlanPolicy(SCMrepository, SCMOrganisation, job.ProjectName, planJsonOutput, planCostEstimate(planSummary))
+			log.Print(strings.Join(messages, "\n"))
+			log.Print(strings.Join(warnings, "\n"))
 			if err != nil {
 				msg := fmt.Sprintf("Failed to validate plan %v", err)
 				log.Printf(msg)
@@ -651,6 +746,21 @@ func RunJob(
 			if err != nil {
 				log.Printf("Failed to send usage report. %v", err)
 			}
+			planJson := ""
+			if len(job.Protect) > 0 && os.Getenv("PLAN_UPLOAD_DESTINATION") != "" {
+				planJson, err = diggerExecutor.RetrievePlanJson()
+				if err != nil {
+					log.Printf("Failed to retrieve stored plan. %v", err)
+				}
+			}
+			msg, err := guardProtectedResources(command, job, planJson, policyChecker, orgService, nil, SCMOrganisation, SCMrepository, requestedBy, diggerExecutor.Reporter, nil)
+			if err != nil {
+				reportErr := backendApi.ReportProjectRun(repo, job.ProjectName, runStartedAt, time.Now(), "FORBIDDEN", command, msg)
+				if reportErr != nil {
+					log.Printf("Error reporting Run: %v", reportErr)
+				}
+				return err
+			}
 			_, output, err := diggerExecutor.Apply()
 			if err != nil {
 				msg := fmt.Sprintf("Failed to Run digger apply command. %v", err)
@@ -670,6 +780,14 @@ func RunJob(
 			if err != nil {
 				log.Printf("Failed to send usage report. %v", err)
 			}
+			msg, err := guardProtectedResources(command, job, "", policyChecker, orgService, nil, SCMOrganisation, SCMrepository, requestedBy, diggerExecutor.Reporter, nil)
+			if err != nil {
+				reportErr := backendApi.ReportProjectRun(repo, job.ProjectName, runStartedAt, time.Now(), "FORBIDDEN", command, msg)
+				if reportErr != nil {
+					log.Printf("Error reporting Run: %v", reportErr)
+				}
+				return err
+			}
 			_, err = diggerExecutor.Destroy()
 			if err != nil {
 				log.Printf("Failed to Run digger destroy command. %v", err)
@@ -2,6 +2,8 @@ package digger
 
 import (
 	"os"
+	"os/exec"
+	"path"
 	"sort"
 	"strconv"
 	"strings"
@@ -13,6 +15,7 @@ import (
 	"github.com/diggerhq/digger/libs/comment_utils/reporting"
 	configuration "github.com/diggerhq/digger/libs/digger_config"
 	orchestrator "github.com/diggerhq/digger/libs/orchestrator"
+	"github.com/diggerhq/digger/libs/terraform_utils"
 
 	"github.com/dominikbraun/graph"
 	"github.com/stretchr/testify/assert"
@@ -153,6 +156,11 @@ func (m *MockPRManager) GetBranchName(prNumber int) (string, string, error) {
 	return "", "", nil
 }
 
+func (m *MockPRManager) GetPullRequestDetails(prNumber int) (*orchestrator.PullRequestDetails, error) {
+	m.Commands = append(m.Commands, RunInfo{"GetPullRequestDetails", strconv.Itoa(prNumber), time.Now()})
+	return &orchestrator.PullRequestDetails{Labels: []string{}}, nil
+}
+
 func (m *MockPRManager) SetOutput(prNumber int, key string, value string) error {
 	m.Commands = append(m.Commands, RunInfo{"SetOutput", strconv.Itoa(prNumber), time.Now()})
 	return nil
@@ -492,3 +500,159 @@ func TestParseWorkspace(t *testing.T) {
 	}
 
 }
+
+func TestPlanPolicyReportMessage(t *testing.T) {
+	assert.Equal(t, "Terraform plan validation checks succeeded :white_check_mark:", planPolicyReportMessage([]string{}, nil))
+	assert.Equal(t, "Terraform plan validation: 1 warning :warning:<br>    untagged", planPolicyReportMessage(nil, []string{"untagged"}))
+	assert.Equal(t, "Terraform plan failed validation checks :x:<br>    deleted<br><br>Terraform plan validation: 2 warnings :warning:<br>    untagged<br>    public",
+		planPolicyReportMessage([]string{"deleted"}, []string{"untagged", "public"}))
+}
+
+// checkoutWithCodeowners clones a repository whose main branch has the CODEOWNERS file, the clone is on a pull request
+// branch whose CODEOWNERS makes mallory the owner of everything
+func checkoutWithCodeowners(t *testing.T, codeowners string) string {
+	git := func(dir string, args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=digger", "-c", "user.email=digger@example.com"}, args...)...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(output))
+	}
+	origin := t.TempDir()
+	git(origin, "init", "--initial-branch=main")
+	if codeowners != "" {
+		assert.NoError(t, os.MkdirAll(origin+"/.github", 0755))
+		assert.NoError(t, os.WriteFile(origin+"/.github/CODEOWNERS", []byte(codeowners), 0644))
+	}
+	git(origin, "add", "-A")
+	git(origin, "commit", "--allow-empty", "-m", "owners")
+
+	dir := t.TempDir()
+	git(dir, "clone", "file://"+origin, ".")
+	git(dir, "checkout", "-b", "feature")
+	assert.NoError(t, os.MkdirAll(dir+"/.github", 0755))
+	assert.NoError(t, os.WriteFile(dir+"/.github/CODEOWNERS", []byte("* @mallory\n"), 0644))
+	return dir
+}
+
+func TestCheckCodeownersApproval(t *testing.T) {
+	dir := checkoutWithCodeowners(t, "* @diggerhq/platform\n/prod/ @diggerhq/infra-team\n/modules/ @alice\n*.tfvars @bob\n")
+
+	prNumber := 1
+	job := orchestrator.Job{ProjectName: "prod", ProjectDir: "prod", IncludePatterns: []string{"../modules/**"}, PullRequestNumber: &prNumber, RequireCodeowners: true}
+
+	// the owners of the base branch apply, not the ones of the pull request
+	approved, msg, err := checkCodeownersApproval(job, &utils.MockPullRequestManager{Approvals: []string{"mallory"}, BaseBranch: "main"}, utils.MockPullRequestManager{}, "diggerhq", dir)
+	assert.NoError(t, err)
+	assert.False(t, approved)
+	assert.Equal(t, "Project prod requires an approval from one of its code owners: @diggerhq/infra-team, @alice", msg)
+
+	approved, _, err = checkCodeownersApproval(job, &utils.MockPullRequestManager{Approvals: []string{"bob"}, BaseBranch: "main"}, utils.MockPullRequestManager{Teams: []string{"Infra Team"}}, "diggerhq", dir)
+	assert.NoError(t, err)
+	assert.True(t, approved)
+
+	approved, _, err = checkCodeownersApproval(job, &utils.MockPullRequestManager{Approvals: []string{"bob", "alice"}, BaseBranch: "main"}, utils.MockPullRequestManager{}, "diggerhq", dir)
+	assert.NoError(t, err)
+	assert.True(t, approved)
+
+	// owners are resolved over the changed files of the project, extension rules included
+	prService := &utils.MockPullRequestManager{Approvals: []string{"alice"}, BaseBranch: "main", ChangedFiles: []string{"prod/prod.tfvars", "staging/main.tf"}}
+	approved, msg, err = checkCodeownersApproval(job, prService, utils.MockPullRequestManager{}, "diggerhq", dir)
+	assert.NoError(t, err)
+	assert.False(t, approved)
+	assert.Equal(t, "Project prod requires an approval from one of its code owners: @bob", msg)
+
+	_, _, err = checkCodeownersApproval(job, &utils.MockPullRequestManager{Approvals: []string{"alice"}}, utils.MockPullRequestManager{}, "diggerhq", dir)
+	assert.Error(t, err)
+
+	approved, msg, err = checkCodeownersApproval(job, &utils.MockPullRequestManager{Approvals: []string{"alice"}, BaseBranch: "main"}, utils.MockPullRequestManager{}, "diggerhq", checkoutWithCodeowners(t, ""))
+	assert.NoError(t, err)
+	assert.False(t, approved)
+	assert.Equal(t, "Project prod requires code owner approval but no CODEOWNERS file was found on main", msg)
+}
+
+func TestCheckCodeownersApprovalOfRootProject(t *testing.T) {
+	dir := checkoutWithCodeowners(t, "* @diggerhq/platform\n*.tf @diggerhq/infra-team\n")
+
+	prNumber := 1
+	job := orchestrator.Job{ProjectName: "root", ProjectDir: ".", PullRequestNumber: &prNumber, RequireCodeowners: true}
+
+	approved, msg, err := checkCodeownersApproval(job, &utils.MockPullRequestManager{Approvals: []string{"bob"}, BaseBranch: "main", ChangedFiles: []string{"main.tf", "modules/vpc/main.tf"}}, utils.MockPullRequestManager{}, "diggerhq", dir)
+	assert.NoError(t, err)
+	assert.False(t, approved)
+	assert.Equal(t, "Project root requires an approval from one of its code owners: @diggerhq/infra-team", msg)
+
+	// without changed files of the project the owners of the root apply
+	approved, msg, err = checkCodeownersApproval(job, &utils.MockPullRequestManager{Approvals: []string{"bob"}, BaseBranch: "main"}, utils.MockPullRequestManager{}, "diggerhq", dir)
+	assert.NoError(t, err)
+	assert.False(t, approved)
+	assert.Equal(t, "Project root requires an approval from one of its code owners: @diggerhq/platform", msg)
+}
+
+type confirmDestroyPolicyChecker struct {
+	utils.MockPolicyChecker
+}
+
+func (c confirmDestroyPolicyChecker) CheckAccessPolicy(ciService orchestrator.OrgService, prService *orchestrator.PullRequestService, SCMOrganisation string, SCMrepository string, projectName string, command string, prNumber *int, requestedBy string, planPolicyViolations []string, planPolicyWarnings []string, workspace string, planSummary *terraform_utils.PlanSummary) (bool, error) {
+	return command == ConfirmDestroyCommand && requestedBy == "alice", nil
+}
+
+func TestCheckDestroyConfirmations(t *testing.T) {
+	planJson := `{"resource_changes": [
+		{"address": "aws_db_instance.main", "type": "aws_db_instance", "change": {"actions": ["delete", "create"]}},
+		{"address": "aws_instance.web", "type": "aws_instance", "change": {"actions": ["delete"]}}
+	]}`
+	prNumber := 1
+	job := orchestrator.Job{ProjectName: "prod", PullRequestNumber: &prNumber, Protect: []string{"aws_db_instance.*"}}
+	var prService orchestrator.PullRequestService = &utils.MockPullRequestManager{}
+
+	confirmed, msg, _, err := checkDestroyConfirmations(job, planJson, confirmDestroyPolicyChecker{}, utils.MockPullRequestManager{}, &prService, "diggerhq", "demo", "alice")
+	assert.NoError(t, err)
+	assert.False(t, confirmed)
+	assert.Equal(t, "Plan of prod destroys protected resources, comment `digger apply --confirm-destroy <address>` for each of them to proceed: aws_db_instance.main", msg)
+
+	job.ConfirmDestroy = []string{"aws_db_instance.main"}
+	confirmed, msg, _, err = checkDestroyConfirmations(job, planJson, confirmDestroyPolicyChecker{}, utils.MockPullRequestManager{}, &prService, "diggerhq", "demo", "bob")
+	assert.NoError(t, err)
+	assert.False(t, confirmed)
+	assert.Equal(t, "User bob is not allowed to confirm the destruction of protected resources of prod", msg)
+
+	confirmed, _, addresses, err := checkDestroyConfirmations(job, planJson, confirmDestroyPolicyChecker{}, utils.MockPullRequestManager{}, &prService, "diggerhq", "demo", "alice")
+	assert.NoError(t, err)
+	assert.True(t, confirmed)
+	assert.Equal(t, []string{"aws_db_instance.main"}, addresses)
+
+	confirmed, msg, _, err = checkDestroyConfirmations(job, "", confirmDestroyPolicyChecker{}, utils.MockPullRequestManager{}, &prService, "diggerhq", "demo", "alice")
+	assert.NoError(t, err)
+	assert.False(t, confirmed)
+	assert.Equal(t, "Project prod has protected resources, plan storage needs to be configured to check the plan before apply", msg)
+
+	assert.Equal(t, []string{"aws_instance.web is protected and will be destroyed, apply with --confirm-destroy aws_instance.web to proceed"}, protectedResourceWarnings([]string{"aws_instance.web"}))
+}
+
+func TestGuardProtectedResources(t *testing.T) {
+	prNumber := 1
+	job := orchestrator.Job{ProjectName: "prod", PullRequestNumber: &prNumber, Protect: []string{"aws_db_instance.*"}}
+	reporter := &reporting.StdOutReporter{}
+
+	msg, err := guardProtectedResources("digger destroy", job, "", confirmDestroyPolicyChecker{}, utils.MockPullRequestManager{}, nil, "diggerhq", "demo", "alice", reporter, nil)
+	assert.Error(t, err)
+	assert.Equal(t, "Project prod has protected resources, remove them from protect to destroy it", msg)
+
+	msg, err = guardProtectedResources("digger apply", job, "", confirmDestroyPolicyChecker{}, utils.MockPullRequestManager{}, nil, "diggerhq", "demo", "alice", reporter, nil)
+	assert.Error(t, err)
+	assert.Equal(t, "Project prod has protected resources, plan storage needs to be configured to check the plan before apply", msg)
+
+	logFile := path.Join(t.TempDir(), "confirmations.jsonl")
+	t.Setenv("DIGGER_DESTROY_CONFIRMATION_LOG", logFile)
+	planJson := `{"resource_changes": [{"address": "aws_db_instance.main", "type": "aws_db_instance", "change": {"actions": ["delete"]}}]}`
+	job.ConfirmDestroy = []string{"aws_db_instance.main"}
+	_, err = guardProtectedResources("digger apply", job, planJson, confirmDestroyPolicyChecker{}, utils.MockPullRequestManager{}, nil, "diggerhq", "demo", "alice", reporter, nil)
+	assert.NoError(t, err)
+	confirmations, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(confirmations), `"project":"prod","pr_number":1,"address":"aws_db_instance.main","confirmed_by":"alice"`)
+
+	job.Protect = nil
+	_, err = guardProtectedResources("digger destroy", job, "", confirmDestroyPolicyChecker{}, utils.MockPullRequestManager{}, nil, "diggerhq", "demo", "alice", reporter, nil)
+	assert.NoError(t, err)
+}
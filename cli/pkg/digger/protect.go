@@ -0,0 +1,165 @@
+package digger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/diggerhq/digger/cli/pkg/core/backend"
+	"github.com/diggerhq/digger/cli/pkg/core/policy"
+	"github.com/diggerhq/digger/libs/comment_utils/reporting"
+	coreutils "github.com/diggerhq/digger/libs/comment_utils/utils"
+	"github.com/diggerhq/digger/libs/orchestrator"
+	"github.com/diggerhq/digger/libs/terraform_utils"
+)
+
+// ConfirmDestroyCommand is the access policy action checked for the user confirming the destruction of protected resources
+const ConfirmDestroyCommand = "digger confirm-destroy"
+
+const defaultDestroyConfirmationLogFile = "digger-destroy-confirmations.jsonl"
+
+// protectedResourceWarnings are shown with the plan for every protected resource it deletes or replaces
+func protectedResourceWarnings(addresses []string) []string {
+	warnings := make([]string, 0)
+	for _, address := range addresses {
+		warnings = append(warnings, fmt.Sprintf("%v is protected and will be destroyed, apply with --confirm-destroy %v to proceed", address, address))
+	}
+	return warnings
+}
+
+// checkDestroyConfirmations reports whether every protected resource destroyed by the stored plan was confirmed by an
+// authorised user, it returns the confirmed addresses or a message explaining why the apply is blocked.
+// An empty planJson means plan storage is not configured, in that case the apply is refused as the plan can't be checked.
+func checkDestroyConfirmations(job orchestrator.Job, planJson string, policyChecker policy.Checker, orgService orchestrator.OrgService, prService *orchestrator.PullRequestService, SCMOrganisation string, SCMrepository string, requestedBy string) (bool, string, []string, error) {
+	if planJson == "" {
+		return false, fmt.Sprintf("Project %v has protected resources, plan storage needs to be configured to check the plan before apply", job.ProjectName), nil, nil
+	}
+	destroyed, err := terraform_utils.GetProtectedResourceChanges(planJson, job.Protect)
+	if err != nil {
+		return false, "", nil, fmt.Errorf("could not check protected resources: %v", err)
+	}
+	if len(destroyed) == 0 {
+		return true, "", destroyed, nil
+	}
+
+	missing := make([]string, 0)
+	for _, address := range destroyed {
+		if !slices.Contains(job.ConfirmDestroy, address) {
+			missing = append(missing, address)
+		}
+	}
+	if len(missing) > 0 {
+		return false, fmt.Sprintf("Plan of %v destroys protected resources, comment `digger apply --confirm-destroy <address>` for each of them to proceed: %v", job.ProjectName, strings.Join(missing, ", ")), nil, nil
+	}
+
+	allowed, err := policyChecker.CheckAccessPolicy(orgService, prService, SCMOrganisation, SCMrepository, job.ProjectName, ConfirmDestroyCommand, job.PullRequestNumber, requestedBy, []string{}, []string{}, job.ProjectWorkspace, nil)
+	if err != nil {
+		return false, "", nil, fmt.Errorf("could not check access policy: %v", err)
+	}
+	if !allowed {
+		return false, fmt.Sprintf("User %v is not allowed to confirm the destruction of protected resources of %v", requestedBy, job.ProjectName), nil, nil
+	}
+	return true, "", destroyed, nil
+}
+
+// guardProtectedResources refuses to run apply or destroy of a project with protected resources unless the destruction
+// of each of them was confirmed, it returns the message of the refusal along with the error
+func guardProtectedResources(command string, job orchestrator.Job, planJson string, policyChecker policy.Checker, orgService orchestrator.OrgService, prService *orchestrator.PullRequestService, SCMOrganisation string, SCMrepository string, requestedBy string, reporter reporting.Reporter, jobReport *backend.JobReport) (string, error) {
+	if len(job.Protect) == 0 {
+		return "", nil
+	}
+	if command == "digger destroy" {
+		msg := fmt.Sprintf("Project %v has protected resources, remove them from protect to destroy it", job.ProjectName)
+		reportDestroyConfirmationError(reporter, job.ProjectName, msg)
+		return msg, errors.New(msg)
+	}
+
+	confirmed, msg, addresses, err := checkDestroyConfirmations(job, planJson, policyChecker, orgService, prService, SCMOrganisation, SCMrepository, requestedBy)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to check protected resources before apply. %v", err)
+		log.Print(msg)
+		return msg, errors.New(msg)
+	}
+	if !confirmed {
+		reportDestroyConfirmationError(reporter, job.ProjectName, msg)
+		return msg, errors.New(msg)
+	}
+	if len(addresses) > 0 {
+		reportDestroyConfirmation(reporter, job.ProjectName, requestedBy, addresses)
+		recordDestroyConfirmations(jobReport, job, requestedBy, addresses)
+	}
+	return "", nil
+}
+
+// destroyConfirmationLogFile is the JSON lines file confirmations of jobs without a report to the backend are appended to
+func destroyConfirmationLogFile() string {
+	if path := os.Getenv("DIGGER_DESTROY_CONFIRMATION_LOG"); path != "" {
+		return path
+	}
+	return defaultDestroyConfirmationLogFile
+}
+
+type destroyConfirmationRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	Project   string    `json:"project"`
+	PrNumber  *int      `json:"pr_number,omitempty"`
+	backend.DestroyConfirmation
+}
+
+// recordDestroyConfirmations keeps the confirmations in the report of the job, jobs that don't report to the backend
+// append them to the local confirmation log instead so they can still be audited
+func recordDestroyConfirmations(jobReport *backend.JobReport, job orchestrator.Job, requestedBy string, addresses []string) {
+	if jobReport != nil {
+		jobReport.RecordDestroyConfirmations(requestedBy, addresses)
+		return
+	}
+	path := destroyConfirmationLogFile()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("WARNING: could not write destroy confirmations to %v: %v", path, err)
+		return
+	}
+	defer f.Close()
+	encoder := json.NewEncoder(f)
+	for _, address := range addresses {
+		record := destroyConfirmationRecord{
+			Timestamp:           time.Now().UTC(),
+			Project:             job.ProjectName,
+			PrNumber:            job.PullRequestNumber,
+			DestroyConfirmation: backend.DestroyConfirmation{Address: address, ConfirmedBy: requestedBy},
+		}
+		if err := encoder.Encode(record); err != nil {
+			log.Printf("WARNING: could not write destroy confirmations to %v: %v", path, err)
+			return
+		}
+	}
+}
+
+func reportDestroyConfirmation(reporter reporting.Reporter, projectName string, requestedBy string, addresses []string) {
+	msg := fmt.Sprintf("%v confirmed the destruction of protected resources of %v: %v", requestedBy, projectName, strings.Join(addresses, ", "))
+	log.Println(msg)
+	_, _, err := reporter.Report(msg, coreutils.AsComment(fmt.Sprintf("Destruction of protected resources confirmed for %v", projectName)))
+	if err != nil {
+		log.Printf("Error publishing comment: %v", err)
+	}
+}
+
+func reportDestroyConfirmationError(reporter reporting.Reporter, projectName string, msg string) {
+	log.Println(msg)
+	if reporter.SupportsMarkdown() {
+		_, _, err := reporter.Report(msg+" :x:", coreutils.AsCollapsibleComment(fmt.Sprintf("Destruction of protected resources not confirmed for <b>%v</b>", projectName), false))
+		if err != nil {
+			log.Printf("Error publishing comment: %v", err)
+		}
+	} else {
+		_, _, err := reporter.Report(msg, coreutils.AsComment(fmt.Sprintf("Destruction of protected resources not confirmed for %v", projectName)))
+		if err != nil {
+			log.Printf("Error publishing comment: %v", err)
+		}
+	}
+}
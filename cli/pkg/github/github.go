@@ -130,7 +130,7 @@ func GitHubCI(lock core_locking.Lock, policyChecker core_policy.Checker, backend
 			usage.ReportErrorAndExit(githubActor, fmt.Sprintf("Failed to parse jobs json. %s", err), 4)
 		}
 
-		serializedBatch, err := backendApi.ReportProjectJobStatus(repoName, jobSpec.ProjectName, inputs.Id, "started", time.Now(), nil, "", "")
+		serializedBatch, err := backendApi.ReportProjectJobStatus(repoName, jobSpec.ProjectName, inputs.Id, "started", time.Now(), nil, "", "", nil)
 		if err != nil {
 			usage.ReportErrorAndExit(githubActor, fmt.Sprintf("Failed to report jobSpec status to backend. Exiting. %s", err), 4)
 		}
@@ -174,7 +174,7 @@ func GitHubCI(lock core_locking.Lock, policyChecker core_policy.Checker, backend
 		planStorage := storage.NewPlanStorage(ghToken, repoOwner, repositoryName, githubActor, jobSpec.PullRequestNumber)
 
 		if err != nil {
-			serializedBatch, reportingError := backendApi.ReportProjectJobStatus(repoName, jobSpec.ProjectName, inputs.Id, "failed", time.Now(), nil, "", "")
+			serializedBatch, reportingError := backendApi.ReportProjectJobStatus(repoName, jobSpec.ProjectName, inputs.Id, "failed", time.Now(), nil, "", "", nil)
 			if reportingError != nil {
 				log.Printf("Failed to report jobSpec status to backend. %v", reportingError)
 				usage.ReportErrorAndExit(githubActor, fmt.Sprintf("Failed run commands. %s", err), 5)
@@ -192,10 +192,11 @@ func GitHubCI(lock core_locking.Lock, policyChecker core_policy.Checker, backend
 		jobSpec.CommandEnvVars = lo.Assign(jobSpec.CommandEnvVars, commandEnvVars)
 
 		jobs := []orchestrator.Job{orchestrator.JsonToJob(jobSpec)}
+		jobReport := core_backend.NewJobReport()
 
-		allAppliesSuccess, _, err := digger.RunJobs(jobs, &githubPrService, &githubPrService, lock, reporter, planStorage, policyChecker, commentUpdater, backendApi, inputs.Id, true, reportTerraformOutput, commentId64, currentDir)
+		allAppliesSuccess, _, err := digger.RunJobs(jobs, &githubPrService, &githubPrService, lock, reporter, planStorage, policyChecker, commentUpdater, backendApi, inputs.Id, jobReport, true, reportTerraformOutput, commentId64, currentDir)
 		if !allAppliesSuccess || err != nil {
-			serializedBatch, reportingError := backendApi.ReportProjectJobStatus(repoName, jobSpec.ProjectName, inputs.Id, "failed", time.Now(), nil, "", "")
+			serializedBatch, reportingError := backendApi.ReportProjectJobStatus(repoName, jobSpec.ProjectName, inputs.Id, "failed", time.Now(), nil, "", "", jobReport)
 			if reportingError != nil {
 				usage.ReportErrorAndExit(githubActor, fmt.Sprintf("Failed run commands. %s", err), 5)
 			}
@@ -253,6 +254,9 @@ func GitHubCI(lock core_locking.Lock, policyChecker core_policy.Checker, backend
 		jobs := orchestrator.Job{
 			ProjectName:       project,
 			ProjectDir:        projectConfig.Dir,
+			IncludePatterns:   projectConfig.IncludePatterns,
+			RequireCodeowners: projectConfig.RequireCodeownersApproval,
+			Protect:           projectConfig.Protect,
 			ProjectWorkspace:  projectConfig.Workspace,
 			Terragrunt:        projectConfig.Terragrunt,
 			OpenTofu:          projectConfig.OpenTofu,
@@ -285,6 +289,9 @@ func GitHubCI(lock core_locking.Lock, policyChecker core_policy.Checker, backend
 			job := orchestrator.Job{
 				ProjectName:        projectConfig.Name,
 				ProjectDir:         projectConfig.Dir,
+				IncludePatterns:    projectConfig.IncludePatterns,
+				RequireCodeowners:  projectConfig.RequireCodeownersApproval,
+				Protect:            projectConfig.Protect,
 				ProjectWorkspace:   projectConfig.Workspace,
 				Terragrunt:         projectConfig.Terragrunt,
 				OpenTofu:           projectConfig.OpenTofu,
@@ -375,7 +382,7 @@ func GitHubCI(lock core_locking.Lock, policyChecker core_policy.Checker, backend
 
 		jobs = digger.SortedCommandsByDependency(jobs, &dependencyGraph)
 
-		allAppliesSuccessful, atLeastOneApply, err := digger.RunJobs(jobs, &githubPrService, &githubPrService, lock, reporter, planStorage, policyChecker, comment_updater.NoopCommentUpdater{}, backendApi, "", false, false, 0, currentDir)
+		allAppliesSuccessful, atLeastOneApply, err := digger.RunJobs(jobs, &githubPrService, &githubPrService, lock, reporter, planStorage, policyChecker, comment_updater.NoopCommentUpdater{}, backendApi, "", nil, false, false, 0, currentDir)
 		if err != nil {
 			usage.ReportErrorAndExit(githubActor, fmt.Sprintf("Failed to run commands. %s", err), 8)
 			// aggregate status checks: failure
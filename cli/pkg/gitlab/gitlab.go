@@ -254,9 +254,18 @@ func (gitlabService GitLabService) GetComments(prNumber int) ([]orchestrator.Com
 }
 
 func (gitlabService *GitLabService) GetApprovals(prNumber int) ([]string, error) {
-	approvals := make([]string, 0)
-	// TODO: implement me
-	return approvals, nil
+	projectId := *gitlabService.Context.ProjectId
+	approvals, _, err := gitlabService.Client.MergeRequestApprovals.GetConfiguration(projectId, prNumber)
+	if err != nil {
+		return nil, fmt.Errorf("could not get approvals of merge request %v: %v", prNumber, err)
+	}
+	approvedBy := make([]string, 0)
+	for _, approver := range approvals.ApprovedBy {
+		if approver.User != nil {
+			approvedBy = append(approvedBy, approver.User.Username)
+		}
+	}
+	return approvedBy, nil
 }
 
 func (gitlabService GitLabService) GetBranchName(prNumber int) (string, string, error) {
@@ -264,6 +273,23 @@ func (gitlabService GitLabService) GetBranchName(prNumber int) (string, string,
 	return "", "", nil
 }
 
+func (gitlabService GitLabService) GetPullRequestDetails(prNumber int) (*orchestrator.PullRequestDetails, error) {
+	mergeRequest := getMergeRequest(gitlabService)
+	if mergeRequest == nil {
+		return nil, fmt.Errorf("could not get merge request %v", prNumber)
+	}
+	details := &orchestrator.PullRequestDetails{
+		Labels:     mergeRequest.Labels,
+		Branch:     mergeRequest.SourceBranch,
+		CommitSha:  mergeRequest.SHA,
+		BaseBranch: mergeRequest.TargetBranch,
+	}
+	if mergeRequest.Author != nil {
+		details.Author = mergeRequest.Author.Username
+	}
+	return details, nil
+}
+
 func (svc *GitLabService) SetOutput(prNumber int, key string, value string) error {
 	//TODO implement me
 	return nil
@@ -323,6 +349,9 @@ func ConvertGitLabEventToCommands(event GitLabEvent, gitLabContext *GitLabContex
 			jobs = append(jobs, orchestrator.Job{
 				ProjectName:        project.Name,
 				ProjectDir:         project.Dir,
+				IncludePatterns:    project.IncludePatterns,
+				RequireCodeowners:  project.RequireCodeownersApproval,
+				Protect:            project.Protect,
 				ProjectWorkspace:   project.Workspace,
 				Terragrunt:         project.Terragrunt,
 				OpenTofu:           project.OpenTofu,
@@ -366,6 +395,9 @@ func ConvertGitLabEventToCommands(event GitLabEvent, gitLabContext *GitLabContex
 			jobs = append(jobs, orchestrator.Job{
 				ProjectName:        project.Name,
 				ProjectDir:         project.Dir,
+				IncludePatterns:    project.IncludePatterns,
+				RequireCodeowners:  project.RequireCodeownersApproval,
+				Protect:            project.Protect,
 				ProjectWorkspace:   project.Workspace,
 				Terragrunt:         project.Terragrunt,
 				OpenTofu:           project.OpenTofu,
@@ -421,6 +453,9 @@ func ConvertGitLabEventToCommands(event GitLabEvent, gitLabContext *GitLabContex
 					jobs = append(jobs, orchestrator.Job{
 						ProjectName:        project.Name,
 						ProjectDir:         project.Dir,
+						IncludePatterns:    project.IncludePatterns,
+						RequireCodeowners:  project.RequireCodeownersApproval,
+						Protect:            project.Protect,
 						ProjectWorkspace:   workspace,
 						Terragrunt:         project.Terragrunt,
 						OpenTofu:           project.OpenTofu,
@@ -431,6 +466,7 @@ func ConvertGitLabEventToCommands(event GitLabEvent, gitLabContext *GitLabContex
 						EventName:          gitLabContext.EventType.String(),
 						RequestedBy:        gitLabContext.GitlabUserName,
 						Namespace:          gitLabContext.ProjectNamespace,
+						ConfirmDestroy:     orchestrator.ParseConfirmDestroy(gitLabContext.DiggerCommand),
 						StateEnvVars:       stateEnvVars,
 						CommandEnvVars:     commandEnvVars,
 						StateEnvProvider:   StateEnvProvider,
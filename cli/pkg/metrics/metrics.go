@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Exporter sends command durations to a metrics backend once the run is done
+type Exporter interface {
+	RecordCommand(repo string, project string, command string, result string, duration time.Duration)
+	Flush() error
+}
+
+type pushGroup struct {
+	repo    string
+	project string
+}
+
+// PushgatewayExporter pushes a histogram of command durations to a prometheus pushgateway, every project is pushed to
+// its own group that holds the durations of its last run, the number of groups is bound by the projects
+type PushgatewayExporter struct {
+	Url    string
+	groups map[pushGroup]*prometheus.HistogramVec
+}
+
+func NewPushgatewayExporter(url string) *PushgatewayExporter {
+	return &PushgatewayExporter{Url: url, groups: map[pushGroup]*prometheus.HistogramVec{}}
+}
+
+func (p *PushgatewayExporter) RecordCommand(repo string, project string, command string, result string, duration time.Duration) {
+	group := pushGroup{repo: repo, project: project}
+	durations, ok := p.groups[group]
+	if !ok {
+		// repo and project are added by the grouping key
+		durations = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "digger_command_duration_seconds",
+			Help:    "Duration of digger commands per project.",
+			Buckets: []float64{5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+		}, []string{"command", "result"})
+		p.groups[group] = durations
+	}
+	durations.WithLabelValues(command, result).Observe(duration.Seconds())
+}
+
+func (p *PushgatewayExporter) Flush() error {
+	var errs []error
+	for group, durations := range p.groups {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(durations)
+		err := push.New(p.Url, "digger").
+			Grouping("repo", group.repo).
+			Grouping("project", group.project).
+			Gatherer(registry).
+			Add()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	p.groups = map[pushGroup]*prometheus.HistogramVec{}
+	return errors.Join(errs...)
+}
+
+// OtlpExporter sends command durations to an OTLP/HTTP endpoint configured with the OTEL_EXPORTER_OTLP_* variables
+type OtlpExporter struct {
+	provider  *sdkmetric.MeterProvider
+	durations otelmetric.Float64Histogram
+}
+
+func NewOtlpExporter() (*OtlpExporter, error) {
+	exporter, err := otlpmetrichttp.New(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("could not create otlp exporter: %v", err)
+	}
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)))
+	durations, err := provider.Meter("github.com/diggerhq/digger/cli").Float64Histogram("digger.command.duration",
+		otelmetric.WithUnit("s"), otelmetric.WithDescription("Duration of digger commands per project."))
+	if err != nil {
+		return nil, fmt.Errorf("could not create histogram: %v", err)
+	}
+	return &OtlpExporter{provider: provider, durations: durations}, nil
+}
+
+func (o *OtlpExporter) RecordCommand(repo string, project string, command string, result string, duration time.Duration) {
+	o.durations.Record(context.Background(), duration.Seconds(), otelmetric.WithAttributes(
+		attribute.String("repo", repo),
+		attribute.String("project", project),
+		attribute.String("command", command),
+		attribute.String("result", result),
+	))
+}
+
+func (o *OtlpExporter) Flush() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return o.provider.Shutdown(ctx)
+}
+
+var exportersMutex sync.Mutex
+
+// exporters of the current run, they are created on the first record of a run and dropped once flushed
+var exporters []Exporter
+
+// exportersFromEnv enables the pushgateway exporter with DIGGER_METRICS_PUSHGATEWAY_URL
+// and the otlp exporter with OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_METRICS_ENDPOINT
+func exportersFromEnv() []Exporter {
+	result := make([]Exporter, 0)
+	if url := os.Getenv("DIGGER_METRICS_PUSHGATEWAY_URL"); url != "" {
+		result = append(result, NewPushgatewayExporter(url))
+	}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT") != "" {
+		exporter, err := NewOtlpExporter()
+		if err != nil {
+			log.Printf("Failed to set up otlp metrics exporter: %v", err)
+		} else {
+			result = append(result, exporter)
+		}
+	}
+	return result
+}
+
+// RecordCommand records how long a command took for a project, it is a no-op when no exporter is configured
+func RecordCommand(repo string, project string, command string, startedAt time.Time, err error) {
+	exportersMutex.Lock()
+	defer exportersMutex.Unlock()
+	if exporters == nil {
+		exporters = exportersFromEnv()
+	}
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	for _, exporter := range exporters {
+		exporter.RecordCommand(repo, project, command, result, time.Since(startedAt))
+	}
+}
+
+// Flush sends the recorded durations, failures are logged since metrics should never fail a run.
+// The exporters are dropped so that the next run in the same process starts with new ones.
+func Flush() {
+	exportersMutex.Lock()
+	defer exportersMutex.Unlock()
+	for _, exporter := range exporters {
+		if err := exporter.Flush(); err != nil {
+			log.Printf("Failed to export metrics: %v", err)
+		}
+	}
+	exporters = nil
+}
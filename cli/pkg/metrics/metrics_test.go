@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordedRequest struct {
+	method string
+	path   string
+	body   string
+}
+
+func recordingServer(t *testing.T) (*httptest.Server, func() []recordedRequest) {
+	var mu sync.Mutex
+	requests := make([]recordedRequest, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		mu.Lock()
+		requests = append(requests, recordedRequest{r.Method, r.URL.Path, string(body)})
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	return server, func() []recordedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func TestPushgatewayExporter(t *testing.T) {
+	server, requests := recordingServer(t)
+	defer server.Close()
+
+	exporter := NewPushgatewayExporter(server.URL)
+	exporter.RecordCommand("diggerhq/demo", "prod", "digger plan", "success", 42*time.Second)
+	assert.NoError(t, exporter.Flush())
+
+	if assert.Equal(t, 1, len(requests())) {
+		request := requests()[0]
+		assert.Equal(t, "POST", request.method)
+		// the order of the grouping labels isn't fixed, values with slashes are base64 encoded
+		assert.True(t, strings.HasPrefix(request.path, "/metrics/job/digger/"))
+		assert.Contains(t, request.path, "/repo@base64/ZGlnZ2VyaHEvZGVtbw")
+		assert.Contains(t, request.path, "/project/prod")
+		assert.NotContains(t, request.path, "/job_id/")
+		assert.Contains(t, request.body, "digger_command_duration_seconds")
+		assert.Contains(t, request.body, "digger plan")
+	}
+
+	// nothing is pushed again once flushed
+	assert.NoError(t, exporter.Flush())
+	assert.Equal(t, 1, len(requests()))
+}
+
+func TestMetricsAreExportedForEveryRun(t *testing.T) {
+	server, requests := recordingServer(t)
+	defer server.Close()
+	t.Setenv("DIGGER_METRICS_PUSHGATEWAY_URL", server.URL)
+
+	RecordCommand("diggerhq/demo", "prod", "digger plan", time.Now(), nil)
+	Flush()
+	RecordCommand("diggerhq/demo", "prod", "digger apply", time.Now(), nil)
+	Flush()
+
+	if assert.Equal(t, 2, len(requests())) {
+		// runs of a project replace the durations of its group
+		assert.Equal(t, requests()[0].path, requests()[1].path)
+		assert.Contains(t, requests()[1].body, "digger apply")
+	}
+}
+
+func TestOtlpExporter(t *testing.T) {
+	server, requests := recordingServer(t)
+	defer server.Close()
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL)
+
+	exporter, err := NewOtlpExporter()
+	assert.NoError(t, err)
+	exporter.RecordCommand("diggerhq/demo", "prod", "digger apply", "failure", 3*time.Minute)
+	assert.NoError(t, exporter.Flush())
+
+	if assert.Equal(t, 1, len(requests())) {
+		request := requests()[0]
+		assert.Equal(t, "/v1/metrics", request.path)
+		// the body is protobuf encoded, attribute values are stored as plain strings
+		assert.True(t, strings.Contains(request.body, "digger.command.duration"))
+		assert.True(t, strings.Contains(request.body, "digger apply"))
+	}
+}
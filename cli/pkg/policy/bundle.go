@@ -0,0 +1,421 @@
+package policy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/bundle"
+	"github.com/open-policy-agent/opa/loader"
+)
+
+const ociManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+
+var bundleLayerMediaTypes = []string{"application/vnd.oci.image.layer.v1.tar+gzip", "application/vnd.oci.image.layer.v1.tar"}
+
+var sha256DigestRegex = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// BundleProvider is implemented by policy providers whose policies are evaluated as a whole OPA bundle
+// instead of a single module
+type BundleProvider interface {
+	GetBundle() (*bundle.Bundle, error)
+}
+
+// digestedBundle is a loaded bundle along with the digest of its content, compiled queries of the bundle are cached by it
+type digestedBundle struct {
+	bundle *bundle.Bundle
+	digest string
+}
+
+// newDigestedBundle hashes the modules, data and manifest of the bundle, modules are hashed in the order of their path
+func newDigestedBundle(policyBundle *bundle.Bundle) (*digestedBundle, error) {
+	modules := make([]bundle.ModuleFile, len(policyBundle.Modules))
+	copy(modules, policyBundle.Modules)
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Path < modules[j].Path })
+
+	hash := sha256.New()
+	for _, module := range modules {
+		hash.Write([]byte(module.Path))
+		hash.Write([]byte{0})
+		hash.Write(module.Raw)
+		hash.Write([]byte{0})
+	}
+	for _, value := range []interface{}{policyBundle.Data, policyBundle.Manifest} {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("could not hash policy bundle: %v", err)
+		}
+		hash.Write(encoded)
+	}
+	return &digestedBundle{bundle: policyBundle, digest: "sha256:" + hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// definesRule reports whether a module of the bundle in the digger package has a rule with the name
+func (b *digestedBundle) definesRule(name string) bool {
+	for _, moduleFile := range b.bundle.Modules {
+		module := moduleFile.Parsed
+		if module == nil {
+			parsed, err := ast.ParseModule(moduleFile.Path, string(moduleFile.Raw))
+			if err != nil {
+				continue
+			}
+			module = parsed
+		}
+		if !module.Package.Path.Equal(ast.MustParseRef("data.digger")) {
+			continue
+		}
+		for _, rule := range module.Rules {
+			if rule.Head.Ref().String() == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BundlePolicyProvider loads an OPA bundle of rego modules in any packages, data and manifest. Source is a local
+// directory or tarball, an http(s) url of a tarball which can be pinned with a #sha256:<digest> fragment,
+// or oci://<registry>/<repository>[:tag|@digest]. Downloads are cached by digest in CacheDir
+type BundlePolicyProvider struct {
+	Source           string
+	Organisation     string
+	CacheDir         string
+	HttpClient       *http.Client
+	RegistryUsername string
+	RegistryPassword string
+
+	bundle *bundle.Bundle
+}
+
+func NewBundlePolicyProvider(source string, organisation string) *BundlePolicyProvider {
+	cacheDir := os.Getenv("DIGGER_POLICY_BUNDLE_CACHE_DIR")
+	if cacheDir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			userCacheDir = os.TempDir()
+		}
+		cacheDir = filepath.Join(userCacheDir, "digger", "policy-bundles")
+	}
+	return &BundlePolicyProvider{
+		Source:           source,
+		Organisation:     organisation,
+		CacheDir:         cacheDir,
+		HttpClient:       http.DefaultClient,
+		RegistryUsername: os.Getenv("DIGGER_POLICY_REGISTRY_USERNAME"),
+		RegistryPassword: os.Getenv("DIGGER_POLICY_REGISTRY_PASSWORD"),
+	}
+}
+
+// GetAccessPolicy returns no policy module, bundle policies are evaluated through GetBundle
+func (b *BundlePolicyProvider) GetAccessPolicy(_ string, _ string, _ string) (string, error) {
+	return "", nil
+}
+
+func (b *BundlePolicyProvider) GetPlanPolicy(_ string, _ string, _ string) (string, error) {
+	return "", nil
+}
+
+func (b *BundlePolicyProvider) GetDriftPolicy() (string, error) {
+	return "", nil
+}
+
+func (b *BundlePolicyProvider) GetOrganisation() string {
+	return b.Organisation
+}
+
+// GetBundle loads the bundle once per provider
+func (b *BundlePolicyProvider) GetBundle() (*bundle.Bundle, error) {
+	if b.bundle != nil {
+		return b.bundle, nil
+	}
+
+	var bundlePath string
+	var err error
+	switch {
+	case strings.HasPrefix(b.Source, "oci://"):
+		bundlePath, err = b.downloadOciBundle(strings.TrimPrefix(b.Source, "oci://"))
+	case strings.HasPrefix(b.Source, "https://") || strings.HasPrefix(b.Source, "http://"):
+		bundlePath, err = b.downloadBundle(b.Source)
+	default:
+		bundlePath = b.Source
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	loaded, err := loader.NewFileLoader().AsBundle(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not load policy bundle %v: %v", b.Source, err)
+	}
+	log.Printf("Loaded policy bundle %v with %v modules", b.Source, len(loaded.Modules))
+	b.bundle = loaded
+	return loaded, nil
+}
+
+func (b *BundlePolicyProvider) cachePath(digest string) string {
+	return filepath.Join(b.CacheDir, strings.Replace(digest, ":", "-", 1)+".tar.gz")
+}
+
+func (b *BundlePolicyProvider) cached(digest string) (string, bool) {
+	cachePath := b.cachePath(digest)
+	if _, err := os.Stat(cachePath); err != nil {
+		return "", false
+	}
+	log.Printf("Using cached policy bundle %v", digest)
+	return cachePath, true
+}
+
+// store writes the bundle to the cache, verifying its digest if one is expected
+func (b *BundlePolicyProvider) store(body io.Reader, expectedDigest string) (string, error) {
+	if err := os.MkdirAll(b.CacheDir, 0700); err != nil {
+		return "", fmt.Errorf("could not create policy bundle cache: %v", err)
+	}
+	tmp, err := os.CreateTemp(b.CacheDir, ".download-*")
+	if err != nil {
+		return "", fmt.Errorf("could not create policy bundle cache file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), body); err != nil {
+		return "", fmt.Errorf("could not download policy bundle: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	digest := "sha256:" + hex.EncodeToString(hash.Sum(nil))
+	if expectedDigest != "" && digest != expectedDigest {
+		return "", fmt.Errorf("policy bundle digest %v does not match %v", digest, expectedDigest)
+	}
+	cachePath := b.cachePath(digest)
+	if err := os.Rename(tmp.Name(), cachePath); err != nil {
+		return "", fmt.Errorf("could not cache policy bundle: %v", err)
+	}
+	return cachePath, nil
+}
+
+func (b *BundlePolicyProvider) downloadBundle(source string) (string, error) {
+	bundleUrl, digest, _ := strings.Cut(source, "#")
+	if digest != "" {
+		if !sha256DigestRegex.MatchString(digest) {
+			return "", fmt.Errorf("policy bundle digest has to be sha256:<hex>, got %v", digest)
+		}
+		if cachePath, ok := b.cached(digest); ok {
+			return cachePath, nil
+		}
+	}
+
+	resp, err := b.HttpClient.Get(bundleUrl)
+	if err != nil {
+		return "", fmt.Errorf("could not download policy bundle: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not download policy bundle, status code: %v", resp.StatusCode)
+	}
+	return b.store(resp.Body, digest)
+}
+
+type ociReference struct {
+	Registry   string
+	Repository string
+	// Reference is a tag or a digest
+	Reference string
+}
+
+func parseOciReference(ref string) (*ociReference, error) {
+	registry, repository, ok := strings.Cut(ref, "/")
+	if !ok || registry == "" || repository == "" {
+		return nil, fmt.Errorf("oci reference has to be <registry>/<repository>[:tag|@digest], got %v", ref)
+	}
+	reference := "latest"
+	if name, digest, ok := strings.Cut(repository, "@"); ok {
+		repository, reference = name, digest
+	} else if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository, reference = repository[:i], repository[i+1:]
+	}
+	return &ociReference{Registry: registry, Repository: repository, Reference: reference}, nil
+}
+
+type ociManifest struct {
+	Layers []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+	} `json:"layers"`
+}
+
+func (b *BundlePolicyProvider) downloadOciBundle(ref string) (string, error) {
+	reference, err := parseOciReference(ref)
+	if err != nil {
+		return "", err
+	}
+	registry := &ociRegistry{
+		BaseUrl:    "https://" + reference.Registry,
+		Repository: reference.Repository,
+		Username:   b.RegistryUsername,
+		Password:   b.RegistryPassword,
+		HttpClient: b.HttpClient,
+	}
+
+	resp, err := registry.get(fmt.Sprintf("/v2/%v/manifests/%v", reference.Repository, reference.Reference), ociManifestMediaType)
+	if err != nil {
+		return "", err
+	}
+	var manifest ociManifest
+	err = json.NewDecoder(resp.Body).Decode(&manifest)
+	resp.Body.Close()
+	if err != nil {
+		return "", fmt.Errorf("could not parse oci manifest of %v: %v", ref, err)
+	}
+
+	digest := ""
+	for _, layer := range manifest.Layers {
+		for _, mediaType := range bundleLayerMediaTypes {
+			if layer.MediaType == mediaType && digest == "" {
+				digest = layer.Digest
+			}
+		}
+	}
+	if digest == "" {
+		return "", fmt.Errorf("oci manifest of %v has no bundle layer", ref)
+	}
+	if !sha256DigestRegex.MatchString(digest) {
+		return "", fmt.Errorf("unsupported bundle layer digest %v", digest)
+	}
+	if cachePath, ok := b.cached(digest); ok {
+		return cachePath, nil
+	}
+
+	resp, err = registry.get(fmt.Sprintf("/v2/%v/blobs/%v", reference.Repository, digest), "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	return b.store(resp.Body, digest)
+}
+
+// ociRegistry pulls from a registry anonymously or with basic credentials, exchanging them for a bearer token
+// when the registry asks for one
+type ociRegistry struct {
+	BaseUrl    string
+	Repository string
+	Username   string
+	Password   string
+	HttpClient *http.Client
+
+	token string
+}
+
+func (r *ociRegistry) get(path string, accept string) (*http.Response, error) {
+	resp, err := r.request(path, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && r.token == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := r.authenticate(challenge); err != nil {
+			return nil, err
+		}
+		resp, err = r.request(path, accept)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status from registry for %v: %v", path, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func (r *ociRegistry) request(path string, accept string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", r.BaseUrl+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	} else if r.Username != "" {
+		req.SetBasicAuth(r.Username, r.Password)
+	}
+	resp, err := r.HttpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not reach registry: %v", err)
+	}
+	return resp, nil
+}
+
+func (r *ociRegistry) authenticate(challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return fmt.Errorf("registry requires authentication, challenge: %v", challenge)
+	}
+	values := map[string]string{}
+	for _, match := range regexp.MustCompile(`(\w+)="([^"]*)"`).FindAllStringSubmatch(params, -1) {
+		values[match[1]] = match[2]
+	}
+	if values["realm"] == "" {
+		return fmt.Errorf("registry token challenge has no realm: %v", challenge)
+	}
+	tokenUrl, err := url.Parse(values["realm"])
+	if err != nil {
+		return fmt.Errorf("could not parse registry token realm: %v", err)
+	}
+	query := tokenUrl.Query()
+	if values["service"] != "" {
+		query.Set("service", values["service"])
+	}
+	scope := values["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%v:pull", r.Repository)
+	}
+	query.Set("scope", scope)
+	tokenUrl.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", tokenUrl.String(), nil)
+	if err != nil {
+		return err
+	}
+	if r.Username != "" {
+		req.SetBasicAuth(r.Username, r.Password)
+	}
+	resp, err := r.HttpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not get registry token: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not get registry token, status code: %v", resp.StatusCode)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("could not parse registry token: %v", err)
+	}
+	r.token = token.Token
+	if r.token == "" {
+		r.token = token.AccessToken
+	}
+	if r.token == "" {
+		return fmt.Errorf("registry returned an empty token")
+	}
+	return nil
+}
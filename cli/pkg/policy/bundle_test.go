@@ -0,0 +1,191 @@
+package policy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/diggerhq/digger/cli/pkg/utils"
+	"github.com/stretchr/testify/require"
+)
+
+var bundleFiles = map[string]string{
+	"digger/policy.rego": `package digger
+
+import data.lib.naming
+
+deny[msg] {
+	resource := input.terraform.resource_changes[_]
+	not naming.valid(resource.name)
+	msg := sprintf("%v is not named after the convention", [resource.address])
+}
+
+allow {
+	data.admins[_] == input.user
+}
+`,
+	"lib/naming.rego": `package lib.naming
+
+valid(name) {
+	startswith(name, "digger_")
+}
+`,
+	"data.json": `{"admins": ["motatoes"]}`,
+	".manifest": `{"revision": "1"}`,
+}
+
+func writeBundleDir(t *testing.T) string {
+	dir := t.TempDir()
+	for name, contents := range bundleFiles {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+	}
+	return dir
+}
+
+func bundleTarball(t *testing.T) ([]byte, string) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, contents := range bundleFiles {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "/" + name, Mode: 0600, Size: int64(len(contents))}))
+		_, err := tw.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	digest := sha256.Sum256(buf.Bytes())
+	return buf.Bytes(), "sha256:" + hex.EncodeToString(digest[:])
+}
+
+func requireBundleDecisions(t *testing.T, provider *BundlePolicyProvider) {
+	checker := DiggerPolicyChecker{PolicyProvider: provider}
+
+	allowed, violations, _, err := checker.CheckPlanPolicy("demo", "diggerhq", "prod", `{"resource_changes": [{"address": "aws_s3_bucket.state", "name": "state"}, {"address": "aws_s3_bucket.digger_state", "name": "digger_state"}]}`, nil)
+	require.NoError(t, err)
+	require.False(t, allowed)
+	require.Equal(t, []string{"aws_s3_bucket.state is not named after the convention"}, violations)
+
+	ciService := &utils.MockPullRequestManager{Teams: []string{}}
+	allowed, err = checker.CheckAccessPolicy(ciService, nil, "diggerhq", "demo", "prod", "digger apply", nil, "motatoes", []string{}, []string{}, "", nil)
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	// allow is undefined for users who aren't admins, they are denied
+	allowed, err = checker.CheckAccessPolicy(ciService, nil, "diggerhq", "demo", "prod", "digger apply", nil, "mallory", []string{}, []string{}, "", nil)
+	require.NoError(t, err)
+	require.False(t, allowed)
+
+	// the bundle has no drift rules
+	enabled, err := checker.CheckDriftPolicy("diggerhq", "demo", "prod")
+	require.NoError(t, err)
+	require.True(t, enabled)
+}
+
+func TestBundlePolicyProviderLocal(t *testing.T) {
+	requireBundleDecisions(t, &BundlePolicyProvider{Source: writeBundleDir(t)})
+
+	tarball, _ := bundleTarball(t)
+	tarballPath := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	require.NoError(t, os.WriteFile(tarballPath, tarball, 0600))
+	requireBundleDecisions(t, &BundlePolicyProvider{Source: tarballPath})
+}
+
+func TestBundlePolicyProviderHttp(t *testing.T) {
+	tarball, digest := bundleTarball(t)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(tarball)
+	}))
+	defer server.Close()
+	cacheDir := t.TempDir()
+
+	requireBundleDecisions(t, &BundlePolicyProvider{Source: server.URL + "/bundle.tar.gz#" + digest, CacheDir: cacheDir, HttpClient: server.Client()})
+	requireBundleDecisions(t, &BundlePolicyProvider{Source: server.URL + "/bundle.tar.gz#" + digest, CacheDir: cacheDir, HttpClient: server.Client()})
+	require.Equal(t, 1, requests)
+
+	_, err := (&BundlePolicyProvider{Source: server.URL + "/bundle.tar.gz#sha256:" + strings.Repeat("0", 64), CacheDir: cacheDir, HttpClient: server.Client()}).GetBundle()
+	require.ErrorContains(t, err, "does not match")
+}
+
+// fakeRegistry serves a single bundle behind token authentication
+type fakeRegistry struct {
+	mu        sync.Mutex
+	tarball   []byte
+	digest    string
+	blobPulls int
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/token" {
+		if r.URL.Query().Get("scope") != "repository:policies/digger:pull" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"token": "pull-token"})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer pull-token" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="https://%v/token",service="registry",scope="repository:policies/digger:pull"`, r.Host))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Path {
+	case "/v2/policies/digger/manifests/v1":
+		w.Header().Set("Content-Type", ociManifestMediaType)
+		fmt.Fprintf(w, `{"schemaVersion": 2, "config": {"mediaType": "application/vnd.oci.image.config.v1+json", "digest": "sha256:%v"}, "layers": [{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": %q}]}`, strings.Repeat("1", 64), f.digest)
+	case "/v2/policies/digger/blobs/" + f.digest:
+		f.blobPulls++
+		w.Write(f.tarball)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestBundlePolicyProviderOci(t *testing.T) {
+	tarball, digest := bundleTarball(t)
+	registry := &fakeRegistry{tarball: tarball, digest: digest}
+	server := httptest.NewTLSServer(registry)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+	cacheDir := t.TempDir()
+
+	requireBundleDecisions(t, &BundlePolicyProvider{Source: "oci://" + host + "/policies/digger:v1", CacheDir: cacheDir, HttpClient: server.Client()})
+	requireBundleDecisions(t, &BundlePolicyProvider{Source: "oci://" + host + "/policies/digger:v1", CacheDir: cacheDir, HttpClient: server.Client()})
+	require.Equal(t, 1, registry.blobPulls)
+
+	_, err := (&BundlePolicyProvider{Source: "oci://" + host + "/policies/digger:v2", CacheDir: cacheDir, HttpClient: server.Client()}).GetBundle()
+	require.Error(t, err)
+}
+
+func TestParseOciReference(t *testing.T) {
+	reference, err := parseOciReference("ghcr.io/diggerhq/policies")
+	require.NoError(t, err)
+	require.Equal(t, ociReference{Registry: "ghcr.io", Repository: "diggerhq/policies", Reference: "latest"}, *reference)
+
+	reference, err = parseOciReference("localhost:5000/policies:v1")
+	require.NoError(t, err)
+	require.Equal(t, ociReference{Registry: "localhost:5000", Repository: "policies", Reference: "v1"}, *reference)
+
+	reference, err = parseOciReference("ghcr.io/diggerhq/policies@sha256:abc")
+	require.NoError(t, err)
+	require.Equal(t, "sha256:abc", reference.Reference)
+
+	_, err = parseOciReference("policies")
+	require.Error(t, err)
+}
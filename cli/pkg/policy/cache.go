@@ -0,0 +1,71 @@
+package policy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"github.com/open-policy-agent/opa/rego"
+)
+
+type cachedPolicy struct {
+	ETag    string
+	Policy  string
+	Version uint
+}
+
+// policyResponseCache keeps fetched policies by url, they are revalidated with their ETag on every fetch
+type policyResponseCache struct {
+	mu       sync.Mutex
+	policies map[string]cachedPolicy
+}
+
+func (c *policyResponseCache) get(url string) (cachedPolicy, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	policy, ok := c.policies[url]
+	return policy, ok
+}
+
+func (c *policyResponseCache) set(url string, policy cachedPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policies[url] = policy
+}
+
+// preparedQueryCache keeps compiled queries by query, policy hash and bundle digest, so the same policy or bundle is
+// compiled once for all jobs and commands of a run
+type preparedQueryCache struct {
+	mu      sync.Mutex
+	queries map[string]rego.PreparedEvalQuery
+}
+
+func (c *preparedQueryCache) prepare(ctx context.Context, queryString string, policy string, policyBundle *digestedBundle) (rego.PreparedEvalQuery, error) {
+	digest := sha256.Sum256([]byte(policy))
+	key := queryString + "@" + hex.EncodeToString(digest[:])
+	options := []func(*rego.Rego){rego.Query(queryString)}
+	if policy != "" {
+		options = append(options, rego.Module("digger", policy))
+	}
+	if policyBundle != nil {
+		key += "@" + policyBundle.digest
+		options = append(options, rego.ParsedBundle("digger", policyBundle.bundle))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if query, ok := c.queries[key]; ok {
+		return query, nil
+	}
+	query, err := rego.New(options...).PrepareForEval(ctx)
+	if err != nil {
+		return rego.PreparedEvalQuery{}, err
+	}
+	c.queries[key] = query
+	return query, nil
+}
+
+var policyResponses = &policyResponseCache{policies: map[string]cachedPolicy{}}
+
+var preparedQueries = &preparedQueryCache{queries: map[string]rego.PreparedEvalQuery{}}
@@ -0,0 +1,121 @@
+package policy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/diggerhq/digger/cli/pkg/backend"
+	core_backend "github.com/diggerhq/digger/cli/pkg/core/backend"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpPolicyProviderRevalidatesWithETag(t *testing.T) {
+	policy := "package digger\ndefault allow = true\n"
+	fetched, revalidated := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/orgs/diggerhq/access-policy", r.URL.Path)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			revalidated++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fetched++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set(backend.PolicyVersionHeader, "7")
+		w.Write([]byte(policy))
+	}))
+	defer server.Close()
+
+	provider := DiggerHttpPolicyProvider{DiggerHost: server.URL, DiggerOrganisation: "diggerhq", AuthToken: "token", HttpClient: server.Client()}
+	for i := 0; i < 3; i++ {
+		content, version, statusCode, err := getAccessPolicyForOrganisation(&provider)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, statusCode)
+		require.Equal(t, policy, content)
+		require.Equal(t, uint(7), version)
+	}
+	require.Equal(t, 1, fetched)
+	require.Equal(t, 2, revalidated)
+}
+
+func TestPreparedQueriesAreReused(t *testing.T) {
+	policy := "package digger\ndefault allow = false\nallow {\n\tinput.user == \"motatoes\"\n}\n"
+	compiled := func() int {
+		preparedQueries.mu.Lock()
+		defer preparedQueries.mu.Unlock()
+		return len(preparedQueries.queries)
+	}
+	before := compiled()
+
+	allowed, err := EvalAccessPolicy(policy, map[string]interface{}{"user": "motatoes"})
+	require.NoError(t, err)
+	require.True(t, allowed)
+	allowed, err = EvalAccessPolicy(policy, map[string]interface{}{"user": "someone"})
+	require.NoError(t, err)
+	require.False(t, allowed)
+	require.Equal(t, before+1, compiled())
+
+	// a changed policy is compiled again
+	_, err = EvalAccessPolicy(policy+"\nallow {\n\tinput.user == \"veziak\"\n}\n", map[string]interface{}{"user": "veziak"})
+	require.NoError(t, err)
+	require.Equal(t, before+2, compiled())
+}
+
+func TestHttpPolicyProviderRecordsPolicyVersions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/diggerhq-demo/projects/versioned/access-policy":
+			w.Header().Set(backend.PolicyVersionHeader, "3")
+			w.Write([]byte("package digger\ndefault allow = true\n"))
+		case "/orgs/diggerhq/plan-policy":
+			w.Header().Set(backend.PolicyVersionHeader, "4")
+			w.Write([]byte("package digger\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	sharedProvider := &DiggerHttpPolicyProvider{DiggerHost: server.URL, DiggerOrganisation: "diggerhq", AuthToken: "token", HttpClient: server.Client()}
+	report := core_backend.NewJobReport()
+	provider := DiggerPolicyChecker{PolicyProvider: sharedProvider}.WithReport(report).(DiggerPolicyChecker).PolicyProvider
+	_, err := provider.GetAccessPolicy("diggerhq", "demo", "versioned")
+	require.NoError(t, err)
+	_, err = provider.GetPlanPolicy("diggerhq", "demo", "versioned")
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint{"access": 3, "plan": 4}, report.PolicyVersions)
+	// the checker of other jobs doesn't record into this report
+	require.Nil(t, sharedProvider.Report)
+}
+
+func TestBundleQueriesAreReused(t *testing.T) {
+	compiled := func() int {
+		preparedQueries.mu.Lock()
+		defer preparedQueries.mu.Unlock()
+		return len(preparedQueries.queries)
+	}
+	provider := &BundlePolicyProvider{Source: writeBundleDir(t)}
+	before := compiled()
+
+	requireBundleDecisions(t, provider)
+	afterFirstRun := compiled()
+	require.Greater(t, afterFirstRun, before)
+	requireBundleDecisions(t, provider)
+	require.Equal(t, afterFirstRun, compiled())
+
+	// a bundle with other content is compiled again
+	policyBundle, err := provider.GetBundle()
+	require.NoError(t, err)
+	digested, err := newDigestedBundle(policyBundle)
+	require.NoError(t, err)
+	changed := *policyBundle
+	changed.Data = map[string]interface{}{"admins": []interface{}{"veziak"}}
+	changedDigest, err := newDigestedBundle(&changed)
+	require.NoError(t, err)
+	require.NotEqual(t, digested.digest, changedDigest.digest)
+	allowed, err := evalAccessPolicy("", changedDigest, map[string]interface{}{"user": "veziak"})
+	require.NoError(t, err)
+	require.True(t, allowed)
+	require.Equal(t, afterFirstRun+1, compiled())
+}
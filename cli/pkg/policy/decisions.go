@@ -0,0 +1,185 @@
+package policy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"time"
+
+	core_backend "github.com/diggerhq/digger/cli/pkg/core/backend"
+)
+
+const defaultDecisionLogFile = "digger-policy-decisions.jsonl"
+
+const redactedValue = "[REDACTED]"
+
+// secretKeyPattern matches input keys whose values are never recorded
+var secretKeyPattern = regexp.MustCompile(`(?i)(password|secret|token|private_key|access_key|credential)`)
+
+// sensitiveMasks are the keys of terraform plan values along with the keys marking which of them are sensitive
+var sensitiveMasks = map[string]string{
+	"before": "before_sensitive",
+	"after":  "after_sensitive",
+	"values": "sensitive_values",
+}
+
+// decisionLogFile is the JSON lines file decisions are appended to instead of reporting them to the backend,
+// DIGGER_POLICY_DECISION_LOG overrides the default file used in backendless mode
+func decisionLogFile() string {
+	if path := os.Getenv("DIGGER_POLICY_DECISION_LOG"); path != "" {
+		return path
+	}
+	if os.Getenv("NO_BACKEND") == "true" {
+		return defaultDecisionLogFile
+	}
+	return ""
+}
+
+// recordDecision completes the decision with the policy hash and version and keeps it in the report of the job,
+// recording failures are logged and never fail the check
+func (p DiggerPolicyChecker) recordDecision(decision core_backend.PolicyDecision, policyText string) {
+	decision.Timestamp = time.Now().UTC()
+	decision.PolicyHash = p.policyHash(policyText)
+	if versionId, ok := p.Report.PolicyVersion(decision.PolicyType); ok {
+		decision.PolicyVersionId = &versionId
+	}
+	if decision.Messages == nil {
+		decision.Messages = []string{}
+	}
+	input, err := redactInput(decision.Input)
+	if err != nil {
+		log.Printf("WARNING: could not redact the input of the %v policy decision, not recording it: %v", decision.PolicyType, err)
+		return
+	}
+	decision.Input = input
+
+	if path := decisionLogFile(); path != "" {
+		if err := appendDecision(path, decision); err != nil {
+			log.Printf("WARNING: could not write policy decision to %v: %v", path, err)
+		}
+		return
+	}
+	p.Report.RecordPolicyDecision(decision)
+}
+
+// policyHash identifies the evaluated policy, bundles are identified by their source and manifest revision
+func (p DiggerPolicyChecker) policyHash(policyText string) string {
+	if bundleProvider, ok := p.PolicyProvider.(*BundlePolicyProvider); ok && policyText == "" {
+		revision := ""
+		if bundleProvider.bundle != nil {
+			revision = bundleProvider.bundle.Manifest.Revision
+		}
+		return fmt.Sprintf("bundle:%v@%v", bundleProvider.Source, revision)
+	}
+	digest := sha256.Sum256([]byte(policyText))
+	return "sha256:" + hex.EncodeToString(digest[:])
+}
+
+func appendDecision(path string, decision core_backend.PolicyDecision) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(decision)
+}
+
+// redactInput returns a copy of the input where values of secret looking keys and values terraform marks as sensitive
+// are replaced
+func redactInput(input map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	var copied map[string]interface{}
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, err
+	}
+	redacted, _ := redactValue(copied).(map[string]interface{})
+	return redacted, nil
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for valuesKey, maskKey := range sensitiveMasks {
+			if values, ok := v[valuesKey]; ok {
+				v[valuesKey] = applySensitiveMask(values, v[maskKey])
+			}
+		}
+		redactSensitiveVariables(v)
+		for key, nested := range v {
+			if secretKeyPattern.MatchString(key) && nested != nil {
+				v[key] = redactedValue
+				continue
+			}
+			v[key] = redactValue(nested)
+		}
+		return v
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = redactValue(nested)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// redactSensitiveVariables replaces the values of the plan variables that the root module declares as sensitive
+func redactSensitiveVariables(plan map[string]interface{}) {
+	variables, ok := plan["variables"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	configuration, _ := plan["configuration"].(map[string]interface{})
+	rootModule, _ := configuration["root_module"].(map[string]interface{})
+	declared, _ := rootModule["variables"].(map[string]interface{})
+	for name, declaration := range declared {
+		declaration, _ := declaration.(map[string]interface{})
+		if sensitive, _ := declaration["sensitive"].(bool); !sensitive {
+			continue
+		}
+		if variable, ok := variables[name].(map[string]interface{}); ok && variable["value"] != nil {
+			variable["value"] = redactedValue
+		}
+	}
+}
+
+// applySensitiveMask replaces the values marked as true in the mask, masks mirror the structure of the values
+func applySensitiveMask(value interface{}, mask interface{}) interface{} {
+	switch m := mask.(type) {
+	case bool:
+		if m && value != nil {
+			return redactedValue
+		}
+	case map[string]interface{}:
+		if values, ok := value.(map[string]interface{}); ok {
+			for key, nestedMask := range m {
+				if nested, ok := values[key]; ok {
+					values[key] = applySensitiveMask(nested, nestedMask)
+				}
+			}
+		}
+	case []interface{}:
+		if values, ok := value.([]interface{}); ok {
+			for i, nestedMask := range m {
+				if i < len(values) {
+					values[i] = applySensitiveMask(values[i], nestedMask)
+				}
+			}
+		}
+	}
+	return value
+}
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
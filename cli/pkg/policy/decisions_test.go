@@ -0,0 +1,83 @@
+package policy
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	core_backend "github.com/diggerhq/digger/cli/pkg/core/backend"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactInput(t *testing.T) {
+	input, err := PlanPolicyInput(`{"variables": {"db_password": {"value": "hunter2"}}, "resource_changes": [{"address": "aws_db_instance.db", "change": {"before": null, "after": {"username": "admin", "password": "hunter2", "tags": ["a", "b"]}, "after_sensitive": {"tags": [false, true]}}}]}`, nil)
+	require.NoError(t, err)
+
+	redacted, err := redactInput(input)
+	require.NoError(t, err)
+	terraform := redacted["terraform"].(map[string]interface{})
+	require.Equal(t, redactedValue, terraform["variables"].(map[string]interface{})["db_password"])
+	after := terraform["resource_changes"].([]interface{})[0].(map[string]interface{})["change"].(map[string]interface{})["after"].(map[string]interface{})
+	require.Equal(t, "admin", after["username"])
+	require.Equal(t, redactedValue, after["password"])
+	require.Equal(t, []interface{}{"a", redactedValue}, after["tags"])
+
+	// the input evaluated by the policy is left untouched
+	require.Contains(t, input["terraform"].(map[string]interface{})["variables"], "db_password")
+
+	// variables the root module declares as sensitive
+	input, err = PlanPolicyInput(`{"variables": {"db_user": {"value": "admin"}, "api_key_id": {"value": "abc"}}, "configuration": {"root_module": {"variables": {"db_user": {"sensitive": false}, "api_key_id": {"sensitive": true}}}}}`, nil)
+	require.NoError(t, err)
+	redacted, err = redactInput(input)
+	require.NoError(t, err)
+	variables := redacted["terraform"].(map[string]interface{})["variables"].(map[string]interface{})
+	require.Equal(t, "admin", variables["db_user"].(map[string]interface{})["value"])
+	require.Equal(t, redactedValue, variables["api_key_id"].(map[string]interface{})["value"])
+}
+
+func TestPolicyDecisionsAreRecorded(t *testing.T) {
+	report := core_backend.NewJobReport()
+	checker := DiggerPolicyChecker{PolicyProvider: &DiggerWarningPolicyProvider{}}
+	allowed, deny, _, err := checker.WithReport(report).CheckPlanPolicy("demo", "diggerhq", "decisions", `{"resource_changes": []}`, nil)
+	require.NoError(t, err)
+	require.True(t, allowed)
+	require.Empty(t, deny)
+
+	decisions := report.PolicyDecisions
+	require.Len(t, decisions, 1)
+	require.Equal(t, "plan", decisions[0].PolicyType)
+	require.Equal(t, "decisions", decisions[0].Project)
+	require.True(t, decisions[0].Allowed)
+	require.Regexp(t, "^sha256:[0-9a-f]{64}$", decisions[0].PolicyHash)
+
+	// checkers without a job report don't keep decisions around
+	_, _, _, err = checker.CheckPlanPolicy("demo", "diggerhq", "decisions", `{"resource_changes": []}`, nil)
+	require.NoError(t, err)
+	require.Len(t, report.PolicyDecisions, 1)
+}
+
+func TestPolicyDecisionsAreWrittenToFileInBackendlessMode(t *testing.T) {
+	t.Setenv("NO_BACKEND", "true")
+	path := filepath.Join(t.TempDir(), "decisions.jsonl")
+	t.Setenv("DIGGER_POLICY_DECISION_LOG", path)
+
+	report := core_backend.NewJobReport()
+	checker := DiggerPolicyChecker{PolicyProvider: &BundlePolicyProvider{Source: writeBundleDir(t)}}
+	_, _, _, err := checker.WithReport(report).CheckPlanPolicy("demo", "diggerhq", "backendless", `{"resource_changes": [{"address": "aws_s3_bucket.state", "name": "state"}]}`, nil)
+	require.NoError(t, err)
+	require.Empty(t, report.PolicyDecisions)
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	require.True(t, scanner.Scan())
+	var decision core_backend.PolicyDecision
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &decision))
+	require.False(t, decision.Allowed)
+	require.Equal(t, []string{"aws_s3_bucket.state is not named after the convention"}, decision.Messages)
+	require.Equal(t, "bundle:"+checker.PolicyProvider.(*BundlePolicyProvider).Source+"@1", decision.PolicyHash)
+	require.False(t, scanner.Scan())
+}
@@ -10,9 +10,14 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/diggerhq/digger/cli/pkg/backend"
+	core_backend "github.com/diggerhq/digger/cli/pkg/core/backend"
 	"github.com/diggerhq/digger/cli/pkg/core/policy"
 	"github.com/diggerhq/digger/libs/orchestrator"
+	"github.com/diggerhq/digger/libs/terraform_utils"
 
 	// "github.com/diggerhq/digger/cli/pkg/core/policy/AccessPolicyContext"
 	// TODO fix imports - publish?
@@ -30,228 +35,167 @@ type DiggerHttpPolicyProvider struct {
 	DiggerOrganisation string
 	AuthToken          string
 	HttpClient         *http.Client
+	// Report records the versions of the fetched policies, it is nil when no job reports them
+	Report *core_backend.JobReport
 }
 
 type NoOpPolicyChecker struct {
 }
 
-func (p NoOpPolicyChecker) CheckAccessPolicy(_ orchestrator.OrgService, _ *orchestrator.PullRequestService, _ string, _ string, _ string, _ string, _ *int, _ string, _ []string) (bool, error) {
+func (p NoOpPolicyChecker) CheckAccessPolicy(_ orchestrator.OrgService, _ *orchestrator.PullRequestService, _ string, _ string, _ string, _ string, _ *int, _ string, _ []string, _ []string, _ string, _ *terraform_utils.PlanSummary) (bool, error) {
 	return true, nil
 }
 
-func (p NoOpPolicyChecker) CheckPlanPolicy(_ string, _ string, _ string, _ string) (bool, []string, error) {
-	return true, nil, nil
+func (p NoOpPolicyChecker) CheckPlanPolicy(_ string, _ string, _ string, _ string, _ *terraform_utils.CostEstimate) (bool, []string, []string, error) {
+	return true, nil, nil, nil
 }
 
 func (p NoOpPolicyChecker) CheckDriftPolicy(SCMOrganisation string, SCMrepository string, projectname string) (bool, error) {
 	return true, nil
 }
 
-func getAccessPolicyForOrganisation(p *DiggerHttpPolicyProvider) (string, *http.Response, error) {
-	organisation := p.DiggerOrganisation
+// fetchPolicy revalidates policies fetched before with their ETag, so unchanged policies aren't downloaded again.
+// The version id is 0 when the backend doesn't version policies
+func fetchPolicy(p *DiggerHttpPolicyProvider, path string) (string, uint, int, error) {
 	u, err := url.Parse(p.DiggerHost)
 	if err != nil {
 		log.Fatalf("Not able to parse digger cloud url: %v", err)
 	}
-	u.Path = "/orgs/" + organisation + "/access-policy"
+	u.Path = path
 	req, err := http.NewRequest("GET", u.String(), nil)
 	if err != nil {
-		return "", nil, err
+		return "", 0, 0, err
 	}
-	req.Header.Add("Authorization", "Bearer "+p.AuthToken)
-
-	resp, err := p.HttpClient.Do(req)
+	authToken, err := backend.ResolveAuthToken(p.AuthToken)
 	if err != nil {
-		return "", nil, err
+		return "", 0, 0, err
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", resp, nil
+	req.Header.Add("Authorization", "Bearer "+authToken)
+	cached, isCached := policyResponses.get(u.String())
+	if isCached {
+		req.Header.Add("If-None-Match", cached.ETag)
 	}
-	return string(body), resp, nil
-}
-
-func getPlanPolicyForOrganisation(p *DiggerHttpPolicyProvider) (string, *http.Response, error) {
-	organisation := p.DiggerOrganisation
-	u, err := url.Parse(p.DiggerHost)
-	if err != nil {
-		log.Fatalf("Not able to parse digger cloud url: %v", err)
-	}
-	u.Path = "/orgs/" + organisation + "/plan-policy"
-	req, err := http.NewRequest("GET", u.String(), nil)
-	if err != nil {
-		return "", nil, err
-	}
-	req.Header.Add("Authorization", "Bearer "+p.AuthToken)
 
 	resp, err := p.HttpClient.Do(req)
 	if err != nil {
-		return "", nil, err
+		return "", 0, 0, err
 	}
 	defer resp.Body.Close()
 
+	version, _ := strconv.ParseUint(resp.Header.Get(backend.PolicyVersionHeader), 10, 64)
+	if resp.StatusCode == http.StatusNotModified && isCached {
+		if version == 0 {
+			version = uint64(cached.Version)
+		}
+		return cached.Policy, uint(version), http.StatusOK, nil
+	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", resp, nil
+		return "", 0, resp.StatusCode, nil
+	}
+	if etag := resp.Header.Get("ETag"); resp.StatusCode == http.StatusOK && etag != "" {
+		policyResponses.set(u.String(), cachedPolicy{ETag: etag, Policy: string(body), Version: uint(version)})
 	}
-	return string(body), resp, nil
+	return string(body), uint(version), resp.StatusCode, nil
 }
 
-func getDriftPolicyForOrganisation(p *DiggerHttpPolicyProvider) (string, *http.Response, error) {
-	organisation := p.DiggerOrganisation
-	u, err := url.Parse(p.DiggerHost)
-	if err != nil {
-		log.Fatalf("Not able to parse digger cloud url: %v", err)
-	}
-	u.Path = "/orgs/" + organisation + "/drift-policy"
-	req, err := http.NewRequest("GET", u.String(), nil)
-	if err != nil {
-		return "", nil, err
-	}
-	req.Header.Add("Authorization", "Bearer "+p.AuthToken)
+func getAccessPolicyForOrganisation(p *DiggerHttpPolicyProvider) (string, uint, int, error) {
+	return fetchPolicy(p, "/orgs/"+p.DiggerOrganisation+"/access-policy")
+}
 
-	resp, err := p.HttpClient.Do(req)
-	if err != nil {
-		return "", nil, err
-	}
-	defer resp.Body.Close()
+func getPlanPolicyForOrganisation(p *DiggerHttpPolicyProvider) (string, uint, int, error) {
+	return fetchPolicy(p, "/orgs/"+p.DiggerOrganisation+"/plan-policy")
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", resp, nil
-	}
-	return string(body), resp, nil
+func getDriftPolicyForOrganisation(p *DiggerHttpPolicyProvider) (string, uint, int, error) {
+	return fetchPolicy(p, "/orgs/"+p.DiggerOrganisation+"/drift-policy")
 }
 
-func getAccessPolicyForNamespace(p *DiggerHttpPolicyProvider, namespace string, projectName string) (string, *http.Response, error) {
+func getAccessPolicyForNamespace(p *DiggerHttpPolicyProvider, namespace string, projectName string) (string, uint, int, error) {
 	// fetch RBAC policies for project from Digger API
-	u, err := url.Parse(p.DiggerHost)
-	if err != nil {
-		log.Fatalf("Not able to parse digger cloud url: %v", err)
-	}
-	u.Path = "/repos/" + namespace + "/projects/" + projectName + "/access-policy"
-	req, err := http.NewRequest("GET", u.String(), nil)
-
-	if err != nil {
-		return "", nil, err
-	}
-	req.Header.Add("Authorization", "Bearer "+p.AuthToken)
-
-	resp, err := p.HttpClient.Do(req)
-	if err != nil {
-		return "", resp, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", resp, nil
-	}
-	return string(body), resp, nil
-
+	return fetchPolicy(p, "/repos/"+namespace+"/projects/"+projectName+"/access-policy")
 }
 
-func getPlanPolicyForNamespace(p *DiggerHttpPolicyProvider, namespace string, projectName string) (string, *http.Response, error) {
-	u, err := url.Parse(p.DiggerHost)
-	if err != nil {
-		log.Fatalf("Not able to parse digger cloud url: %v", err)
-	}
-	u.Path = "/repos/" + namespace + "/projects/" + projectName + "/plan-policy"
-	req, err := http.NewRequest("GET", u.String(), nil)
-
-	if err != nil {
-		return "", nil, err
-	}
-	req.Header.Add("Authorization", "Bearer "+p.AuthToken)
-
-	resp, err := p.HttpClient.Do(req)
-	if err != nil {
-		return "", nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", resp, nil
-	}
-	return string(body), resp, nil
-
+func getPlanPolicyForNamespace(p *DiggerHttpPolicyProvider, namespace string, projectName string) (string, uint, int, error) {
+	return fetchPolicy(p, "/repos/"+namespace+"/projects/"+projectName+"/plan-policy")
 }
 
 // GetPolicy fetches policy for particular project,  if not found then it will fallback to org level policy
 func (p DiggerHttpPolicyProvider) GetAccessPolicy(organisation string, repo string, projectName string) (string, error) {
 	namespace := fmt.Sprintf("%v-%v", organisation, repo)
-	content, resp, err := getAccessPolicyForNamespace(&p, namespace, projectName)
+	content, version, statusCode, err := getAccessPolicyForNamespace(&p, namespace, projectName)
 	if err != nil {
 		return "", fmt.Errorf("error while fetching access policy for namespace: %v", err)
 	}
 
 	// project policy found
-	if resp.StatusCode == 200 && content != "" {
+	if statusCode == 200 && content != "" {
+		p.Report.RecordPolicyVersion(policy.PolicyTypeAccess, version)
 		return content, nil
 	}
 
 	// check if project policy was empty or not found (retrieve org policy if so)
-	if (resp.StatusCode == 200 && content == "") || resp.StatusCode == 404 {
-		content, resp, err := getAccessPolicyForOrganisation(&p)
+	if (statusCode == 200 && content == "") || statusCode == 404 {
+		content, version, statusCode, err := getAccessPolicyForOrganisation(&p)
 		if err != nil {
 			return "", fmt.Errorf("error while fetching access policy for organisation: %v", err)
 		}
-		if resp.StatusCode == 200 {
+		if statusCode == 200 {
+			p.Report.RecordPolicyVersion(policy.PolicyTypeAccess, version)
 			return content, nil
-		} else if resp.StatusCode == 404 {
+		} else if statusCode == 404 {
 			return DefaultAccessPolicy, nil
 		} else {
-			return "", errors.New(fmt.Sprintf("unexpected response while fetching organisation policy: %v, code %v", content, resp.StatusCode))
+			return "", errors.New(fmt.Sprintf("unexpected response while fetching organisation policy: %v, code %v", content, statusCode))
 		}
 	} else {
-		return "", errors.New(fmt.Sprintf("unexpected response while fetching project policy: %v code %v", content, resp.StatusCode))
+		return "", errors.New(fmt.Sprintf("unexpected response while fetching project policy: %v code %v", content, statusCode))
 	}
 }
 
 func (p DiggerHttpPolicyProvider) GetPlanPolicy(organisation string, repo string, projectName string) (string, error) {
 	namespace := fmt.Sprintf("%v-%v", organisation, repo)
-	content, resp, err := getPlanPolicyForNamespace(&p, namespace, projectName)
+	content, version, statusCode, err := getPlanPolicyForNamespace(&p, namespace, projectName)
 	if err != nil {
 		return "", err
 	}
 
 	// project policy found
-	if resp.StatusCode == 200 && content != "" {
+	if statusCode == 200 && content != "" {
+		p.Report.RecordPolicyVersion(policy.PolicyTypePlan, version)
 		return content, nil
 	}
 
 	// check if project policy was empty or not found (retrieve org policy if so)
-	if (resp.StatusCode == 200 && content == "") || resp.StatusCode == 404 {
-		content, resp, err := getPlanPolicyForOrganisation(&p)
+	if (statusCode == 200 && content == "") || statusCode == 404 {
+		content, version, statusCode, err := getPlanPolicyForOrganisation(&p)
 		if err != nil {
 			return "", err
 		}
-		if resp.StatusCode == 200 {
+		if statusCode == 200 {
+			p.Report.RecordPolicyVersion(policy.PolicyTypePlan, version)
 			return content, nil
-		} else if resp.StatusCode == 404 {
+		} else if statusCode == 404 {
 			return "", nil
 		} else {
-			return "", errors.New(fmt.Sprintf("unexpected response while fetching organisation policy: %v, code %v", content, resp.StatusCode))
+			return "", errors.New(fmt.Sprintf("unexpected response while fetching organisation policy: %v, code %v", content, statusCode))
 		}
 	} else {
-		return "", errors.New(fmt.Sprintf("unexpected response while fetching project policy: %v code %v", content, resp.StatusCode))
+		return "", errors.New(fmt.Sprintf("unexpected response while fetching project policy: %v code %v", content, statusCode))
 	}
 }
 
 func (p DiggerHttpPolicyProvider) GetDriftPolicy() (string, error) {
-	content, resp, err := getDriftPolicyForOrganisation(&p)
+	content, _, statusCode, err := getDriftPolicyForOrganisation(&p)
 	if err != nil {
 		return "", err
 	}
-	if resp.StatusCode == 200 {
+	if statusCode == 200 {
 		return content, nil
-	} else if resp.StatusCode == 404 {
+	} else if statusCode == 404 {
 		return "", nil
 	} else {
-		return "", errors.New(fmt.Sprintf("unexpected response while fetching organisation policy: %v, code %v", content, resp.StatusCode))
+		return "", errors.New(fmt.Sprintf("unexpected response while fetching organisation policy: %v, code %v", content, statusCode))
 	}
 }
 
@@ -261,62 +205,156 @@ func (p DiggerHttpPolicyProvider) GetOrganisation() string {
 
 type DiggerPolicyChecker struct {
 	PolicyProvider policy.Provider
+	// Report is the report of the job the checker evaluates policies for, it is nil when no job reports them
+	Report *core_backend.JobReport
 }
 
-// TODO refactor to use AccessPolicyContext - too many arguments
-func (p DiggerPolicyChecker) CheckAccessPolicy(ciService orchestrator.OrgService, prService *orchestrator.PullRequestService, SCMOrganisation string, SCMrepository string, projectName string, command string, prNumber *int, requestedBy string, planPolicyViolations []string) (bool, error) {
-
-	policy, err := p.PolicyProvider.GetAccessPolicy(SCMOrganisation, SCMrepository, projectName)
+// WithReport returns the checker of a job, its decisions and the versions of the policies it fetches are recorded in
+// the report
+func (p DiggerPolicyChecker) WithReport(report *core_backend.JobReport) policy.Checker {
+	p.Report = report
+	switch httpProvider := p.PolicyProvider.(type) {
+	case *DiggerHttpPolicyProvider:
+		jobProvider := *httpProvider
+		jobProvider.Report = report
+		p.PolicyProvider = &jobProvider
+	case DiggerHttpPolicyProvider:
+		httpProvider.Report = report
+		p.PolicyProvider = httpProvider
+	}
+	return p
+}
 
-	if err != nil {
-		log.Printf("Error while fetching policy: %v", err)
-		return false, err
+// ErrUndefinedDecision is returned when the policy doesn't define the queried decision
+var ErrUndefinedDecision = errors.New("no result found")
+
+// AccessPolicyInput is the input access policies are evaluated against, times are in UTC
+func AccessPolicyInput(accessContext policy.AccessPolicyContext) map[string]interface{} {
+	var planSummary map[string]interface{}
+	if accessContext.PlanSummary != nil {
+		planSummary = map[string]interface{}{
+			"resourcesCreated": accessContext.PlanSummary.ResourcesCreated,
+			"resourcesUpdated": accessContext.PlanSummary.ResourcesUpdated,
+			"resourcesDeleted": accessContext.PlanSummary.ResourcesDeleted,
+		}
 	}
-
-	teams, err := ciService.GetUserTeams(SCMOrganisation, requestedBy)
-	if err != nil {
-		log.Printf("Error while fetching user teams for CI service: %v", err)
-		log.Printf("WARNING: teams failed to be fetched, passing an empty list instead for access policy checks\n")
-		teams = []string{}
+	now := accessContext.Time.UTC()
+	return map[string]interface{}{
+		"user":                 accessContext.User,
+		"organisation":         accessContext.Organisation,
+		"teams":                accessContext.Teams,
+		"approvals":            accessContext.Approvals,
+		"planPolicyViolations": accessContext.PlanPolicyViolations,
+		"planPolicyWarnings":   accessContext.PlanPolicyWarnings,
+		"action":               accessContext.Action,
+		"project":              accessContext.Project,
+		"workspace":            accessContext.Workspace,
+		"changedFiles":         accessContext.ChangedFiles,
+		"labels":               accessContext.Labels,
+		"author":               accessContext.Author,
+		"commitSha":            accessContext.CommitSha,
+		"planSummary":          planSummary,
+		"time": map[string]interface{}{
+			"rfc3339": now.Format(time.RFC3339),
+			"unix":    now.Unix(),
+			"weekday": now.Weekday().String(),
+			"hour":    now.Hour(),
+			"minute":  now.Minute(),
+		},
 	}
+}
 
-	// list of pull request approvals (if applicable)
-	var approvals = make([]string, 0)
-	if prService != nil && prNumber != nil {
-		approvals, err = (*prService).GetApprovals(*prNumber)
+// PlanPolicyInput is the input plan policies are evaluated against, planOutput is the json output of terraform show.
+// The cost is left out when the plan could not be estimated
+func PlanPolicyInput(planOutput string, costEstimate *terraform_utils.CostEstimate) (map[string]interface{}, error) {
+	var parsedPlanOutput map[string]interface{}
+	err := json.Unmarshal([]byte(planOutput), &parsedPlanOutput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse json terraform output to map: %v", err)
 	}
-
 	input := map[string]interface{}{
-		"user":                 requestedBy,
-		"organisation":         SCMOrganisation,
-		"teams":                teams,
-		"approvals":            approvals,
-		"planPolicyViolations": planPolicyViolations,
-		"action":               command,
-		"project":              projectName,
+		"terraform": parsedPlanOutput,
+	}
+	if costEstimate != nil {
+		input["cost"] = costEstimate.ToJson()
 	}
+	return input, nil
+}
 
-	if policy == "" {
-		return true, nil
+// DriftPolicyInput is the input drift policies are evaluated against
+func DriftPolicyInput(organisation string, projectName string) map[string]interface{} {
+	return map[string]interface{}{
+		"organisation": organisation,
+		"project":      projectName,
 	}
+}
 
+// evalPolicy evaluates the query against the policy and the bundle, options are passed on to rego. No expressions are
+// returned when the query is undefined
+func evalPolicy(queryString string, policy string, policyBundle *digestedBundle, input map[string]interface{}, options ...func(*rego.Rego)) ([]*rego.ExpressionValue, error) {
 	ctx := context.Background()
-	log.Printf("DEBUG: passing the following input policy: %v ||| text: %v", input, policy)
-	query, err := rego.New(
-		rego.Query("data.digger.allow"),
-		rego.Module("digger", policy),
-	).PrepareForEval(ctx)
-
+	// policies and bundles are compiled once, tracers are passed as options and evaluated directly
+	if len(options) == 0 && (policy != "" || policyBundle != nil) {
+		query, err := preparedQueries.prepare(ctx, queryString, policy, policyBundle)
+		if err != nil {
+			return nil, err
+		}
+		results, err := query.Eval(ctx, rego.EvalInput(input))
+		if err != nil {
+			return nil, err
+		}
+		if len(results) == 0 {
+			return nil, nil
+		}
+		return results[0].Expressions, nil
+	}
+	regoOptions := []func(*rego.Rego){
+		rego.Query(queryString),
+		rego.Input(input),
+	}
+	if policy != "" {
+		regoOptions = append(regoOptions, rego.Module("digger", policy))
+	}
+	if policyBundle != nil {
+		regoOptions = append(regoOptions, rego.ParsedBundle("digger", policyBundle.bundle))
+	}
+	results, err := rego.New(append(regoOptions, options...)...).Eval(ctx)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
 	}
+	return results[0].Expressions, nil
+}
 
-	results, err := query.Eval(ctx, rego.EvalInput(input))
-	if len(results) == 0 || len(results[0].Expressions) == 0 {
-		return false, fmt.Errorf("no result found")
+func stringsResult(expressions []*rego.ExpressionValue) ([]string, error) {
+	messages := make([]string, 0)
+	for _, expression := range expressions {
+		decisions, ok := expression.Value.([]interface{})
+
+		if !ok {
+			return nil, fmt.Errorf("decision is not a slice of interfaces")
+		}
+		for _, d := range decisions {
+			message, ok := d.(string)
+			if !ok {
+				return nil, fmt.Errorf("policy message is not a string: %v", d)
+			}
+			messages = append(messages, message)
+		}
 	}
+	return messages, nil
+}
 
-	expressions := results[0].Expressions
+func evalBoolPolicy(queryString string, policy string, policyBundle *digestedBundle, input map[string]interface{}, options ...func(*rego.Rego)) (bool, error) {
+	expressions, err := evalPolicy(queryString, policy, policyBundle, input, options...)
+	if err != nil {
+		return false, err
+	}
+	if len(expressions) == 0 {
+		return false, ErrUndefinedDecision
+	}
 
 	for _, expression := range expressions {
 		decision, ok := expression.Value.(bool)
@@ -331,121 +369,257 @@ func (p DiggerPolicyChecker) CheckAccessPolicy(ciService orchestrator.OrgService
 	return true, nil
 }
 
-func (p DiggerPolicyChecker) CheckPlanPolicy(SCMrepository string, SCMOrganisation string, projectName string, planOutput string) (bool, []string, error) {
-	policy, err := p.PolicyProvider.GetPlanPolicy(SCMOrganisation, SCMrepository, projectName)
+// EvalAccessPolicy evaluates data.digger.allow
+func EvalAccessPolicy(policy string, input map[string]interface{}, options ...func(*rego.Rego)) (bool, error) {
+	return evalAccessPolicy(policy, nil, input, options...)
+}
+
+// EvalPlanPolicy evaluates data.digger.deny and returns the deny messages
+func EvalPlanPolicy(policy string, input map[string]interface{}, options ...func(*rego.Rego)) ([]string, error) {
+	return evalPlanPolicy(policy, nil, input, options...)
+}
+
+// EvalPlanPolicyWarnings evaluates data.digger.warn, warnings are advisory and optional in a policy
+func EvalPlanPolicyWarnings(policy string, input map[string]interface{}, options ...func(*rego.Rego)) ([]string, error) {
+	return evalPlanPolicyWarnings(policy, nil, input, options...)
+}
+
+// EvalDriftPolicy evaluates data.digger.enable
+func EvalDriftPolicy(policy string, input map[string]interface{}, options ...func(*rego.Rego)) (bool, error) {
+	return evalDriftPolicy(policy, nil, input, options...)
+}
+
+func evalAccessPolicy(policy string, policyBundle *digestedBundle, input map[string]interface{}, options ...func(*rego.Rego)) (bool, error) {
+	return evalBoolPolicy("data.digger.allow", policy, policyBundle, input, options...)
+}
+
+func evalPlanPolicy(policy string, policyBundle *digestedBundle, input map[string]interface{}, options ...func(*rego.Rego)) ([]string, error) {
+	expressions, err := evalPolicy("data.digger.deny", policy, policyBundle, input, options...)
 	if err != nil {
-		return false, nil, fmt.Errorf("failed get plan policy: %v", err)
+		return nil, err
 	}
-	var parsedPlanOutput map[string]interface{}
+	if len(expressions) == 0 {
+		return nil, ErrUndefinedDecision
+	}
+	return stringsResult(expressions)
+}
 
-	err = json.Unmarshal([]byte(planOutput), &parsedPlanOutput)
+func evalPlanPolicyWarnings(policy string, policyBundle *digestedBundle, input map[string]interface{}, options ...func(*rego.Rego)) ([]string, error) {
+	expressions, err := evalPolicy("data.digger.warn", policy, policyBundle, input, options...)
 	if err != nil {
-		return false, nil, fmt.Errorf("failed to parse json terraform output to map: %v", err)
+		return nil, err
 	}
+	return stringsResult(expressions)
+}
 
-	input := map[string]interface{}{
-		"terraform": parsedPlanOutput,
-	}
+func evalDriftPolicy(policy string, policyBundle *digestedBundle, input map[string]interface{}, options ...func(*rego.Rego)) (bool, error) {
+	return evalBoolPolicy("data.digger.enable", policy, policyBundle, input, options...)
+}
 
-	if policy == "" {
-		log.Printf("No plan policies found, succeeding")
-		return true, nil, nil
-	}
+func (p DiggerPolicyChecker) CheckAccessPolicy(ciService orchestrator.OrgService, prService *orchestrator.PullRequestService, SCMOrganisation string, SCMrepository string, projectName string, command string, prNumber *int, requestedBy string, planPolicyViolations []string, planPolicyWarnings []string, workspace string, planSummary *terraform_utils.PlanSummary) (bool, error) {
 
-	ctx := context.Background()
-	log.Printf("DEBUG: passing the following input policy: %v", policy)
-	query, err := rego.New(
-		rego.Query("data.digger.deny"),
-		rego.Module("digger", policy),
-	).PrepareForEval(ctx)
+	accessPolicy, err := p.PolicyProvider.GetAccessPolicy(SCMOrganisation, SCMrepository, projectName)
 
 	if err != nil {
-		return false, nil, err
+		log.Printf("Error while fetching policy: %v", err)
+		return false, err
 	}
 
-	results, err := query.Eval(ctx, rego.EvalInput(input))
-	if len(results) == 0 || len(results[0].Expressions) == 0 {
-		return false, nil, fmt.Errorf("no result found")
+	policyBundle, err := p.bundle()
+	if err != nil {
+		return false, err
+	}
+	if accessPolicy == "" && policyBundle == nil {
+		return true, nil
 	}
 
-	expressions := results[0].Expressions
+	teams, err := ciService.GetUserTeams(SCMOrganisation, requestedBy)
+	if err != nil {
+		log.Printf("Error while fetching user teams for CI service: %v", err)
+		log.Printf("WARNING: teams failed to be fetched, passing an empty list instead for access policy checks\n")
+		teams = []string{}
+	}
 
-	decisionsResult := make([]string, 0)
-	for _, expression := range expressions {
-		decisions, ok := expression.Value.([]interface{})
+	accessContext := policy.AccessPolicyContext{
+		User:                 requestedBy,
+		Organisation:         SCMOrganisation,
+		Teams:                teams,
+		Approvals:            make([]string, 0),
+		PlanPolicyViolations: planPolicyViolations,
+		PlanPolicyWarnings:   planPolicyWarnings,
+		Action:               command,
+		Project:              projectName,
+		Workspace:            workspace,
+		ChangedFiles:         make([]string, 0),
+		Labels:               make([]string, 0),
+		PlanSummary:          planSummary,
+		Time:                 time.Now(),
+	}
 
-		if !ok {
-			return false, nil, fmt.Errorf("decision is not a slice of interfaces")
+	// pull request details (if applicable)
+	if prService != nil && prNumber != nil {
+		accessContext.Approvals, err = (*prService).GetApprovals(*prNumber)
+		if err != nil {
+			log.Printf("WARNING: approvals failed to be fetched, passing an empty list instead for access policy checks: %v", err)
+			accessContext.Approvals = make([]string, 0)
+		}
+		accessContext.ChangedFiles, err = (*prService).GetChangedFiles(*prNumber)
+		if err != nil {
+			log.Printf("WARNING: changed files failed to be fetched, passing an empty list instead for access policy checks: %v", err)
+			accessContext.ChangedFiles = make([]string, 0)
 		}
-		if len(decisions) > 0 {
-			for _, d := range decisions {
-				decisionsResult = append(decisionsResult, d.(string))
-				log.Printf("denied: %v\n", d)
+		details, err := (*prService).GetPullRequestDetails(*prNumber)
+		if err != nil {
+			log.Printf("WARNING: pull request details failed to be fetched, passing no labels, author or commit sha for access policy checks: %v", err)
+		} else {
+			if details.Labels != nil {
+				accessContext.Labels = details.Labels
 			}
-
+			accessContext.Author = details.Author
+			accessContext.CommitSha = details.CommitSha
 		}
+	}
+
+	input := AccessPolicyInput(accessContext)
+	log.Printf("DEBUG: passing the following input policy: %v ||| text: %v", input, accessPolicy)
+	allowed, err := evalAccessPolicy(accessPolicy, policyBundle, input)
+	if policyBundle != nil && errors.Is(err, ErrUndefinedDecision) {
+		allowed, err = undefinedBundleDecision(policyBundle, "allow")
+	}
+	p.recordDecision(core_backend.PolicyDecision{
+		PolicyType: policy.PolicyTypeAccess,
+		Project:    projectName,
+		PrNumber:   prNumber,
+		Input:      input,
+		Allowed:    allowed,
+		Error:      errorMessage(err),
+	}, accessPolicy)
+	return allowed, err
+}
+
+// CheckPlanPolicy returns whether the plan is allowed along with the deny messages and the advisory warn messages
+func (p DiggerPolicyChecker) CheckPlanPolicy(SCMrepository string, SCMOrganisation string, projectName string, planOutput string, costEstimate *terraform_utils.CostEstimate) (bool, []string, []string, error) {
+	planPolicy, err := p.PolicyProvider.GetPlanPolicy(SCMOrganisation, SCMrepository, projectName)
+	if err != nil {
+		return false, nil, nil, fmt.Errorf("failed get plan policy: %v", err)
+	}
+
+	input, err := PlanPolicyInput(planOutput, costEstimate)
+	if err != nil {
+		return false, nil, nil, err
+	}
+
+	policyBundle, err := p.bundle()
+	if err != nil {
+		return false, nil, nil, err
+	}
+	if planPolicy == "" && policyBundle == nil {
+		log.Printf("No plan policies found, succeeding")
+		return true, nil, nil, nil
+	}
 
+	log.Printf("DEBUG: passing the following input policy: %v", planPolicy)
+	decisions, err := evalPlanPolicy(planPolicy, policyBundle, input)
+	if policyBundle != nil && errors.Is(err, ErrUndefinedDecision) && !policyBundle.definesRule("deny") {
+		decisions, err = []string{}, nil
+	}
+	if err != nil {
+		p.recordDecision(core_backend.PolicyDecision{PolicyType: policy.PolicyTypePlan, Project: projectName, Input: input, Error: err.Error()}, planPolicy)
+		return false, nil, nil, err
+	}
+	for _, d := range decisions {
+		log.Printf("denied: %v\n", d)
 	}
 
-	if len(decisionsResult) > 0 {
-		return false, decisionsResult, nil
+	warnings, err := evalPlanPolicyWarnings(planPolicy, policyBundle, input)
+	if err != nil {
+		p.recordDecision(core_backend.PolicyDecision{PolicyType: policy.PolicyTypePlan, Project: projectName, Input: input, Messages: decisions, Error: err.Error()}, planPolicy)
+		return false, nil, nil, err
+	}
+	for _, w := range warnings {
+		log.Printf("warning: %v\n", w)
+	}
+	p.recordDecision(core_backend.PolicyDecision{
+		PolicyType: policy.PolicyTypePlan,
+		Project:    projectName,
+		Input:      input,
+		Allowed:    len(decisions) == 0,
+		Messages:   decisions,
+		Warnings:   warnings,
+	}, planPolicy)
+
+	if len(decisions) > 0 {
+		return false, decisions, warnings, nil
 	}
 
-	return true, []string{}, nil
+	return true, []string{}, warnings, nil
 }
 
 func (p DiggerPolicyChecker) CheckDriftPolicy(SCMOrganisation string, SCMrepository string, projectName string) (bool, error) {
 	// TODO: Get rid of organisation if its not needed
 	//organisation := p.PolicyProvider.GetOrganisation()
-	policy, err := p.PolicyProvider.GetDriftPolicy()
+	driftPolicy, err := p.PolicyProvider.GetDriftPolicy()
 	if err != nil {
 		log.Printf("Error while fetching drift policy: %v", err)
 		return false, err
 	}
 
-	input := map[string]interface{}{
-		"organisation": SCMOrganisation,
-		"project":      projectName,
-	}
-
-	if policy == "" {
-		return true, nil
-	}
-
-	ctx := context.Background()
-	log.Printf("DEBUG: passing the following input policy: %v ||| text: %v", input, policy)
-	query, err := rego.New(
-		rego.Query("data.digger.enable"),
-		rego.Module("digger", policy),
-	).PrepareForEval(ctx)
+	input := DriftPolicyInput(SCMOrganisation, projectName)
 
+	policyBundle, err := p.bundle()
 	if err != nil {
 		return false, err
 	}
-
-	results, err := query.Eval(ctx, rego.EvalInput(input))
-	if len(results) == 0 || len(results[0].Expressions) == 0 {
-		return false, fmt.Errorf("no result found")
+	if driftPolicy == "" && policyBundle == nil {
+		return true, nil
 	}
 
-	expressions := results[0].Expressions
-
-	for _, expression := range expressions {
-		decision, ok := expression.Value.(bool)
-		if !ok {
-			return false, fmt.Errorf("decision is not a boolean")
-		}
-		if !decision {
-			return false, nil
-		}
+	log.Printf("DEBUG: passing the following input policy: %v ||| text: %v", input, driftPolicy)
+	enabled, err := evalDriftPolicy(driftPolicy, policyBundle, input)
+	if policyBundle != nil && errors.Is(err, ErrUndefinedDecision) {
+		enabled, err = undefinedBundleDecision(policyBundle, "enable")
 	}
+	p.recordDecision(core_backend.PolicyDecision{
+		PolicyType: policy.PolicyTypeDrift,
+		Project:    projectName,
+		Input:      input,
+		Allowed:    enabled,
+		Error:      errorMessage(err),
+	}, driftPolicy)
+	return enabled, err
+}
 
+// undefinedBundleDecision resolves a decision that is undefined for the input. Bundles without the rule don't take the
+// decision and pass it, a rule of the bundle that is undefined for the input fails closed like in OPA without a default
+func undefinedBundleDecision(policyBundle *digestedBundle, rule string) (bool, error) {
+	if policyBundle.definesRule(rule) {
+		return false, nil
+	}
 	return true, nil
 }
 
+// bundle returns the bundle of bundle providers, policies are evaluated with the whole bundle and a bundle doesn't
+// have to define every decision
+func (p DiggerPolicyChecker) bundle() (*digestedBundle, error) {
+	bundleProvider, ok := p.PolicyProvider.(BundleProvider)
+	if !ok {
+		return nil, nil
+	}
+	policyBundle, err := bundleProvider.GetBundle()
+	if err != nil {
+		log.Printf("Error while loading policy bundle: %v", err)
+		return nil, err
+	}
+	return newDigestedBundle(policyBundle)
+}
+
 func NewPolicyChecker(hostname string, organisationName string, authToken string) policy.Checker {
 	var policyChecker policy.Checker
-	if os.Getenv("NO_BACKEND") == "true" {
+	if bundleSource := os.Getenv("DIGGER_POLICY_BUNDLE"); bundleSource != "" {
+		policyChecker = DiggerPolicyChecker{
+			PolicyProvider: NewBundlePolicyProvider(bundleSource, organisationName),
+		}
+	} else if os.Getenv("NO_BACKEND") == "true" {
 		log.Println("WARNING: running in 'backendless' mode. Features that require backend will not be available.")
 		policyChecker = NoOpPolicyChecker{}
 	} else {
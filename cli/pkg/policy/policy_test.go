@@ -2,9 +2,12 @@ package policy
 
 import (
 	"testing"
+	"time"
 
 	"github.com/diggerhq/digger/cli/pkg/core/policy"
 	"github.com/diggerhq/digger/cli/pkg/utils"
+	"github.com/diggerhq/digger/libs/orchestrator"
+	"github.com/diggerhq/digger/libs/terraform_utils"
 )
 
 type OpaExamplePolicyProvider struct {
@@ -223,7 +226,7 @@ func TestDiggerAccessPolicyChecker_Check(t *testing.T) {
 				PolicyProvider: tt.fields.PolicyProvider,
 			}
 			ciService := utils.MockPullRequestManager{Teams: []string{"engineering"}}
-			got, err := p.CheckAccessPolicy(ciService, nil, tt.organisation, tt.name, tt.name, tt.command, nil, tt.requestedBy, tt.planPolicyViolations)
+			got, err := p.CheckAccessPolicy(ciService, nil, tt.organisation, tt.name, tt.name, tt.command, nil, tt.requestedBy, tt.planPolicyViolations, nil, "", nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("DiggerPolicyChecker.CheckAccessPolicy() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -275,7 +278,7 @@ func TestDiggerPlanPolicyChecker_Check(t *testing.T) {
 			var p = &DiggerPolicyChecker{
 				PolicyProvider: tt.fields.PolicyProvider,
 			}
-			got, _, err := p.CheckPlanPolicy("", "", "", tt.planJsonOutput)
+			got, _, _, err := p.CheckPlanPolicy("", "", "", tt.planJsonOutput, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("DiggerPolicyChecker.CheckPlanPolicy() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -286,3 +289,193 @@ func TestDiggerPlanPolicyChecker_Check(t *testing.T) {
 		})
 	}
 }
+
+type DiggerWarningPolicyProvider struct {
+	DiggerDefaultPolicyProvider
+}
+
+func (s *DiggerWarningPolicyProvider) GetAccessPolicy(_ string, _ string, _ string) (string, error) {
+	return "package digger\n\ndefault allow = false\nallow {\n  count(input.planPolicyWarnings) < 2\n}\n", nil
+}
+
+func (s *DiggerWarningPolicyProvider) GetPlanPolicy(_ string, _ string, _ string) (string, error) {
+	return "package digger\n\ndeny[msg] {\n  resource := input.terraform.resource_changes[_]\n  resource.change.actions[_] == \"delete\"\n  msg := sprintf(\"%v is deleted\", [resource.address])\n}\n\nwarn[msg] {\n  resource := input.terraform.resource_changes[_]\n  not resource.change.after.tags\n  msg := sprintf(\"%v has no tags\", [resource.address])\n}\n", nil
+}
+
+func TestDiggerPlanPolicyCheckerWarnings(t *testing.T) {
+	p := &DiggerPolicyChecker{PolicyProvider: &DiggerWarningPolicyProvider{}}
+
+	allowed, violations, warnings, err := p.CheckPlanPolicy("", "", "", `{"resource_changes": [{"address": "aws_s3_bucket.a", "change": {"actions": ["create"], "after": {}}}]}`, nil)
+	if err != nil || !allowed || len(violations) != 0 {
+		t.Fatalf("expected warnings not to block the plan, got allowed %v, violations %v, error %v", allowed, violations, err)
+	}
+	if len(warnings) != 1 || warnings[0] != "aws_s3_bucket.a has no tags" {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+
+	allowed, violations, warnings, err = p.CheckPlanPolicy("", "", "", `{"resource_changes": [{"address": "aws_s3_bucket.a", "change": {"actions": ["delete"], "after": {}}}]}`, nil)
+	if err != nil || allowed || len(violations) != 1 || len(warnings) != 1 {
+		t.Errorf("expected a violation and a warning, got allowed %v, violations %v, warnings %v, error %v", allowed, violations, warnings, err)
+	}
+
+	// warn is optional in plan policies
+	_, _, warnings, err = DiggerPolicyChecker{PolicyProvider: &DiggerExamplePolicyProvider2{}}.CheckPlanPolicy("", "", "", `{"resource_changes": []}`, nil)
+	if err != nil || len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %v, error %v", warnings, err)
+	}
+
+	ciService := utils.MockPullRequestManager{Teams: []string{}}
+	allowed, err = p.CheckAccessPolicy(ciService, nil, "", "", "", "digger apply", nil, "motatoes", []string{}, []string{"a", "b"}, "", nil)
+	if err != nil || allowed {
+		t.Errorf("expected planPolicyWarnings to be passed to the access policy, got allowed %v, error %v", allowed, err)
+	}
+}
+
+func TestAccessPolicyInputDetails(t *testing.T) {
+	accessPolicy := `package digger
+
+default allow = false
+
+allow {
+	input.labels[_] == "infra-approved"
+	input.author == "motatoes"
+	input.commitSha == ""
+	input.workspace == "prod"
+	input.planSummary.resourcesDeleted == 0
+	not changes_outside_modules
+	input.time.hour >= 0
+}
+
+changes_outside_modules {
+	file := input.changedFiles[_]
+	not startswith(file, "modules/")
+}
+`
+	p := DiggerPolicyChecker{PolicyProvider: &staticAccessPolicyProvider{policy: accessPolicy}}
+	prManager := &utils.MockPullRequestManager{
+		Labels:       []string{"infra-approved"},
+		Author:       "motatoes",
+		ChangedFiles: []string{"modules/vpc/main.tf"},
+	}
+	var prService orchestrator.PullRequestService = prManager
+	prNumber := 1
+
+	allowed, err := p.CheckAccessPolicy(prManager, &prService, "diggerhq", "demo", "prod", "digger apply", &prNumber, "motatoes", []string{}, []string{}, "prod", &terraform_utils.PlanSummary{ResourcesCreated: 1})
+	if err != nil || !allowed {
+		t.Errorf("expected apply to be allowed, got %v, error %v", allowed, err)
+	}
+
+	allowed, err = p.CheckAccessPolicy(prManager, &prService, "diggerhq", "demo", "prod", "digger apply", &prNumber, "motatoes", []string{}, []string{}, "prod", &terraform_utils.PlanSummary{ResourcesDeleted: 1})
+	if err != nil || allowed {
+		t.Errorf("expected apply of a plan that deletes resources to be denied, got %v, error %v", allowed, err)
+	}
+
+	allowed, err = p.CheckAccessPolicy(prManager, &prService, "diggerhq", "demo", "prod", "digger apply", &prNumber, "motatoes", []string{}, []string{}, "prod", nil)
+	if err != nil || allowed {
+		t.Errorf("expected apply without a known plan to be denied, got %v, error %v", allowed, err)
+	}
+}
+
+// countingPullRequestManager counts the requests an access check makes for the pull request
+type countingPullRequestManager struct {
+	utils.MockPullRequestManager
+	detailsRequests int
+	branchRequests  int
+}
+
+func (c *countingPullRequestManager) GetPullRequestDetails(prNumber int) (*orchestrator.PullRequestDetails, error) {
+	c.detailsRequests++
+	return &orchestrator.PullRequestDetails{Labels: c.Labels, Author: c.Author, CommitSha: "abc123"}, nil
+}
+
+func (c *countingPullRequestManager) GetBranchName(prNumber int) (string, string, error) {
+	c.branchRequests++
+	return "", "", nil
+}
+
+func TestAccessPolicyReadsPullRequestOnce(t *testing.T) {
+	accessPolicy := `package digger
+
+allow {
+	input.labels[_] == "infra-approved"
+	input.author == "motatoes"
+	input.commitSha == "abc123"
+}
+`
+	p := DiggerPolicyChecker{PolicyProvider: &staticAccessPolicyProvider{policy: accessPolicy}}
+	prManager := &countingPullRequestManager{MockPullRequestManager: utils.MockPullRequestManager{Labels: []string{"infra-approved"}, Author: "motatoes"}}
+	var prService orchestrator.PullRequestService = prManager
+	prNumber := 1
+
+	allowed, err := p.CheckAccessPolicy(prManager, &prService, "diggerhq", "demo", "prod", "digger apply", &prNumber, "motatoes", []string{}, []string{}, "prod", nil)
+	if err != nil || !allowed {
+		t.Errorf("expected apply to be allowed, got %v, error %v", allowed, err)
+	}
+	if prManager.detailsRequests != 1 || prManager.branchRequests != 0 {
+		t.Errorf("expected the pull request to be read once, got %v details and %v branch requests", prManager.detailsRequests, prManager.branchRequests)
+	}
+}
+
+func TestAccessPolicyInputTime(t *testing.T) {
+	input := AccessPolicyInput(policy.AccessPolicyContext{Time: time.Date(2024, 6, 20, 9, 30, 0, 0, time.FixedZone("CEST", 2*60*60))})
+	inputTime := input["time"].(map[string]interface{})
+	if inputTime["weekday"] != "Thursday" || inputTime["hour"] != 7 || inputTime["minute"] != 30 || inputTime["rfc3339"] != "2024-06-20T07:30:00Z" {
+		t.Errorf("unexpected time input %v", inputTime)
+	}
+	if planSummary := input["planSummary"].(map[string]interface{}); planSummary != nil {
+		t.Errorf("expected no plan summary, got %v", input["planSummary"])
+	}
+}
+
+type staticAccessPolicyProvider struct {
+	DiggerDefaultPolicyProvider
+	policy string
+}
+
+func (s *staticAccessPolicyProvider) GetAccessPolicy(_ string, _ string, _ string) (string, error) {
+	return s.policy, nil
+}
+
+type costPlanPolicyProvider struct {
+	DiggerDefaultPolicyProvider
+}
+
+func (s *costPlanPolicyProvider) GetPlanPolicy(_ string, _ string, _ string) (string, error) {
+	return "package digger\n\ndeny[msg] {\n  input.cost.monthly_cost_delta > 50\n  msg := sprintf(\"monthly cost increases by %v %v\", [input.cost.monthly_cost_delta, input.cost.currency])\n}\n", nil
+}
+
+func TestPlanPolicyInputCost(t *testing.T) {
+	p := &DiggerPolicyChecker{PolicyProvider: &costPlanPolicyProvider{}}
+
+	checkPlanPolicy := func(planJson string) (bool, []string, []string, error) {
+		costEstimate, err := terraform_utils.GetCostEstimate(planJson)
+		if err != nil {
+			t.Fatalf("could not estimate the cost of the plan: %v", err)
+		}
+		return p.CheckPlanPolicy("", "", "", planJson, costEstimate)
+	}
+
+	allowed, violations, _, err := checkPlanPolicy(`{"format_version": "1.2", "resource_changes": [{"address": "aws_instance.a", "type": "aws_instance", "change": {"actions": ["create"], "after": {"instance_type": "t3.micro"}}}]}`)
+	if err != nil || !allowed {
+		t.Fatalf("expected a cheap plan to be allowed, got allowed %v, violations %v, error %v", allowed, violations, err)
+	}
+
+	allowed, violations, _, err = checkPlanPolicy(`{"format_version": "1.2", "resource_changes": [{"address": "aws_instance.a", "type": "aws_instance", "change": {"actions": ["create"], "after": {"instance_type": "m5.xlarge"}}}]}`)
+	if err != nil || allowed || len(violations) != 1 || violations[0] != "monthly cost increases by 140.16 USD" {
+		t.Errorf("expected the cost policy to deny the plan, got allowed %v, violations %v, error %v", allowed, violations, err)
+	}
+}
+
+func TestPlanPolicyInputUsesGivenCostEstimate(t *testing.T) {
+	planJson := `{"format_version": "1.2", "resource_changes": [{"address": "aws_instance.a", "type": "aws_instance", "change": {"actions": ["create"], "after": {"instance_type": "m5.xlarge"}}}]}`
+	input, err := PlanPolicyInput(planJson, nil)
+	if err != nil || input["cost"] != nil {
+		t.Fatalf("expected no cost without an estimate, got %v, error %v", input["cost"], err)
+	}
+
+	estimate := &terraform_utils.CostEstimate{Currency: "EUR", MonthlyCostDelta: 1}
+	input, err = PlanPolicyInput(planJson, estimate)
+	if err != nil || input["cost"].(map[string]interface{})["currency"] != "EUR" {
+		t.Errorf("expected the cost of the given estimate, got %v, error %v", input["cost"], err)
+	}
+}
@@ -2,16 +2,21 @@ package spec
 
 import (
 	"fmt"
+	"github.com/diggerhq/digger/cli/pkg/core/backend"
+	"github.com/diggerhq/digger/cli/pkg/core/storage"
 	"github.com/diggerhq/digger/cli/pkg/digger"
 	storage2 "github.com/diggerhq/digger/cli/pkg/storage"
 	"github.com/diggerhq/digger/cli/pkg/usage"
 	comment_summary "github.com/diggerhq/digger/libs/comment_utils/summary"
 	"github.com/diggerhq/digger/libs/digger_config"
 	"github.com/diggerhq/digger/libs/orchestrator"
-	orchestrator_github "github.com/diggerhq/digger/libs/orchestrator/github"
 	"github.com/diggerhq/digger/libs/spec"
+	"github.com/diggerhq/digger/libs/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"log"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -65,12 +70,27 @@ func RunSpec(
 		usage.ReportErrorAndExit(spec.VCS.Actor, fmt.Sprintf("could not get comment updater: %v", err), 8)
 	}
 
-	planStorage := storage2.NewPlanStorage("", "", "", "", nil)
+	// the job token of the spec lets the job store plans in the backend without credentials of its own
+	var planStorage storage.PlanStorage
+	if strings.ToLower(os.Getenv("PLAN_UPLOAD_DESTINATION")) == "backend" {
+		if spec.Backend.BackendHostname == "" {
+			usage.ReportErrorAndExit(spec.VCS.Actor, "the spec has no backend hostname to store plans in", 9)
+		}
+		planStorage, err = storage2.WithPlanEnvelopeFromEnv(storage2.NewBackendPlanStorage(spec.Backend.BackendHostname, spec.Backend.BackendJobToken, fmt.Sprintf("%v-%v", spec.VCS.RepoOwner, spec.VCS.RepoName)))
+		if err != nil {
+			usage.ReportErrorAndExit(spec.VCS.Actor, fmt.Sprintf("could not configure plan encryption: %v", err), 9)
+		}
+	} else {
+		planStorage = storage2.NewPlanStorage("", "", "", "", nil)
+	}
 
+	spanCtx, span := tracing.StartSpan(job.TraceParent, "RunSpec", attribute.String("digger.project", job.ProjectName))
+	job.TraceParent = tracing.TraceParent(spanCtx)
 	jobs := []orchestrator.Job{job}
+	jobReport := backend.NewJobReport()
 
 	fullRepoName := fmt.Sprintf("%v-%v", spec.VCS.RepoOwner, spec.VCS.RepoName)
-	_, err = backendApi.ReportProjectJobStatus(fullRepoName, spec.Job.ProjectName, spec.JobId, "started", time.Now(), nil, "", "")
+	_, err = backendApi.ReportProjectJobStatus(fullRepoName, spec.Job.ProjectName, spec.JobId, "started", time.Now(), nil, "", "", nil)
 	if err != nil {
 		usage.ReportErrorAndExit(spec.VCS.Actor, fmt.Sprintf("Failed to report jobSpec status to backend. Exiting. %v", err), 4)
 	}
@@ -80,11 +100,14 @@ func RunSpec(
 		usage.ReportErrorAndExit(spec.VCS.Actor, fmt.Sprintf("failed to get comment ID: %v", err), 4)
 	}
 
-	// TODO: do not require conversion to gh service
-	ghService := prService.(orchestrator_github.GithubService)
-	allAppliesSuccess, _, err := digger.RunJobs(jobs, prService, ghService, lock, reporter, planStorage, policyChecker, commentUpdater, backendApi, spec.JobId, true, false, commentId64, "")
+	orgService, ok := prService.(orchestrator.OrgService)
+	if !ok {
+		usage.ReportErrorAndExit(spec.VCS.Actor, fmt.Sprintf("%v does not support looking up user teams", spec.VCS.VcsType), 1)
+	}
+	allAppliesSuccess, _, err := digger.RunJobs(jobs, prService, orgService, lock, reporter, planStorage, policyChecker, commentUpdater, backendApi, spec.JobId, jobReport, true, false, commentId64, "")
+	tracing.EndSpan(span, err)
 	if !allAppliesSuccess || err != nil {
-		serializedBatch, reportingError := backendApi.ReportProjectJobStatus(spec.VCS.RepoName, spec.Job.ProjectName, spec.JobId, "failed", time.Now(), nil, "", "")
+		serializedBatch, reportingError := backendApi.ReportProjectJobStatus(spec.VCS.RepoName, spec.Job.ProjectName, spec.JobId, "failed", time.Now(), nil, "", "", jobReport)
 		if reportingError != nil {
 			usage.ReportErrorAndExit(spec.VCS.RepoOwner, fmt.Sprintf("Failed run commands. %s", err), 5)
 		}
@@ -9,12 +9,14 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
+	"github.com/diggerhq/digger/cli/pkg/core/storage"
 )
 
 type S3Client interface {
@@ -22,6 +24,7 @@ type S3Client interface {
 	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
 	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
 	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
 }
 
 type PlanStorageAWS struct {
@@ -104,11 +107,44 @@ func (psa *PlanStorageAWS) DeleteStoredPlan(artifactName, storedPlanFilePath str
 	return nil
 }
 
+// ListPlans returns the .tfplan objects of the bucket
+func (psa *PlanStorageAWS) ListPlans() ([]storage.StoredPlan, error) {
+	var plans []storage.StoredPlan
+	paginator := s3.NewListObjectsV2Paginator(psa.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(psa.Bucket),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(psa.Context)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list objects of bucket %v: %v", psa.Bucket, err)
+		}
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			if !strings.HasSuffix(key, ".tfplan") {
+				continue
+			}
+			plans = append(plans, storage.StoredPlan{Path: key, LastModified: aws.ToTime(object.LastModified)})
+		}
+	}
+	return plans, nil
+}
+
 func GetAWSStorageClient() (context.Context, *s3.Client, error) {
 	ctx := context.Background()
 	sdkConfig, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
 		return ctx, nil, err
 	}
-	return ctx, s3.NewFromConfig(sdkConfig), nil
+	return ctx, s3.NewFromConfig(sdkConfig, s3OptionsFromEnv), nil
+}
+
+// s3OptionsFromEnv points the client at an S3 compatible service such as MinIO, Ceph or R2 when AWS_S3_ENDPOINT is set,
+// AWS_S3_FORCE_PATH_STYLE=true puts the bucket in the path instead of the host name
+func s3OptionsFromEnv(o *s3.Options) {
+	if endpoint := os.Getenv("AWS_S3_ENDPOINT"); endpoint != "" {
+		o.BaseEndpoint = aws.String(endpoint)
+	}
+	if os.Getenv("AWS_S3_FORCE_PATH_STYLE") == "true" {
+		o.UsePathStyle = true
+	}
 }
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/diggerhq/digger/cli/pkg/backend"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PlanStorageBackend keeps plans in the digger backend, which stores them per organisation of the token and per Repo,
+// the digger repo name in the form owner-repo
+type PlanStorageBackend struct {
+	Hostname   string
+	Token      string
+	Repo       string
+	HttpClient *http.Client
+}
+
+func NewBackendPlanStorage(hostname string, token string, repo string) *PlanStorageBackend {
+	return &PlanStorageBackend{
+		Hostname:   hostname,
+		Token:      token,
+		Repo:       repo,
+		HttpClient: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+func (psb *PlanStorageBackend) request(method string, artifactName string, storedPlanFilePath string, body []byte) (*http.Response, error) {
+	u, err := url.Parse(psb.Hostname)
+	if err != nil {
+		return nil, fmt.Errorf("not able to parse digger backend url: %v", err)
+	}
+	u = u.JoinPath("repos", psb.Repo, "plans", storedPlanFilePath)
+	if artifactName != "" {
+		u.RawQuery = url.Values{"artifact": {artifactName}}.Encode()
+	}
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error while creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	authToken, err := backend.ResolveAuthToken(psb.Token)
+	if err != nil {
+		return nil, fmt.Errorf("could not get auth token: %v", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", authToken))
+
+	resp, err := psb.HttpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error while sending request: %v", err)
+	}
+	return resp, nil
+}
+
+func unexpectedStatus(action string, resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	return fmt.Errorf("unexpected status when %v: %v %v", action, resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+func (psb *PlanStorageBackend) StorePlanFile(fileContents []byte, artifactName string, storedPlanFilePath string) error {
+	resp, err := psb.request("PUT", artifactName, storedPlanFilePath, fileContents)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return unexpectedStatus("storing plan", resp)
+	}
+	return nil
+}
+
+func (psb *PlanStorageBackend) RetrievePlan(localPlanFilePath string, artifactName string, storedPlanFilePath string) (*string, error) {
+	resp, err := psb.request("GET", artifactName, storedPlanFilePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, unexpectedStatus("retrieving plan", resp)
+	}
+
+	file, err := os.Create(localPlanFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create file: %v", err)
+	}
+	defer file.Close()
+
+	if _, err = io.Copy(file, resp.Body); err != nil {
+		return nil, fmt.Errorf("unable to write data to file: %v", err)
+	}
+	fileName, err := filepath.Abs(file.Name())
+	if err != nil {
+		return nil, fmt.Errorf("unable to get absolute path for file: %v", err)
+	}
+	return &fileName, nil
+}
+
+func (psb *PlanStorageBackend) DeleteStoredPlan(artifactName string, storedPlanFilePath string) error {
+	resp, err := psb.request("DELETE", artifactName, storedPlanFilePath, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return unexpectedStatus("deleting plan", resp)
+	}
+	return nil
+}
+
+func (psb *PlanStorageBackend) PlanExists(artifactName string, storedPlanFilePath string) (bool, error) {
+	resp, err := psb.request("HEAD", artifactName, storedPlanFilePath, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, unexpectedStatus("checking plan", resp)
+	}
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakePlanBackend struct {
+	mu        sync.Mutex
+	plans     map[string][]byte
+	artifacts map[string]string
+}
+
+func (f *fakePlanBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer cli:token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api/repos/diggerhq-demo/plans/")
+	switch r.Method {
+	case "PUT":
+		data, _ := io.ReadAll(r.Body)
+		f.plans[path] = data
+		f.artifacts[path] = r.URL.Query().Get("artifact")
+		w.WriteHeader(http.StatusOK)
+	case "GET", "HEAD":
+		data, ok := f.plans[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.Method == "GET" {
+			w.Write(data)
+		}
+	case "DELETE":
+		delete(f.plans, path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestPlanStorageBackend(t *testing.T) {
+	backend := &fakePlanBackend{plans: map[string][]byte{}, artifacts: map[string]string{}}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+	planStorage := NewBackendPlanStorage(server.URL+"/api", "cli:token", "diggerhq-demo")
+
+	exists, err := planStorage.PlanExists("plan-prod", "diggerhq-demo-3-prod.tfplan")
+	require.NoError(t, err)
+	require.False(t, exists)
+	_, err = planStorage.RetrievePlan(filepath.Join(t.TempDir(), "plan"), "plan-prod", "diggerhq-demo-3-prod.tfplan")
+	require.Error(t, err)
+
+	require.NoError(t, planStorage.StorePlanFile([]byte("plan"), "plan-prod", "diggerhq-demo-3-prod.tfplan"))
+	require.Equal(t, "plan-prod", backend.artifacts["diggerhq-demo-3-prod.tfplan"])
+	exists, err = planStorage.PlanExists("plan-prod", "diggerhq-demo-3-prod.tfplan")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	localPath := filepath.Join(t.TempDir(), "plan")
+	retrieved, err := planStorage.RetrievePlan(localPath, "plan-prod", "diggerhq-demo-3-prod.tfplan")
+	require.NoError(t, err)
+	require.Equal(t, localPath, *retrieved)
+	data, err := os.ReadFile(localPath)
+	require.NoError(t, err)
+	require.Equal(t, "plan", string(data))
+
+	require.NoError(t, planStorage.DeleteStoredPlan("plan-prod", "diggerhq-demo-3-prod.tfplan"))
+	exists, err = planStorage.PlanExists("plan-prod", "diggerhq-demo-3-prod.tfplan")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	planStorage.Token = "cli:wrong"
+	require.Error(t, planStorage.StorePlanFile([]byte("plan"), "plan-prod", "diggerhq-demo-3-prod.tfplan"))
+	_, err = planStorage.PlanExists("plan-prod", "diggerhq-demo-3-prod.tfplan")
+	require.Error(t, err)
+}
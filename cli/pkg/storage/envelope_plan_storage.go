@@ -0,0 +1,292 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"filippo.io/age"
+	"fmt"
+	"github.com/diggerhq/digger/cli/pkg/core/storage"
+	"io"
+	"os"
+	"strings"
+)
+
+const planEnvelopeVersion = 1
+
+// PlanCipher encrypts plans before they are stored, additionalData is authenticated along with the plan where supported
+type PlanCipher interface {
+	Name() string
+	Encrypt(plaintext []byte, additionalData []byte) ([]byte, error)
+	Decrypt(ciphertext []byte, additionalData []byte) ([]byte, error)
+}
+
+type AesGcmCipher struct {
+	Key []byte
+}
+
+func (a AesGcmCipher) Name() string {
+	return "aes-256-gcm"
+}
+
+func (a AesGcmCipher) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(a.Key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt prepends the random nonce to the ciphertext
+func (a AesGcmCipher) Encrypt(plaintext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := a.aead()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func (a AesGcmCipher) Decrypt(ciphertext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := a.aead()
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, additionalData)
+}
+
+// AgeCipher encrypts to age recipients, Identities are only needed to decrypt
+type AgeCipher struct {
+	Recipients []age.Recipient
+	Identities []age.Identity
+}
+
+func (a AgeCipher) Name() string {
+	return "age"
+}
+
+func (a AgeCipher) Encrypt(plaintext []byte, additionalData []byte) ([]byte, error) {
+	if len(a.Recipients) == 0 {
+		return nil, fmt.Errorf("no age recipients to encrypt the plan to")
+	}
+	var out bytes.Buffer
+	w, err := age.Encrypt(&out, a.Recipients...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (a AgeCipher) Decrypt(ciphertext []byte, additionalData []byte) ([]byte, error) {
+	if len(a.Identities) == 0 {
+		return nil, fmt.Errorf("no age identity to decrypt the plan with")
+	}
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), a.Identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+type planEnvelope struct {
+	Version        int    `json:"version"`
+	ArtifactName   string `json:"artifact_name"`
+	StoredPlanPath string `json:"stored_plan_path"`
+	Encryption     string `json:"encryption"`
+	PlanSha256     string `json:"plan_sha256"`
+	Payload        []byte `json:"payload"`
+	Signature      []byte `json:"signature"`
+}
+
+// signedContent binds the plan digest to the job the plan was made for, so a plan can't be applied for another project or pull request
+func (e planEnvelope) signedContent() []byte {
+	return []byte(fmt.Sprintf("digger-plan-v%v\n%v\n%v\n%v\n%v", e.Version, e.ArtifactName, e.StoredPlanPath, e.Encryption, e.PlanSha256))
+}
+
+// EnvelopePlanStorage signs, and optionally encrypts, plans before handing them to the wrapped storage
+// and refuses to return plans that fail verification
+type EnvelopePlanStorage struct {
+	PlanStorage storage.PlanStorage
+	// Cipher of nil stores plans signed but unencrypted
+	Cipher     PlanCipher
+	SigningKey []byte
+}
+
+func (e *EnvelopePlanStorage) sign(envelope planEnvelope) []byte {
+	mac := hmac.New(sha256.New, e.SigningKey)
+	mac.Write(envelope.signedContent())
+	return mac.Sum(nil)
+}
+
+func (e *EnvelopePlanStorage) StorePlanFile(fileContents []byte, artifactName string, storedPlanFilePath string) error {
+	digest := sha256.Sum256(fileContents)
+	envelope := planEnvelope{
+		Version:        planEnvelopeVersion,
+		ArtifactName:   artifactName,
+		StoredPlanPath: storedPlanFilePath,
+		Encryption:     "none",
+		PlanSha256:     hex.EncodeToString(digest[:]),
+		Payload:        fileContents,
+	}
+	if e.Cipher != nil {
+		envelope.Encryption = e.Cipher.Name()
+		payload, err := e.Cipher.Encrypt(fileContents, envelope.signedContent())
+		if err != nil {
+			return fmt.Errorf("could not encrypt plan: %v", err)
+		}
+		envelope.Payload = payload
+	}
+	envelope.Signature = e.sign(envelope)
+
+	contents, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("could not marshal plan envelope: %v", err)
+	}
+	return e.PlanStorage.StorePlanFile(contents, artifactName, storedPlanFilePath)
+}
+
+func (e *EnvelopePlanStorage) open(contents []byte, artifactName string, storedPlanFilePath string) ([]byte, error) {
+	var envelope planEnvelope
+	if err := json.Unmarshal(contents, &envelope); err != nil {
+		return nil, &storage.PlanVerificationError{Reason: "the stored plan is not signed"}
+	}
+	if envelope.Version != planEnvelopeVersion {
+		return nil, &storage.PlanVerificationError{Reason: fmt.Sprintf("unsupported envelope version %v", envelope.Version)}
+	}
+	if !hmac.Equal(envelope.Signature, e.sign(envelope)) {
+		return nil, &storage.PlanVerificationError{Reason: "signature mismatch"}
+	}
+	if envelope.ArtifactName != artifactName || envelope.StoredPlanPath != storedPlanFilePath {
+		return nil, &storage.PlanVerificationError{Reason: fmt.Sprintf("the plan was made for %v, not %v", envelope.StoredPlanPath, storedPlanFilePath)}
+	}
+
+	plan := envelope.Payload
+	if envelope.Encryption != "none" {
+		if e.Cipher == nil || e.Cipher.Name() != envelope.Encryption {
+			return nil, fmt.Errorf("the plan is encrypted with %v which is not configured", envelope.Encryption)
+		}
+		var err error
+		plan, err = e.Cipher.Decrypt(envelope.Payload, envelope.signedContent())
+		if err != nil {
+			return nil, &storage.PlanVerificationError{Reason: fmt.Sprintf("could not decrypt plan: %v", err)}
+		}
+	}
+	digest := sha256.Sum256(plan)
+	if hex.EncodeToString(digest[:]) != envelope.PlanSha256 {
+		return nil, &storage.PlanVerificationError{Reason: "plan digest mismatch"}
+	}
+	return plan, nil
+}
+
+// RetrievePlan replaces the downloaded envelope with the verified plan, the file is removed if verification fails
+func (e *EnvelopePlanStorage) RetrievePlan(localPlanFilePath string, artifactName string, storedPlanFilePath string) (*string, error) {
+	retrievedPath, err := e.PlanStorage.RetrievePlan(localPlanFilePath, artifactName, storedPlanFilePath)
+	if err != nil {
+		return nil, err
+	}
+	if retrievedPath == nil {
+		return nil, fmt.Errorf("no plan retrieved for %v", storedPlanFilePath)
+	}
+	contents, err := os.ReadFile(*retrievedPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read retrieved plan: %v", err)
+	}
+	plan, err := e.open(contents, artifactName, storedPlanFilePath)
+	if err != nil {
+		os.Remove(*retrievedPath)
+		return nil, err
+	}
+	if err := os.WriteFile(*retrievedPath, plan, 0600); err != nil {
+		return nil, fmt.Errorf("unable to write verified plan: %v", err)
+	}
+	return retrievedPath, nil
+}
+
+func (e *EnvelopePlanStorage) DeleteStoredPlan(artifactName string, storedPlanFilePath string) error {
+	return e.PlanStorage.DeleteStoredPlan(artifactName, storedPlanFilePath)
+}
+
+func (e *EnvelopePlanStorage) PlanExists(artifactName string, storedPlanFilePath string) (bool, error) {
+	return e.PlanStorage.PlanExists(artifactName, storedPlanFilePath)
+}
+
+func (e *EnvelopePlanStorage) ListPlans() ([]storage.StoredPlan, error) {
+	lister, ok := e.PlanStorage.(storage.PlanLister)
+	if !ok {
+		return nil, fmt.Errorf("plan storage %T can't list plans", e.PlanStorage)
+	}
+	return lister.ListPlans()
+}
+
+// WithPlanEnvelopeFromEnv wraps the storage when DIGGER_PLAN_SIGNING_KEY is set. Plans are encrypted with the
+// base64 AES-256 key in DIGGER_PLAN_ENCRYPTION_KEY, or to the age recipients in DIGGER_PLAN_AGE_RECIPIENTS and
+// decrypted with the identities in DIGGER_PLAN_AGE_IDENTITY
+func WithPlanEnvelopeFromEnv(planStorage storage.PlanStorage) (storage.PlanStorage, error) {
+	signingKey := os.Getenv("DIGGER_PLAN_SIGNING_KEY")
+	encryptionKey := os.Getenv("DIGGER_PLAN_ENCRYPTION_KEY")
+	ageRecipients := os.Getenv("DIGGER_PLAN_AGE_RECIPIENTS")
+	ageIdentity := os.Getenv("DIGGER_PLAN_AGE_IDENTITY")
+
+	if signingKey == "" {
+		if encryptionKey != "" || ageRecipients != "" || ageIdentity != "" {
+			return nil, fmt.Errorf("DIGGER_PLAN_SIGNING_KEY is required to encrypt plans")
+		}
+		return planStorage, nil
+	}
+	if encryptionKey != "" && (ageRecipients != "" || ageIdentity != "") {
+		return nil, fmt.Errorf("DIGGER_PLAN_ENCRYPTION_KEY and age keys can't be used together")
+	}
+
+	envelope := &EnvelopePlanStorage{PlanStorage: planStorage, SigningKey: []byte(signingKey)}
+	switch {
+	case encryptionKey != "":
+		key, err := base64.StdEncoding.DecodeString(encryptionKey)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("DIGGER_PLAN_ENCRYPTION_KEY has to be 32 bytes encoded in base64")
+		}
+		envelope.Cipher = AesGcmCipher{Key: key}
+	case ageRecipients != "" || ageIdentity != "":
+		ageCipher := AgeCipher{}
+		if ageIdentity != "" {
+			identities, err := age.ParseIdentities(strings.NewReader(ageIdentity))
+			if err != nil {
+				return nil, fmt.Errorf("could not parse DIGGER_PLAN_AGE_IDENTITY: %v", err)
+			}
+			ageCipher.Identities = identities
+			// plans can be stored by runners that only have the identity
+			for _, identity := range identities {
+				if x25519Identity, ok := identity.(*age.X25519Identity); ok {
+					ageCipher.Recipients = append(ageCipher.Recipients, x25519Identity.Recipient())
+				}
+			}
+		}
+		if ageRecipients != "" {
+			recipients, err := age.ParseRecipients(strings.NewReader(strings.ReplaceAll(ageRecipients, ",", "\n")))
+			if err != nil {
+				return nil, fmt.Errorf("could not parse DIGGER_PLAN_AGE_RECIPIENTS: %v", err)
+			}
+			ageCipher.Recipients = append(ageCipher.Recipients, recipients...)
+		}
+		envelope.Cipher = ageCipher
+	}
+	return envelope, nil
+}
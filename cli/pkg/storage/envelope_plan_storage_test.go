@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/diggerhq/digger/cli/pkg/core/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryPlanStorage struct {
+	plans map[string][]byte
+}
+
+func (m *memoryPlanStorage) StorePlanFile(fileContents []byte, artifactName string, storedPlanFilePath string) error {
+	m.plans[storedPlanFilePath] = fileContents
+	return nil
+}
+
+func (m *memoryPlanStorage) RetrievePlan(localPlanFilePath string, artifactName string, storedPlanFilePath string) (*string, error) {
+	err := os.WriteFile(localPlanFilePath, m.plans[storedPlanFilePath], 0600)
+	return &localPlanFilePath, err
+}
+
+func (m *memoryPlanStorage) DeleteStoredPlan(artifactName string, storedPlanFilePath string) error {
+	delete(m.plans, storedPlanFilePath)
+	return nil
+}
+
+func (m *memoryPlanStorage) PlanExists(artifactName string, storedPlanFilePath string) (bool, error) {
+	_, ok := m.plans[storedPlanFilePath]
+	return ok, nil
+}
+
+func requireVerificationError(t *testing.T, err error) {
+	var verificationErr *storage.PlanVerificationError
+	require.True(t, errors.As(err, &verificationErr), "expected a verification error, got %v", err)
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	ciphers := map[string]PlanCipher{
+		"signed only": nil,
+		"aes":         AesGcmCipher{Key: make([]byte, 32)},
+		"age":         AgeCipher{Recipients: []age.Recipient{identity.Recipient()}, Identities: []age.Identity{identity}},
+	}
+	for name, cipher := range ciphers {
+		t.Run(name, func(t *testing.T) {
+			inner := &memoryPlanStorage{plans: map[string][]byte{}}
+			envelope := &EnvelopePlanStorage{PlanStorage: inner, Cipher: cipher, SigningKey: []byte("secret")}
+
+			require.NoError(t, envelope.StorePlanFile([]byte("plan with secrets"), "prod", "diggerhq-demo-3-prod.tfplan"))
+			if cipher != nil {
+				require.NotContains(t, string(inner.plans["diggerhq-demo-3-prod.tfplan"]), "plan with secrets")
+			}
+
+			localPath := filepath.Join(t.TempDir(), "plan.tfplan")
+			retrieved, err := envelope.RetrievePlan(localPath, "prod", "diggerhq-demo-3-prod.tfplan")
+			require.NoError(t, err)
+			plan, err := os.ReadFile(*retrieved)
+			require.NoError(t, err)
+			require.Equal(t, "plan with secrets", string(plan))
+		})
+	}
+}
+
+func TestEnvelopeRejectsTamperedPlans(t *testing.T) {
+	inner := &memoryPlanStorage{plans: map[string][]byte{}}
+	envelope := &EnvelopePlanStorage{PlanStorage: inner, SigningKey: []byte("secret")}
+	localPath := filepath.Join(t.TempDir(), "plan.tfplan")
+
+	require.NoError(t, envelope.StorePlanFile([]byte("plan"), "prod", "diggerhq-demo-3-prod.tfplan"))
+	var stored planEnvelope
+	require.NoError(t, json.Unmarshal(inner.plans["diggerhq-demo-3-prod.tfplan"], &stored))
+	stored.Payload = []byte("destroy everything")
+	inner.plans["diggerhq-demo-3-prod.tfplan"], _ = json.Marshal(stored)
+	_, err := envelope.RetrievePlan(localPath, "prod", "diggerhq-demo-3-prod.tfplan")
+	requireVerificationError(t, err)
+	_, err = os.Stat(localPath)
+	require.True(t, os.IsNotExist(err))
+
+	// a valid plan of another pull request
+	require.NoError(t, envelope.StorePlanFile([]byte("plan"), "prod", "diggerhq-demo-4-prod.tfplan"))
+	inner.plans["diggerhq-demo-3-prod.tfplan"] = inner.plans["diggerhq-demo-4-prod.tfplan"]
+	_, err = envelope.RetrievePlan(localPath, "prod", "diggerhq-demo-3-prod.tfplan")
+	requireVerificationError(t, err)
+
+	inner.plans["diggerhq-demo-3-prod.tfplan"] = []byte("unsigned plan")
+	_, err = envelope.RetrievePlan(localPath, "prod", "diggerhq-demo-3-prod.tfplan")
+	requireVerificationError(t, err)
+
+	require.NoError(t, envelope.StorePlanFile([]byte("plan"), "prod", "diggerhq-demo-3-prod.tfplan"))
+	otherKey := &EnvelopePlanStorage{PlanStorage: inner, SigningKey: []byte("other secret")}
+	_, err = otherKey.RetrievePlan(localPath, "prod", "diggerhq-demo-3-prod.tfplan")
+	requireVerificationError(t, err)
+}
+
+func TestWithPlanEnvelopeFromEnv(t *testing.T) {
+	inner := &memoryPlanStorage{plans: map[string][]byte{}}
+
+	planStorage, err := WithPlanEnvelopeFromEnv(inner)
+	require.NoError(t, err)
+	require.Equal(t, inner, planStorage)
+
+	t.Setenv("DIGGER_PLAN_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	_, err = WithPlanEnvelopeFromEnv(inner)
+	require.Error(t, err)
+
+	t.Setenv("DIGGER_PLAN_SIGNING_KEY", "secret")
+	planStorage, err = WithPlanEnvelopeFromEnv(inner)
+	require.NoError(t, err)
+	require.IsType(t, AesGcmCipher{}, planStorage.(*EnvelopePlanStorage).Cipher)
+
+	t.Setenv("DIGGER_PLAN_ENCRYPTION_KEY", "c2hvcnQ=")
+	_, err = WithPlanEnvelopeFromEnv(inner)
+	require.Error(t, err)
+
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	t.Setenv("DIGGER_PLAN_ENCRYPTION_KEY", "")
+	t.Setenv("DIGGER_PLAN_AGE_IDENTITY", identity.String())
+	planStorage, err = WithPlanEnvelopeFromEnv(inner)
+	require.NoError(t, err)
+	ageCipher := planStorage.(*EnvelopePlanStorage).Cipher.(AgeCipher)
+	require.Len(t, ageCipher.Recipients, 1)
+	require.NoError(t, planStorage.StorePlanFile([]byte("plan"), "prod", "diggerhq-demo-3-prod.tfplan"))
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggerhq/digger/cli/pkg/core/storage"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PlanStorageFilesystem keeps plans below Dir, for self-hosted runners that share a volume
+type PlanStorageFilesystem struct {
+	Dir string
+}
+
+func (psf *PlanStorageFilesystem) path(storedPlanFilePath string) (string, error) {
+	if !filepath.IsLocal(storedPlanFilePath) {
+		return "", fmt.Errorf("invalid stored plan path: %v", storedPlanFilePath)
+	}
+	return filepath.Join(psf.Dir, storedPlanFilePath), nil
+}
+
+func (psf *PlanStorageFilesystem) PlanExists(artifactName string, storedPlanFilePath string) (bool, error) {
+	path, err := psf.path(storedPlanFilePath)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to get file attributes: %v", err)
+	}
+	return true, nil
+}
+
+// StorePlanFile writes to a temporary file first so that a concurrent apply never reads a partial plan
+func (psf *PlanStorageFilesystem) StorePlanFile(fileContents []byte, artifactName string, storedPlanFilePath string) error {
+	path, err := psf.path(storedPlanFilePath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("unable to create plan directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".plan-*")
+	if err != nil {
+		return fmt.Errorf("unable to create file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(fileContents); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write data to file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write data to file: %v", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (psf *PlanStorageFilesystem) RetrievePlan(localPlanFilePath string, artifactName string, storedPlanFilePath string) (*string, error) {
+	path, err := psf.path(storedPlanFilePath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read stored plan: %v", err)
+	}
+	if err := os.WriteFile(localPlanFilePath, data, 0600); err != nil {
+		return nil, fmt.Errorf("unable to write data to file: %v", err)
+	}
+	fileName, err := filepath.Abs(localPlanFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get absolute path for file: %v", err)
+	}
+	return &fileName, nil
+}
+
+func (psf *PlanStorageFilesystem) DeleteStoredPlan(artifactName string, storedPlanFilePath string) error {
+	path, err := psf.path(storedPlanFilePath)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to delete file '%v': %v", storedPlanFilePath, err)
+	}
+	return nil
+}
+
+func (psf *PlanStorageFilesystem) ListPlans() ([]storage.StoredPlan, error) {
+	var plans []storage.StoredPlan
+	err := filepath.WalkDir(psf.Dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasSuffix(path, ".tfplan") {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(psf.Dir, path)
+		if err != nil {
+			return err
+		}
+		plans = append(plans, storage.StoredPlan{Path: filepath.ToSlash(relativePath), LastModified: info.ModTime()})
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("unable to list plans in %v: %v", psf.Dir, err)
+	}
+	return plans, nil
+}
@@ -7,8 +7,11 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"cloud.google.com/go/storage"
+	core_storage "github.com/diggerhq/digger/cli/pkg/core/storage"
+	"google.golang.org/api/iterator"
 )
 
 type PlanStorageGcp struct {
@@ -75,3 +78,23 @@ func (psg *PlanStorageGcp) DeleteStoredPlan(artifactName string, storedPlanFileP
 	}
 	return nil
 }
+
+// ListPlans returns the .tfplan objects of the bucket
+func (psg *PlanStorageGcp) ListPlans() ([]core_storage.StoredPlan, error) {
+	var plans []core_storage.StoredPlan
+	it := psg.Bucket.Objects(psg.Context, nil)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to list objects of bucket: %v", err)
+		}
+		if !strings.HasSuffix(attrs.Name, ".tfplan") {
+			continue
+		}
+		plans = append(plans, core_storage.StoredPlan{Path: attrs.Name, LastModified: attrs.Updated})
+	}
+	return plans, nil
+}
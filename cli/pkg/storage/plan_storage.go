@@ -8,6 +8,7 @@ import (
 	"github.com/diggerhq/digger/cli/pkg/core/storage"
 	"github.com/diggerhq/digger/cli/pkg/usage"
 	"github.com/diggerhq/digger/libs/locking/gcp"
+	dg_github "github.com/diggerhq/digger/libs/orchestrator/github"
 	"io"
 	"log"
 	"net/http"
@@ -216,7 +217,7 @@ func NewPlanStorage(ghToken string, ghRepoOwner string, ghRepositoryName string,
 	case uploadDestination == "github":
 		zipManager := utils.Zipper{}
 		planStorage = &GithubPlanStorage{
-			Client:            github.NewTokenClient(context.Background(), ghToken),
+			Client:            dg_github.NewGithubClient(nil).WithAuthToken(ghToken),
 			Owner:             ghRepoOwner,
 			RepoName:          ghRepositoryName,
 			PullRequestNumber: *prNumber,
@@ -248,9 +249,33 @@ func NewPlanStorage(ghToken string, ghRepoOwner string, ghRepositoryName string,
 			Client:  client,
 			Bucket:  bucketName,
 		}
+	case uploadDestination == "backend":
+		hostname := os.Getenv("DIGGER_HOSTNAME")
+		token := os.Getenv("DIGGER_TOKEN")
+		if hostname == "" || token == "" {
+			usage.ReportErrorAndExit(requestedBy, "DIGGER_HOSTNAME and DIGGER_TOKEN are required to store plans in the backend", 9)
+		}
+		if ghRepoOwner == "" || ghRepositoryName == "" {
+			usage.ReportErrorAndExit(requestedBy, "the repo is required to store plans in the backend", 9)
+		}
+		planStorage = NewBackendPlanStorage(hostname, token, fmt.Sprintf("%v-%v", ghRepoOwner, ghRepositoryName))
+	case uploadDestination == "filesystem":
+		dir := os.Getenv("PLAN_STORAGE_DIR")
+		if dir == "" {
+			usage.ReportErrorAndExit(requestedBy, "PLAN_STORAGE_DIR is not defined", 9)
+		}
+		planStorage = &PlanStorageFilesystem{Dir: dir}
 	case uploadDestination == "gitlab":
 		//TODO implement me
 	}
 
+	if planStorage != nil {
+		var err error
+		planStorage, err = WithPlanEnvelopeFromEnv(planStorage)
+		if err != nil {
+			usage.ReportErrorAndExit(requestedBy, fmt.Sprintf("Failed to configure plan encryption: %v", err), 9)
+		}
+	}
+
 	return planStorage
 }
@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGithubPlanStorageUsesEnterpriseHost(t *testing.T) {
+	var requestedPath, authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"total_count": 1, "artifacts": [{"id": 1, "name": "prod.tfplan", "updated_at": "2024-06-01T00:00:00Z"}]}`))
+	}))
+	defer server.Close()
+	t.Setenv("GITHUB_SERVER_URL", server.URL)
+	t.Setenv("GITHUB_API_URL", server.URL+"/api/v3")
+	t.Setenv("GITHUB_UPLOAD_URL", "")
+	t.Setenv("PLAN_UPLOAD_DESTINATION", "github")
+
+	prNumber := 3
+	planStorage := NewPlanStorage("token", "diggerhq", "demo", "", &prNumber)
+	exists, err := planStorage.PlanExists("prod.tfplan", "")
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, "/api/v3/repos/diggerhq/demo/actions/artifacts", requestedPath)
+	require.Equal(t, "Bearer token", authorization)
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/diggerhq/digger/cli/pkg/core/storage"
+	"github.com/diggerhq/digger/libs/orchestrator"
+	"log"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PlanRetention struct {
+	// MaxAge of zero keeps plans regardless of their age
+	MaxAge time.Duration
+	// RepoNamespace and PrService, when both are set, expire the plans of closed pull requests of the repo
+	RepoNamespace string
+	PrService     orchestrator.PullRequestService
+}
+
+// planNamePattern matches the names of the stored plans of ProjectPathProvider for pull requests of the namespace
+func planNamePattern(repoNamespace string) *regexp.Regexp {
+	return regexp.MustCompile("^" + regexp.QuoteMeta(strings.ReplaceAll(repoNamespace, "/", "-")) + `-(\d+)-(.+)$`)
+}
+
+// prNumberOfPlan parses the pull request number out of a stored plan path. The number following the namespace is
+// always taken as a pull request of the namespace, project names like 2024-prod are fine, and the sweep only
+// expires the plan once that pull request of the namespace is closed
+func prNumberOfPlan(pattern *regexp.Regexp, storedPlanFilePath string) (int, bool) {
+	match := pattern.FindStringSubmatch(path.Base(storedPlanFilePath))
+	if match == nil {
+		return 0, false
+	}
+	prNumber, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+	return prNumber, true
+}
+
+// SweepPlans deletes the stored plans that are older than the max age or belong to closed pull requests,
+// it returns the paths of the deleted plans
+func SweepPlans(planStorage storage.PlanStorage, retention PlanRetention, now time.Time) ([]string, error) {
+	lister, ok := planStorage.(storage.PlanLister)
+	if !ok {
+		return nil, fmt.Errorf("plan storage %T can't list plans", planStorage)
+	}
+	plans, err := lister.ListPlans()
+	if err != nil {
+		return nil, err
+	}
+
+	closedPrs := map[int]bool{}
+	namePattern := planNamePattern(retention.RepoNamespace)
+	var deleted []string
+	for _, plan := range plans {
+		expired := retention.MaxAge > 0 && now.Sub(plan.LastModified) > retention.MaxAge
+		if !expired && retention.PrService != nil && retention.RepoNamespace != "" {
+			if prNumber, ok := prNumberOfPlan(namePattern, plan.Path); ok {
+				closed, checked := closedPrs[prNumber]
+				if !checked {
+					closed, err = retention.PrService.IsClosed(prNumber)
+					if err != nil {
+						log.Printf("could not check if pull request %v is closed: %v", prNumber, err)
+						continue
+					}
+					closedPrs[prNumber] = closed
+				}
+				expired = closed
+			}
+		}
+		if !expired {
+			continue
+		}
+		err := planStorage.DeleteStoredPlan("", plan.Path)
+		if err != nil {
+			return deleted, fmt.Errorf("could not delete plan %v: %v", plan.Path, err)
+		}
+		log.Printf("deleted expired plan %v", plan.Path)
+		deleted = append(deleted, plan.Path)
+	}
+	return deleted, nil
+}
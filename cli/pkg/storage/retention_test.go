@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/diggerhq/digger/libs/orchestrator"
+	orchestrator_github "github.com/diggerhq/digger/libs/orchestrator/github"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeS3Object struct {
+	data         []byte
+	lastModified time.Time
+}
+
+// fakeS3 serves the path-style object API of a single bucket
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string]*fakeS3Object
+	paths   []string
+}
+
+type fakeS3ListResult struct {
+	XMLName     xml.Name `xml:"ListBucketResult"`
+	Name        string   `xml:"Name"`
+	KeyCount    int      `xml:"KeyCount"`
+	IsTruncated bool     `xml:"IsTruncated"`
+	Contents    []struct {
+		Key          string `xml:"Key"`
+		LastModified string `xml:"LastModified"`
+		Size         int    `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paths = append(f.paths, r.URL.Path)
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "<Error><Code>NoSuchBucket</Code></Error>")
+		return
+	}
+	switch {
+	case r.Method == "GET" && key == "" && r.URL.Query().Get("list-type") == "2":
+		result := fakeS3ListResult{Name: f.bucket}
+		for name, object := range f.objects {
+			result.Contents = append(result.Contents, struct {
+				Key          string `xml:"Key"`
+				LastModified string `xml:"LastModified"`
+				Size         int    `xml:"Size"`
+			}{name, object.lastModified.UTC().Format("2006-01-02T15:04:05.000Z"), len(object.data)})
+		}
+		sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+		result.KeyCount = len(result.Contents)
+		w.Header().Set("Content-Type", "application/xml")
+		xml.NewEncoder(w).Encode(result)
+	case r.Method == "PUT":
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = &fakeS3Object{data: data, lastModified: time.Now()}
+		w.WriteHeader(http.StatusOK)
+	case r.Method == "HEAD" || r.Method == "GET":
+		object, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == "GET" {
+				io.WriteString(w, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>")
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.Method == "GET" {
+			w.Write(object.data)
+		}
+	case r.Method == "DELETE":
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newFakeS3PlanStorage(t *testing.T) (*fakeS3, *PlanStorageAWS) {
+	fake := &fakeS3{bucket: "plans", objects: map[string]*fakeS3Object{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("AWS_S3_ENDPOINT", server.URL)
+	t.Setenv("AWS_S3_FORCE_PATH_STYLE", "true")
+
+	ctx, client, err := GetAWSStorageClient()
+	require.NoError(t, err)
+	return fake, &PlanStorageAWS{Context: ctx, Client: client, Bucket: "plans"}
+}
+
+func TestPlanStorageAWSCustomEndpoint(t *testing.T) {
+	fake, planStorage := newFakeS3PlanStorage(t)
+
+	exists, err := planStorage.PlanExists("prod", "diggerhq-demo-3-prod.tfplan")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	require.NoError(t, planStorage.StorePlanFile([]byte("plan"), "prod", "diggerhq-demo-3-prod.tfplan"))
+	require.Contains(t, fake.paths, "/plans/diggerhq-demo-3-prod.tfplan")
+	exists, err = planStorage.PlanExists("prod", "diggerhq-demo-3-prod.tfplan")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	localPath := filepath.Join(t.TempDir(), "plan.tfplan")
+	_, err = planStorage.RetrievePlan(localPath, "prod", "diggerhq-demo-3-prod.tfplan")
+	require.NoError(t, err)
+	data, err := os.ReadFile(localPath)
+	require.NoError(t, err)
+	require.Equal(t, "plan", string(data))
+
+	plans, err := planStorage.ListPlans()
+	require.NoError(t, err)
+	require.Len(t, plans, 1)
+	require.Equal(t, "diggerhq-demo-3-prod.tfplan", plans[0].Path)
+
+	require.NoError(t, planStorage.DeleteStoredPlan("prod", "diggerhq-demo-3-prod.tfplan"))
+	exists, err = planStorage.PlanExists("prod", "diggerhq-demo-3-prod.tfplan")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestPlanStorageFilesystem(t *testing.T) {
+	planStorage := &PlanStorageFilesystem{Dir: t.TempDir()}
+
+	exists, err := planStorage.PlanExists("prod", "diggerhq-demo-3-prod.tfplan")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	require.NoError(t, planStorage.StorePlanFile([]byte("plan"), "prod", "diggerhq-demo-3-prod.tfplan"))
+	exists, err = planStorage.PlanExists("prod", "diggerhq-demo-3-prod.tfplan")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	localPath := filepath.Join(t.TempDir(), "plan.tfplan")
+	retrieved, err := planStorage.RetrievePlan(localPath, "prod", "diggerhq-demo-3-prod.tfplan")
+	require.NoError(t, err)
+	require.Equal(t, localPath, *retrieved)
+	data, err := os.ReadFile(localPath)
+	require.NoError(t, err)
+	require.Equal(t, "plan", string(data))
+
+	require.Error(t, planStorage.StorePlanFile([]byte("plan"), "prod", "../outside.tfplan"))
+
+	require.NoError(t, planStorage.DeleteStoredPlan("prod", "diggerhq-demo-3-prod.tfplan"))
+	require.NoError(t, planStorage.DeleteStoredPlan("prod", "diggerhq-demo-3-prod.tfplan"))
+	exists, err = planStorage.PlanExists("prod", "diggerhq-demo-3-prod.tfplan")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+type closedPrService struct {
+	orchestrator_github.MockCiService
+	closed  map[int]bool
+	checked []int
+}
+
+func (c *closedPrService) IsClosed(prNumber int) (bool, error) {
+	c.checked = append(c.checked, prNumber)
+	return c.closed[prNumber], nil
+}
+
+func TestSweepPlans(t *testing.T) {
+	fake, planStorage := newFakeS3PlanStorage(t)
+	for _, path := range []string{"diggerhq-demo-3-prod.tfplan", "diggerhq-demo-3-dev.tfplan", "diggerhq-demo-4-prod.tfplan",
+		"diggerhq-demo-5-prod.tfplan", "diggerhq-demo-prod.tfplan", "diggerhq-demo-3-2024-prod.tfplan", "diggerhq-demo-4-2024-prod.tfplan", "other-file.json"} {
+		require.NoError(t, planStorage.StorePlanFile([]byte("plan"), "", path))
+	}
+	fake.objects["diggerhq-demo-5-prod.tfplan"].lastModified = time.Now().Add(-10 * 24 * time.Hour)
+	fake.objects["diggerhq-demo-prod.tfplan"].lastModified = time.Now().Add(-10 * 24 * time.Hour)
+
+	prService := &closedPrService{closed: map[int]bool{3: true}}
+	var service orchestrator.PullRequestService = prService
+	deleted, err := SweepPlans(planStorage, PlanRetention{
+		MaxAge:        7 * 24 * time.Hour,
+		RepoNamespace: "diggerhq/demo",
+		PrService:     service,
+	}, time.Now())
+	require.NoError(t, err)
+	sort.Strings(deleted)
+	require.Equal(t, []string{"diggerhq-demo-3-2024-prod.tfplan", "diggerhq-demo-3-dev.tfplan", "diggerhq-demo-3-prod.tfplan", "diggerhq-demo-5-prod.tfplan", "diggerhq-demo-prod.tfplan"}, deleted)
+	require.ElementsMatch(t, []int{3, 4}, prService.checked)
+
+	require.Contains(t, fake.objects, "diggerhq-demo-4-prod.tfplan")
+	require.Contains(t, fake.objects, "other-file.json")
+	// projects named after a number are swept with the pull request they were planned for
+	require.Contains(t, fake.objects, "diggerhq-demo-4-2024-prod.tfplan")
+
+	_, err = SweepPlans(&memoryPlanStorage{}, PlanRetention{MaxAge: time.Hour}, time.Now())
+	require.Error(t, err)
+}
+
+func TestPrNumberOfPlan(t *testing.T) {
+	pattern := planNamePattern("diggerhq/demo")
+	prNumber, ok := prNumberOfPlan(pattern, "diggerhq-demo-12-prod-eu.tfplan")
+	require.True(t, ok)
+	require.Equal(t, 12, prNumber)
+
+	prNumber, ok = prNumberOfPlan(pattern, "diggerhq-demo-7-2024-prod.tfplan")
+	require.True(t, ok)
+	require.Equal(t, 7, prNumber)
+
+	_, ok = prNumberOfPlan(pattern, "diggerhq-demo-prod.tfplan")
+	require.False(t, ok)
+	_, ok = prNumberOfPlan(pattern, "diggerhq-other-12-prod.tfplan")
+	require.False(t, ok)
+}
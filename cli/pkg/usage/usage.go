@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	configuration "github.com/diggerhq/digger/libs/digger_config"
+	"github.com/diggerhq/digger/libs/tracing"
 	"log"
 	"net/http"
 	"os"
@@ -114,5 +115,6 @@ func ReportErrorAndExit(repoOwner string, message string, exitCode int) {
 	if err != nil {
 		log.Printf("Failed to send log record. %s\n", err)
 	}
+	tracing.Shutdown()
 	os.Exit(exitCode)
 }
@@ -1,8 +1,10 @@
 package utils
 
 import (
+	"github.com/diggerhq/digger/cli/pkg/core/backend"
 	"github.com/diggerhq/digger/cli/pkg/core/execution"
 	"github.com/diggerhq/digger/libs/orchestrator/scheduler"
+	"github.com/diggerhq/digger/libs/terraform_utils"
 	"time"
 
 	"github.com/diggerhq/digger/libs/orchestrator"
@@ -25,12 +27,12 @@ func (tf *MockTerraform) Plan() (bool, string, string, error) {
 type MockPolicyChecker struct {
 }
 
-func (t MockPolicyChecker) CheckAccessPolicy(ciService orchestrator.OrgService, prService *orchestrator.PullRequestService, SCMOrganisation string, SCMrepository string, projectName string, command string, ptr *int, requestedBy string, planPolicyViolations []string) (bool, error) {
+func (t MockPolicyChecker) CheckAccessPolicy(ciService orchestrator.OrgService, prService *orchestrator.PullRequestService, SCMOrganisation string, SCMrepository string, projectName string, command string, ptr *int, requestedBy string, planPolicyViolations []string, planPolicyWarnings []string, workspace string, planSummary *terraform_utils.PlanSummary) (bool, error) {
 	return false, nil
 }
 
-func (t MockPolicyChecker) CheckPlanPolicy(projectName string, SCMOrganisation string, command string, requestedBy string) (bool, []string, error) {
-	return false, nil, nil
+func (t MockPolicyChecker) CheckPlanPolicy(projectName string, SCMOrganisation string, command string, requestedBy string, costEstimate *terraform_utils.CostEstimate) (bool, []string, []string, error) {
+	return false, nil, nil, nil
 }
 
 func (t MockPolicyChecker) CheckDriftPolicy(SCMOrganisation string, SCMrepository string, projectname string) (bool, error) {
@@ -41,6 +43,9 @@ type MockPullRequestManager struct {
 	ChangedFiles []string
 	Teams        []string
 	Approvals    []string
+	Labels       []string
+	Author       string
+	BaseBranch   string
 }
 
 func (t MockPullRequestManager) GetUserTeams(organisation string, user string) ([]string, error) {
@@ -74,6 +79,10 @@ func (t MockPullRequestManager) GetApprovals(prNumber int) ([]string, error) {
 	return t.Approvals, nil
 }
 
+func (t MockPullRequestManager) GetPullRequestDetails(prNumber int) (*orchestrator.PullRequestDetails, error) {
+	return &orchestrator.PullRequestDetails{Labels: t.Labels, Author: t.Author, BaseBranch: t.BaseBranch}, nil
+}
+
 func (t MockPullRequestManager) MergePullRequest(prNumber int) error {
 	return nil
 }
@@ -144,6 +153,6 @@ func (t MockBackendApi) ReportProjectRun(repo string, projectName string, starte
 	return nil
 }
 
-func (t MockBackendApi) ReportProjectJobStatus(repo string, projectName string, jobId string, status string, timestamp time.Time, summary *execution.DiggerExecutorPlanResult, PrCommentUrl string, terraformOutput string) (*scheduler.SerializedBatch, error) {
+func (t MockBackendApi) ReportProjectJobStatus(repo string, projectName string, jobId string, status string, timestamp time.Time, summary *execution.DiggerExecutorPlanResult, PrCommentUrl string, terraformOutput string, report *backend.JobReport) (*scheduler.SerializedBatch, error) {
 	return nil, nil
 }
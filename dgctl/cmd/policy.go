@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/diggerhq/digger/dgctl/pkg/policytest"
+	"github.com/spf13/cobra"
+)
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Work with digger OPA policies",
+	Long:  `Work with digger OPA policies`,
+}
+
+var policyTestCmd = &cobra.Command{
+	Use:   "test <policy.rego>",
+	Short: "Test a policy against fixture inputs and its OPA tests",
+	Long: `Test a policy before uploading it. The policy is evaluated against the fixture inputs exactly the way
+digger evaluates access, plan and drift policies, and the test_ rules of its OPA tests are run.
+By default the tests are read from <policy>_test.rego next to the policy. For example:
+
+dgctl policy test access.rego --fixtures access_fixtures.yml`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		policyPath := args[0]
+		fixturePaths, _ := cmd.Flags().GetStringSlice("fixtures")
+		testPaths, _ := cmd.Flags().GetStringSlice("tests")
+
+		policyText, err := os.ReadFile(policyPath)
+		if err != nil {
+			log.Printf("Could not read policy: %v", err)
+			os.Exit(1)
+		}
+		if !cmd.Flags().Changed("tests") {
+			defaultTests := strings.TrimSuffix(policyPath, ".rego") + "_test.rego"
+			if _, err := os.Stat(defaultTests); err == nil {
+				testPaths = []string{defaultTests}
+			}
+		}
+		if len(fixturePaths) == 0 && len(testPaths) == 0 {
+			log.Printf("Nothing to test, pass --fixtures or --tests")
+			os.Exit(1)
+		}
+
+		var fixtures []*policytest.Fixtures
+		for _, path := range fixturePaths {
+			f, err := policytest.LoadFixtures(path)
+			if err != nil {
+				log.Printf("%v", err)
+				os.Exit(1)
+			}
+			fixtures = append(fixtures, f)
+		}
+		tests := map[string]string{}
+		for _, path := range testPaths {
+			text, err := os.ReadFile(path)
+			if err != nil {
+				log.Printf("Could not read tests: %v", err)
+				os.Exit(1)
+			}
+			tests[path] = string(text)
+		}
+
+		report, err := policytest.Run(string(policyText), fixtures, tests)
+		if err != nil {
+			log.Printf("%v", err)
+			os.Exit(1)
+		}
+		for _, result := range report.Results {
+			if result.Passed {
+				fmt.Printf("PASS  %v\n", result.Name)
+			} else {
+				fmt.Printf("FAIL  %v: %v\n", result.Name, result.Message)
+			}
+		}
+		fmt.Printf("\n%v passed, %v failed\n", len(report.Results)-report.Failed(), report.Failed())
+		fmt.Printf("coverage: %.1f%% of %v\n", report.Coverage, policyPath)
+		if len(report.NotCovered) > 0 {
+			lines := make([]string, 0, len(report.NotCovered))
+			for _, r := range report.NotCovered {
+				if r.Start.Row == r.End.Row {
+					lines = append(lines, fmt.Sprint(r.Start.Row))
+				} else {
+					lines = append(lines, fmt.Sprintf("%v-%v", r.Start.Row, r.End.Row))
+				}
+			}
+			fmt.Printf("not covered lines: %v\n", strings.Join(lines, ", "))
+		}
+		if report.Failed() > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	policyTestCmd.Flags().StringSlice("fixtures", nil, "yaml files of fixture inputs and expected decisions")
+	policyTestCmd.Flags().StringSlice("tests", nil, "rego files with test_ rules (default <policy>_test.rego)")
+	policyCmd.AddCommand(policyTestCmd)
+	rootCmd.AddCommand(policyCmd)
+}
@@ -0,0 +1,284 @@
+package policytest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	corepolicy "github.com/diggerhq/digger/cli/pkg/core/policy"
+	"github.com/diggerhq/digger/cli/pkg/policy"
+	"github.com/diggerhq/digger/libs/terraform_utils"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/cover"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/tester"
+	"gopkg.in/yaml.v3"
+)
+
+// policyModule is the module name the policy is loaded under, the same name digger evaluates it with
+const policyModule = "digger"
+
+const (
+	PolicyTypeAccess = "access"
+	PolicyTypePlan   = "plan"
+	PolicyTypeDrift  = "drift"
+)
+
+// Fixtures is a file of cases evaluated against one kind of policy
+type Fixtures struct {
+	Type  string `yaml:"type"`
+	Cases []Case `yaml:"cases"`
+	// dir is where plan files are looked up
+	dir string
+}
+
+// Case holds the input of a single evaluation and the expected decision
+type Case struct {
+	Name string `yaml:"name"`
+
+	// access and drift policies
+	User                 string       `yaml:"user"`
+	Organisation         string       `yaml:"organisation"`
+	Teams                []string     `yaml:"teams"`
+	Approvals            []string     `yaml:"approvals"`
+	PlanPolicyViolations []string     `yaml:"planPolicyViolations"`
+	PlanPolicyWarnings   []string     `yaml:"planPolicyWarnings"`
+	Action               string       `yaml:"action"`
+	Project              string       `yaml:"project"`
+	Workspace            string       `yaml:"workspace"`
+	ChangedFiles         []string     `yaml:"changedFiles"`
+	Labels               []string     `yaml:"labels"`
+	Author               string       `yaml:"author"`
+	CommitSha            string       `yaml:"commitSha"`
+	PlanSummary          *PlanSummary `yaml:"planSummary"`
+	// Time in RFC 3339, the current time by default
+	Time string `yaml:"time"`
+
+	// plan policies, a terraform show -json file relative to the fixtures file
+	Plan string `yaml:"plan"`
+
+	Allow  *bool    `yaml:"allow"`
+	Deny   []string `yaml:"deny"`
+	Warn   []string `yaml:"warn"`
+	Enable *bool    `yaml:"enable"`
+}
+
+type PlanSummary struct {
+	ResourcesCreated uint `yaml:"resourcesCreated"`
+	ResourcesUpdated uint `yaml:"resourcesUpdated"`
+	ResourcesDeleted uint `yaml:"resourcesDeleted"`
+}
+
+type Result struct {
+	Name   string
+	Passed bool
+	// Message explains a failure
+	Message string
+}
+
+type Report struct {
+	Results []Result
+	// Coverage of the policy by both fixtures and OPA tests, in percent
+	Coverage   float64
+	NotCovered []cover.Range
+}
+
+func (r Report) Failed() int {
+	failed := 0
+	for _, result := range r.Results {
+		if !result.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+func LoadFixtures(path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read fixtures: %v", err)
+	}
+	var fixtures Fixtures
+	if err := yaml.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("could not parse fixtures %v: %v", path, err)
+	}
+	switch fixtures.Type {
+	case PolicyTypeAccess, PolicyTypePlan, PolicyTypeDrift:
+	default:
+		return nil, fmt.Errorf("fixtures %v: type has to be access, plan or drift, got '%v'", path, fixtures.Type)
+	}
+	fixtures.dir = filepath.Dir(path)
+	return &fixtures, nil
+}
+
+// Run evaluates the policy against the fixtures and runs the test_ rules of the test modules,
+// tests are keyed by file name
+func Run(policyText string, fixtures []*Fixtures, tests map[string]string) (*Report, error) {
+	module, err := ast.ParseModule(policyModule, policyText)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse policy: %v", err)
+	}
+	coverage := cover.New()
+	report := &Report{}
+
+	for _, f := range fixtures {
+		for _, c := range f.Cases {
+			report.Results = append(report.Results, runCase(policyText, f, c, coverage))
+		}
+	}
+
+	if len(tests) > 0 {
+		modules := map[string]*ast.Module{policyModule: module}
+		for name, text := range tests {
+			testModule, err := ast.ParseModule(name, text)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse tests: %v", err)
+			}
+			modules[name] = testModule
+		}
+		ch, err := tester.NewRunner().
+			SetCoverageQueryTracer(coverage).
+			SetModules(modules).
+			RunTests(context.Background(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("could not run tests: %v", err)
+		}
+		for testResult := range ch {
+			result := Result{Name: fmt.Sprintf("%v.%v", testResult.Package, testResult.Name), Passed: testResult.Pass()}
+			switch {
+			case testResult.Error != nil:
+				result.Message = testResult.Error.Error()
+			case testResult.Skip:
+				result.Message = "skipped"
+			case testResult.Fail && testResult.FailedAt != nil:
+				result.Message = fmt.Sprintf("failed at %v", testResult.FailedAt)
+			}
+			report.Results = append(report.Results, result)
+		}
+	}
+
+	fileReport := coverage.Report(map[string]*ast.Module{policyModule: module}).Files[policyModule]
+	if fileReport != nil {
+		report.Coverage = fileReport.Coverage
+		report.NotCovered = fileReport.NotCovered
+	}
+	return report, nil
+}
+
+func runCase(policyText string, fixtures *Fixtures, c Case, coverage *cover.Cover) Result {
+	result := Result{Name: fmt.Sprintf("%v: %v", fixtures.Type, c.Name)}
+	tracer := rego.QueryTracer(coverage)
+
+	switch fixtures.Type {
+	case PolicyTypeAccess:
+		accessContext := corepolicy.AccessPolicyContext{
+			User:                 c.User,
+			Organisation:         c.Organisation,
+			Teams:                emptyIfNil(c.Teams),
+			Approvals:            emptyIfNil(c.Approvals),
+			PlanPolicyViolations: emptyIfNil(c.PlanPolicyViolations),
+			PlanPolicyWarnings:   emptyIfNil(c.PlanPolicyWarnings),
+			Action:               c.Action,
+			Project:              c.Project,
+			Workspace:            c.Workspace,
+			ChangedFiles:         emptyIfNil(c.ChangedFiles),
+			Labels:               emptyIfNil(c.Labels),
+			Author:               c.Author,
+			CommitSha:            c.CommitSha,
+			Time:                 time.Now(),
+		}
+		if c.PlanSummary != nil {
+			accessContext.PlanSummary = &terraform_utils.PlanSummary{
+				ResourcesCreated: c.PlanSummary.ResourcesCreated,
+				ResourcesUpdated: c.PlanSummary.ResourcesUpdated,
+				ResourcesDeleted: c.PlanSummary.ResourcesDeleted,
+			}
+		}
+		if c.Time != "" {
+			t, err := time.Parse(time.RFC3339, c.Time)
+			if err != nil {
+				result.Message = fmt.Sprintf("could not parse time: %v", err)
+				return result
+			}
+			accessContext.Time = t
+		}
+		input := policy.AccessPolicyInput(accessContext)
+		allow, err := policy.EvalAccessPolicy(policyText, input, tracer)
+		if err != nil {
+			result.Message = err.Error()
+			return result
+		}
+		if c.Allow != nil && *c.Allow != allow {
+			result.Message = fmt.Sprintf("expected allow to be %v, got %v", *c.Allow, allow)
+			return result
+		}
+	case PolicyTypePlan:
+		planOutput, err := os.ReadFile(filepath.Join(fixtures.dir, c.Plan))
+		if err != nil {
+			result.Message = fmt.Sprintf("could not read plan: %v", err)
+			return result
+		}
+		// plans that can't be estimated are evaluated without their cost, as in a digger run
+		costEstimate, _ := terraform_utils.GetCostEstimate(string(planOutput))
+		input, err := policy.PlanPolicyInput(string(planOutput), costEstimate)
+		if err != nil {
+			result.Message = err.Error()
+			return result
+		}
+		deny, err := policy.EvalPlanPolicy(policyText, input, tracer)
+		if err != nil {
+			result.Message = err.Error()
+			return result
+		}
+		if message := compareMessages("deny", c.Deny, deny); message != "" {
+			result.Message = message
+			return result
+		}
+		warn, err := policy.EvalPlanPolicyWarnings(policyText, input, tracer)
+		if err != nil {
+			result.Message = err.Error()
+			return result
+		}
+		if message := compareMessages("warn", c.Warn, warn); message != "" {
+			result.Message = message
+			return result
+		}
+	case PolicyTypeDrift:
+		input := policy.DriftPolicyInput(c.Organisation, c.Project)
+		enable, err := policy.EvalDriftPolicy(policyText, input, tracer)
+		if err != nil {
+			result.Message = err.Error()
+			return result
+		}
+		if c.Enable != nil && *c.Enable != enable {
+			result.Message = fmt.Sprintf("expected enable to be %v, got %v", *c.Enable, enable)
+			return result
+		}
+	}
+	result.Passed = true
+	return result
+}
+
+// compareMessages returns why the messages don't match the expected ones, the order doesn't matter
+func compareMessages(rule string, expected []string, actual []string) string {
+	expected = emptyIfNil(expected)
+	sort.Strings(expected)
+	sort.Strings(actual)
+	if !reflect.DeepEqual(expected, actual) {
+		return fmt.Sprintf("expected %v to be [%v], got [%v]", rule, strings.Join(expected, ", "), strings.Join(actual, ", "))
+	}
+	return ""
+}
+
+// emptyIfNil matches the inputs digger builds, which never pass null lists
+func emptyIfNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
@@ -0,0 +1,138 @@
+package policytest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const accessPolicy = `package digger
+
+default allow = false
+
+allow {
+	input.teams[_] == "admins"
+}
+
+allow {
+	input.action == "digger plan"
+	count(input.planPolicyViolations) == 0
+}
+
+allow {
+	input.labels[_] == "infra-approved"
+	input.planSummary.resourcesDeleted == 0
+	input.time.weekday != "Friday"
+}
+`
+
+const accessPolicyTests = `package digger
+
+test_admins_can_apply {
+	allow with input as {"teams": ["admins"], "action": "digger apply", "planPolicyViolations": []}
+}
+
+test_others_can_not_apply {
+	not allow with input as {"teams": ["devs"], "action": "digger apply", "planPolicyViolations": []}
+}
+`
+
+const planPolicy = `package digger
+
+deny[msg] {
+	resource := input.terraform.resource_changes[_]
+	resource.change.actions[_] == "delete"
+	msg := sprintf("%v can't be deleted", [resource.address])
+}
+
+warn[msg] {
+	resource := input.terraform.resource_changes[_]
+	resource.change.actions[_] == "create"
+	msg := sprintf("%v is new", [resource.address])
+}
+`
+
+func writeFile(t *testing.T, dir string, name string, contents string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+	return path
+}
+
+func TestAccessPolicy(t *testing.T) {
+	dir := t.TempDir()
+	fixtures, err := LoadFixtures(writeFile(t, dir, "fixtures.yml", `
+type: access
+cases:
+  - name: admins can apply
+    teams: [admins]
+    action: digger apply
+    allow: true
+  - name: devs can plan
+    teams: [devs]
+    action: digger plan
+    allow: true
+  - name: devs can apply
+    teams: [devs]
+    action: digger apply
+    allow: true
+  - name: approved changes can be applied
+    teams: [devs]
+    action: digger apply
+    labels: [infra-approved]
+    planSummary:
+      resourcesCreated: 2
+    time: "2024-06-20T09:00:00Z"
+    allow: true
+  - name: approved changes can't be applied on fridays
+    teams: [devs]
+    action: digger apply
+    labels: [infra-approved]
+    planSummary:
+      resourcesCreated: 2
+    time: "2024-06-21T09:00:00Z"
+    allow: false
+`))
+	require.NoError(t, err)
+
+	report, err := Run(accessPolicy, []*Fixtures{fixtures}, map[string]string{"access_test.rego": accessPolicyTests})
+	require.NoError(t, err)
+	require.Len(t, report.Results, 7)
+	require.Equal(t, 1, report.Failed())
+	require.Equal(t, "access: devs can apply", report.Results[2].Name)
+	require.Equal(t, "expected allow to be true, got false", report.Results[2].Message)
+	require.Equal(t, "data.digger.test_admins_can_apply", report.Results[5].Name)
+	require.True(t, report.Results[5].Passed)
+	require.Equal(t, 100.0, report.Coverage)
+}
+
+func TestPlanPolicy(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "destroy.json", `{"resource_changes": [{"address": "aws_s3_bucket.state", "change": {"actions": ["delete"]}}]}`)
+	writeFile(t, dir, "create.json", `{"resource_changes": [{"address": "aws_s3_bucket.state", "change": {"actions": ["create"]}}]}`)
+	fixtures, err := LoadFixtures(writeFile(t, dir, "fixtures.yml", `
+type: plan
+cases:
+  - name: deletes are denied
+    plan: destroy.json
+    deny: ["aws_s3_bucket.state can't be deleted"]
+  - name: creates are allowed with a warning
+    plan: create.json
+    warn: ["aws_s3_bucket.state is new"]
+`))
+	require.NoError(t, err)
+
+	report, err := Run(planPolicy, []*Fixtures{fixtures}, nil)
+	require.NoError(t, err)
+	require.Equal(t, 0, report.Failed())
+	require.Equal(t, 100.0, report.Coverage)
+
+	_, err = Run("package digger\ndeny[msg] {", []*Fixtures{fixtures}, nil)
+	require.Error(t, err)
+}
+
+func TestLoadFixturesRequiresType(t *testing.T) {
+	_, err := LoadFixtures(writeFile(t, t.TempDir(), "fixtures.yml", "cases: []"))
+	require.Error(t, err)
+}
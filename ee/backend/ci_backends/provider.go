@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/buildkite/go-buildkite/v3/buildkite"
 	"github.com/diggerhq/digger/backend/ci_backends"
+	"github.com/diggerhq/digger/backend/models"
 	"github.com/diggerhq/digger/backend/utils"
 	"log"
 	"os"
@@ -13,6 +14,15 @@ type EEBackendProvider struct{}
 
 func (b EEBackendProvider) GetCiBackend(options ci_backends.CiBackendOptions) (ci_backends.CiBackend, error) {
 	ciBackendType := os.Getenv("CI_BACKEND")
+	if ciBackendType == "agent" {
+		return ci_backends.AgentCiBackend()
+	}
+	if ciBackendType == "kubernetes" {
+		return ci_backends.KubernetesCiBackend()
+	}
+	if options.VCS == models.DiggerVCSGitlab {
+		return ci_backends.GitlabCiBackend()
+	}
 	switch ciBackendType {
 	case "github_actions", "":
 		client, _, err := utils.GetGithubClient(&utils.DiggerGithubRealClientProvider{}, options.GithubInstallationId, options.RepoFullName)
@@ -23,7 +33,7 @@ func (b EEBackendProvider) GetCiBackend(options ci_backends.CiBackendOptions) (c
 		backend := &ci_backends.GithubActionCi{
 			Client: client,
 		}
-		return backend, nil
+		return ci_backends.InstrumentedCiBackend{Name: "github_actions", Backend: backend}, nil
 	case "buildkite":
 		token := os.Getenv("BUILDKITE_TOKEN")
 		org := os.Getenv("BUILDKITE_ORG")
@@ -38,7 +48,7 @@ func (b EEBackendProvider) GetCiBackend(options ci_backends.CiBackendOptions) (c
 		}
 		buildkite := buildkite.NewClient(bconfig.Client())
 		ciBackend := &BuildkiteCi{Org: org, Pipeline: pipeline, Client: *buildkite}
-		return ciBackend, nil
+		return ci_backends.InstrumentedCiBackend{Name: "buildkite", Backend: ciBackend}, nil
 	}
 	return nil, fmt.Errorf("unkown ci system: %v", ciBackendType)
 }
@@ -901,7 +901,7 @@ func TestGitHubNewPullRequestContext(t *testing.T) {
 		assert.NoError(t, err)
 		log.Println(err)
 	}
-	_, _, err = digger.RunJobs(jobs, prManager, prManager, lock, reporter, planStorage, policyChecker, comment_updater.NoopCommentUpdater{}, backendApi, "123", false, false, 1, "dir")
+	_, _, err = digger.RunJobs(jobs, prManager, prManager, lock, reporter, planStorage, policyChecker, comment_updater.NoopCommentUpdater{}, backendApi, "123", nil, false, false, 1, "dir")
 
 	assert.NoError(t, err)
 	if err != nil {
@@ -934,7 +934,7 @@ func TestGitHubNewCommentContext(t *testing.T) {
 	event := context.Event.(github.IssueCommentEvent)
 	jobs, _, err := dggithub.ConvertGithubIssueCommentEventToJobs(&event, impactedProjects, requestedProject, map[string]configuration.Workflow{}, "prbranch")
 	assert.NoError(t, err)
-	_, _, err = digger.RunJobs(jobs, prManager, prManager, lock, reporter, planStorage, policyChecker, comment_updater.NoopCommentUpdater{}, backendApi, "123", false, false, 1, "")
+	_, _, err = digger.RunJobs(jobs, prManager, prManager, lock, reporter, planStorage, policyChecker, comment_updater.NoopCommentUpdater{}, backendApi, "123", nil, false, false, 1, "")
 	assert.NoError(t, err)
 	if err != nil {
 		log.Println(err)
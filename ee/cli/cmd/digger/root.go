@@ -12,6 +12,7 @@ import (
 	"github.com/diggerhq/digger/libs/locking"
 	"github.com/diggerhq/digger/libs/orchestrator"
 	orchestrator_github "github.com/diggerhq/digger/libs/orchestrator/github"
+	"github.com/diggerhq/digger/libs/tracing"
 	"github.com/spf13/cobra"
 	"log"
 	"net/http"
@@ -84,9 +85,13 @@ var ReportStrategy reporting.ReportStrategy
 var lock locking.Lock
 
 func PreRun(cmd *cobra.Command, args []string) {
+	tracing.Init("digger-cli")
 
 	hostName := os.Getenv("DIGGER_HOSTNAME")
 	token := os.Getenv("DIGGER_TOKEN")
+	if os.Getenv("DIGGER_USE_OIDC") == "true" {
+		token = backend.OidcAuthToken
+	}
 	orgName := os.Getenv("DIGGER_ORGANISATION")
 	BackendApi = NewBackendApi(hostName, token)
 	PolicyChecker = NewPolicyChecker(hostName, orgName, token)
@@ -135,7 +140,11 @@ func NewBackendApi(hostName string, authToken string) core_backend.Api {
 
 func NewPolicyChecker(hostname string, organisationName string, authToken string) core_policy.Checker {
 	var policyChecker core_policy.Checker
-	if os.Getenv("NO_BACKEND") == "true" {
+	if bundleSource := os.Getenv("DIGGER_POLICY_BUNDLE"); bundleSource != "" {
+		policyChecker = policy.DiggerPolicyChecker{
+			PolicyProvider: policy.NewBundlePolicyProvider(bundleSource, organisationName),
+		}
+	} else if os.Getenv("NO_BACKEND") == "true" {
 		log.Println("WARNING: running in 'backendless' mode. Features that require backend will not be available.")
 		policyChecker = policy.NoOpPolicyChecker{}
 	} else {
@@ -0,0 +1,271 @@
+package codeowners
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Locations are the places GitHub and GitLab look for a CODEOWNERS file, the first one found is used
+var Locations = []string{
+	".github/CODEOWNERS",
+	"CODEOWNERS",
+	"docs/CODEOWNERS",
+	".gitlab/CODEOWNERS",
+}
+
+type Rule struct {
+	Pattern string
+	Owners  []string
+	// GitLab section the rule belongs to, empty for files without sections
+	Section string
+}
+
+type section struct {
+	name string
+	// approval of optional GitLab sections (^[Section]) is not required
+	optional      bool
+	defaultOwners []string
+}
+
+type Codeowners struct {
+	Rules    []Rule
+	sections map[string]section
+}
+
+// Load reads the CODEOWNERS file of the repository checked out at repoDir, it returns nil if there is none
+func Load(repoDir string) (*Codeowners, error) {
+	for _, location := range Locations {
+		f, err := os.Open(filepath.Join(repoDir, location))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not open %v: %v", location, err)
+		}
+		defer f.Close()
+		codeowners, err := Parse(f)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %v: %v", location, err)
+		}
+		return codeowners, nil
+	}
+	return nil, nil
+}
+
+// LoadFromBranch reads the CODEOWNERS file of the branch of the origin remote of the repository checked out at repoDir,
+// it returns nil if there is none. Owners are read from the base branch of a pull request so that the pull request
+// can't change who approves it. The branch is fetched when the checkout doesn't have it, e.g. shallow clones
+func LoadFromBranch(repoDir string, branch string) (*Codeowners, error) {
+	ref := "refs/remotes/origin/" + branch
+	if _, err := git(repoDir, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		if _, err := git(repoDir, "fetch", "--no-tags", "--depth=1", "origin", "+refs/heads/"+branch+":"+ref); err != nil {
+			return nil, fmt.Errorf("could not fetch branch %v: %v", branch, err)
+		}
+	}
+	for _, location := range Locations {
+		if _, err := git(repoDir, "cat-file", "-e", ref+":"+location); err != nil {
+			continue
+		}
+		content, err := git(repoDir, "show", ref+":"+location)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %v of branch %v: %v", location, branch, err)
+		}
+		codeowners, err := Parse(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %v of branch %v: %v", location, branch, err)
+		}
+		return codeowners, nil
+	}
+	return nil, nil
+}
+
+func git(repoDir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = repoDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %v: %v %v", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// Parse parses a GitHub or GitLab CODEOWNERS file, GitLab sections are supported including their default owners
+func Parse(r io.Reader) (*Codeowners, error) {
+	codeowners := &Codeowners{sections: map[string]section{"": {}}}
+	current := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") || strings.HasPrefix(line, "^[") {
+			s, err := parseSection(line)
+			if err != nil {
+				return nil, err
+			}
+			// sections with the same name are combined, as GitLab does
+			current = strings.ToLower(s.name)
+			if existing, ok := codeowners.sections[current]; ok {
+				s.defaultOwners = append(existing.defaultOwners, s.defaultOwners...)
+			}
+			codeowners.sections[current] = s
+			continue
+		}
+
+		fields := strings.Fields(stripComment(line))
+		owners := append([]string{}, fields[1:]...)
+		codeowners.Rules = append(codeowners.Rules, Rule{Pattern: fields[0], Owners: owners, Section: current})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return codeowners, nil
+}
+
+// parseSection parses GitLab section headers like "^[Infrastructure][2] @infra-team"
+func parseSection(line string) (section, error) {
+	s := section{optional: strings.HasPrefix(line, "^")}
+	line = strings.TrimPrefix(line, "^")
+	end := strings.Index(line, "]")
+	if end < 0 {
+		return s, fmt.Errorf("invalid section header: %v", line)
+	}
+	s.name = line[1:end]
+	rest := line[end+1:]
+	// skip the number of required approvals
+	if strings.HasPrefix(rest, "[") {
+		if end := strings.Index(rest, "]"); end >= 0 {
+			rest = rest[end+1:]
+		}
+	}
+	s.defaultOwners = strings.Fields(stripComment(rest))
+	return s, nil
+}
+
+func stripComment(line string) string {
+	if i := strings.Index(line, " #"); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// Owners returns the owners of a path relative to the repository root, "." is the root itself. The last matching rule
+// wins, for GitLab files the owners of the last matching rule of every required section are combined.
+func (c *Codeowners) Owners(filePath string) []string {
+	filePath = strings.Trim(path.Clean("/"+filePath), "/")
+	matches := make(map[string]Rule)
+	order := make([]string, 0)
+	for _, rule := range c.Rules {
+		if !matchPattern(rule.Pattern, filePath) {
+			continue
+		}
+		if _, ok := matches[rule.Section]; !ok {
+			order = append(order, rule.Section)
+		}
+		matches[rule.Section] = rule
+	}
+
+	owners := make([]string, 0)
+	for _, name := range order {
+		s := c.sections[name]
+		if s.optional {
+			continue
+		}
+		rule := matches[name]
+		if len(rule.Owners) == 0 {
+			owners = appendUnique(owners, s.defaultOwners...)
+		} else {
+			owners = appendUnique(owners, rule.Owners...)
+		}
+	}
+	return owners
+}
+
+// matchPattern matches a gitignore style CODEOWNERS pattern, patterns also match everything below a matching directory
+func matchPattern(pattern string, filePath string) bool {
+	anchored := strings.HasPrefix(pattern, "/") || strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "" {
+		return false
+	}
+	// the root is only owned by the rules owning every file
+	if filePath == "" {
+		return pattern == "*" || pattern == "**"
+	}
+	if !anchored && !strings.HasPrefix(pattern, "**/") {
+		pattern = "**/" + pattern
+	}
+	for _, p := range []string{pattern, pattern + "/**"} {
+		if matched, err := doublestar.Match(p, filePath); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(values []string, extra ...string) []string {
+	for _, v := range extra {
+		found := false
+		for _, existing := range values {
+			if strings.EqualFold(existing, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// StaticPrefix returns the leading path segments of a glob pattern that contain no wildcards, it is used to find the
+// owners of the directory an include pattern points to
+func StaticPrefix(pattern string) string {
+	segments := strings.Split(path.Clean(pattern), "/")
+	static := make([]string, 0)
+	for _, segment := range segments {
+		if strings.ContainsAny(segment, "*?[{") {
+			break
+		}
+		static = append(static, segment)
+	}
+	return strings.Join(static, "/")
+}
+
+// IsOwner reports whether the user or one of the user's teams is in owners. Owners are @user, @org/team or for GitLab
+// @group/subgroup, teams of the organisation are matched by the full slug of their name, teams given as full paths
+// such as GitLab subgroups by their path.
+func IsOwner(owners []string, organisation string, user string, teams []string) bool {
+	for _, owner := range owners {
+		owner = strings.TrimPrefix(owner, "@")
+		if strings.EqualFold(owner, user) {
+			return true
+		}
+		if !strings.Contains(owner, "/") {
+			continue
+		}
+		for _, t := range teams {
+			if strings.EqualFold(owner, t) || strings.EqualFold(owner, organisation+"/"+t) || strings.EqualFold(owner, organisation+"/"+slug(t)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func slug(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), "-"))
+}
@@ -0,0 +1,141 @@
+package codeowners
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const githubCodeowners = `
+# default owners
+*           @diggerhq/platform
+/prod/      @diggerhq/infra @alice
+staging/**  @bob
+/prod/legacy/
+*.md        @docs-writer
+`
+
+const gitlabCodeowners = `
+* @platform
+
+[Infrastructure][2] @infra-group
+/prod/
+/staging/ @bob
+
+^[Docs]
+/prod/ @docs-writer
+`
+
+func TestOwnersLastMatchingRuleWins(t *testing.T) {
+	codeowners, err := Parse(strings.NewReader(githubCodeowners))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"@diggerhq/infra", "@alice"}, codeowners.Owners("prod"))
+	assert.Equal(t, []string{"@diggerhq/infra", "@alice"}, codeowners.Owners("prod/main.tf"))
+	assert.Equal(t, []string{"@docs-writer"}, codeowners.Owners("prod/README.md"))
+	assert.Equal(t, []string{"@bob"}, codeowners.Owners("staging/vpc"))
+	assert.Equal(t, []string{"@diggerhq/platform"}, codeowners.Owners("dev/staging-like"))
+	// a rule without owners leaves the path unowned
+	assert.Empty(t, codeowners.Owners("prod/legacy/main.tf"))
+}
+
+func TestOwnersOfRootAndExtensionRules(t *testing.T) {
+	codeowners, err := Parse(strings.NewReader("* @platform\n*.tf @infra\n/prod/ @alice\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"@platform"}, codeowners.Owners("."))
+	assert.Equal(t, []string{"@platform"}, codeowners.Owners(""))
+	assert.Equal(t, []string{"@infra"}, codeowners.Owners("main.tf"))
+	assert.Equal(t, []string{"@infra"}, codeowners.Owners("modules/vpc/main.tf"))
+	assert.Equal(t, []string{"@alice"}, codeowners.Owners("prod/main.tf"))
+
+	// rules of single files don't own the root
+	codeowners, err = Parse(strings.NewReader("*.tf @infra\n"))
+	require.NoError(t, err)
+	assert.Empty(t, codeowners.Owners("."))
+}
+
+func TestOwnersCombinesGitlabSections(t *testing.T) {
+	codeowners, err := Parse(strings.NewReader(gitlabCodeowners))
+	require.NoError(t, err)
+
+	// rules without owners use the default owners of their section, optional sections are ignored
+	assert.Equal(t, []string{"@platform", "@infra-group"}, codeowners.Owners("prod"))
+	assert.Equal(t, []string{"@platform", "@bob"}, codeowners.Owners("staging/main.tf"))
+	assert.Equal(t, []string{"@platform"}, codeowners.Owners("dev"))
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	codeowners, err := Load(dir)
+	require.NoError(t, err)
+	assert.Nil(t, codeowners)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".gitlab"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitlab", "CODEOWNERS"), []byte(gitlabCodeowners), 0644))
+	codeowners, err = Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"@platform", "@bob"}, codeowners.Owners("staging"))
+}
+
+func gitRun(t *testing.T, dir string, args ...string) {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=digger", "-c", "user.email=digger@example.com"}, args...)...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+}
+
+func TestLoadFromBranch(t *testing.T) {
+	origin := t.TempDir()
+	gitRun(t, origin, "init", "--initial-branch=main")
+	require.NoError(t, os.WriteFile(filepath.Join(origin, "CODEOWNERS"), []byte("* @platform\n"), 0644))
+	gitRun(t, origin, "add", "CODEOWNERS")
+	gitRun(t, origin, "commit", "-m", "owners")
+	gitRun(t, origin, "checkout", "-b", "feature")
+	require.NoError(t, os.WriteFile(filepath.Join(origin, "CODEOWNERS"), []byte("* @mallory\n"), 0644))
+	gitRun(t, origin, "commit", "-am", "take over")
+
+	// shallow clones of the pull request branch don't have the base branch
+	dir := t.TempDir()
+	gitRun(t, dir, "clone", "--depth=1", "--branch=feature", "file://"+origin, ".")
+	codeowners, err := LoadFromBranch(dir, "main")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"@platform"}, codeowners.Owners("prod"))
+
+	codeowners, err = LoadFromBranch(dir, "feature")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"@mallory"}, codeowners.Owners("prod"))
+
+	_, err = LoadFromBranch(dir, "unknown")
+	assert.Error(t, err)
+
+	gitRun(t, origin, "checkout", "-b", "unowned", "main")
+	gitRun(t, origin, "rm", "CODEOWNERS")
+	gitRun(t, origin, "commit", "-m", "no owners")
+	codeowners, err = LoadFromBranch(dir, "unowned")
+	require.NoError(t, err)
+	assert.Nil(t, codeowners)
+}
+
+func TestStaticPrefix(t *testing.T) {
+	assert.Equal(t, "modules", StaticPrefix("prod/../modules/**"))
+	assert.Equal(t, "prod", StaticPrefix("prod/*.tf"))
+	assert.Equal(t, "prod/vpc", StaticPrefix("prod/vpc"))
+}
+
+func TestIsOwner(t *testing.T) {
+	owners := []string{"@diggerhq/infra-team", "@alice", "@group/platform"}
+	assert.True(t, IsOwner(owners, "diggerhq", "Alice", nil))
+	assert.True(t, IsOwner(owners, "diggerhq", "bob", []string{"Infra Team"}))
+	assert.False(t, IsOwner(owners, "diggerhq", "bob", []string{"platform"}))
+	assert.False(t, IsOwner(nil, "diggerhq", "alice", nil))
+	// a team of the same name in another organisation doesn't own
+	assert.False(t, IsOwner(owners, "otherorg", "bob", []string{"infra-team"}))
+	// gitlab subgroups are given by their full path
+	assert.True(t, IsOwner(owners, "group", "bob", []string{"group/platform"}))
+}
@@ -102,6 +102,10 @@ func (svc MockCiService) GetBranchName(prNumber int) (string, string, error) {
 	return "", "", nil
 }
 
+func (svc MockCiService) GetPullRequestDetails(prNumber int) (*orchestrator.PullRequestDetails, error) {
+	return &orchestrator.PullRequestDetails{Labels: []string{}}, nil
+}
+
 func (svc MockCiService) SetOutput(prNumber int, key string, value string) error {
 	return nil
 }
@@ -14,6 +14,7 @@ type DiggerConfig struct {
 	Workflows                  map[string]Workflow
 	MentionDriftedProjectsInPR bool
 	TraverseToNestedProjects   bool
+	RequireCodeownersApproval  bool
 }
 
 type DependencyConfiguration struct {
@@ -39,6 +40,12 @@ type Project struct {
 	DependencyProjects []string
 	DriftDetection     bool
 	AwsRoleToAssume    *AssumeRoleForProject
+	// AgentPool selects the pool of agents that run the project's jobs with the agent ci backend
+	AgentPool string
+	// RequireCodeownersApproval blocks applies until a code owner of the project's files approved the pull request
+	RequireCodeownersApproval bool
+	// Protect lists address globs and types of resources an apply may only delete or replace with --confirm-destroy
+	Protect []string
 }
 
 type Workflow struct {
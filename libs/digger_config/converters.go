@@ -16,7 +16,7 @@ const (
 	DependencyConfigurationSoft = "soft"
 )
 
-func copyProjects(projects []*ProjectYaml) []Project {
+func copyProjects(projects []*ProjectYaml, requireCodeownersApproval bool) []Project {
 	result := make([]Project, len(projects))
 	for i, p := range projects {
 		driftDetection := true
@@ -47,6 +47,11 @@ func copyProjects(projects []*ProjectYaml) []Project {
 			}
 		}
 
+		projectRequiresCodeownersApproval := requireCodeownersApproval
+		if p.RequireCodeownersApproval != nil {
+			projectRequiresCodeownersApproval = *p.RequireCodeownersApproval
+		}
+
 		workflowFile := "digger_workflow.yml"
 		if p.WorkflowFile != nil {
 			workflowFile = *p.WorkflowFile
@@ -64,6 +69,9 @@ func copyProjects(projects []*ProjectYaml) []Project {
 			p.DependencyProjects,
 			driftDetection,
 			roleToAssume,
+			p.AgentPool,
+			projectRequiresCodeownersApproval,
+			p.Protect,
 		}
 		result[i] = item
 	}
@@ -202,6 +210,12 @@ func ConvertDiggerYamlToConfig(diggerYaml *DiggerConfigYaml) (*DiggerConfig, gra
 		diggerConfig.AllowDraftPRs = false
 	}
 
+	if diggerYaml.RequireCodeownersApproval != nil {
+		diggerConfig.RequireCodeownersApproval = *diggerYaml.RequireCodeownersApproval
+	} else {
+		diggerConfig.RequireCodeownersApproval = false
+	}
+
 	// if workflow block is not specified in yaml we create a default one, and add it to every project
 	if diggerYaml.Workflows != nil {
 		workflows := copyWorkflows(diggerYaml.Workflows)
@@ -218,7 +232,7 @@ func ConvertDiggerYamlToConfig(diggerYaml *DiggerConfigYaml) (*DiggerConfig, gra
 		diggerConfig.Workflows[defaultWorkflowName] = workflow
 	}
 
-	projects := copyProjects(diggerYaml.Projects)
+	projects := copyProjects(diggerYaml.Projects, diggerConfig.RequireCodeownersApproval)
 	diggerConfig.Projects = projects
 
 	// update project's workflow if needed
@@ -182,6 +182,71 @@ projects:
 	assert.Equal(t, "arn://abc:xyz:cmd", dg.Projects[0].AwsRoleToAssume.State)
 }
 
+func TestDiggerConfigAgentPool(t *testing.T) {
+	tempDir, teardown := setUp()
+	defer teardown()
+
+	diggerCfg := `
+projects:
+- name: prod
+  dir: prod
+  agent_pool: private
+- name: dev
+  dir: dev
+`
+	deleteFile := createFile(path.Join(tempDir, "digger.yaml"), diggerCfg)
+	defer deleteFile()
+
+	dg, _, _, err := LoadDiggerConfig(tempDir, true)
+	assert.NoError(t, err, "expected error to be nil")
+	assert.Equal(t, "private", dg.Projects[0].AgentPool)
+	assert.Equal(t, "", dg.Projects[1].AgentPool)
+}
+
+func TestDiggerConfigRequireCodeownersApproval(t *testing.T) {
+	tempDir, teardown := setUp()
+	defer teardown()
+
+	diggerCfg := `
+require_codeowners_approval: true
+projects:
+- name: prod
+  dir: prod
+- name: dev
+  dir: dev
+  require_codeowners_approval: false
+`
+	deleteFile := createFile(path.Join(tempDir, "digger.yaml"), diggerCfg)
+	defer deleteFile()
+
+	dg, _, _, err := LoadDiggerConfig(tempDir, true)
+	assert.NoError(t, err, "expected error to be nil")
+	assert.True(t, dg.RequireCodeownersApproval)
+	assert.True(t, dg.Projects[0].RequireCodeownersApproval)
+	assert.False(t, dg.Projects[1].RequireCodeownersApproval)
+}
+
+func TestDiggerConfigProtect(t *testing.T) {
+	tempDir, teardown := setUp()
+	defer teardown()
+
+	diggerCfg := `
+projects:
+- name: prod
+  dir: prod
+  protect: ["aws_db_instance.*", "aws_s3_bucket.state"]
+- name: dev
+  dir: dev
+`
+	deleteFile := createFile(path.Join(tempDir, "digger.yaml"), diggerCfg)
+	defer deleteFile()
+
+	dg, _, _, err := LoadDiggerConfig(tempDir, true)
+	assert.NoError(t, err, "expected error to be nil")
+	assert.Equal(t, []string{"aws_db_instance.*", "aws_s3_bucket.state"}, dg.Projects[0].Protect)
+	assert.Empty(t, dg.Projects[1].Protect)
+}
+
 func TestDiggerConfigDefaultWorkflow(t *testing.T) {
 	tempDir, teardown := setUp()
 	defer teardown()
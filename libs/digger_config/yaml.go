@@ -18,6 +18,7 @@ type DiggerConfigYaml struct {
 	GenerateProjectsConfig     *GenerateProjectsConfigYaml  `yaml:"generate_projects"`
 	TraverseToNestedProjects   *bool                        `yaml:"traverse_to_nested_projects"`
 	MentionDriftedProjectsInPR *bool                        `yaml:"mention_drifted_projects_in_pr"`
+	RequireCodeownersApproval  *bool                        `yaml:"require_codeowners_approval"`
 }
 
 type DependencyConfigurationYaml struct {
@@ -37,6 +38,11 @@ type ProjectYaml struct {
 	DependencyProjects []string                    `yaml:"depends_on,omitempty"`
 	DriftDetection     *bool                       `yaml:"drift_detection,omitempty"`
 	AwsRoleToAssume    *AssumeRoleForProjectConfig `yaml:"aws_role_to_assume,omitempty"`
+	AgentPool          string                      `yaml:"agent_pool,omitempty"`
+	// overrides the top level require_codeowners_approval for the project
+	RequireCodeownersApproval *bool `yaml:"require_codeowners_approval,omitempty"`
+	// address globs and types of resources that can't be destroyed without confirmation
+	Protect []string `yaml:"protect,omitempty"`
 }
 
 type WorkflowYaml struct {
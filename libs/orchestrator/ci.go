@@ -9,6 +9,8 @@ type PullRequestService interface {
 	CreateCommentReaction(id interface{}, reaction string) error
 	GetComments(prNumber int) ([]Comment, error)
 	GetApprovals(prNumber int) ([]string, error)
+	// GetPullRequestDetails returns the author, labels, branches and head commit of the pull request, read with one request
+	GetPullRequestDetails(prNumber int) (*PullRequestDetails, error)
 	// SetStatus set status of specified pull/merge request, status could be: "pending", "failure", "success"
 	SetStatus(prNumber int, status string, statusContext string) error
 	GetCombinedPullRequestStatus(prNumber int) (string, error)
@@ -23,6 +25,17 @@ type PullRequestService interface {
 	SetOutput(prNumber int, key string, value string) error
 }
 
+// PullRequestDetails are the properties of a pull request that access policies are evaluated against
+type PullRequestDetails struct {
+	// Author is the user who opened the pull request
+	Author    string
+	Labels    []string
+	Branch    string
+	CommitSha string
+	// BaseBranch is the branch the pull request merges into
+	BaseBranch string
+}
+
 type OrgService interface {
 	GetUserTeams(organisation string, user string) ([]string, error)
 }
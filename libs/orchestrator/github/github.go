@@ -15,7 +15,7 @@ import (
 )
 
 func NewGitHubService(ghToken string, repoName string, owner string) GithubService {
-	client := github.NewClient(nil)
+	client := NewGithubClient(nil)
 	if ghToken != "" {
 		client = client.WithAuthToken(ghToken)
 	}
@@ -300,13 +300,33 @@ func (svc GithubService) SetOutput(prNumber int, key string, value string) error
 func (svc GithubService) GetBranchName(prNumber int) (string, string, error) {
 	pr, _, err := svc.Client.PullRequests.Get(context.Background(), svc.Owner, svc.RepoName, prNumber)
 	if err != nil {
-		log.Fatalf("error getting pull request: %v", err)
+		log.Printf("error getting pull request: %v", err)
 		return "", "", err
 	}
 
 	return pr.Head.GetRef(), pr.Head.GetSHA(), nil
 }
 
+func (svc GithubService) GetPullRequestDetails(prNumber int) (*orchestrator.PullRequestDetails, error) {
+	pr, _, err := svc.Client.PullRequests.Get(context.Background(), svc.Owner, svc.RepoName, prNumber)
+	if err != nil {
+		log.Printf("error getting pull request: %v", err)
+		return nil, err
+	}
+
+	labels := make([]string, 0, len(pr.Labels))
+	for _, label := range pr.Labels {
+		labels = append(labels, label.GetName())
+	}
+	return &orchestrator.PullRequestDetails{
+		Author:     pr.GetUser().GetLogin(),
+		Labels:     labels,
+		Branch:     pr.Head.GetRef(),
+		CommitSha:  pr.Head.GetSHA(),
+		BaseBranch: pr.Base.GetRef(),
+	}, nil
+}
+
 func ConvertGithubPullRequestEventToJobs(payload *github.PullRequestEvent, impactedProjects []digger_config.Project, requestedProject *digger_config.Project, config digger_config.DiggerConfig) ([]orchestrator.Job, bool, error) {
 	workflows := config.Workflows
 	jobs := make([]orchestrator.Job, 0)
@@ -330,6 +350,9 @@ func ConvertGithubPullRequestEventToJobs(payload *github.PullRequestEvent, impac
 			jobs = append(jobs, orchestrator.Job{
 				ProjectName:        project.Name,
 				ProjectDir:         project.Dir,
+				IncludePatterns:    project.IncludePatterns,
+				RequireCodeowners:  project.RequireCodeownersApproval,
+				Protect:            project.Protect,
 				ProjectWorkspace:   project.Workspace,
 				ProjectWorkflow:    project.Workflow,
 				Terragrunt:         project.Terragrunt,
@@ -350,6 +373,9 @@ func ConvertGithubPullRequestEventToJobs(payload *github.PullRequestEvent, impac
 			jobs = append(jobs, orchestrator.Job{
 				ProjectName:        project.Name,
 				ProjectDir:         project.Dir,
+				IncludePatterns:    project.IncludePatterns,
+				RequireCodeowners:  project.RequireCodeownersApproval,
+				Protect:            project.Protect,
 				ProjectWorkspace:   project.Workspace,
 				ProjectWorkflow:    project.Workflow,
 				Terragrunt:         project.Terragrunt,
@@ -371,6 +397,9 @@ func ConvertGithubPullRequestEventToJobs(payload *github.PullRequestEvent, impac
 			jobs = append(jobs, orchestrator.Job{
 				ProjectName:        project.Name,
 				ProjectDir:         project.Dir,
+				IncludePatterns:    project.IncludePatterns,
+				RequireCodeowners:  project.RequireCodeownersApproval,
+				Protect:            project.Protect,
 				ProjectWorkspace:   project.Workspace,
 				ProjectWorkflow:    project.Workflow,
 				Terragrunt:         project.Terragrunt,
@@ -399,6 +428,9 @@ func ConvertGithubPullRequestEventToJobs(payload *github.PullRequestEvent, impac
 			jobs = append(jobs, orchestrator.Job{
 				ProjectName:        project.Name,
 				ProjectDir:         project.Dir,
+				IncludePatterns:    project.IncludePatterns,
+				RequireCodeowners:  project.RequireCodeownersApproval,
+				Protect:            project.Protect,
 				ProjectWorkspace:   project.Workspace,
 				ProjectWorkflow:    project.Workflow,
 				Terragrunt:         project.Terragrunt,
@@ -472,6 +504,10 @@ func ConvertGithubIssueCommentEventToJobs(payload *github.IssueCommentEvent, imp
 	if err != nil {
 		return nil, false, err
 	}
+	confirmDestroy := orchestrator.ParseConfirmDestroy(*payload.Comment.Body)
+	for i := range jobs {
+		jobs[i].ConfirmDestroy = confirmDestroy
+	}
 
 	return jobs, coversAllImpactedProjects, nil
 
@@ -493,6 +529,9 @@ func CreateJobsForProjects(projects []digger_config.Project, command string, eve
 		jobs = append(jobs, orchestrator.Job{
 			ProjectName:        project.Name,
 			ProjectDir:         project.Dir,
+			IncludePatterns:    project.IncludePatterns,
+			RequireCodeowners:  project.RequireCodeownersApproval,
+			Protect:            project.Protect,
 			ProjectWorkspace:   workspace,
 			ProjectWorkflow:    project.Workflow,
 			Terragrunt:         project.Terragrunt,
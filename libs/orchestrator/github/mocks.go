@@ -102,6 +102,10 @@ func (t MockCiService) GetBranchName(prNumber int) (string, string, error) {
 	return "", "", nil
 }
 
+func (t MockCiService) GetPullRequestDetails(prNumber int) (*orchestrator.PullRequestDetails, error) {
+	return &orchestrator.PullRequestDetails{Labels: []string{}}, nil
+}
+
 func (svc MockCiService) SetOutput(prNumber int, key string, value string) error {
 	//TODO implement me
 	return nil
@@ -0,0 +1,89 @@
+package github
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v61/github"
+)
+
+const (
+	DefaultApiUrl = "https://api.github.com"
+	DefaultWebUrl = "https://github.com"
+)
+
+// GithubUrls are the endpoints of the GitHub instance digger talks to, github.com unless GitHub Enterprise Server is configured
+type GithubUrls struct {
+	// ApiUrl is the REST api, e.g. https://ghes.example.com/api/v3
+	ApiUrl string
+	// UploadUrl is used for release asset uploads, e.g. https://ghes.example.com/api/uploads
+	UploadUrl string
+	// WebUrl is where repositories, the app settings and the oauth endpoints live, e.g. https://ghes.example.com
+	WebUrl string
+}
+
+// GithubUrlsFromEnv reads GITHUB_SERVER_URL, GITHUB_API_URL and GITHUB_UPLOAD_URL. The first two are also set by
+// GitHub Actions so jobs running on GHES runners pick them up without extra configuration.
+func GithubUrlsFromEnv() GithubUrls {
+	webUrl := strings.TrimSuffix(os.Getenv("GITHUB_SERVER_URL"), "/")
+	apiUrl := strings.TrimSuffix(os.Getenv("GITHUB_API_URL"), "/")
+	uploadUrl := strings.TrimSuffix(os.Getenv("GITHUB_UPLOAD_URL"), "/")
+
+	if (webUrl == "" || webUrl == DefaultWebUrl) && (apiUrl == "" || apiUrl == DefaultApiUrl) {
+		return GithubUrls{ApiUrl: DefaultApiUrl, UploadUrl: "https://uploads.github.com", WebUrl: DefaultWebUrl}
+	}
+	if webUrl == "" {
+		webUrl = strings.TrimSuffix(apiUrl, "/api/v3")
+	}
+	if apiUrl == "" {
+		apiUrl = webUrl + "/api/v3"
+	}
+	if uploadUrl == "" {
+		uploadUrl = webUrl + "/api/uploads"
+	}
+	return GithubUrls{ApiUrl: apiUrl, UploadUrl: uploadUrl, WebUrl: webUrl}
+}
+
+func (u GithubUrls) IsEnterprise() bool {
+	return u.ApiUrl != "" && u.ApiUrl != DefaultApiUrl
+}
+
+// NewClient returns a client for the configured instance, httpClient may be nil
+func (u GithubUrls) NewClient(httpClient *http.Client) (*github.Client, error) {
+	client := github.NewClient(httpClient)
+	if !u.IsEnterprise() {
+		return client, nil
+	}
+	client, err := client.WithEnterpriseURLs(u.ApiUrl, u.UploadUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid github enterprise urls: %v", err)
+	}
+	return client, nil
+}
+
+// Web returns the web url of the instance, github.com if none is configured
+func (u GithubUrls) Web() string {
+	if u.WebUrl == "" {
+		return DefaultWebUrl
+	}
+	return u.WebUrl
+}
+
+// RepoUrl returns the web url of a repository, repoFullName being owner/name
+func (u GithubUrls) RepoUrl(repoFullName string) string {
+	return fmt.Sprintf("%v/%v", u.Web(), repoFullName)
+}
+
+// NewGithubClient returns a client for the instance configured in the environment, falling back to github.com
+// if the configured urls are invalid
+func NewGithubClient(httpClient *http.Client) *github.Client {
+	client, err := GithubUrlsFromEnv().NewClient(httpClient)
+	if err != nil {
+		log.Printf("%v, falling back to github.com", err)
+		return github.NewClient(httpClient)
+	}
+	return client
+}
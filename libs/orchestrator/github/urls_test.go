@@ -0,0 +1,62 @@
+package github
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGithubUrlsFromEnvDefaultsToGithubCom(t *testing.T) {
+	t.Setenv("GITHUB_SERVER_URL", "")
+	t.Setenv("GITHUB_API_URL", "")
+	t.Setenv("GITHUB_UPLOAD_URL", "")
+	urls := GithubUrlsFromEnv()
+	assert.False(t, urls.IsEnterprise())
+	assert.Equal(t, "https://github.com/diggerhq/digger", urls.RepoUrl("diggerhq/digger"))
+
+	// the values github actions sets on github.com runners
+	t.Setenv("GITHUB_SERVER_URL", "https://github.com")
+	t.Setenv("GITHUB_API_URL", "https://api.github.com")
+	assert.False(t, GithubUrlsFromEnv().IsEnterprise())
+}
+
+func TestGithubUrlsFromEnvEnterprise(t *testing.T) {
+	t.Setenv("GITHUB_SERVER_URL", "https://ghes.example.com/")
+	t.Setenv("GITHUB_API_URL", "")
+	t.Setenv("GITHUB_UPLOAD_URL", "")
+	assert.Equal(t, GithubUrls{
+		ApiUrl:    "https://ghes.example.com/api/v3",
+		UploadUrl: "https://ghes.example.com/api/uploads",
+		WebUrl:    "https://ghes.example.com",
+	}, GithubUrlsFromEnv())
+
+	t.Setenv("GITHUB_SERVER_URL", "")
+	t.Setenv("GITHUB_API_URL", "https://ghes.example.com/api/v3")
+	urls := GithubUrlsFromEnv()
+	assert.True(t, urls.IsEnterprise())
+	assert.Equal(t, "https://ghes.example.com", urls.WebUrl)
+}
+
+func TestNewGitHubServiceUsesEnterpriseHost(t *testing.T) {
+	var requestedPath, authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"number": 7, "state": "open", "head": {"ref": "feature", "sha": "abc"}}`))
+	}))
+	defer server.Close()
+	t.Setenv("GITHUB_SERVER_URL", server.URL)
+	t.Setenv("GITHUB_API_URL", "")
+	t.Setenv("GITHUB_UPLOAD_URL", "")
+
+	service := NewGitHubService("token", "demo", "diggerhq")
+	branch, sha, err := service.GetBranchName(7)
+	assert.NoError(t, err)
+	assert.Equal(t, "feature", branch)
+	assert.Equal(t, "abc", sha)
+	assert.Equal(t, "/api/v3/repos/diggerhq/demo/pulls/7", requestedPath)
+	assert.Equal(t, "Bearer token", authorization)
+}
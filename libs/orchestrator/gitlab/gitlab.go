@@ -0,0 +1,456 @@
+package gitlab
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/diggerhq/digger/libs/digger_config"
+	orchestrator "github.com/diggerhq/digger/libs/orchestrator"
+	dg_github "github.com/diggerhq/digger/libs/orchestrator/github"
+	"github.com/dominikbraun/graph"
+	"github.com/xanzy/go-gitlab"
+)
+
+// GitLabService implements orchestrator.PullRequestService for the merge requests of a project, pull request numbers are merge request iids
+type GitLabService struct {
+	Client *gitlab.Client
+	// Project is the id or the path with namespace of the project, e.g. group/subgroup/name
+	Project interface{}
+}
+
+// NewGitLabService returns a service for the project, baseUrl is only needed for self-managed instances
+func NewGitLabService(token string, baseUrl string, project interface{}) (*GitLabService, error) {
+	options := make([]gitlab.ClientOptionFunc, 0)
+	if baseUrl != "" {
+		options = append(options, gitlab.WithBaseURL(baseUrl))
+	}
+	client, err := gitlab.NewClient(token, options...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create gitlab client: %v", err)
+	}
+	return &GitLabService{Client: client, Project: project}, nil
+}
+
+func (svc GitLabService) GetChangedFiles(prNumber int) ([]string, error) {
+	fileNames := make([]string, 0)
+	opt := &gitlab.ListMergeRequestDiffsOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+	for {
+		diffs, resp, err := svc.Client.MergeRequests.ListMergeRequestDiffs(svc.Project, prNumber, opt)
+		if err != nil {
+			return nil, fmt.Errorf("error getting changed files of merge request %v: %v", prNumber, err)
+		}
+		for _, diff := range diffs {
+			fileNames = append(fileNames, diff.NewPath)
+			if diff.RenamedFile || diff.DeletedFile {
+				fileNames = append(fileNames, diff.OldPath)
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return fileNames, nil
+}
+
+func (svc GitLabService) PublishComment(prNumber int, comment string) (*orchestrator.Comment, error) {
+	note, _, err := svc.Client.Notes.CreateMergeRequestNote(svc.Project, prNumber, &gitlab.CreateMergeRequestNoteOptions{Body: &comment})
+	if err != nil {
+		return nil, fmt.Errorf("could not publish comment to merge request %v: %v", prNumber, err)
+	}
+	return &orchestrator.Comment{Id: note.ID, Body: &note.Body}, nil
+}
+
+func (svc GitLabService) ListIssues() ([]*orchestrator.Issue, error) {
+	issues, _, err := svc.Client.Issues.ListProjectIssues(svc.Project, &gitlab.ListProjectIssuesOptions{State: gitlab.Ptr("opened")})
+	if err != nil {
+		return nil, fmt.Errorf("could not list issues: %v", err)
+	}
+	result := make([]*orchestrator.Issue, 0)
+	for _, issue := range issues {
+		result = append(result, &orchestrator.Issue{ID: int64(issue.IID), Title: issue.Title, Body: issue.Description})
+	}
+	return result, nil
+}
+
+func (svc GitLabService) PublishIssue(title string, body string) (int64, error) {
+	issue, _, err := svc.Client.Issues.CreateIssue(svc.Project, &gitlab.CreateIssueOptions{Title: &title, Description: &body})
+	if err != nil {
+		return 0, fmt.Errorf("could not publish issue: %v", err)
+	}
+	return int64(issue.IID), nil
+}
+
+func (svc GitLabService) EditComment(prNumber int, id interface{}, comment string) error {
+	noteId, err := strconv.Atoi(fmt.Sprintf("%v", id))
+	if err != nil {
+		return fmt.Errorf("invalid note id %v: %v", id, err)
+	}
+	_, _, err = svc.Client.Notes.UpdateMergeRequestNote(svc.Project, prNumber, noteId, &gitlab.UpdateMergeRequestNoteOptions{Body: &comment})
+	if err != nil {
+		return fmt.Errorf("could not edit comment %v: %v", id, err)
+	}
+	return nil
+}
+
+// MergeRequestNote identifies a note for CreateCommentReaction, award emojis on notes need the merge request of the note
+type MergeRequestNote struct {
+	MergeRequestIID int
+	NoteID          int
+}
+
+// awardEmojis maps the github reactions used for comments to the names of the gitlab award emojis
+var awardEmojis = map[string]string{
+	"+1":     "thumbsup",
+	"-1":     "thumbsdown",
+	"laugh":  "laughing",
+	"hooray": "tada",
+}
+
+// CreateCommentReaction awards the emoji of the reaction to the note, id is a MergeRequestNote
+func (svc GitLabService) CreateCommentReaction(id interface{}, reaction string) error {
+	note, ok := id.(MergeRequestNote)
+	if !ok {
+		return fmt.Errorf("can't react to comment %v, notes are identified by their merge request", id)
+	}
+	name, ok := awardEmojis[reaction]
+	if !ok {
+		name = reaction
+	}
+	_, _, err := svc.Client.AwardEmoji.CreateMergeRequestAwardEmojiOnNote(svc.Project, note.MergeRequestIID, note.NoteID, &gitlab.CreateAwardEmojiOptions{Name: name})
+	if err != nil {
+		return fmt.Errorf("could not react to note %v of merge request %v: %v", note.NoteID, note.MergeRequestIID, err)
+	}
+	return nil
+}
+
+func (svc GitLabService) GetComments(prNumber int) ([]orchestrator.Comment, error) {
+	notes, _, err := svc.Client.Notes.ListMergeRequestNotes(svc.Project, prNumber, &gitlab.ListMergeRequestNotesOptions{ListOptions: gitlab.ListOptions{PerPage: 100}})
+	if err != nil {
+		return nil, fmt.Errorf("could not list comments of merge request %v: %v", prNumber, err)
+	}
+	comments := make([]orchestrator.Comment, 0)
+	for _, note := range notes {
+		comments = append(comments, orchestrator.Comment{Id: note.ID, Body: &note.Body})
+	}
+	return comments, nil
+}
+
+func (svc GitLabService) GetApprovals(prNumber int) ([]string, error) {
+	approvals, _, err := svc.Client.MergeRequestApprovals.GetConfiguration(svc.Project, prNumber)
+	if err != nil {
+		return nil, fmt.Errorf("could not get approvals of merge request %v: %v", prNumber, err)
+	}
+	approvedBy := make([]string, 0)
+	for _, approver := range approvals.ApprovedBy {
+		if approver.User != nil {
+			approvedBy = append(approvedBy, approver.User.Username)
+		}
+	}
+	return approvedBy, nil
+}
+
+// SetStatus sets a commit status on the head of the merge request, statusContext is used as the name of the status
+func (svc GitLabService) SetStatus(prNumber int, status string, statusContext string) error {
+	mr, err := svc.getMergeRequest(prNumber)
+	if err != nil {
+		return err
+	}
+	var state gitlab.BuildStateValue
+	switch status {
+	case "pending":
+		state = gitlab.Pending
+	case "failure", "error":
+		state = gitlab.Failed
+	case "success":
+		state = gitlab.Success
+	default:
+		return fmt.Errorf("unknown status %v", status)
+	}
+	_, _, err = svc.Client.Commits.SetCommitStatus(svc.Project, mr.SHA, &gitlab.SetCommitStatusOptions{
+		State: state,
+		Ref:   &mr.SourceBranch,
+		Name:  &statusContext,
+	})
+	if err != nil {
+		return fmt.Errorf("could not set status %v of merge request %v: %v", statusContext, prNumber, err)
+	}
+	return nil
+}
+
+func (svc GitLabService) GetCombinedPullRequestStatus(prNumber int) (string, error) {
+	mr, err := svc.getMergeRequest(prNumber)
+	if err != nil {
+		return "", err
+	}
+	statuses, _, err := svc.Client.Commits.GetCommitStatuses(svc.Project, mr.SHA, &gitlab.GetCommitStatusesOptions{})
+	if err != nil {
+		return "", fmt.Errorf("could not get statuses of merge request %v: %v", prNumber, err)
+	}
+	combined := "success"
+	for _, status := range statuses {
+		switch gitlab.BuildStateValue(status.Status) {
+		case gitlab.Failed, gitlab.Canceled:
+			return "failure", nil
+		case gitlab.Success, gitlab.Skipped:
+		default:
+			combined = "pending"
+		}
+	}
+	return combined, nil
+}
+
+func (svc GitLabService) MergePullRequest(prNumber int) error {
+	_, _, err := svc.Client.MergeRequests.AcceptMergeRequest(svc.Project, prNumber, &gitlab.AcceptMergeRequestOptions{})
+	if err != nil {
+		return fmt.Errorf("could not merge merge request %v: %v", prNumber, err)
+	}
+	return nil
+}
+
+func (svc GitLabService) IsMergeable(prNumber int) (bool, error) {
+	mr, err := svc.getMergeRequest(prNumber)
+	if err != nil {
+		return false, err
+	}
+	return mr.State == "opened" && mr.DetailedMergeStatus == "mergeable", nil
+}
+
+func (svc GitLabService) IsMerged(prNumber int) (bool, error) {
+	mr, err := svc.getMergeRequest(prNumber)
+	if err != nil {
+		return false, err
+	}
+	return mr.State == "merged", nil
+}
+
+func (svc GitLabService) IsClosed(prNumber int) (bool, error) {
+	mr, err := svc.getMergeRequest(prNumber)
+	if err != nil {
+		return false, err
+	}
+	return mr.State == "closed", nil
+}
+
+func (svc GitLabService) GetBranchName(prNumber int) (string, string, error) {
+	mr, err := svc.getMergeRequest(prNumber)
+	if err != nil {
+		return "", "", err
+	}
+	return mr.SourceBranch, mr.SHA, nil
+}
+
+func (svc GitLabService) GetPullRequestDetails(prNumber int) (*orchestrator.PullRequestDetails, error) {
+	mr, err := svc.getMergeRequest(prNumber)
+	if err != nil {
+		return nil, err
+	}
+	details := &orchestrator.PullRequestDetails{
+		Labels:     mr.Labels,
+		Branch:     mr.SourceBranch,
+		CommitSha:  mr.SHA,
+		BaseBranch: mr.TargetBranch,
+	}
+	if mr.Author != nil {
+		details.Author = mr.Author.Username
+	}
+	return details, nil
+}
+
+func (svc GitLabService) SetOutput(prNumber int, key string, value string) error {
+	return nil
+}
+
+// GetUserTeams returns the full paths of the subgroups of the organisation group the user is a member of
+func (svc GitLabService) GetUserTeams(organisation string, user string) ([]string, error) {
+	users, _, err := svc.Client.Users.ListUsers(&gitlab.ListUsersOptions{Username: &user})
+	if err != nil {
+		return nil, fmt.Errorf("could not find user %v: %v", user, err)
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("user %v not found", user)
+	}
+	userId := users[0].ID
+
+	teams := make([]string, 0)
+	opt := &gitlab.ListDescendantGroupsOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+	for {
+		groups, resp, err := svc.Client.Groups.ListDescendantGroups(organisation, opt)
+		if err != nil {
+			return nil, fmt.Errorf("could not list subgroups of %v: %v", organisation, err)
+		}
+		for _, group := range groups {
+			_, memberResp, err := svc.Client.GroupMembers.GetGroupMember(group.ID, userId)
+			if err != nil {
+				if memberResp != nil && memberResp.StatusCode == http.StatusNotFound {
+					continue
+				}
+				return nil, fmt.Errorf("could not check membership of %v in %v: %v", user, group.FullPath, err)
+			}
+			teams = append(teams, group.FullPath)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return teams, nil
+}
+
+func (svc GitLabService) getMergeRequest(prNumber int) (*gitlab.MergeRequest, error) {
+	mr, _, err := svc.Client.MergeRequests.GetMergeRequest(svc.Project, prNumber, &gitlab.GetMergeRequestsOptions{})
+	if err != nil {
+		log.Printf("could not get merge request %v: %v", prNumber, err)
+		return nil, fmt.Errorf("could not get merge request %v: %v", prNumber, err)
+	}
+	return mr, nil
+}
+
+// ProcessGitLabMergeRequestEvent returns the projects impacted by the files changed in the merge request
+func ProcessGitLabMergeRequestEvent(payload *gitlab.MergeEvent, diggerConfig *digger_config.DiggerConfig, dependencyGraph graph.Graph[string, digger_config.Project], ciService orchestrator.PullRequestService) ([]digger_config.Project, map[string]digger_config.ProjectToSourceMapping, error) {
+	changedFiles, err := ciService.GetChangedFiles(payload.ObjectAttributes.IID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get changed files: %v", err)
+	}
+	impactedProjects, impactedProjectsSourceLocations := diggerConfig.GetModifiedProjects(changedFiles)
+
+	if diggerConfig.DependencyConfiguration.Mode == digger_config.DependencyConfigurationHard {
+		impactedProjects, err = dg_github.FindAllProjectsDependantOnImpactedProjects(impactedProjects, dependencyGraph)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to find all projects dependant on impacted projects")
+		}
+	}
+	return impactedProjects, impactedProjectsSourceLocations, nil
+}
+
+// ConvertGitLabMergeRequestEventToJobs maps merge request actions to the commands of the project workflows
+func ConvertGitLabMergeRequestEventToJobs(payload *gitlab.MergeEvent, impactedProjects []digger_config.Project, config digger_config.DiggerConfig) ([]orchestrator.Job, error) {
+	jobs := make([]orchestrator.Job, 0)
+	attributes := payload.ObjectAttributes
+	defaultBranch := payload.Project.DefaultBranch
+	prNumber := attributes.IID
+
+	for _, project := range impactedProjects {
+		workflow, ok := config.Workflows[project.Workflow]
+		if !ok {
+			return nil, fmt.Errorf("failed to find workflow config '%s' for project '%s'", project.Workflow, project.Name)
+		}
+
+		var commands []string
+		switch attributes.Action {
+		case "open", "reopen":
+			commands = workflow.Configuration.OnPullRequestPushed
+		case "update":
+			// updates without a new revision are edits of the title, labels, etc.
+			if attributes.OldRev == "" {
+				continue
+			}
+			commands = workflow.Configuration.OnPullRequestPushed
+		case "merge":
+			if attributes.TargetBranch != defaultBranch {
+				continue
+			}
+			commands = workflow.Configuration.OnCommitToDefault
+		case "close":
+			commands = workflow.Configuration.OnPullRequestClosed
+		default:
+			continue
+		}
+
+		runEnvVars := dg_github.GetRunEnvVars(defaultBranch, attributes.SourceBranch, project.Name, project.Dir)
+		stateEnvVars, commandEnvVars := digger_config.CollectTerraformEnvConfig(workflow.EnvVars)
+		StateEnvProvider, CommandEnvProvider := orchestrator.GetStateAndCommandProviders(project)
+		jobs = append(jobs, orchestrator.Job{
+			ProjectName:        project.Name,
+			ProjectDir:         project.Dir,
+			IncludePatterns:    project.IncludePatterns,
+			RequireCodeowners:  project.RequireCodeownersApproval,
+			Protect:            project.Protect,
+			ProjectWorkspace:   project.Workspace,
+			ProjectWorkflow:    project.Workflow,
+			Terragrunt:         project.Terragrunt,
+			OpenTofu:           project.OpenTofu,
+			Commands:           commands,
+			ApplyStage:         orchestrator.ToConfigStage(workflow.Apply),
+			PlanStage:          orchestrator.ToConfigStage(workflow.Plan),
+			RunEnvVars:         runEnvVars,
+			CommandEnvVars:     commandEnvVars,
+			StateEnvVars:       stateEnvVars,
+			PullRequestNumber:  &prNumber,
+			EventName:          "merge_request",
+			Namespace:          payload.Project.PathWithNamespace,
+			RequestedBy:        payload.User.Username,
+			CommandEnvProvider: CommandEnvProvider,
+			StateEnvProvider:   StateEnvProvider,
+		})
+	}
+	return jobs, nil
+}
+
+// ProcessGitLabNoteEvent returns the projects impacted by the merge request and the project requested in the comment, if any
+func ProcessGitLabNoteEvent(payload *gitlab.MergeCommentEvent, diggerConfig *digger_config.DiggerConfig, dependencyGraph graph.Graph[string, digger_config.Project], ciService orchestrator.PullRequestService) ([]digger_config.Project, map[string]digger_config.ProjectToSourceMapping, *digger_config.Project, error) {
+	changedFiles, err := ciService.GetChangedFiles(payload.MergeRequest.IID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("could not get changed files: %v", err)
+	}
+	impactedProjects, impactedProjectsSourceLocations := diggerConfig.GetModifiedProjects(changedFiles)
+
+	if diggerConfig.DependencyConfiguration.Mode == digger_config.DependencyConfigurationHard {
+		impactedProjects, err = dg_github.FindAllProjectsDependantOnImpactedProjects(impactedProjects, dependencyGraph)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to find all projects dependant on impacted projects")
+		}
+	}
+
+	requestedProject := orchestrator.ParseProjectName(payload.ObjectAttributes.Note)
+	if requestedProject == "" {
+		return impactedProjects, impactedProjectsSourceLocations, nil, nil
+	}
+	for _, project := range impactedProjects {
+		if project.Name == requestedProject {
+			return impactedProjects, impactedProjectsSourceLocations, &project, nil
+		}
+	}
+	return nil, nil, nil, fmt.Errorf("requested project not found in modified projects")
+}
+
+// ConvertGitLabNoteEventToJobs creates jobs for the digger command in the comment
+func ConvertGitLabNoteEventToJobs(payload *gitlab.MergeCommentEvent, impactedProjects []digger_config.Project, requestedProject *digger_config.Project, workflows map[string]digger_config.Workflow) ([]orchestrator.Job, bool, error) {
+	supportedCommands := []string{"digger plan", "digger apply", "digger unlock", "digger lock"}
+
+	coversAllImpactedProjects := true
+	runForProjects := impactedProjects
+	if requestedProject != nil {
+		if len(impactedProjects) > 1 {
+			coversAllImpactedProjects = false
+			runForProjects = []digger_config.Project{*requestedProject}
+		} else if len(impactedProjects) == 1 && impactedProjects[0].Name != requestedProject.Name {
+			return nil, false, fmt.Errorf("requested project %v is not impacted by this merge request", requestedProject.Name)
+		}
+	}
+
+	diggerCommand := strings.TrimSpace(strings.ToLower(payload.ObjectAttributes.Note))
+	commandToRun := ""
+	for _, command := range supportedCommands {
+		if strings.HasPrefix(diggerCommand, command) {
+			commandToRun = command
+		}
+	}
+	if commandToRun == "" {
+		return nil, false, fmt.Errorf("command is not supported: %v", diggerCommand)
+	}
+
+	prNumber := payload.MergeRequest.IID
+	jobs, err := dg_github.CreateJobsForProjects(runForProjects, commandToRun, "merge_request_note", payload.Project.PathWithNamespace, payload.User.Username, workflows, &prNumber, nil, payload.Project.DefaultBranch, payload.MergeRequest.SourceBranch)
+	if err != nil {
+		return nil, false, err
+	}
+	confirmDestroy := orchestrator.ParseConfirmDestroy(payload.ObjectAttributes.Note)
+	for i := range jobs {
+		jobs[i].ConfirmDestroy = confirmDestroy
+	}
+	return jobs, coversAllImpactedProjects, nil
+}
@@ -0,0 +1,157 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/diggerhq/digger/libs/digger_config"
+	"github.com/stretchr/testify/assert"
+	"github.com/xanzy/go-gitlab"
+)
+
+type request struct {
+	method string
+	path   string
+	body   string
+}
+
+func gitlabServer(t *testing.T, responses map[string]string) (*GitLabService, *[]request) {
+	requests := make([]request, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, request{r.Method, r.URL.EscapedPath(), string(body)})
+		response, ok := responses[r.Method+" "+r.URL.EscapedPath()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	service, err := NewGitLabService("token", server.URL, "group/demo")
+	assert.NoError(t, err)
+	return service, &requests
+}
+
+func TestGetChangedFiles(t *testing.T) {
+	service, _ := gitlabServer(t, map[string]string{
+		"GET /api/v4/projects/group%2Fdemo/merge_requests/3/diffs": `[
+			{"old_path": "dev/main.tf", "new_path": "dev/main.tf"},
+			{"old_path": "staging/old.tf", "new_path": "prod/new.tf", "renamed_file": true}
+		]`,
+	})
+
+	files, err := service.GetChangedFiles(3)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dev/main.tf", "prod/new.tf", "staging/old.tf"}, files)
+}
+
+func TestPublishAndEditComment(t *testing.T) {
+	service, requests := gitlabServer(t, map[string]string{
+		"POST /api/v4/projects/group%2Fdemo/merge_requests/3/notes":   `{"id": 11, "body": "starting"}`,
+		"PUT /api/v4/projects/group%2Fdemo/merge_requests/3/notes/11": `{"id": 11, "body": "done"}`,
+	})
+
+	comment, err := service.PublishComment(3, "starting")
+	assert.NoError(t, err)
+	assert.Equal(t, 11, comment.Id)
+
+	// the comment reporter keeps ids as int64
+	assert.NoError(t, service.EditComment(3, int64(11), "done"))
+	assert.Equal(t, 2, len(*requests))
+	assert.JSONEq(t, `{"body": "done"}`, (*requests)[1].body)
+}
+
+func TestSetStatus(t *testing.T) {
+	service, requests := gitlabServer(t, map[string]string{
+		"GET /api/v4/projects/group%2Fdemo/merge_requests/3": `{"iid": 3, "sha": "abc123", "source_branch": "feature"}`,
+		"POST /api/v4/projects/group%2Fdemo/statuses/abc123": `{"id": 1}`,
+	})
+
+	assert.NoError(t, service.SetStatus(3, "failure", "dev/plan"))
+	var body map[string]string
+	assert.NoError(t, json.Unmarshal([]byte((*requests)[1].body), &body))
+	assert.Equal(t, map[string]string{"state": "failed", "ref": "feature", "name": "dev/plan"}, body)
+
+	assert.Error(t, service.SetStatus(3, "unknown", "dev/plan"))
+}
+
+func TestCreateCommentReaction(t *testing.T) {
+	service, requests := gitlabServer(t, map[string]string{
+		"POST /api/v4/projects/group%2Fdemo/merge_requests/3/notes/11/award_emoji": `{"id": 1, "name": "thumbsup"}`,
+	})
+
+	assert.NoError(t, service.CreateCommentReaction(MergeRequestNote{MergeRequestIID: 3, NoteID: 11}, "+1"))
+	assert.JSONEq(t, `{"name": "thumbsup"}`, (*requests)[0].body)
+
+	assert.Error(t, service.CreateCommentReaction(11, "eyes"))
+}
+
+func TestGetUserTeams(t *testing.T) {
+	service, _ := gitlabServer(t, map[string]string{
+		"GET /api/v4/users":                          `[{"id": 7, "username": "alice"}]`,
+		"GET /api/v4/groups/group/descendant_groups": `[{"id": 20, "full_path": "group/platform"}, {"id": 21, "full_path": "group/platform/infra"}]`,
+		"GET /api/v4/groups/20/members/7":            `{"id": 7, "username": "alice"}`,
+	})
+
+	teams, err := service.GetUserTeams("group", "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"group/platform"}, teams)
+}
+
+func mergeEvent(action string, oldRev string, targetBranch string) *gitlab.MergeEvent {
+	event := &gitlab.MergeEvent{User: &gitlab.EventUser{Username: "alice"}}
+	event.Project.PathWithNamespace = "group/demo"
+	event.Project.DefaultBranch = "main"
+	event.ObjectAttributes.IID = 3
+	event.ObjectAttributes.Action = action
+	event.ObjectAttributes.OldRev = oldRev
+	event.ObjectAttributes.SourceBranch = "feature"
+	event.ObjectAttributes.TargetBranch = targetBranch
+	return event
+}
+
+func TestConvertGitLabMergeRequestEventToJobs(t *testing.T) {
+	config := digger_config.DiggerConfig{
+		Workflows: map[string]digger_config.Workflow{
+			"default": {
+				Configuration: &digger_config.WorkflowConfiguration{
+					OnPullRequestPushed: []string{"digger plan"},
+					OnPullRequestClosed: []string{"digger unlock"},
+					OnCommitToDefault:   []string{"digger apply"},
+				},
+			},
+		},
+	}
+	projects := []digger_config.Project{{Name: "dev", Dir: "dev", Workflow: "default"}}
+
+	jobs, err := ConvertGitLabMergeRequestEventToJobs(mergeEvent("open", "", "main"), projects, config)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(jobs))
+	assert.Equal(t, []string{"digger plan"}, jobs[0].Commands)
+	assert.Equal(t, 3, *jobs[0].PullRequestNumber)
+	assert.Equal(t, "group/demo", jobs[0].Namespace)
+	assert.Equal(t, "alice", jobs[0].RequestedBy)
+	assert.Equal(t, "feature", jobs[0].RunEnvVars["PR_BRANCH"])
+
+	jobs, err = ConvertGitLabMergeRequestEventToJobs(mergeEvent("update", "", "main"), projects, config)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(jobs))
+
+	jobs, err = ConvertGitLabMergeRequestEventToJobs(mergeEvent("update", "def456", "main"), projects, config)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"digger plan"}, jobs[0].Commands)
+
+	jobs, err = ConvertGitLabMergeRequestEventToJobs(mergeEvent("merge", "", "main"), projects, config)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"digger apply"}, jobs[0].Commands)
+
+	jobs, err = ConvertGitLabMergeRequestEventToJobs(mergeEvent("merge", "", "release"), projects, config)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(jobs))
+}
@@ -42,6 +42,14 @@ type JobJson struct {
 	BackendHostname         string            `json:"backend_hostname"`
 	BackendOrganisationName string            `json:"backend_organisation_hostname"`
 	BackendJobToken         string            `json:"backend_job_token"`
+	// carried inside the job so that the trace context reaches the cli through any ci backend
+	TraceParent string `json:"trace_parent,omitempty"`
+	AgentPool   string `json:"agent_pool,omitempty"`
+	// used by the cli to look up the code owners of the project before applying
+	IncludePatterns   []string `json:"include_patterns,omitempty"`
+	RequireCodeowners bool     `json:"require_codeowners,omitempty"`
+	Protect           []string `json:"protect,omitempty"`
+	ConfirmDestroy    []string `json:"confirm_destroy,omitempty"`
 }
 
 func (j *JobJson) IsPlan() bool {
@@ -85,6 +93,12 @@ func JobToJson(job Job, jobType DiggerCommand, organisationName string, branch s
 		BackendHostname:         backendHostname,
 		BackendJobToken:         jobToken,
 		BackendOrganisationName: organisationName,
+		TraceParent:             job.TraceParent,
+		AgentPool:               project.AgentPool,
+		IncludePatterns:         job.IncludePatterns,
+		RequireCodeowners:       job.RequireCodeowners,
+		Protect:                 job.Protect,
+		ConfirmDestroy:          job.ConfirmDestroy,
 	}
 }
 
@@ -107,6 +121,11 @@ func JsonToJob(jobJson JobJson) Job {
 		CommandEnvVars:     jobJson.CommandEnvVars,
 		StateEnvProvider:   GetProviderFromRole(jobJson.StateRoleName, jobJson.AwsRoleRegion),
 		CommandEnvProvider: GetProviderFromRole(jobJson.CommandRoleName, jobJson.AwsRoleRegion),
+		TraceParent:        jobJson.TraceParent,
+		IncludePatterns:    jobJson.IncludePatterns,
+		RequireCodeowners:  jobJson.RequireCodeowners,
+		Protect:            jobJson.Protect,
+		ConfirmDestroy:     jobJson.ConfirmDestroy,
 	}
 }
 
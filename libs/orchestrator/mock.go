@@ -88,6 +88,11 @@ func (mockGithubPullrequestManager *MockGithubPullrequestManager) GetBranchName(
 	return "", "", nil
 }
 
+func (mockGithubPullrequestManager *MockGithubPullrequestManager) GetPullRequestDetails(prNumber int) (*PullRequestDetails, error) {
+	mockGithubPullrequestManager.commands = append(mockGithubPullrequestManager.commands, "GetPullRequestDetails")
+	return &PullRequestDetails{Labels: []string{}}, nil
+}
+
 func (mockGithubPullrequestManager MockGithubPullrequestManager) SetOutput(prNumber int, key string, value string) error {
 	mockGithubPullrequestManager.commands = append(mockGithubPullrequestManager.commands, "SetOutput")
 	return nil
@@ -13,6 +13,7 @@ import (
 type Job struct {
 	ProjectName        string
 	ProjectDir         string
+	IncludePatterns    []string
 	ProjectWorkspace   string
 	ProjectWorkflow    string
 	Terragrunt         bool
@@ -29,6 +30,13 @@ type Job struct {
 	CommandEnvVars     map[string]string
 	StateEnvProvider   *stscreds.WebIdentityRoleProvider
 	CommandEnvProvider *stscreds.WebIdentityRoleProvider
+	// W3C traceparent of the span the job runs under
+	TraceParent string
+	// applies need the approval of a code owner of the project's dir or include patterns
+	RequireCodeowners bool
+	// resources an apply may not delete or replace, unless their address is in ConfirmDestroy
+	Protect        []string
+	ConfirmDestroy []string
 }
 
 type Step struct {
@@ -102,11 +110,14 @@ func ConvertProjectsToJobs(actor string, repoNamespace string, command string, p
 		stateEnvVars, commandEnvVars := digger_config.CollectTerraformEnvConfig(workflow.EnvVars)
 		StateEnvProvider, CommandEnvProvider := GetStateAndCommandProviders(project)
 		jobs = append(jobs, Job{
-			ProjectName:      project.Name,
-			ProjectDir:       project.Dir,
-			ProjectWorkspace: project.Workspace,
-			Terragrunt:       project.Terragrunt,
-			OpenTofu:         project.OpenTofu,
+			ProjectName:       project.Name,
+			ProjectDir:        project.Dir,
+			IncludePatterns:   project.IncludePatterns,
+			RequireCodeowners: project.RequireCodeownersApproval,
+			Protect:           project.Protect,
+			ProjectWorkspace:  project.Workspace,
+			Terragrunt:        project.Terragrunt,
+			OpenTofu:          project.OpenTofu,
 			// TODO: expose lower level api per command configuration
 			Commands:   []string{command},
 			ApplyStage: ToConfigStage(workflow.Apply),
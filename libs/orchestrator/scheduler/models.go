@@ -100,6 +100,13 @@ type SerializedJob struct {
 	ResourcesCreated uint            `json:"resources_created"`
 	ResourcesDeleted uint            `json:"resources_deleted"`
 	ResourcesUpdated uint            `json:"resources_updated"`
+	// estimated monthly cost of the plan, see terraform_utils.CostEstimate
+	MonthlyCost      *float64 `json:"monthly_cost,omitempty"`
+	MonthlyCostDelta *float64 `json:"monthly_cost_delta,omitempty"`
+	CostCurrency     string   `json:"cost_currency,omitempty"`
+	// ids of the policy versions the job was evaluated against
+	AccessPolicyVersionId *uint `json:"access_policy_version_id,omitempty"`
+	PlanPolicyVersionId   *uint `json:"plan_policy_version_id,omitempty"`
 }
 
 type SerializedBatch struct {
@@ -6,15 +6,27 @@ import (
 	"strings"
 )
 
+var projectNamePattern = regexp.MustCompile(`-p ([0-9a-zA-Z\-_]+)`)
+
+var confirmDestroyPattern = regexp.MustCompile(`--confirm-destroy\s+(\S+)`)
+
 func ParseProjectName(comment string) string {
-	re := regexp.MustCompile(`-p ([0-9a-zA-Z\-_]+)`)
-	match := re.FindStringSubmatch(comment)
+	match := projectNamePattern.FindStringSubmatch(comment)
 	if len(match) > 1 {
 		return match[1]
 	}
 	return ""
 }
 
+// ParseConfirmDestroy returns the addresses of resources confirmed with --confirm-destroy <address>
+func ParseConfirmDestroy(comment string) []string {
+	addresses := make([]string, 0)
+	for _, match := range confirmDestroyPattern.FindAllStringSubmatch(comment, -1) {
+		addresses = append(addresses, match[1])
+	}
+	return addresses
+}
+
 type DiggerCommand string
 
 const DiggerCommandNoop DiggerCommand = "noop"
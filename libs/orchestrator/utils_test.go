@@ -0,0 +1,14 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConfirmDestroy(t *testing.T) {
+	addresses := ParseConfirmDestroy("digger apply -p prod --confirm-destroy aws_db_instance.Main --confirm-destroy module.a.aws_s3_bucket.b")
+	assert.Equal(t, []string{"aws_db_instance.Main", "module.a.aws_s3_bucket.b"}, addresses)
+
+	assert.Empty(t, ParseConfirmDestroy("digger apply"))
+}
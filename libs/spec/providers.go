@@ -21,6 +21,7 @@ import (
 	"github.com/diggerhq/digger/libs/locking/gcp"
 	"github.com/diggerhq/digger/libs/orchestrator"
 	"github.com/diggerhq/digger/libs/orchestrator/github"
+	"github.com/diggerhq/digger/libs/orchestrator/gitlab"
 	"github.com/samber/lo"
 	"log"
 	"net/http"
@@ -176,6 +177,16 @@ func (v VCSProvider) GetPrService(vcsSpec VcsSpec) (orchestrator.PullRequestServ
 			return nil, fmt.Errorf("failed to get githbu service: GITHUB_TOKEN not specified")
 		}
 		return github.NewGitHubService(token, vcsSpec.RepoName, vcsSpec.RepoOwner), nil
+	case "gitlab":
+		token := os.Getenv("GITLAB_TOKEN")
+		if token == "" {
+			return nil, fmt.Errorf("failed to get gitlab service: GITLAB_TOKEN not specified")
+		}
+		service, err := gitlab.NewGitLabService(token, os.Getenv("GITLAB_BASE_URL"), vcsSpec.RepoOwner+"/"+vcsSpec.RepoName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get gitlab service: %v", err)
+		}
+		return service, nil
 	default:
 		return nil, fmt.Errorf("could not get PRService, unknown type %v", vcsSpec.VcsType)
 	}
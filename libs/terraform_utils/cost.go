@@ -0,0 +1,269 @@
+package terraform_utils
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed pricing_catalog.yml
+var defaultPricingCatalog []byte
+
+// PricingCatalogEnvVar points to a pricing catalog file used instead of the default catalog
+const PricingCatalogEnvVar = "DIGGER_PRICING_CATALOG"
+
+// PriceComponent is one part of the monthly price of a resource, see pricing_catalog.yml for the format
+type PriceComponent struct {
+	Monthly   float64            `yaml:"monthly"`
+	Attribute string             `yaml:"attribute"`
+	Default   string             `yaml:"default"`
+	Quantity  string             `yaml:"quantity"`
+	Prices    map[string]float64 `yaml:"prices"`
+}
+
+type PricingCatalog struct {
+	Currency  string                      `yaml:"currency"`
+	Resources map[string][]PriceComponent `yaml:"resources"`
+}
+
+type ResourceCost struct {
+	Address           string  `json:"address"`
+	Type              string  `json:"type"`
+	MonthlyCostBefore float64 `json:"monthly_cost_before"`
+	MonthlyCostAfter  float64 `json:"monthly_cost_after"`
+	MonthlyCostDelta  float64 `json:"monthly_cost_delta"`
+}
+
+// CostEstimate is the estimated monthly cost of the resources in a plan before and after applying it,
+// changed resources of catalog types that could not be priced are listed in UnpricedResources
+type CostEstimate struct {
+	Currency          string         `json:"currency"`
+	MonthlyCostBefore float64        `json:"monthly_cost_before"`
+	MonthlyCostAfter  float64        `json:"monthly_cost_after"`
+	MonthlyCostDelta  float64        `json:"monthly_cost_delta"`
+	Resources         []ResourceCost `json:"resources"`
+	UnpricedResources []string       `json:"unpriced_resources"`
+}
+
+var pricingCatalogs = struct {
+	sync.Mutex
+	byPath map[string]*PricingCatalog
+}{byPath: map[string]*PricingCatalog{}}
+
+// LoadPricingCatalog loads the catalog at path, the default catalog is returned for an empty path
+func LoadPricingCatalog(path string) (*PricingCatalog, error) {
+	pricingCatalogs.Lock()
+	defer pricingCatalogs.Unlock()
+	if catalog, ok := pricingCatalogs.byPath[path]; ok {
+		return catalog, nil
+	}
+
+	data := defaultPricingCatalog
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read pricing catalog: %v", err)
+		}
+	}
+	var catalog PricingCatalog
+	if err := yaml.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("could not parse pricing catalog %v: %v", path, err)
+	}
+	if catalog.Currency == "" {
+		catalog.Currency = "USD"
+	}
+	pricingCatalogs.byPath[path] = &catalog
+	return &catalog, nil
+}
+
+// GetCostEstimate estimates the monthly cost of a plan json with the catalog set in DIGGER_PRICING_CATALOG
+// or the default catalog
+func GetCostEstimate(planJson string) (*CostEstimate, error) {
+	plan := tfjson.Plan{}
+	if err := json.Unmarshal([]byte(planJson), &plan); err != nil {
+		return nil, fmt.Errorf("could not parse plan: %v", err)
+	}
+	catalog, err := LoadPricingCatalog(os.Getenv(PricingCatalogEnvVar))
+	if err != nil {
+		return nil, err
+	}
+	return EstimateCost(&plan, catalog), nil
+}
+
+// EstimateCost prices the resource changes of a plan, unchanged resources count towards the totals
+func EstimateCost(plan *tfjson.Plan, catalog *PricingCatalog) *CostEstimate {
+	estimate := &CostEstimate{
+		Currency:          catalog.Currency,
+		Resources:         []ResourceCost{},
+		UnpricedResources: []string{},
+	}
+	for _, rc := range plan.ResourceChanges {
+		if rc.Change == nil || rc.Mode == tfjson.DataResourceMode || rc.Change.Actions.Read() {
+			continue
+		}
+		components, ok := catalog.Resources[rc.Type]
+		if !ok {
+			continue
+		}
+
+		actions := rc.Change.Actions
+		before, beforePriced := 0.0, true
+		after, afterPriced := 0.0, true
+		if !actions.Create() {
+			before, beforePriced = priceResource(components, rc.Change.Before)
+		}
+		if !actions.Delete() {
+			after, afterPriced = priceResource(components, rc.Change.After)
+		}
+		if !beforePriced || !afterPriced {
+			if !actions.NoOp() {
+				estimate.UnpricedResources = append(estimate.UnpricedResources, rc.Address)
+			}
+			continue
+		}
+
+		estimate.MonthlyCostBefore += before
+		estimate.MonthlyCostAfter += after
+		if !actions.NoOp() {
+			estimate.Resources = append(estimate.Resources, ResourceCost{
+				Address:           rc.Address,
+				Type:              rc.Type,
+				MonthlyCostBefore: roundCost(before),
+				MonthlyCostAfter:  roundCost(after),
+				MonthlyCostDelta:  roundCost(after - before),
+			})
+		}
+	}
+	estimate.MonthlyCostDelta = roundCost(estimate.MonthlyCostAfter - estimate.MonthlyCostBefore)
+	estimate.MonthlyCostBefore = roundCost(estimate.MonthlyCostBefore)
+	estimate.MonthlyCostAfter = roundCost(estimate.MonthlyCostAfter)
+	return estimate
+}
+
+// priceResource sums the components of a resource, it reports false if a price or quantity is unknown
+func priceResource(components []PriceComponent, values interface{}) (float64, bool) {
+	total := 0.0
+	for _, component := range components {
+		price := component.Monthly
+		if component.Attribute != "" {
+			value, ok := lookupAttribute(values, component.Attribute)
+			if !ok {
+				if component.Default == "" {
+					return 0, false
+				}
+				value = component.Default
+			}
+			price, ok = component.Prices[fmt.Sprintf("%v", value)]
+			if !ok {
+				return 0, false
+			}
+		}
+		if component.Quantity != "" {
+			value, ok := lookupAttribute(values, component.Quantity)
+			if !ok {
+				return 0, false
+			}
+			quantity, ok := value.(float64)
+			if !ok {
+				return 0, false
+			}
+			price *= quantity
+		}
+		total += price
+	}
+	return total, true
+}
+
+// lookupAttribute resolves a dotted attribute path like settings.0.tier, unset and null values are not found
+func lookupAttribute(values interface{}, attribute string) (interface{}, bool) {
+	current := values
+	for _, key := range strings.Split(attribute, ".") {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			current = v[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			current = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, current != nil
+}
+
+func roundCost(cost float64) float64 {
+	return math.Round(cost*100) / 100
+}
+
+func (c *CostEstimate) ToJson() map[string]interface{} {
+	if c == nil {
+		return map[string]interface{}{}
+	}
+	resources := make([]interface{}, 0)
+	for _, r := range c.Resources {
+		resources = append(resources, map[string]interface{}{
+			"address":             r.Address,
+			"type":                r.Type,
+			"monthly_cost_before": r.MonthlyCostBefore,
+			"monthly_cost_after":  r.MonthlyCostAfter,
+			"monthly_cost_delta":  r.MonthlyCostDelta,
+		})
+	}
+	unpriced := make([]interface{}, 0)
+	for _, address := range c.UnpricedResources {
+		unpriced = append(unpriced, address)
+	}
+	return map[string]interface{}{
+		"currency":            c.Currency,
+		"monthly_cost_before": c.MonthlyCostBefore,
+		"monthly_cost_after":  c.MonthlyCostAfter,
+		"monthly_cost_delta":  c.MonthlyCostDelta,
+		"resources":           resources,
+		"unpriced_resources":  unpriced,
+	}
+}
+
+// IsPriced reports whether the plan has resources of catalog types, priced or not
+func (c *CostEstimate) IsPriced() bool {
+	return c != nil && (c.MonthlyCostBefore != 0 || c.MonthlyCostAfter != 0 || len(c.Resources) > 0 || len(c.UnpricedResources) > 0)
+}
+
+// FormatCostEstimate renders the estimate as markdown to be shown next to the plan summary, it is empty when nothing
+// in the plan was priced
+func FormatCostEstimate(c *CostEstimate) string {
+	if !c.IsPriced() {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**Estimated monthly cost:** %.2f %v → %.2f %v (%v %v)\n",
+		c.MonthlyCostBefore, c.Currency, c.MonthlyCostAfter, c.Currency, formatCostDelta(c.MonthlyCostDelta), c.Currency))
+	if len(c.Resources) > 0 {
+		sb.WriteString("\n| Resource | Before | After | Delta |\n| --- | ---: | ---: | ---: |\n")
+		for _, r := range c.Resources {
+			sb.WriteString(fmt.Sprintf("| %v | %.2f | %.2f | %v |\n", r.Address, r.MonthlyCostBefore, r.MonthlyCostAfter, formatCostDelta(r.MonthlyCostDelta)))
+		}
+	}
+	if len(c.UnpricedResources) > 0 {
+		sb.WriteString(fmt.Sprintf("\nNot priced: %v\n", strings.Join(c.UnpricedResources, ", ")))
+	}
+	return sb.String()
+}
+
+func formatCostDelta(delta float64) string {
+	if delta >= 0 {
+		return fmt.Sprintf("+%.2f", delta)
+	}
+	return fmt.Sprintf("%.2f", delta)
+}
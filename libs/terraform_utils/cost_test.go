@@ -0,0 +1,69 @@
+package terraform_utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const costPlanJson = `{"format_version": "1.2", "resource_changes": [
+	{"address": "aws_instance.web", "mode": "managed", "type": "aws_instance", "name": "web", "change": {"actions": ["update"], "before": {"instance_type": "t3.micro"}, "after": {"instance_type": "t3.medium"}}},
+	{"address": "aws_ebs_volume.data", "mode": "managed", "type": "aws_ebs_volume", "name": "data", "change": {"actions": ["create"], "before": null, "after": {"size": 100, "type": null}}},
+	{"address": "aws_nat_gateway.main", "mode": "managed", "type": "aws_nat_gateway", "name": "main", "change": {"actions": ["no-op"], "before": {}, "after": {}}},
+	{"address": "google_sql_database_instance.db", "mode": "managed", "type": "google_sql_database_instance", "name": "db", "change": {"actions": ["delete"], "before": {"settings": [{"tier": "db-g1-small"}]}, "after": null}},
+	{"address": "azurerm_kubernetes_cluster.aks", "mode": "managed", "type": "azurerm_kubernetes_cluster", "name": "aks", "change": {"actions": ["create"], "before": null, "after": {"default_node_pool": [{"vm_size": "Standard_D2s_v3"}]}}},
+	{"address": "aws_s3_bucket.logs", "mode": "managed", "type": "aws_s3_bucket", "name": "logs", "change": {"actions": ["create"], "before": null, "after": {"bucket": "logs"}}}
+]}`
+
+func TestGetCostEstimate(t *testing.T) {
+	estimate, err := GetCostEstimate(costPlanJson)
+	require.NoError(t, err)
+
+	assert.Equal(t, "USD", estimate.Currency)
+	// t3.micro, the nat gateway and the sql instance before, t3.medium, the nat gateway and a 100GB gp2 volume after
+	assert.Equal(t, 65.99, estimate.MonthlyCostBefore)
+	assert.Equal(t, 73.22, estimate.MonthlyCostAfter)
+	assert.Equal(t, 7.23, estimate.MonthlyCostDelta)
+	assert.Equal(t, []ResourceCost{
+		{Address: "aws_instance.web", Type: "aws_instance", MonthlyCostBefore: 7.59, MonthlyCostAfter: 30.37, MonthlyCostDelta: 22.78},
+		{Address: "aws_ebs_volume.data", Type: "aws_ebs_volume", MonthlyCostBefore: 0, MonthlyCostAfter: 10, MonthlyCostDelta: 10},
+		{Address: "google_sql_database_instance.db", Type: "google_sql_database_instance", MonthlyCostBefore: 25.55, MonthlyCostAfter: 0, MonthlyCostDelta: -25.55},
+	}, estimate.Resources)
+	// the node count is only known after apply
+	assert.Equal(t, []string{"azurerm_kubernetes_cluster.aks"}, estimate.UnpricedResources)
+
+	summary := FormatCostEstimate(estimate)
+	assert.Contains(t, summary, "**Estimated monthly cost:** 65.99 USD → 73.22 USD (+7.23 USD)")
+	assert.Contains(t, summary, "| google_sql_database_instance.db | 25.55 | 0.00 | -25.55 |")
+	assert.Contains(t, summary, "Not priced: azurerm_kubernetes_cluster.aks")
+}
+
+func TestGetCostEstimateWithCustomCatalog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pricing.yml")
+	err := os.WriteFile(path, []byte("currency: EUR\nresources:\n  aws_s3_bucket:\n    - monthly: 1.5\n"), 0644)
+	require.NoError(t, err)
+	t.Setenv(PricingCatalogEnvVar, path)
+
+	estimate, err := GetCostEstimate(costPlanJson)
+	require.NoError(t, err)
+	assert.Equal(t, "EUR", estimate.Currency)
+	assert.Equal(t, 1.5, estimate.MonthlyCostDelta)
+
+	summary := &PlanSummary{ResourcesCreated: 1}
+	summary.SetCostEstimate(estimate)
+	assert.Equal(t, 1.5, summary.ToJson()["monthly_cost_delta"])
+	assert.Equal(t, "EUR", summary.ToJson()["cost_currency"])
+	assert.Same(t, estimate, summary.CostEstimate)
+	assert.NotContains(t, summary.ToJson(), "cost_estimate")
+}
+
+func TestFormatCostEstimateOfUnpricedPlan(t *testing.T) {
+	estimate, err := GetCostEstimate(`{"format_version": "1.2", "resource_changes": [{"address": "random_id.a", "mode": "managed", "type": "random_id", "name": "a", "change": {"actions": ["create"], "before": null, "after": {}}}]}`)
+	require.NoError(t, err)
+	assert.False(t, estimate.IsPriced())
+	assert.Empty(t, FormatCostEstimate(estimate))
+	assert.Empty(t, FormatCostEstimate(nil))
+}
@@ -17,6 +17,13 @@ type PlanSummary struct {
 	ResourcesCreated uint `json:"resources_created"`
 	ResourcesUpdated uint `json:"resources_updated"`
 	ResourcesDeleted uint `json:"resources_deleted"`
+	// estimated monthly cost after applying the plan and its change, not set when the plan was not estimated
+	MonthlyCost      *float64 `json:"monthly_cost,omitempty"`
+	MonthlyCostDelta *float64 `json:"monthly_cost_delta,omitempty"`
+	CostCurrency     string   `json:"cost_currency,omitempty"`
+	// CostEstimate is the estimate the cost fields are set from, it is computed once per plan and reused by the plan
+	// comment and plan policies
+	CostEstimate *CostEstimate `json:"-"`
 }
 
 type TerraformPlan struct {
@@ -65,11 +72,27 @@ func (p *PlanSummary) ToJson() map[string]interface{} {
 	if p == nil {
 		return map[string]interface{}{}
 	}
-	return map[string]interface{}{
+	summary := map[string]interface{}{
 		"resources_created": p.ResourcesCreated,
 		"resources_updated": p.ResourcesUpdated,
 		"resources_deleted": p.ResourcesDeleted,
 	}
+	if p.MonthlyCost != nil {
+		summary["monthly_cost"] = *p.MonthlyCost
+		summary["monthly_cost_delta"] = *p.MonthlyCostDelta
+		summary["cost_currency"] = p.CostCurrency
+	}
+	return summary
+}
+
+func (p *PlanSummary) SetCostEstimate(estimate *CostEstimate) {
+	if p == nil || estimate == nil {
+		return
+	}
+	p.MonthlyCost = &estimate.MonthlyCostAfter
+	p.MonthlyCostDelta = &estimate.MonthlyCostDelta
+	p.CostCurrency = estimate.Currency
+	p.CostEstimate = estimate
 }
 func parseTerraformPlanOutput(terraformJson string) (*TerraformPlan, error) {
 	var plan TerraformPlan
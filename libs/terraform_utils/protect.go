@@ -0,0 +1,63 @@
+package terraform_utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// GetProtectedResourceChanges returns the addresses of resources the plan deletes or replaces that match one of the
+// protect patterns. Patterns are globs matched against the address, the address without its module path or the type,
+// e.g. aws_db_instance.* or aws_db_instance.
+func GetProtectedResourceChanges(planJson string, patterns []string) ([]string, error) {
+	addresses := make([]string, 0)
+	if len(patterns) == 0 {
+		return addresses, nil
+	}
+	var plan TerraformPlan
+	if err := json.Unmarshal([]byte(planJson), &plan); err != nil {
+		return nil, fmt.Errorf("could not parse plan: %v", err)
+	}
+	for _, rc := range plan.ResourceChanges {
+		if !isDestroyed(rc.Change.Actions) {
+			continue
+		}
+		if IsProtected(rc.Address, rc.Type, patterns) {
+			addresses = append(addresses, rc.Address)
+		}
+	}
+	return addresses, nil
+}
+
+// IsProtected reports whether a resource matches one of the protect patterns
+func IsProtected(address string, resourceType string, patterns []string) bool {
+	candidates := []string{address, resourceType, stripModulePath(address)}
+	for _, pattern := range patterns {
+		for _, candidate := range candidates {
+			if matched, err := path.Match(pattern, candidate); err == nil && matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isDestroyed reports whether the actions of a change delete the resource, replacements are delete and create
+func isDestroyed(actions []string) bool {
+	for _, action := range actions {
+		if action == "delete" {
+			return true
+		}
+	}
+	return false
+}
+
+// stripModulePath turns module.a.module.b.aws_db_instance.main into aws_db_instance.main
+func stripModulePath(address string) string {
+	parts := strings.Split(address, ".")
+	for len(parts) > 2 && parts[0] == "module" {
+		parts = parts[2:]
+	}
+	return strings.Join(parts, ".")
+}
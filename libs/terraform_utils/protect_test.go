@@ -0,0 +1,33 @@
+package terraform_utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const protectPlanJson = `{"format_version": "1.2", "resource_changes": [
+	{"address": "aws_db_instance.main", "type": "aws_db_instance", "change": {"actions": ["delete", "create"]}},
+	{"address": "aws_db_instance.replica", "type": "aws_db_instance", "change": {"actions": ["update"]}},
+	{"address": "module.storage.aws_s3_bucket.state", "type": "aws_s3_bucket", "change": {"actions": ["delete"]}},
+	{"address": "aws_s3_bucket.logs", "type": "aws_s3_bucket", "change": {"actions": ["create"]}},
+	{"address": "aws_instance.web", "type": "aws_instance", "change": {"actions": ["delete"]}}
+]}`
+
+func TestGetProtectedResourceChanges(t *testing.T) {
+	addresses, err := GetProtectedResourceChanges(protectPlanJson, []string{"aws_db_instance.*", "aws_s3_bucket.state"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aws_db_instance.main", "module.storage.aws_s3_bucket.state"}, addresses)
+
+	addresses, err = GetProtectedResourceChanges(protectPlanJson, []string{"aws_s3_bucket"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"module.storage.aws_s3_bucket.state"}, addresses)
+
+	addresses, err = GetProtectedResourceChanges(protectPlanJson, []string{})
+	require.NoError(t, err)
+	assert.Empty(t, addresses)
+
+	_, err = GetProtectedResourceChanges("{", []string{"aws_db_instance.*"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestTraceParentIsEmptyWithoutSpan(t *testing.T) {
+	assert.Equal(t, "", TraceParent(context.Background()))
+}
+
+func TestStartSpanContinuesTraceParent(t *testing.T) {
+	recorder := recordSpans(t)
+
+	ctx, parent := Tracer().Start(context.Background(), "GithubAppWebHook")
+	traceParent := TraceParent(ctx)
+	assert.Regexp(t, "^00-[0-9a-f]{32}-[0-9a-f]{16}-01$", traceParent)
+
+	childCtx, child := StartSpan(traceParent, "digger plan")
+	EndSpan(child, nil)
+	parent.End()
+
+	spans := recorder.Ended()
+	if assert.Equal(t, 2, len(spans)) {
+		assert.Equal(t, "digger plan", spans[0].Name())
+		assert.Equal(t, parent.SpanContext().TraceID(), spans[0].SpanContext().TraceID())
+		assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	}
+	assert.NotEqual(t, traceParent, TraceParent(childCtx))
+}
+
+func TestStartSpanWithoutTraceParentStartsNewTrace(t *testing.T) {
+	recorder := recordSpans(t)
+
+	_, span := StartSpan("", "RunSpec")
+	EndSpan(span, assert.AnError)
+
+	spans := recorder.Ended()
+	if assert.Equal(t, 1, len(spans)) {
+		assert.False(t, spans[0].Parent().IsValid())
+		assert.Equal(t, "Error", spans[0].Status().Code.String())
+		assert.Equal(t, 1, len(spans[0].Events()))
+	}
+}